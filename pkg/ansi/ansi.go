@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"runtime"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/briandowns/spinner"
 	"github.com/logrusorgru/aurora"
-	"github.com/tidwall/pretty"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -23,10 +25,156 @@ var ForceColors = false
 // DisableColors disables all colors and other ANSI sequences.
 var DisableColors = false
 
-// EnvironmentOverrideColors overs coloring based on `CLICOLOR` and
-// `CLICOLOR_FORCE`. Cf. https://bixense.com/clicolors/
+// EnvironmentOverrideColors overs coloring based on `CLICOLOR`,
+// `CLICOLOR_FORCE` and `NO_COLOR`. Cf. https://bixense.com/clicolors/ and
+// https://no-color.org/
 var EnvironmentOverrideColors = true
 
+// ForceInteractive forces the spinners and the other interactive output, such
+// as cursor movements, even when the writer isn't a terminal.
+var ForceInteractive = false
+
+// DisableInteractive disables the spinners and the other interactive output,
+// even when the writer is a terminal.
+var DisableInteractive = false
+
+//
+// Public types
+//
+
+// ColorMode controls whether colors and other ANSI sequences are used,
+// regardless of the global settings when it isn't ColorModeAuto.
+type ColorMode string
+
+const (
+	// ColorModeAuto uses colors if the writer is a terminal, unless
+	// overridden by ForceColors, DisableColors or the environment
+	ColorModeAuto ColorMode = "auto"
+
+	// ColorModeAlways always uses colors, even when the output is piped
+	ColorModeAlways ColorMode = "always"
+
+	// ColorModeNever never uses colors or other ANSI sequences
+	ColorModeNever ColorMode = "never"
+)
+
+// ColorModes are the acceptable color modes
+var ColorModes = []ColorMode{ColorModeAuto, ColorModeAlways, ColorModeNever}
+
+// ParseColorMode returns the color mode with the given name
+// (case-insensitive). An empty name is ColorModeAuto.
+func ParseColorMode(name string) (ColorMode, error) {
+	mode := ColorMode(strings.ToLower(name))
+	switch mode {
+	case "":
+		return ColorModeAuto, nil
+	case ColorModeAuto, ColorModeAlways, ColorModeNever:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%s is not an acceptable color mode (auto, always, never)", name)
+	}
+}
+
+// Color returns an aurora.Aurora instance with colors enabled or disabled
+// depending on the mode and whether the writer supports colors.
+func (mode ColorMode) Color(w io.Writer) aurora.Aurora {
+	return aurora.NewAurora(mode.SupportsColors(w))
+}
+
+// ColorizeJSON returns a version of the input JSON colorized with the
+// default theme, if colors are used for the writer.
+func (mode ColorMode) ColorizeJSON(json string, w io.Writer) string {
+	return DefaultTheme.ColorizeJSON(json, mode, w)
+}
+
+// Linkify returns an ANSI escape sequence with an hyperlink, if colors are
+// used for the writer.
+func (mode ColorMode) Linkify(text, url string, w io.Writer) string {
+	if !mode.SupportsColors(w) {
+		return text
+	}
+
+	// See https://gist.github.com/egmontkob/eb114294efbcd5adb1944c9f3cb5feda
+	// for more information about this escape sequence.
+	return fmt.Sprintf("\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\", url, text)
+}
+
+// StartSpinner starts a spinner with the given message. If colors aren't used
+// for the writer or it isn't interactive, it simply prints the message.
+func (mode ColorMode) StartSpinner(msg string, w io.Writer) *spinner.Spinner {
+	if !mode.SupportsColors(w) || !IsInteractive(w) {
+		fmt.Fprintln(w, msg)
+		return nil
+	}
+
+	s := spinner.New(SpinnerFrames(), 100*time.Millisecond)
+	s.Writer = w
+
+	if msg != "" {
+		s.Suffix = " " + msg
+	}
+
+	s.Start()
+	return s
+}
+
+// UpdateSpinner replaces the message of a running spinner. If colors aren't
+// used for the writer or it isn't interactive, it simply prints the message.
+func (mode ColorMode) UpdateSpinner(s *spinner.Spinner, msg string, w io.Writer) {
+	if s == nil || !mode.SupportsColors(w) || !IsInteractive(w) {
+		fmt.Fprintln(w, msg)
+		return
+	}
+
+	s.Lock()
+	s.Suffix = " " + msg
+	s.Unlock()
+}
+
+// SpinnerFrames returns the frames of the spinners, for the animations that
+// can't use the spinner of StartSpinner, e.g. because they're redrawn along
+// with other output.
+func SpinnerFrames() []string {
+	// See https://github.com/briandowns/spinner#available-character-sets for
+	// list of available charsets
+	charSetIdx := 11
+	if runtime.GOOS == "windows" {
+		// Less fancy, but uses ASCII characters so works with Windows default
+		// console.
+		charSetIdx = 8
+	}
+
+	return spinner.CharSets[charSetIdx]
+}
+
+// StopSpinner stops a spinner with the given message. If colors aren't used
+// for the writer or it isn't interactive, it simply prints the message.
+func (mode ColorMode) StopSpinner(s *spinner.Spinner, msg string, w io.Writer) {
+	if s == nil || !mode.SupportsColors(w) || !IsInteractive(w) {
+		fmt.Fprintln(w, msg)
+		return
+	}
+
+	if msg != "" {
+		s.FinalMSG = "> " + msg + "\n"
+	}
+
+	s.Stop()
+}
+
+// SupportsColors returns true if colors and other ANSI sequences are used
+// when writing to the writer.
+func (mode ColorMode) SupportsColors(w io.Writer) bool {
+	switch mode {
+	case ColorModeAlways:
+		return true
+	case ColorModeNever:
+		return false
+	default:
+		return shouldUseColors(w)
+	}
+}
+
 //
 // Public functions
 //
@@ -40,17 +188,13 @@ func Bold(text string) string {
 // Color returns an aurora.Aurora instance with colors enabled or disabled
 // depending on whether the writer supports colors.
 func Color(w io.Writer) aurora.Aurora {
-	return aurora.NewAurora(shouldUseColors(w))
+	return ColorModeAuto.Color(w)
 }
 
 // ColorizeJSON returns a colorized version of the input JSON, if the writer
 // supports colors.
 func ColorizeJSON(json string, w io.Writer) string {
-	if !shouldUseColors(w) {
-		return json
-	}
-
-	return string(pretty.Color([]byte(json), nil))
+	return ColorModeAuto.ColorizeJSON(json, w)
 }
 
 // Faint returns slightly offset color text if the writer supports it
@@ -68,56 +212,136 @@ func Italic(text string) string {
 // Linkify returns an ANSI escape sequence with an hyperlink, if the writer
 // supports colors.
 func Linkify(text, url string, w io.Writer) string {
-	if !shouldUseColors(w) {
-		return text
-	}
+	return ColorModeAuto.Linkify(text, url, w)
+}
 
-	// See https://gist.github.com/egmontkob/eb114294efbcd5adb1944c9f3cb5feda
-	// for more information about this escape sequence.
-	return fmt.Sprintf("\x1b]8;;%s\x1b\\%s\x1b]8;;\x1b\\", url, text)
+// SupportsColors returns true if colors and other ANSI sequences are used
+// when writing to the writer.
+func SupportsColors(w io.Writer) bool {
+	return ColorModeAuto.SupportsColors(w)
 }
 
 // StartSpinner starts a spinner with the given message. If the writer doesn't
 // support colors, it simply prints the message.
 func StartSpinner(msg string, w io.Writer) *spinner.Spinner {
-	if !shouldUseColors(w) {
-		fmt.Fprintln(w, msg)
-		return nil
+	return ColorModeAuto.StartSpinner(msg, w)
+}
+
+// StopSpinner stops a spinner with the given message. If the writer doesn't
+// support colors, it simply prints the message.
+func StopSpinner(s *spinner.Spinner, msg string, w io.Writer) {
+	ColorModeAuto.StopSpinner(s, msg, w)
+}
+
+// Strip removes the ANSI sequences from the text, such as colors and the
+// hyperlinks created by Linkify.
+func Strip(text string) string {
+	return ansiSequenceRegexp.ReplaceAllString(text, "")
+}
+
+// Width returns the number of characters displayed for the text, not counting
+// ANSI sequences.
+func Width(text string) int {
+	return utf8.RuneCountInString(Strip(text))
+}
+
+// PadRight pads the text with spaces so that it's displayed over at least the
+// given number of characters, not counting ANSI sequences.
+func PadRight(text string, width int) string {
+	padding := width - Width(text)
+	if padding <= 0 {
+		return text
 	}
 
-	// See https://github.com/briandowns/spinner#available-character-sets for
-	// list of available charsets
-	charSetIdx := 11
-	if runtime.GOOS == "windows" {
-		// Less fancy, but uses ASCII characters so works with Windows default
-		// console.
-		charSetIdx = 8
+	return text + strings.Repeat(" ", padding)
+}
+
+// Truncate shortens the text so that it's displayed over at most the given
+// number of characters, the last of which is replaced by `…` when the text is
+// cut. ANSI sequences are never cut and don't count towards the width; the
+// ones following the cut are kept so that colors are still reset.
+func Truncate(text string, width int) string {
+	if Width(text) <= width {
+		return text
+	}
+	if width <= 0 {
+		return ""
 	}
 
-	s := spinner.New(spinner.CharSets[charSetIdx], 100*time.Millisecond)
-	s.Writer = w
+	var b strings.Builder
+	remaining := width - 1
 
-	if msg != "" {
-		s.Suffix = " " + msg
+	for text != "" {
+		if loc := ansiSequenceRegexp.FindStringIndex(text); loc != nil && loc[0] == 0 {
+			b.WriteString(text[:loc[1]])
+			text = text[loc[1]:]
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(text)
+		if remaining > 0 {
+			b.WriteRune(r)
+			remaining--
+		} else if remaining == 0 {
+			b.WriteString("…")
+			remaining--
+		}
+		text = text[size:]
 	}
 
-	s.Start()
-	return s
+	return b.String()
 }
 
-// StopSpinner stops a spinner with the given message. If the writer doesn't
-// support colors, it simply prints the message.
-func StopSpinner(s *spinner.Spinner, msg string, w io.Writer) {
-	if !shouldUseColors(w) {
-		fmt.Fprintln(w, msg)
-		return
+// IsInteractive returns true if the spinners and the other interactive
+// output, such as cursor movements, can be written to the writer: it must be
+// attached to a terminal, outside of CI (`CI` set to anything but `false` or
+// `0`), unless overridden by ForceInteractive or DisableInteractive.
+func IsInteractive(w io.Writer) bool {
+	switch {
+	case DisableInteractive:
+		return false
+	case ForceInteractive:
+		return true
+	case isCI():
+		return false
+	default:
+		return checkIfTerminal(w)
 	}
+}
 
-	if msg != "" {
-		s.FinalMSG = "> " + msg + "\n"
+// IsTerminal returns true if the writer is attached to a terminal.
+func IsTerminal(w io.Writer) bool {
+	return checkIfTerminal(w)
+}
+
+// SupportsUnicode returns true if the locale uses UTF-8, as set by the
+// LC_ALL, LC_CTYPE and LANG environment variables in that order of
+// precedence.
+func SupportsUnicode() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			value = strings.ToLower(value)
+			return strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+		}
 	}
 
-	s.Stop()
+	return false
+}
+
+// TerminalWidth returns the width of the terminal the writer is attached to,
+// and false if it isn't a terminal.
+func TerminalWidth(w io.Writer) (int, bool) {
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0, false
+	}
+
+	width, _, err := terminal.GetSize(int(f.Fd()))
+	if err != nil || width <= 0 {
+		return 0, false
+	}
+
+	return width, true
 }
 
 // StrikeThrough returns struck though text if the writer supports colors
@@ -126,6 +350,14 @@ func StrikeThrough(text string) string {
 	return color.Sprintf(color.StrikeThrough(text))
 }
 
+//
+// Private variables
+//
+
+// ansiSequenceRegexp matches the CSI sequences (e.g. colors and cursor
+// movements) and the OSC sequences (e.g. hyperlinks)
+var ansiSequenceRegexp = regexp.MustCompile("\x1b\\[[0-9;?]*[ -/]*[@-~]|\x1b\\][^\x07\x1b]*(?:\x07|\x1b\\\\)")
+
 //
 // Private functions
 //
@@ -139,6 +371,17 @@ func checkIfTerminal(w io.Writer) bool {
 	}
 }
 
+// isCI returns true if the `CI` environment variable set by most CI services
+// is set
+func isCI() bool {
+	switch strings.ToLower(os.Getenv("CI")) {
+	case "", "false", "0":
+		return false
+	default:
+		return true
+	}
+}
+
 func shouldUseColors(w io.Writer) bool {
 	useColors := ForceColors || checkIfTerminal(w)
 
@@ -152,6 +395,8 @@ func shouldUseColors(w io.Writer) bool {
 			useColors = false
 		case os.Getenv("CLICOLOR") == "0":
 			useColors = false
+		case os.Getenv("NO_COLOR") != "":
+			useColors = false
 		}
 	}
 
@@ -0,0 +1,160 @@
+package ansi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrip(t *testing.T) {
+	require.Equal(t, "plain text", Strip("plain text"))
+	require.Equal(t, "500 POST", Strip("\x1b[1;31m500\x1b[0m \x1b[1mPOST\x1b[0m"))
+	require.Equal(t, "line (x2)", Strip("\x1b[1A\x1b[2Kline (x2)"))
+	require.Equal(t, "req_123", Strip("\x1b]8;;https://dashboard.stripe.com/test/logs/req_123\x1b\\req_123\x1b]8;;\x1b\\"))
+}
+
+func TestParseColorMode(t *testing.T) {
+	mode, err := ParseColorMode("")
+	require.NoError(t, err)
+	require.Equal(t, ColorModeAuto, mode)
+
+	mode, err = ParseColorMode("Never")
+	require.NoError(t, err)
+	require.Equal(t, ColorModeNever, mode)
+
+	_, err = ParseColorMode("on")
+	require.EqualError(t, err, "on is not an acceptable color mode (auto, always, never)")
+}
+
+func TestColorModeSupportsColors(t *testing.T) {
+	defer func(force bool) { ForceColors = force }(ForceColors)
+	ForceColors = false
+
+	var buf bytes.Buffer
+
+	require.True(t, ColorModeAlways.SupportsColors(&buf))
+	require.False(t, ColorModeNever.SupportsColors(&buf))
+	require.False(t, ColorModeAuto.SupportsColors(&buf))
+
+	require.Equal(t, `{"a":1}`, ColorModeNever.ColorizeJSON(`{"a":1}`, &buf))
+	require.Equal(t, "req_123", ColorModeNever.Linkify("req_123", "https://dashboard.stripe.com", &buf))
+	require.Equal(t, "200", ColorModeNever.Color(&buf).Green("200").String())
+	require.Equal(t, "\x1b[32m200\x1b[0m", ColorModeAlways.Color(&buf).Green("200").String())
+}
+
+func TestNoColor(t *testing.T) {
+	defer func(force bool) { ForceColors = force }(ForceColors)
+	defer os.Unsetenv("NO_COLOR")
+
+	ForceColors = true
+	require.True(t, SupportsColors(ioutil.Discard))
+
+	os.Setenv("NO_COLOR", "1")
+	require.False(t, SupportsColors(ioutil.Discard))
+
+	// An explicit color mode takes precedence over the environment
+	require.True(t, ColorModeAlways.SupportsColors(ioutil.Discard))
+}
+
+func TestIsInteractive(t *testing.T) {
+	defer func(force bool) { ForceInteractive = force }(ForceInteractive)
+	defer func(disable bool) { DisableInteractive = disable }(DisableInteractive)
+	if value, ok := os.LookupEnv("CI"); ok {
+		defer os.Setenv("CI", value)
+	} else {
+		defer os.Unsetenv("CI")
+	}
+
+	os.Unsetenv("CI")
+	require.False(t, IsInteractive(&bytes.Buffer{}))
+
+	ForceInteractive = true
+	require.True(t, IsInteractive(&bytes.Buffer{}))
+
+	// DisableInteractive takes precedence
+	DisableInteractive = true
+	require.False(t, IsInteractive(&bytes.Buffer{}))
+
+	ForceInteractive = false
+	DisableInteractive = false
+
+	for value, ci := range map[string]bool{"true": true, "1": true, "false": false, "0": false} {
+		os.Setenv("CI", value)
+		require.Equal(t, ci, isCI(), "CI=%s", value)
+	}
+}
+
+func TestSpinnerNotInteractive(t *testing.T) {
+	defer func(disable bool) { DisableInteractive = disable }(DisableInteractive)
+	DisableInteractive = true
+
+	var buf bytes.Buffer
+
+	s := ColorModeAlways.StartSpinner("Getting ready...", &buf)
+	require.Nil(t, s)
+	ColorModeAlways.UpdateSpinner(s, "Retrying (2/3)...", &buf)
+	ColorModeAlways.StopSpinner(s, "Ready!", &buf)
+
+	require.Equal(t, "Getting ready...\nRetrying (2/3)...\nReady!\n", buf.String())
+}
+
+func TestWidth(t *testing.T) {
+	require.Equal(t, 0, Width(""))
+	require.Equal(t, 4, Width("POST"))
+	require.Equal(t, 4, Width("\x1b[32mPOST\x1b[0m"))
+	require.Equal(t, 7, Width("\x1b]8;;https://dashboard.stripe.com\x1b\\req_123\x1b]8;;\x1b\\"))
+	require.Equal(t, 5, Width("héllo"))
+}
+
+func TestTruncate(t *testing.T) {
+	require.Equal(t, "/v1/charges", Truncate("/v1/charges", 11))
+	require.Equal(t, "/v1/cha…", Truncate("/v1/charges", 8))
+	require.Equal(t, "…", Truncate("/v1/charges", 1))
+	require.Equal(t, "", Truncate("/v1/charges", 0))
+	require.Equal(t, "héll…", Truncate("héllo world", 5))
+
+	// ANSI sequences are kept whole, including the reset after the cut
+	require.Equal(t, "\x1b[32m/v1/cha…\x1b[0m", Truncate("\x1b[32m/v1/charges\x1b[0m", 8))
+	require.Equal(t, "\x1b[32m/v1/charges\x1b[0m", Truncate("\x1b[32m/v1/charges\x1b[0m", 11))
+}
+
+func TestTerminalWidth(t *testing.T) {
+	_, ok := TerminalWidth(&bytes.Buffer{})
+	require.False(t, ok)
+}
+
+func TestSupportsUnicode(t *testing.T) {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		value, ok := os.LookupEnv(name)
+		os.Unsetenv(name)
+		if ok {
+			defer os.Setenv(name, value)
+		}
+	}
+
+	require.False(t, SupportsUnicode())
+
+	os.Setenv("LANG", "en_US.UTF-8")
+	require.True(t, SupportsUnicode())
+
+	os.Setenv("LC_CTYPE", "C.utf8")
+	require.True(t, SupportsUnicode())
+
+	// LC_ALL takes precedence
+	os.Setenv("LC_ALL", "C")
+	require.False(t, SupportsUnicode())
+
+	os.Unsetenv("LC_ALL")
+	os.Unsetenv("LC_CTYPE")
+	os.Unsetenv("LANG")
+}
+
+func TestPadRight(t *testing.T) {
+	require.Equal(t, "GET   ", PadRight("GET", 6))
+	require.Equal(t, "\x1b[36mGET\x1b[0m   ", PadRight("\x1b[36mGET\x1b[0m", 6))
+	require.Equal(t, "DELETE", PadRight("DELETE", 6))
+	require.Equal(t, "OPTIONS", PadRight("OPTIONS", 6))
+}
@@ -0,0 +1,267 @@
+package ansi
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/tidwall/pretty"
+)
+
+//
+// Public types
+//
+
+// Theme maps the semantic roles of colorized output to colors, so that the
+// colors can be adapted to the background of the terminal.
+type Theme struct {
+	// Success, ClientError and ServerError are the colors of 2xx/3xx, 4xx
+	// and 5xx status codes
+	Success     aurora.Color
+	ClientError aurora.Color
+	ServerError aurora.Color
+
+	// MethodGet, MethodPost and MethodDelete are the colors of the HTTP
+	// methods. Other methods aren't colorized.
+	MethodGet    aurora.Color
+	MethodPost   aurora.Color
+	MethodDelete aurora.Color
+
+	// Key, String and Number are the colors of the JSON object keys, string
+	// values and number values
+	Key    aurora.Color
+	String aurora.Color
+	Number aurora.Color
+}
+
+//
+// Public variables
+//
+
+// DefaultTheme is the theme used when none is configured, suited to dark
+// terminal backgrounds.
+var DefaultTheme = &Theme{
+	Success:      aurora.GreenFg | aurora.BoldFm,
+	ClientError:  aurora.YellowFg | aurora.BoldFm,
+	ServerError:  aurora.RedFg | aurora.BoldFm,
+	MethodGet:    aurora.CyanFg,
+	MethodPost:   aurora.GreenFg,
+	MethodDelete: aurora.RedFg,
+	Key:          aurora.BrightFg | aurora.BlueFg,
+	String:       aurora.BrightFg | aurora.GreenFg,
+	Number:       aurora.BrightFg | aurora.YellowFg,
+}
+
+// Themes are the built-in themes, by name.
+var Themes = map[string]*Theme{
+	"default": DefaultTheme,
+	"light": {
+		Success:      aurora.GreenFg | aurora.BoldFm,
+		ClientError:  aurora.MagentaFg | aurora.BoldFm,
+		ServerError:  aurora.RedFg | aurora.BoldFm,
+		MethodGet:    aurora.BlueFg,
+		MethodPost:   aurora.GreenFg,
+		MethodDelete: aurora.RedFg,
+		Key:          aurora.BlueFg,
+		String:       aurora.GreenFg,
+		Number:       aurora.MagentaFg,
+	},
+	"mono": {
+		Success:      aurora.BoldFm,
+		ClientError:  aurora.BoldFm | aurora.UnderlineFm,
+		ServerError:  aurora.BoldFm | aurora.ReverseFm,
+		MethodDelete: aurora.BoldFm,
+		Key:          aurora.BoldFm,
+	},
+}
+
+//
+// Public functions
+//
+
+// LoadTheme returns the built-in theme with the given name (the default theme
+// if empty) with the colors of some roles overridden. The overrides map the
+// role names (success, client_error, server_error, method_get, method_post,
+// method_delete, key, string, number) to colors in the format accepted by
+// ParseColor.
+func LoadTheme(name string, overrides map[string]string) (*Theme, error) {
+	if name == "" {
+		name = "default"
+	}
+
+	base, ok := Themes[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a known color theme (%s)", name, strings.Join(themeNames(), ", "))
+	}
+
+	theme := *base
+
+	roles := map[string]*aurora.Color{
+		"success":       &theme.Success,
+		"client_error":  &theme.ClientError,
+		"server_error":  &theme.ServerError,
+		"method_get":    &theme.MethodGet,
+		"method_post":   &theme.MethodPost,
+		"method_delete": &theme.MethodDelete,
+		"key":           &theme.Key,
+		"string":        &theme.String,
+		"number":        &theme.Number,
+	}
+
+	for role, value := range overrides {
+		dst, ok := roles[strings.ToLower(role)]
+		if !ok {
+			return nil, fmt.Errorf("%s is not a color theme role (success, client_error, server_error, method_get, method_post, method_delete, key, string, number)", role)
+		}
+
+		color, err := ParseColor(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid color for the %s role: %v", role, err)
+		}
+		*dst = color
+	}
+
+	return &theme, nil
+}
+
+// ParseColor parses a color made of space-separated words: at most one color
+// name (black, red, green, yellow, blue, magenta, cyan, white, optionally
+// prefixed with `bright-`) and any formats (bold, faint, italic, underline,
+// reverse). `none` is the absence of color.
+func ParseColor(value string) (aurora.Color, error) {
+	var color aurora.Color
+	hasColor := false
+
+	words := strings.Fields(strings.ToLower(value))
+	if len(words) == 0 {
+		return 0, fmt.Errorf("the color can't be empty")
+	}
+
+	for _, word := range words {
+		if word == "none" {
+			continue
+		}
+
+		if format, ok := colorFormats[word]; ok {
+			color |= format
+			continue
+		}
+
+		name := strings.TrimPrefix(word, "bright-")
+		foreground, ok := colorNames[name]
+		if !ok {
+			return 0, fmt.Errorf("%s is not an acceptable color (%s, optionally prefixed with bright-) or format (%s)",
+				word, strings.Join(sortedKeys(colorNames), ", "), strings.Join(sortedKeys(colorFormats), ", "))
+		}
+		if hasColor {
+			return 0, fmt.Errorf("%s has more than one color", value)
+		}
+
+		if name != word {
+			foreground |= aurora.BrightFg
+		}
+		color |= foreground
+		hasColor = true
+	}
+
+	return color, nil
+}
+
+// StatusColor returns the color of a status code.
+func (theme *Theme) StatusColor(status int) aurora.Color {
+	switch {
+	case status >= 500:
+		return theme.ServerError
+	case status >= 400:
+		return theme.ClientError
+	default:
+		return theme.Success
+	}
+}
+
+// MethodColor returns the color of an HTTP method (case-insensitive).
+func (theme *Theme) MethodColor(method string) aurora.Color {
+	switch strings.ToUpper(method) {
+	case "GET":
+		return theme.MethodGet
+	case "POST":
+		return theme.MethodPost
+	case "DELETE":
+		return theme.MethodDelete
+	default:
+		return 0
+	}
+}
+
+// ColorizeJSON returns a version of the input JSON colorized with the theme,
+// if colors are used for the writer in the color mode.
+func (theme *Theme) ColorizeJSON(json string, mode ColorMode, w io.Writer) string {
+	if !mode.SupportsColors(w) {
+		return json
+	}
+
+	style := *pretty.TerminalStyle
+	style.Key = colorSequences(theme.Key)
+	style.String = colorSequences(theme.String)
+	style.Number = colorSequences(theme.Number)
+
+	return string(pretty.Color([]byte(json), &style))
+}
+
+//
+// Private variables
+//
+
+var colorNames = map[string]aurora.Color{
+	"black":   aurora.BlackFg,
+	"red":     aurora.RedFg,
+	"green":   aurora.GreenFg,
+	"yellow":  aurora.YellowFg,
+	"blue":    aurora.BlueFg,
+	"magenta": aurora.MagentaFg,
+	"cyan":    aurora.CyanFg,
+	"white":   aurora.WhiteFg,
+}
+
+var colorFormats = map[string]aurora.Color{
+	"bold":      aurora.BoldFm,
+	"faint":     aurora.FaintFm,
+	"italic":    aurora.ItalicFm,
+	"underline": aurora.UnderlineFm,
+	"reverse":   aurora.ReverseFm,
+}
+
+//
+// Private functions
+//
+
+// colorSequences returns the ANSI sequences starting and resetting the color
+func colorSequences(color aurora.Color) [2]string {
+	if color == 0 {
+		return [2]string{"", ""}
+	}
+
+	return [2]string{"\x1b[" + color.Nos(false) + "m", "\x1b[0m"}
+}
+
+func sortedKeys(m map[string]aurora.Color) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func themeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
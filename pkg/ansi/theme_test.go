@@ -0,0 +1,63 @@
+package ansi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/pretty"
+)
+
+func TestParseColor(t *testing.T) {
+	color, err := ParseColor("bold bright-blue")
+	require.NoError(t, err)
+	require.Equal(t, aurora.BoldFm|aurora.BrightFg|aurora.BlueFg, color)
+
+	color, err = ParseColor("none")
+	require.NoError(t, err)
+	require.Equal(t, aurora.Color(0), color)
+
+	_, err = ParseColor("purple")
+	require.EqualError(t, err, "purple is not an acceptable color (black, blue, cyan, green, magenta, red, white, yellow, optionally prefixed with bright-) or format (bold, faint, italic, reverse, underline)")
+
+	_, err = ParseColor("red blue")
+	require.EqualError(t, err, "red blue has more than one color")
+}
+
+func TestLoadTheme(t *testing.T) {
+	theme, err := LoadTheme("", nil)
+	require.NoError(t, err)
+	require.Equal(t, *DefaultTheme, *theme)
+
+	theme, err = LoadTheme("Light", map[string]string{"client_error": "cyan"})
+	require.NoError(t, err)
+	require.Equal(t, aurora.CyanFg, theme.ClientError)
+	require.Equal(t, aurora.MagentaFg|aurora.BoldFm, Themes["light"].ClientError)
+
+	_, err = LoadTheme("solarized", nil)
+	require.EqualError(t, err, "solarized is not a known color theme (default, light, mono)")
+
+	_, err = LoadTheme("default", map[string]string{"warning": "red"})
+	require.EqualError(t, err, "warning is not a color theme role (success, client_error, server_error, method_get, method_post, method_delete, key, string, number)")
+
+	_, err = LoadTheme("default", map[string]string{"key": "grey"})
+	require.Contains(t, err.Error(), "invalid color for the key role: grey is not an acceptable color")
+}
+
+func TestThemeStatusColor(t *testing.T) {
+	require.Equal(t, DefaultTheme.Success, DefaultTheme.StatusColor(200))
+	require.Equal(t, DefaultTheme.ClientError, DefaultTheme.StatusColor(404))
+	require.Equal(t, DefaultTheme.ServerError, DefaultTheme.StatusColor(500))
+}
+
+func TestThemeColorizeJSON(t *testing.T) {
+	var buf bytes.Buffer
+	json := `{"status":200,"url":"/v1/charges"}`
+
+	// The default theme matches the default style of the JSON colorizer
+	require.Equal(t, string(pretty.Color([]byte(json), nil)), DefaultTheme.ColorizeJSON(json, ColorModeAlways, &buf))
+
+	require.Equal(t, json, DefaultTheme.ColorizeJSON(json, ColorModeNever, &buf))
+	require.Equal(t, "{\x1b[1m\"status\"\x1b[0m:200,\x1b[1m\"url\"\x1b[0m:\"/v1/charges\"}", Themes["mono"].ColorizeJSON(json, ColorModeAlways, &buf))
+}
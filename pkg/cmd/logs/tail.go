@@ -2,11 +2,16 @@ package logs
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	"github.com/stripe/stripe-cli/pkg/ansi"
 	"github.com/stripe/stripe-cli/pkg/config"
 	logTailing "github.com/stripe/stripe-cli/pkg/logtailing"
 	"github.com/stripe/stripe-cli/pkg/validators"
@@ -22,6 +27,93 @@ type TailCmd struct {
 	format     string
 	LogFilters *logTailing.LogFilters
 	noWSS      bool
+
+	aligned              bool
+	authorizeMaxAttempts int
+	bell                 bool
+	drainTimeout         time.Duration
+	eventLimit           int
+	failOn               string
+	fields               []string
+	flushInterval        time.Duration
+	glyphs               bool
+	forwardHeaders       []string
+	forwardURL           string
+	idleAfter            time.Duration
+	jsonCompact          bool
+	jsonIndent           string
+	keys                 []string
+	liveStats            bool
+	maxReconnectAttempts int
+	maxURLLength         int
+	metricsAddr          string
+	noStatusText         bool
+	noSummary            bool
+	notifyInterval       time.Duration
+	notifyOn             string
+	notifyReconnects     bool
+	otlpEndpoint         string
+	outputFile           string
+	outputFileMaxBackups int
+	outputFileMaxSize    int64
+	outputTemplate       string
+	pauseKey             bool
+	printReady           bool
+	queuePolicy          string
+	queueSize            int
+	quiet                bool
+	recordFile           string
+	recordFiltered       bool
+	redeliveryWindow     int
+	relativeTimestamps   bool
+	replayFile           string
+	replayNoDelay        bool
+	runDuration          time.Duration
+	showLogID            bool
+	showSession          bool
+	sortJSONKeys         bool
+	statsdAddr           string
+	statsdTagStyle       string
+	strict               bool
+	syslogAddress        string
+	syslogNetwork        string
+	timestampFormat      string
+	timezone             string
+	wide                 bool
+	workers              int
+
+	// Filters applied locally by the tailer
+	excludeConnected      bool
+	excludePaths          []string
+	filterAccounts        []string
+	filterAPIVersions     []string
+	filterErrorCodes      []string
+	filterExpression      string
+	filterErrorTypes      []string
+	filterIdempotencyKeys []string
+	filterLivemode        string
+	filterMaxLatency      time.Duration
+	filterMinLatency      time.Duration
+	filterPaths           []string
+	filterRequestIDs      []string
+	filterResources       []string
+	filterSince           string
+	filterStatusClasses   []string
+	filterStatusCodes     []int
+	filterText            []string
+	filterUntil           string
+	filterURLRegex        string
+	filterUserAgents      []string
+	highlight             bool
+	onlyErrors            bool
+	requireConnected      bool
+
+	dedupeWindow time.Duration
+	filtersFile  string
+	sampleRate   uint
+
+	preset     string
+	savePreset string
 }
 
 // NewTailCmd creates and initializes the tail command for the logs package
@@ -40,7 +132,13 @@ The command establishes a direct connection with Stripe to send the request logs
 
 Watch for all request logs sent from Stripe:
 
-  $ stripe logs tail`),
+  $ stripe logs tail
+
+Filter values can be negated with a leading '!', except the ones of
+--filter-status-code and --filter-account. Watch for all request logs except
+the GET requests and the ones on the balance:
+
+  $ stripe logs tail --filter-http-method '!GET' --filter-path '!/v1/balance'`),
 		RunE: tailCmd.runTailCmd,
 	}
 
@@ -50,9 +148,67 @@ Watch for all request logs sent from Stripe:
 		"",
 		`Specifies the output format of request logs
 Acceptable values:
-	'JSON' - Output logs in JSON format`,
+	'CSV'      - Output logs as CSV rows, e.g. to open them in a spreadsheet
+	'ENVELOPE' - Output logs as one JSON object per line wrapping the payload with the request log ID and the time it was received
+	'JSON'     - Output logs in JSON format
+	'LOGFMT'   - Output logs in logfmt, e.g. to ingest them in a log pipeline
+	'NDJSON'   - Output logs as one compact JSON object per line, e.g. to pipe them into jq`,
 	)
 
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.timestampFormat, "timestamp-format", "", "Format of the request log times: unix, unixms or a Go layout of Mon Jan 2 15:04:05 MST 2006 (e.g. 15:04:05.000)")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.relativeTimestamps, "relative-time", false, "Show how long ago request logs were created (e.g. 3s ago) instead of their time, for request logs from the last hour")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.timezone, "timezone", "", "Time zone the request log times are shown in: local, utc or an IANA name such as America/New_York (default: local)")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.aligned, "aligned", false, "Pad the time, status and method of request logs to fixed widths so that the URLs are aligned")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.wide, "wide", false, "Also show the API version, source, IP address and error code of request logs")
+	tailCmd.Cmd.Flags().IntVar(&tailCmd.maxURLLength, "max-url-length", 0, "Truncate the URLs of request logs to this many characters, dropping the query string first. A negative value fits the lines to the terminal width")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.showLogID, "show-log-id", false, "Also show the resp_ ID of request logs, which Stripe support may ask for")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.showSession, "show-session", false, "Print the websocket ID and endpoint of the session once ready, for debugging")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.fields, "fields", []string{}, "Only print these fields of the request logs, separated by tabs. Nested fields are selected with dotted paths, e.g. status,url,error.code")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.jsonCompact, "json-compact", false, "Print each JSON payload on a single line (JSON format only)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.jsonIndent, "json-indent", "", "Indent the JSON payloads with this string of spaces or tabs instead of two spaces (JSON format only)")
+	tailCmd.Cmd.Flags().DurationVar(&tailCmd.flushInterval, "flush-interval", 0, "How often request logs are flushed when the output is redirected, e.g. to a file (default 1s, negative to disable buffering)")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.sortJSONKeys, "sort-keys", false, "Sort the keys of the JSON payloads alphabetically (JSON format only)")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.bell, "bell", false, "Ring the terminal bell when a server error is printed, or a request log matching --fail-on if it's set, at most once every 5 seconds")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.glyphs, "glyphs", false, "Prefix request logs with a glyph for their status: ✓ for successes, ⚠ for client errors and ✗ for server errors")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.noSummary, "no-summary", false, "Don't print a summary of the request logs received when exiting. Send SIGUSR1 to print the stats of the session while tailing")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.liveStats, "live-stats", false, "Show a line with the throughput and error rate below the request logs, when the output is a terminal")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.keys, "keys", []string{}, "Tail the request logs of several API keys at once instead of the key of the profile, e.g. a test mode and a live mode key, labeling each line with [TEST] or [LIVE]. Use label=key to label the request logs of a key with e.g. an account nickname instead")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.strict, "strict", false, "Exit if one of the --keys can't be authorized, instead of tailing the other ones")
+	tailCmd.Cmd.Flags().IntVar(&tailCmd.authorizeMaxAttempts, "authorize-max-attempts", 0, "Number of attempts made to connect to Stripe when it fails with a transient error (default 5)")
+	tailCmd.Cmd.Flags().IntVar(&tailCmd.maxReconnectAttempts, "max-reconnect-attempts", 0, "Exit with an error when the connection to Stripe can't be restored after this many consecutive attempts, e.g. in CI (default: retry forever)")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.noStatusText, "no-status-text", false, "Only show the status code of request logs, without its text (e.g. 402 instead of 402 Payment Required)")
+	tailCmd.Cmd.Flags().DurationVar(&tailCmd.drainTimeout, "drain-timeout", 0, "How long to wait on exit for the request logs received to be written to the outputs, e.g. the output file or --forward-to (default 3s). Press ^C again to exit immediately")
+	tailCmd.Cmd.Flags().IntVar(&tailCmd.workers, "workers", 0, "Parse, filter and render the request logs on this many goroutines in parallel, for heavy traffic. The request logs are still printed in the order they are received")
+	tailCmd.Cmd.Flags().IntVar(&tailCmd.queueSize, "queue-size", 0, "Drop request logs instead of slowing down the connection to Stripe when more than this many are waiting to be processed. The dropped request logs are counted in the summary")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.queuePolicy, "queue-policy", "", "Which request logs --queue-size drops when the queue is full: drop-oldest or drop-newest (default drop-oldest). Server errors are kept when possible")
+	tailCmd.Cmd.Flags().IntVar(&tailCmd.eventLimit, "limit", 0, "Exit after receiving the given number of request logs matching the filters (default: no limit)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.failOn, "fail-on", "", "Exit with an error at the end of the session if request logs with these status codes were received, e.g. in CI: 5xx, 4xx or any-error")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.notifyOn, "notify-on", "", "Show a desktop notification for the request logs matching 5xx, 4xx+ or a filter expression, e.g. when tailing in a background terminal")
+	tailCmd.Cmd.Flags().DurationVar(&tailCmd.notifyInterval, "notify-interval", 0, "Minimum time between two --notify-on desktop notifications (default 30s)")
+	tailCmd.Cmd.Flags().DurationVar(&tailCmd.idleAfter, "idle-after", 0, "Print a notice when no request logs were received for the given duration, e.g. 5m, with the state of the connection to tell a quiet account from a broken tail")
+	tailCmd.Cmd.Flags().DurationVar(&tailCmd.runDuration, "timeout", 0, "Exit after tailing for the given duration once connected, e.g. 2m (default: no timeout)")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.pauseKey, "pause-key", false, "Pause the request logs when space is pressed, to read them, and resume when it's pressed again. The request logs received in the meantime are buffered")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.printReady, "print-ready", false, "Print a {\"ready\": true} line once connected to Stripe, for scripts waiting for the tail to start before sending requests (ndjson and json formats only)")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.notifyReconnects, "notify-reconnects", false, "Print the --print-ready line again every time the connection to Stripe is reestablished")
+	tailCmd.Cmd.Flags().BoolVarP(&tailCmd.quiet, "quiet", "q", false, "Only print the request logs and errors, without the spinner, ready message and warnings")
+	tailCmd.Cmd.Flags().StringVarP(&tailCmd.outputFile, "output", "o", "", "Also append the request logs to the given file, without colors. Send SIGHUP to reopen it, e.g. after logrotate renamed it")
+	tailCmd.Cmd.Flags().Int64Var(&tailCmd.outputFileMaxSize, "output-max-size", 0, "Rotate the output file when it exceeds the given size in bytes")
+	tailCmd.Cmd.Flags().IntVar(&tailCmd.outputFileMaxBackups, "output-max-backups", 0, "Number of rotated output files to keep (default: all)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.recordFile, "record", "", "Also append the raw request logs to the given file, one JSON object per line, to replay them later")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.recordFiltered, "record-filtered", false, "Also record the request logs hidden by the filters")
+	tailCmd.Cmd.Flags().IntVar(&tailCmd.redeliveryWindow, "redelivery-window", 0, "Number of recent request log IDs remembered to drop the request logs Stripe redelivers after a reconnect (default 1000, negative to print every request log received)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.replayFile, "replay", "", "Replay the request logs of a file written with --record, or of a file with a JSON payload per line (- for stdin), instead of connecting to Stripe. The server-side filters don't apply")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.replayNoDelay, "no-delay", false, "Replay the request logs as fast as possible rather than with their recorded timing")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.forwardURL, "forward-to", "", "Also POST the JSON payload of the request logs matching the filters to the given URL")
+	tailCmd.Cmd.Flags().StringArrayVar(&tailCmd.forwardHeaders, "forward-header", []string{}, "Header added to the requests made to the --forward-to URL, in the 'Name: value' format (repeatable)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.syslogAddress, "syslog-address", "", "Also send the request logs to the syslog server at the given address (e.g. localhost:514)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.metricsAddr, "metrics-addr", "", "Expose counters of the session in the Prometheus format on /metrics at the given address (e.g. localhost:9090), and its status on /status")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.statsdAddr, "statsd-addr", "", "Send metrics of the request logs to the statsd server at the given address (e.g. localhost:8125)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.statsdTagStyle, "statsd-tag-style", "", "How tags are sent to the statsd server: datadog, or plain to append them to the metric names (default: datadog)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.otlpEndpoint, "otlp-endpoint", "", "Also export the request logs as OpenTelemetry log records to the given OTLP/HTTP endpoint (e.g. http://localhost:4318)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.syslogNetwork, "syslog-network", "", "Network used to connect to the syslog server: tcp, udp, unix or unixgram (default: udp)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.outputTemplate, "template", "", "Render request logs with a Go template instead of the default format (e.g. '{{color .Status}} {{.Method}} {{.URL}} {{.RequestID}}')")
+
 	// Log filters
 	tailCmd.Cmd.Flags().StringSliceVar(
 		&tailCmd.LogFilters.FilterAccount,
@@ -106,6 +262,59 @@ Acceptable values:
 	'5XX' - All 5XX status codes`,
 	)
 
+	// Local filters, applied by the CLI to the received request logs
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.filterLivemode, "filter-livemode", "", "Filter request logs by mode, 'true' for live mode and 'false' for test mode")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.filterExpression, "filter", "", "Filter request logs with an expression combining conditions (e.g. 'status>=500 and method=POST and path~=/v1/charges')")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.filterPaths, "filter-path", []string{}, "Filter request logs by path prefix, ignoring query strings")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.excludePaths, "exclude-path", []string{}, "Hide request logs whose path starts with the given prefix, ignoring query strings")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.filterAccounts, "filter-connected-account", []string{}, "*CONNECT ONLY* Filter request logs by connected account ID (acct_...), or 'platform' for requests made without one")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.requireConnected, "require-connected-account", false, "*CONNECT ONLY* Only show request logs made on behalf of a connected account")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.excludeConnected, "exclude-connected-account", false, "*CONNECT ONLY* Hide request logs made on behalf of a connected account")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.filterAPIVersions, "filter-api-version", []string{}, "Filter request logs by Stripe API version (e.g. 2019-09-09)")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.filterErrorCodes, "filter-error-code", []string{}, "Filter failed request logs by error code (e.g. card_declined)")
+	tailCmd.Cmd.Flags().StringSliceVar(
+		&tailCmd.filterErrorTypes,
+		"filter-error-type",
+		[]string{},
+		`Filter failed request logs by error type
+Acceptable values:
+	'api_error'             - Errors on Stripe's side
+	'card_error'            - Cards that can't be charged
+	'idempotency_error'     - Misuse of idempotency keys
+	'invalid_request_error' - Requests with invalid parameters`,
+	)
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.filterIdempotencyKeys, "filter-idempotency-key", []string{}, "Filter request logs by idempotency key")
+	tailCmd.Cmd.Flags().DurationVar(&tailCmd.filterMinLatency, "filter-latency-gt", 0, "Only show request logs that took at least the given duration to be served (e.g. 2s)")
+	tailCmd.Cmd.Flags().DurationVar(&tailCmd.filterMaxLatency, "filter-latency-lt", 0, "Only show request logs that took at most the given duration to be served (e.g. 500ms)")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.filterRequestIDs, "filter-request-id", []string{}, "Filter request logs by request ID (e.g. req_123), a trailing '*' matches by prefix")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.filterResources, "filter-resource", []string{}, "Filter request logs by resource type inferred from the URL (e.g. payment_intents,refunds)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.filterSince, "since", "", "Only show request logs created after the given time, either an RFC3339 timestamp or a duration ago (e.g. 5m)")
+	tailCmd.Cmd.Flags().StringSliceVar(
+		&tailCmd.filterStatusClasses,
+		"filter-status",
+		[]string{},
+		`Filter request logs by status class, combined with --status if both are set
+Acceptable values:
+	'succeeded'  - 2XX status codes
+	'client-err' - 4XX status codes
+	'server-err' - 5XX status codes`,
+	)
+	tailCmd.Cmd.Flags().IntSliceVar(&tailCmd.filterStatusCodes, "status", []int{}, "Only show request logs with one of the given status codes (e.g. 402,500), unlike --filter-status-code this is applied by the CLI")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.filterUntil, "until", "", "Only show request logs created before the given time, either an RFC3339 timestamp or a duration ago (e.g. 5m)")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.filterText, "filter-text", []string{}, "Only show request logs whose payload contains all of the given terms (case-insensitive)")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.filterURLRegex, "filter-url-regex", "", "Filter request logs by matching the request URL against a regular expression")
+	tailCmd.Cmd.Flags().StringSliceVar(&tailCmd.filterUserAgents, "filter-user-agent", []string{}, "Filter request logs by user agent substring (case-insensitive), e.g. the name of an SDK")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.highlight, "highlight", false, "Show all request logs, emphasizing the ones matching the local filters instead of hiding the others")
+	tailCmd.Cmd.Flags().BoolVar(&tailCmd.onlyErrors, "only-errors", false, "Only show request logs for failed requests (status code 400 and above)")
+
+	tailCmd.Cmd.Flags().DurationVar(&tailCmd.dedupeWindow, "dedupe-window", 0, "Collapse the request logs received for the same request ID within the given duration into a single line (e.g. 10s)")
+	tailCmd.Cmd.Flags().UintVar(&tailCmd.sampleRate, "sample-rate", 0, "Only show one in every N matching request logs, server errors are always shown")
+
+	// Filter presets
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.filtersFile, "filters-file", "", "Load local filters from a JSON file in the preset format, send SIGHUP to reload it without restarting the tail")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.preset, "preset", "", "Load the local filters saved under the given preset name, explicit filter flags take precedence")
+	tailCmd.Cmd.Flags().StringVar(&tailCmd.savePreset, "save-preset", "", "Save the local filters under the given preset name for later use with --preset")
+
 	// Hidden configuration flags, useful for dev/debugging
 	tailCmd.Cmd.Flags().StringVar(&tailCmd.apiBaseURL, "api-base", "", "Sets the API base URL")
 	tailCmd.Cmd.Flags().MarkHidden("api-base") // #nosec G104
@@ -117,7 +326,36 @@ Acceptable values:
 }
 
 func (tailCmd *TailCmd) runTailCmd(cmd *cobra.Command, args []string) error {
-	err := tailCmd.validateArgs()
+	// The server-side filters don't support negation, so negated values are
+	// applied by the tailer instead
+	negatedHTTPMethods := splitNegatedValues(&tailCmd.LogFilters.FilterHTTPMethod)
+	negatedIPAddresses := splitNegatedValues(&tailCmd.LogFilters.FilterIPAddress)
+	negatedRequestPaths := splitNegatedValues(&tailCmd.LogFilters.FilterRequestPath)
+	negatedStatusClasses, err := statusClassesOfTypes(splitNegatedValues(&tailCmd.LogFilters.FilterStatusCodeType))
+	if err != nil {
+		return err
+	}
+
+	// The source filter of the tailer only takes a single value
+	negatedSources := splitNegatedValues(&tailCmd.LogFilters.FilterSource)
+	if len(negatedSources) > 1 {
+		return fmt.Errorf("only one source filter can be negated (%s)", strings.Join(negatedSources, ", "))
+	}
+	var filterSource string
+	if len(negatedSources) == 1 {
+		filterSource = negatedSources[0]
+	}
+
+	// The tailer has no equivalent of these filters to apply the negated
+	// values to
+	if negated := splitNegatedValues(&tailCmd.LogFilters.FilterStatusCode); len(negated) > 0 {
+		return fmt.Errorf("negation is not supported by the status code filter (%s), use e.g. --filter 'status!=%s' instead", strings.Join(negated, ", "), strings.TrimPrefix(negated[0], "!"))
+	}
+	if negated := splitNegatedValues(&tailCmd.LogFilters.FilterAccount); len(negated) > 0 {
+		return fmt.Errorf("negation is not supported by the account filter (%s), use --require-connected-account or --exclude-connected-account instead", strings.Join(negated, ", "))
+	}
+
+	err = tailCmd.validateArgs()
 	if err != nil {
 		return err
 	}
@@ -127,26 +365,182 @@ func (tailCmd *TailCmd) runTailCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	deviceName, err := tailCmd.cfg.Profile.GetDeviceName()
+	var filterLivemode *bool
+	if tailCmd.filterLivemode != "" {
+		livemode, err := strconv.ParseBool(tailCmd.filterLivemode)
+		if err != nil {
+			return fmt.Errorf("%s is not an acceptable livemode filter (true, false)", tailCmd.filterLivemode)
+		}
+		filterLivemode = &livemode
+	}
+
+	now := time.Now()
+
+	var filterSince, filterUntil time.Time
+	if tailCmd.filterSince != "" {
+		filterSince, err = logTailing.ParseTimeFilter(tailCmd.filterSince, now)
+		if err != nil {
+			return err
+		}
+	}
+	if tailCmd.filterUntil != "" {
+		filterUntil, err = logTailing.ParseTimeFilter(tailCmd.filterUntil, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The global color setting is passed explicitly so that it also applies
+	// to the output of the tailer when it's piped
+	color, err := tailCmd.cfg.Profile.GetColor()
+	if err != nil {
+		return err
+	}
+
+	colorMode := ""
+	switch color {
+	case config.ColorOn:
+		colorMode = string(ansi.ColorModeAlways)
+	case config.ColorOff:
+		colorMode = string(ansi.ColorModeNever)
+	}
+
+	theme, err := tailCmd.cfg.Profile.GetColorTheme()
+	if err != nil {
+		return err
+	}
+
+	forwardHeaders, err := parseHeaders(tailCmd.forwardHeaders)
 	if err != nil {
 		return err
 	}
 
-	key, err := tailCmd.cfg.Profile.GetAPIKey()
+	deviceName, err := tailCmd.cfg.Profile.GetDeviceName()
 	if err != nil {
 		return err
 	}
 
-	tailer := logTailing.New(&logTailing.Config{
-		APIBaseURL:       tailCmd.apiBaseURL,
-		DeviceName:       deviceName,
-		Filters:          tailCmd.LogFilters,
-		Key:              key,
-		Log:              log.StandardLogger(),
-		NoWSS:            tailCmd.noWSS,
-		OutputFormat:     strings.ToUpper(tailCmd.format),
-		WebSocketFeature: requestLogsWebSocketFeature,
-	})
+	// Replaying doesn't connect to Stripe, so it works without an API key
+	var key string
+	if tailCmd.replayFile == "" && len(tailCmd.keys) == 0 {
+		key, err = tailCmd.cfg.Profile.GetAPIKey()
+		if err != nil {
+			return err
+		}
+	}
+
+	keys, keyLabels := parseKeys(tailCmd.keys)
+
+	tailerConfig := &logTailing.Config{
+		Aligned:                 tailCmd.aligned,
+		APIBaseURL:              tailCmd.apiBaseURL,
+		AuthorizeMaxAttempts:    tailCmd.authorizeMaxAttempts,
+		Bell:                    tailCmd.bell,
+		ColorMode:               colorMode,
+		DedupeWindow:            tailCmd.dedupeWindow,
+		DeviceName:              deviceName,
+		DrainTimeout:            tailCmd.drainTimeout,
+		EventLimit:              tailCmd.eventLimit,
+		ExcludeConnectedAccount: tailCmd.excludeConnected,
+		ExcludeRequestPaths:     tailCmd.excludePaths,
+		FailOn:                  tailCmd.failOn,
+		Fields:                  tailCmd.fields,
+		Filters:                 tailCmd.LogFilters,
+		FiltersFile:             tailCmd.filtersFile,
+		FilterAccount:           tailCmd.filterAccounts,
+		FilterAPIVersion:        tailCmd.filterAPIVersions,
+		FilterErrorCode:         tailCmd.filterErrorCodes,
+		FilterErrorType:         tailCmd.filterErrorTypes,
+		FilterExpression:        tailCmd.filterExpression,
+		FilterHTTPMethods:       negatedHTTPMethods,
+		FilterIdempotencyKeys:   tailCmd.filterIdempotencyKeys,
+		FilterIPAddress:         negatedIPAddresses,
+		FilterLivemode:          filterLivemode,
+		FilterMaxLatency:        tailCmd.filterMaxLatency,
+		FilterMinLatency:        tailCmd.filterMinLatency,
+		FilterRequestIDs:        tailCmd.filterRequestIDs,
+		FilterRequestPaths:      append(tailCmd.filterPaths, negatedRequestPaths...),
+		FilterResources:         tailCmd.filterResources,
+		FilterSince:             filterSince,
+		FilterSource:            filterSource,
+		FilterStatusClasses:     append(tailCmd.filterStatusClasses, negatedStatusClasses...),
+		FilterStatusCodes:       tailCmd.filterStatusCodes,
+		FilterText:              tailCmd.filterText,
+		FilterUntil:             filterUntil,
+		FilterURLRegex:          tailCmd.filterURLRegex,
+		FilterUserAgent:         tailCmd.filterUserAgents,
+		FlushInterval:           tailCmd.flushInterval,
+		ForwardHeaders:          forwardHeaders,
+		ForwardURL:              tailCmd.forwardURL,
+		Glyphs:                  tailCmd.glyphs,
+		HighlightMode:           tailCmd.highlight,
+		IdleAfter:               tailCmd.idleAfter,
+		JSONCompact:             tailCmd.jsonCompact,
+		JSONIndent:              tailCmd.jsonIndent,
+		Key:                     key,
+		KeyLabels:               keyLabels,
+		Keys:                    keys,
+		LiveStats:               tailCmd.liveStats,
+		Log:                     log.StandardLogger(),
+		MaxReconnectAttempts:    tailCmd.maxReconnectAttempts,
+		MaxURLLength:            tailCmd.maxURLLength,
+		MetricsAddr:             tailCmd.metricsAddr,
+		NoStatusText:            tailCmd.noStatusText,
+		NoSummary:               tailCmd.noSummary,
+		NoWSS:                   tailCmd.noWSS,
+		NotifyInterval:          tailCmd.notifyInterval,
+		NotifyOn:                tailCmd.notifyOn,
+		NotifyReconnects:        tailCmd.notifyReconnects,
+		OnlyErrors:              tailCmd.onlyErrors,
+		OTLPEndpoint:            tailCmd.otlpEndpoint,
+		OutputFile:              tailCmd.outputFile,
+		OutputFileMaxBackups:    tailCmd.outputFileMaxBackups,
+		OutputFileMaxSize:       tailCmd.outputFileMaxSize,
+		OutputFormat:            strings.ToUpper(tailCmd.format),
+		OutputTemplate:          tailCmd.outputTemplate,
+		PauseKey:                tailCmd.pauseKey,
+		PresetName:              tailCmd.preset,
+		Presets:                 &tailCmd.cfg.Profile,
+		PrintReady:              tailCmd.printReady,
+		QueuePolicy:             tailCmd.queuePolicy,
+		QueueSize:               tailCmd.queueSize,
+		Quiet:                   tailCmd.quiet,
+		RecordFile:              tailCmd.recordFile,
+		RecordFiltered:          tailCmd.recordFiltered,
+		RedeliveryWindow:        tailCmd.redeliveryWindow,
+		RelativeTimestamps:      tailCmd.relativeTimestamps,
+		ReplayNoDelay:           tailCmd.replayNoDelay,
+		RequireConnectedAccount: tailCmd.requireConnected,
+		RunDuration:             tailCmd.runDuration,
+		SampleRate:              tailCmd.sampleRate,
+		ShowLogID:               tailCmd.showLogID,
+		ShowSession:             tailCmd.showSession,
+		SortJSONKeys:            tailCmd.sortJSONKeys,
+		StatsdAddr:              tailCmd.statsdAddr,
+		StatsdTagStyle:          tailCmd.statsdTagStyle,
+		Strict:                  tailCmd.strict,
+		SyslogAddress:           tailCmd.syslogAddress,
+		SyslogNetwork:           tailCmd.syslogNetwork,
+		Theme:                   theme,
+		TimestampFormat:         tailCmd.timestampFormat,
+		Timezone:                tailCmd.timezone,
+		WebSocketFeature:        requestLogsWebSocketFeature,
+		Wide:                    tailCmd.wide,
+		Workers:                 tailCmd.workers,
+	}
+
+	if tailCmd.savePreset != "" {
+		err = logTailing.SavePreset(&tailCmd.cfg.Profile, tailCmd.savePreset, tailerConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	tailer := logTailing.New(tailerConfig)
+
+	if tailCmd.replayFile != "" {
+		return tailCmd.replay(tailer)
+	}
 
 	err = tailer.Run()
 	if err != nil {
@@ -156,6 +550,21 @@ func (tailCmd *TailCmd) runTailCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// replay replays the request logs of the --replay file
+func (tailCmd *TailCmd) replay(tailer *logTailing.Tailer) error {
+	var reader io.Reader = os.Stdin
+	if tailCmd.replayFile != "-" {
+		file, err := os.Open(tailCmd.replayFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	return tailer.Replay(reader)
+}
+
 func (tailCmd *TailCmd) validateArgs() error {
 	err := validators.CallNonEmptyArray(validators.Account, tailCmd.LogFilters.FilterAccount)
 	if err != nil {
@@ -190,6 +599,96 @@ func (tailCmd *TailCmd) validateArgs() error {
 	return nil
 }
 
+// splitNegatedValues removes the negated values (e.g. `!GET`) from the values
+// and returns them.
+func splitNegatedValues(values *[]string) []string {
+	var kept, negated []string
+	for _, value := range *values {
+		if strings.HasPrefix(value, "!") {
+			negated = append(negated, value)
+		} else {
+			kept = append(kept, value)
+		}
+	}
+
+	*values = kept
+
+	return negated
+}
+
+// statusCodeTypeClasses maps the status code types of the server-side filter
+// to the status classes of the tailer
+var statusCodeTypeClasses = map[string]string{
+	"2XX": "succeeded",
+	"4XX": "client-err",
+	"5XX": "server-err",
+}
+
+// statusClassesOfTypes returns the status classes of the tailer matching the
+// status code types, keeping their negation.
+func statusClassesOfTypes(types []string) ([]string, error) {
+	var classes []string
+	for _, codeType := range types {
+		negated := strings.HasPrefix(codeType, "!")
+		codeType = strings.TrimPrefix(codeType, "!")
+
+		err := validators.StatusCodeType(codeType)
+		if err != nil {
+			return nil, err
+		}
+
+		class := statusCodeTypeClasses[strings.ToUpper(codeType)]
+		if negated {
+			class = "!" + class
+		}
+		classes = append(classes, class)
+	}
+
+	return classes, nil
+}
+
+// parseHeaders parses headers in the `Name: value` format.
+func parseHeaders(headers []string) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	parsed := make(map[string]string, len(headers))
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("%s is not a valid header, the expected format is 'Name: value'", header)
+		}
+		parsed[name] = strings.TrimSpace(parts[1])
+	}
+
+	return parsed, nil
+}
+
+// parseKeys parses the keys of --keys, in the `key` or `label=key` format,
+// and returns the keys and their labels.
+func parseKeys(values []string) ([]string, map[string]string) {
+	var keys []string
+	var labels map[string]string
+
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) == 1 {
+			keys = append(keys, value)
+			continue
+		}
+
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		keys = append(keys, parts[1])
+		labels[parts[1]] = parts[0]
+	}
+
+	return keys, labels
+}
+
 func (tailCmd *TailCmd) convertArgs() error {
 	// The backend expects to receive the status code type as a string representing the start of the range (e.g., '200')
 	if len(tailCmd.LogFilters.FilterStatusCodeType) > 0 {
@@ -0,0 +1,114 @@
+package logs
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/config"
+)
+
+func TestTailOnlyErrorsWithSuccessStatusCodes(t *testing.T) {
+	viper.Set("device_name", "test-device")
+	defer viper.Set("device_name", "")
+
+	tests := [][]string{
+		{"--filter-status-code", "200"},
+		{"--filter-status-code-type", "2XX"},
+	}
+
+	for _, filters := range tests {
+		tailCmd := NewTailCmd(&config.Config{})
+		tailCmd.Cmd.SetOutput(ioutil.Discard)
+		tailCmd.Cmd.SetArgs(append([]string{"--keys", "sk_test_123", "--only-errors"}, filters...))
+
+		err := tailCmd.Cmd.Execute()
+		require.Error(t, err, "%v", filters)
+		require.Contains(t, err.Error(), "the only errors filter can't be combined with status code filters that only select successful requests")
+	}
+}
+
+func TestTailStatus(t *testing.T) {
+	viper.Set("device_name", "test-device")
+	defer viper.Set("device_name", "")
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--status", "99"}, "99 is not a valid HTTP status code"},
+		{[]string{"--status", "402,500", "--only-errors"}, "the only errors filter can't be combined with the status code or status class filters"},
+	}
+
+	for _, tt := range tests {
+		tailCmd := NewTailCmd(&config.Config{})
+		tailCmd.Cmd.SetOutput(ioutil.Discard)
+		tailCmd.Cmd.SetArgs(append([]string{"--keys", "sk_test_123"}, tt.args...))
+
+		err := tailCmd.Cmd.Execute()
+		require.Error(t, err, "%v", tt.args)
+		require.Contains(t, err.Error(), tt.err)
+	}
+
+	tailCmd := NewTailCmd(&config.Config{})
+	require.NoError(t, tailCmd.Cmd.ParseFlags([]string{"--status", "402,500"}))
+	require.Equal(t, []int{402, 500}, tailCmd.filterStatusCodes)
+}
+
+func TestTailFilterStatus(t *testing.T) {
+	viper.Set("device_name", "test-device")
+	defer viper.Set("device_name", "")
+
+	tailCmd := NewTailCmd(&config.Config{})
+	tailCmd.Cmd.SetOutput(ioutil.Discard)
+	tailCmd.Cmd.SetArgs([]string{"--keys", "sk_test_123", "--filter-status", "server-erorr"})
+
+	err := tailCmd.Cmd.Execute()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "server-erorr is not an acceptable status class")
+
+	tailCmd = NewTailCmd(&config.Config{})
+	require.NoError(t, tailCmd.Cmd.ParseFlags([]string{"--filter-status", "client-err,!server-err"}))
+	require.Equal(t, []string{"client-err", "!server-err"}, tailCmd.filterStatusClasses)
+}
+
+func TestTailNegatedServerSideFilters(t *testing.T) {
+	viper.Set("device_name", "test-device")
+	defer viper.Set("device_name", "")
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		// The negated values are applied by the tailer, which validates them
+		{[]string{"--filter-status-code-type", "!2XX", "--only-errors"}, "the only errors filter can't be combined with the status code or status class filters"},
+		{[]string{"--filter-status-code-type", "!3XX"}, "Provided status code type 3XX is not a valid type"},
+		{[]string{"--filter-source", "!cli"}, "cli is not an acceptable source"},
+		{[]string{"--filter-source", "!api,!dashboard"}, "only one source filter can be negated (!api, !dashboard)"},
+
+		// or rejected when the tailer has no equivalent filter
+		{[]string{"--filter-status-code", "!500"}, "negation is not supported by the status code filter (!500), use e.g. --filter 'status!=500' instead"},
+		{[]string{"--filter-account", "connect_in,!self"}, "negation is not supported by the account filter (!self)"},
+	}
+
+	for _, tt := range tests {
+		tailCmd := NewTailCmd(&config.Config{})
+		tailCmd.Cmd.SetOutput(ioutil.Discard)
+		tailCmd.Cmd.SetArgs(append([]string{"--keys", "sk_test_123"}, tt.args...))
+
+		err := tailCmd.Cmd.Execute()
+		require.Error(t, err, "%v", tt.args)
+		require.Contains(t, err.Error(), tt.err)
+	}
+}
+
+func TestStatusClassesOfTypes(t *testing.T) {
+	classes, err := statusClassesOfTypes([]string{"!2xx", "!5XX"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"!succeeded", "!server-err"}, classes)
+
+	_, err = statusClassesOfTypes([]string{"!6XX"})
+	require.Error(t, err)
+}
@@ -106,7 +106,10 @@ func (c *Config) InitConfig() {
 		ansi.DisableColors = true
 		logFormatter.DisableColors = true
 	case ColorAuto:
-		// Nothing to do
+		// Cf. https://no-color.org/
+		if os.Getenv("NO_COLOR") != "" {
+			logFormatter.DisableColors = true
+		}
 	default:
 		log.Fatalf("Unrecognized color value: %s. Expected one of on, off, auto.", c.Color)
 	}
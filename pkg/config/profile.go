@@ -8,9 +8,14 @@ import (
 
 	"github.com/spf13/viper"
 
+	"github.com/stripe/stripe-cli/pkg/ansi"
 	"github.com/stripe/stripe-cli/pkg/validators"
 )
 
+// logFilterPresetsField is the configuration field under which log filter
+// presets are saved
+const logFilterPresetsField = "log_filter_presets"
+
 // Profile handles all things related to managing the project specific configurations
 type Profile struct {
 	DeviceName  string
@@ -49,6 +54,20 @@ func (p *Profile) GetColor() (string, error) {
 	}
 }
 
+// GetColorTheme returns the color theme named by the color_theme field of the
+// profile, with the colors overridden by the color_theme_colors table, e.g.
+//
+//	[default]
+//	  color_theme = "light"
+//	[default.color_theme_colors]
+//	  client_error = "bold magenta"
+func (p *Profile) GetColorTheme() (*ansi.Theme, error) {
+	name := viper.GetString(p.GetConfigField("color_theme"))
+	overrides := viper.GetStringMapString(p.GetConfigField("color_theme_colors"))
+
+	return ansi.LoadTheme(name, overrides)
+}
+
 // GetDeviceName returns the configured device name
 func (p *Profile) GetDeviceName() (string, error) {
 	deviceName := viper.GetString("device_name")
@@ -93,6 +112,35 @@ func (p *Profile) GetAPIKey() (string, error) {
 	return "", errors.New("your API key has not been configured. Use `stripe login` to set your API key")
 }
 
+// GetFilterPreset returns the serialized log filter preset saved under the
+// name, and false if there's none.
+func (p *Profile) GetFilterPreset(name string) (string, bool) {
+	field := p.GetConfigField(logFilterPresetsField + "." + name)
+	if !viper.IsSet(field) {
+		return "", false
+	}
+
+	return viper.GetString(field), true
+}
+
+// ListFilterPresets returns the names of the saved log filter presets.
+func (p *Profile) ListFilterPresets() []string {
+	presets := viper.GetStringMap(p.GetConfigField(logFilterPresetsField))
+
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// SaveFilterPreset saves a serialized log filter preset under the name and
+// writes the updated configuration to disk.
+func (p *Profile) SaveFilterPreset(name string, preset string) error {
+	return p.WriteConfigField(logFilterPresetsField+"."+name, preset)
+}
+
 // GetConfigField returns the configuration field for the specific profile
 func (p *Profile) GetConfigField(field string) string {
 	return p.ProfileName + "." + field
@@ -7,8 +7,11 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/logrusorgru/aurora"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
 )
 
 func TestWriteProfile(t *testing.T) {
@@ -89,6 +92,39 @@ func TestWriteProfilesMerge(t *testing.T) {
 	cleanUp(c.ProfilesFile)
 }
 
+func TestFilterPresets(t *testing.T) {
+	profilesFile := filepath.Join(os.TempDir(), "stripe", "presets.toml")
+	p := Profile{
+		DeviceName:  "st-testing",
+		ProfileName: "tests",
+		APIKey:      "sk_test_123",
+	}
+
+	c := &Config{
+		Color:        "auto",
+		LogLevel:     "info",
+		Profile:      p,
+		ProfilesFile: profilesFile,
+	}
+	c.InitConfig()
+
+	err := p.writeProfile(viper.GetViper())
+	require.NoError(t, err)
+
+	_, ok := p.GetFilterPreset("daily")
+	require.False(t, ok)
+
+	err = p.SaveFilterPreset("daily", `{"filter_http_methods":["POST"]}`)
+	require.NoError(t, err)
+
+	preset, ok := p.GetFilterPreset("daily")
+	require.True(t, ok)
+	require.Equal(t, `{"filter_http_methods":["POST"]}`, preset)
+	require.Equal(t, []string{"daily"}, p.ListFilterPresets())
+
+	cleanUp(c.ProfilesFile)
+}
+
 func helperLoadBytes(t *testing.T, name string) []byte {
 	bytes, err := ioutil.ReadFile(name)
 	if err != nil {
@@ -100,3 +136,24 @@ func helperLoadBytes(t *testing.T, name string) []byte {
 func cleanUp(file string) {
 	os.Remove(file)
 }
+
+func TestGetColorTheme(t *testing.T) {
+	p := Profile{ProfileName: "tests"}
+
+	theme, err := p.GetColorTheme()
+	require.NoError(t, err)
+	require.Equal(t, ansi.DefaultTheme, theme)
+
+	viper.Set("tests.color_theme", "light")
+	viper.Set("tests.color_theme_colors", map[string]string{"client_error": "bold cyan"})
+	defer viper.Reset()
+
+	theme, err = p.GetColorTheme()
+	require.NoError(t, err)
+	require.Equal(t, aurora.BoldFm|aurora.CyanFg, theme.ClientError)
+	require.Equal(t, ansi.Themes["light"].Key, theme.Key)
+
+	viper.Set("tests.color_theme", "solarized")
+	_, err = p.GetColorTheme()
+	require.EqualError(t, err, "solarized is not a known color theme (default, light, mono)")
+}
@@ -0,0 +1,141 @@
+package logtailing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/briandowns/spinner"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/stripeauth"
+)
+
+const (
+	defaultAuthorizeMaxAttempts = 5
+
+	// authorizeRetryDelay is the delay before the first retry of the
+	// authorization. It doubles with every retry, up to
+	// authorizeMaxRetryDelay, and is jittered so that the tailers started
+	// together don't retry together.
+	authorizeRetryDelay    = 1 * time.Second
+	authorizeMaxRetryDelay = 30 * time.Second
+)
+
+// errStopped is returned when the tailer is interrupted or stopped before
+// the session is initiated
+var errStopped = errors.New("the tailer was stopped")
+
+// authorize initiates a CLI session with Stripe for the stream, retrying the
+// transient errors with an exponential backoff. The spinner, if any, shows
+// the retries. It returns errStopped if the tailer is interrupted, stopped or
+// the context is cancelled first.
+func (tailer *Tailer) authorize(ctx context.Context, st *stream, filters *string, s *spinner.Spinner) (*stripeauth.StripeCLISession, error) {
+	maxAttempts := tailer.cfg.AuthorizeMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultAuthorizeMaxAttempts
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano())) // #nosec G404
+
+	for attempt := 1; ; attempt++ {
+		session, err := tailer.authorizeOnce(ctx, st, filters)
+		if err == nil || err == errStopped || attempt >= maxAttempts || !stripeauth.IsRetryable(err) {
+			return session, err
+		}
+
+		delay := authorizeBackoff(attempt, random)
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":  "logs.Tailer.authorize",
+			"stream":  st.label,
+			"attempt": attempt,
+			"delay":   delay,
+		}).Debugf("Could not authenticate with Stripe, retrying: %v", err)
+
+		if !tailer.cfg.Quiet {
+			tailer.cfg.colorMode().UpdateSpinner(s, fmt.Sprintf("Retrying (%d/%d)...", attempt+1, maxAttempts), tailer.cfg.Stderr)
+		}
+
+		if !tailer.waitAuthorize(ctx, nil, tailer.after(delay)) {
+			return nil, errStopped
+		}
+	}
+}
+
+// authorizeOnce makes a single attempt to initiate a CLI session with Stripe
+// for the stream
+func (tailer *Tailer) authorizeOnce(ctx context.Context, st *stream, filters *string) (*stripeauth.StripeCLISession, error) {
+	var session *stripeauth.StripeCLISession
+	var err error
+
+	// Authorize doesn't take a context, so it's left to complete in the
+	// background when the tailer is stopped
+	done := make(chan struct{})
+	go func() {
+		session, err = st.authClient.Authorize(tailer.cfg.DeviceName, tailer.cfg.WebSocketFeature, filters)
+		close(done)
+	}()
+
+	if !tailer.waitAuthorize(ctx, done, nil) {
+		return nil, errStopped
+	}
+
+	return session, err
+}
+
+// waitAuthorize waits for done to be closed or the timer to fire, whichever
+// is set. It returns false if the tailer is interrupted or the context is
+// cancelled first.
+func (tailer *Tailer) waitAuthorize(ctx context.Context, done <-chan struct{}, timer <-chan time.Time) bool {
+	for {
+		select {
+		case <-done:
+			return true
+		case <-timer:
+			return true
+		case <-ctx.Done():
+			return false
+		case sig := <-tailer.interruptCh:
+			// SIGHUP only reloads the filters once tailing, and there are no
+			// stats to print yet
+			if sig == syscall.SIGHUP || (statsSignal != nil && sig == statsSignal) {
+				continue
+			}
+			return false
+		}
+	}
+}
+
+// authorizeBackoff returns the delay before retrying the authorization after
+// the attempt
+func authorizeBackoff(attempt int, random *rand.Rand) time.Duration {
+	delay := authorizeRetryDelay
+	for i := 1; i < attempt && delay < authorizeMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > authorizeMaxRetryDelay {
+		delay = authorizeMaxRetryDelay
+	}
+
+	// Wait between half the delay and the full delay
+	return delay/2 + time.Duration(random.Int63n(int64(delay/2)))
+}
+
+// authorizationError wraps an error returned when initiating a CLI session,
+// mentioning the mode of the key, as using a key of the wrong mode is a
+// common mistake
+func authorizationError(key string, err error) error {
+	mode := ""
+	switch {
+	case strings.Contains(key, "_test_"):
+		mode = " with a test mode key"
+	case strings.Contains(key, "_live_"):
+		mode = " with a live mode key"
+	}
+
+	return fmt.Errorf("error while authenticating with Stripe%s: %v", mode, err)
+}
@@ -0,0 +1,170 @@
+package logtailing
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizeBackoff(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for attempt, max := range []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second} {
+		delay := authorizeBackoff(attempt+1, random)
+		require.True(t, delay >= max/2 && delay < max, "attempt %d: %s", attempt+1, delay)
+	}
+}
+
+// flakyAPI fails to initiate the first failures sessions with the status,
+// then initiates sessions connecting to the fake Stripe
+func flakyAPI(stripe *fakeStripe, status int, failures int32, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		writeSession(w, stripe.ws)
+	}))
+}
+
+func TestRunRetriesAuthorization(t *testing.T) {
+	stripe := newFakeStripe(t, 1)
+	defer stripe.close()
+
+	var requests int32
+	apiServer := flakyAPI(stripe, http.StatusServiceUnavailable, 2, &requests)
+	defer apiServer.Close()
+
+	received := make(channelSink, 1)
+	stderr := &lockedBuffer{}
+	tailer := New(&Config{
+		APIBaseURL:           apiServer.URL,
+		AuthorizeMaxAttempts: 3,
+		ColorMode:            "never",
+		Key:                  "sk_test_123",
+		NoSummary:            true,
+		Sinks:                []Sink{received},
+		Stderr:               stderr,
+		Stdout:               ioutil.Discard,
+		WebSocketFeature:     "request_logs",
+	})
+
+	var delays int32
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		atomic.AddInt32(&delays, 1)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the request logs")
+	}
+
+	tailer.Stop()
+	requireReturns(t, done)
+
+	require.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	require.Equal(t, int32(2), atomic.LoadInt32(&delays))
+
+	stderr.mu.Lock()
+	defer stderr.mu.Unlock()
+	require.Contains(t, stderr.buf.String(), "Retrying (2/3)...\nRetrying (3/3)...\n")
+}
+
+func TestRunDoesNotRetryPermanentAuthorizationErrors(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	var requests int32
+	apiServer := flakyAPI(stripe, http.StatusUnauthorized, 1, &requests)
+	defer apiServer.Close()
+
+	tailer := New(&Config{
+		APIBaseURL:       apiServer.URL,
+		Key:              "sk_test_123",
+		Quiet:            true,
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	err := tailer.Run()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "status=401")
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestRunGivesUpAuthorization(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	var requests int32
+	apiServer := flakyAPI(stripe, http.StatusServiceUnavailable, 10, &requests)
+	defer apiServer.Close()
+
+	tailer := New(&Config{
+		APIBaseURL:           apiServer.URL,
+		AuthorizeMaxAttempts: 2,
+		Key:                  "sk_test_123",
+		Quiet:                true,
+		Stderr:               ioutil.Discard,
+		Stdout:               ioutil.Discard,
+		WebSocketFeature:     "request_logs",
+	})
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+
+	err := tailer.Run()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "status=503")
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestRunInterruptedDuringAuthorizationBackoff(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	var requests int32
+	apiServer := flakyAPI(stripe, http.StatusServiceUnavailable, 10, &requests)
+	defer apiServer.Close()
+
+	tailer := New(&Config{
+		APIBaseURL:       apiServer.URL,
+		Key:              "sk_test_123",
+		Quiet:            true,
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		tailer.interruptCh <- os.Interrupt
+		return make(chan time.Time)
+	}
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		require.FailNow(t, "The tailer didn't stop promptly")
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
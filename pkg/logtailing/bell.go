@@ -0,0 +1,40 @@
+package logtailing
+
+import (
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+)
+
+// bellInterval is the minimum time between two bells, so that a burst of
+// server errors rings once
+const bellInterval = 5 * time.Second
+
+// bell is the BEL character, which terminals ring or flash
+const bell = "\a"
+
+// ringsBell returns true if printing a request log with the status code
+// rings the bell of cfg.Bell: it matches cfg.FailOn if it's set, or it's a
+// server error (5xx status code) otherwise. The bell is only rung on
+// interactive terminals, see ansi.IsInteractive, at most once per
+// bellInterval. The caller must hold outputMu.
+func (tailer *Tailer) ringsBell(status int, now time.Time) bool {
+	if !tailer.cfg.Bell || !ansi.IsInteractive(tailer.cfg.Stdout) {
+		return false
+	}
+
+	if tailer.failOn != nil {
+		if !tailer.failOn.matches(status) {
+			return false
+		}
+	} else if status < 500 {
+		return false
+	}
+
+	if !tailer.lastBell.IsZero() && now.Sub(tailer.lastBell) < bellInterval {
+		return false
+	}
+	tailer.lastBell = now
+
+	return true
+}
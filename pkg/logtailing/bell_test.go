@@ -0,0 +1,68 @@
+package logtailing
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingsBell(t *testing.T) {
+	defer withInteractive(true)()
+
+	var stdout bytes.Buffer
+	tailer := New(&Config{Bell: true, Stdout: &stdout})
+
+	now := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	tailer.now = func() time.Time { return now }
+
+	require.NoError(t, tailer.printRequestLogEvent("req_1", "200 GET /v1/charges", 200))
+	require.NoError(t, tailer.printRequestLogEvent("req_2", "500 POST /v1/charges", 500))
+
+	// A burst of server errors rings once
+	now = now.Add(time.Second)
+	require.NoError(t, tailer.printRequestLogEvent("req_3", "502 POST /v1/charges", 502))
+
+	now = now.Add(bellInterval)
+	require.NoError(t, tailer.printRequestLogEvent("req_4", "503 POST /v1/charges", 503))
+
+	require.Equal(t, "200 GET /v1/charges\n500 POST /v1/charges\a\n502 POST /v1/charges\n503 POST /v1/charges\a\n", stdout.String())
+}
+
+func TestRingsBellFailOn(t *testing.T) {
+	defer withInteractive(true)()
+
+	var stdout bytes.Buffer
+	tailer := New(&Config{Bell: true, FailOn: failOn4xx, Stdout: &stdout})
+
+	require.NoError(t, tailer.printRequestLogEvent("req_1", "500 POST /v1/charges", 500))
+	require.NoError(t, tailer.printRequestLogEvent("req_2", "402 POST /v1/charges", 402))
+
+	require.Equal(t, "500 POST /v1/charges\n402 POST /v1/charges\a\n", stdout.String())
+}
+
+func TestRingsBellNotInteractive(t *testing.T) {
+	defer withInteractive(false)()
+
+	var stdout bytes.Buffer
+	tailer := New(&Config{Bell: true, Stdout: &stdout})
+
+	tailer.processRequestLogEvent(requestLogMessage(`{"status": 500, "method": "POST", "url": "/v1/charges"}`))
+
+	require.NotEmpty(t, stdout.String())
+	require.NotContains(t, stdout.String(), bell)
+}
+
+func TestProcessRequestLogEventRingsBell(t *testing.T) {
+	defer withInteractive(true)()
+	defer withColors(false)()
+
+	var stdout bytes.Buffer
+	tailer := New(&Config{Bell: true, Stdout: &stdout})
+
+	tailer.processRequestLogEvent(requestLogMessage(`{"status": 500, "method": "POST", "url": "/v1/charges"}`))
+
+	require.Contains(t, stdout.String(), "/v1/charges")
+	require.True(t, bytes.HasSuffix(stdout.Bytes(), []byte(bell+"\n")))
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package logtailing
+
+import (
+	"errors"
+	"io"
+)
+
+// cbreakTerminal isn't supported on this platform, e.g. Windows consoles, so
+// the keypresses can't be read as they're typed
+func cbreakTerminal(stdin io.Reader) (func() error, error) {
+	return nil, errors.New("reading keypresses isn't supported on this platform")
+}
@@ -0,0 +1,57 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package logtailing
+
+import (
+	"io"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/sys/unix"
+)
+
+// cbreakTerminal puts the terminal of stdin into cbreak mode: the keypresses
+// are read as they're typed, without being echoed, but unlike raw mode the
+// output is still processed and Ctrl+C still sends an interrupt. It returns a
+// function restoring the previous state of the terminal.
+func cbreakTerminal(stdin io.Reader) (func() error, error) {
+	fd, ok := terminalFd(stdin)
+	if !ok {
+		return nil, errNotTerminal
+	}
+
+	termios, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, err
+	}
+	previous := *termios
+
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, cbreakTermios(termios)); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return unix.IoctlSetTermios(fd, ioctlWriteTermios, &previous)
+	}, nil
+}
+
+// cbreakTermios returns the cbreak mode version of the terminal state
+func cbreakTermios(termios *unix.Termios) *unix.Termios {
+	cbreak := *termios
+	cbreak.Lflag &^= unix.ICANON | unix.ECHO
+	cbreak.Lflag |= unix.ISIG
+	cbreak.Cc[unix.VMIN] = 1
+	cbreak.Cc[unix.VTIME] = 0
+	return &cbreak
+}
+
+// terminalFd returns the file descriptor of stdin if it's a terminal
+func terminalFd(stdin io.Reader) (int, bool) {
+	f, ok := stdin.(interface{ Fd() uintptr })
+	if !ok {
+		return 0, false
+	}
+
+	fd := int(f.Fd())
+	return fd, terminal.IsTerminal(fd)
+}
@@ -0,0 +1,43 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package logtailing
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestCbreakTermios(t *testing.T) {
+	termios := &unix.Termios{Lflag: unix.ICANON | unix.ECHO | unix.IEXTEN, Oflag: unix.OPOST}
+	cbreak := cbreakTermios(termios)
+
+	// The keypresses are read as they're typed, without echoing them
+	require.Zero(t, cbreak.Lflag&(unix.ICANON|unix.ECHO))
+	require.Equal(t, uint8(1), cbreak.Cc[unix.VMIN])
+
+	// Ctrl+C still interrupts, and the output is still processed
+	require.NotZero(t, cbreak.Lflag&unix.ISIG)
+	require.NotZero(t, cbreak.Lflag&unix.IEXTEN)
+	require.Equal(t, termios.Oflag, cbreak.Oflag)
+
+	// The previous state is left untouched to be restored
+	require.NotZero(t, termios.Lflag&unix.ICANON)
+}
+
+func TestCbreakTerminalNotTerminal(t *testing.T) {
+	_, err := cbreakTerminal(strings.NewReader(""))
+	require.Equal(t, errNotTerminal, err)
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	_, err = cbreakTerminal(r)
+	require.Equal(t, errNotTerminal, err)
+}
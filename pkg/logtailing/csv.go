@@ -0,0 +1,103 @@
+package logtailing
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// csvHeader are the columns of the CSV output format
+var csvHeader = []string{
+	"created_at",
+	"status",
+	"method",
+	"url",
+	"request_id",
+	"account",
+	"api_version",
+	"error_code",
+	"error_type",
+	"idempotency_key",
+	"ip_address",
+	"latency_ms",
+	"livemode",
+	"source",
+	"user_agent",
+}
+
+// csvRecord returns the CSV columns of a request log, in the order of
+// csvHeader. Missing optional fields are left empty.
+func csvRecord(payload *EventPayload) []string {
+	createdAt := ""
+	if t, ok := payload.CreatedAtTime(); ok {
+		createdAt = t.UTC().Format(time.RFC3339)
+	}
+
+	latency := ""
+	if payload.Latency != nil {
+		latency = strconv.Itoa(*payload.Latency)
+	}
+
+	livemode := ""
+	if payload.Livemode != nil {
+		livemode = strconv.FormatBool(*payload.Livemode)
+	}
+
+	return []string{
+		createdAt,
+		strconv.Itoa(payload.Status),
+		payload.Method,
+		payload.URL,
+		payload.RequestID,
+		payload.Account,
+		payload.APIVersion,
+		payload.Error.Code,
+		payload.Error.Type,
+		payload.IdempotencyKey,
+		payload.IPAddress,
+		latency,
+		livemode,
+		payload.Source,
+		payload.UserAgent,
+	}
+}
+
+// csvMalformedRecord returns the CSV columns of a request log whose payload
+// isn't valid JSON, in the order of csvHeader: the status column reads
+// `malformed` and the request_id column holds the `resp_` ID of the request
+// log, so that the row stays parseable and can be looked up.
+func csvMalformedRecord(event *websocket.RequestLogEvent) []string {
+	record := make([]string, len(csvHeader))
+	record[1] = "malformed"
+	record[4] = event.RequestLogID
+	return record
+}
+
+// csvHeader returns the CSV header of the tailer, with a label column when
+// the request logs are labeled
+func (tailer *Tailer) csvHeader() []string {
+	if !tailer.labeled() {
+		return csvHeader
+	}
+
+	header := make([]string, 0, len(csvHeader)+1)
+	return append(append(header, csvHeader...), "label")
+}
+
+// csvLine renders a CSV record as a single line, without the trailing
+// newline. Every line is written out as soon as it's rendered, so no rows are
+// lost if the tailer is interrupted.
+func csvLine(record []string) string {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+	// Writing to a bytes.Buffer can't fail
+	w.Write(record) // #nosec G104
+	w.Flush()
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
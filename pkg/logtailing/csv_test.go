@@ -0,0 +1,75 @@
+package logtailing
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestCSVHeader(t *testing.T) {
+	require.Equal(t, "created_at,status,method,url,request_id,account,api_version,error_code,error_type,idempotency_key,ip_address,latency_ms,livemode,source,user_agent", csvLine(csvHeader))
+}
+
+func TestCSVRecord(t *testing.T) {
+	livemode := false
+	latency := 120
+	payload := &EventPayload{
+		CreatedAt:  1570000000,
+		Error:      ErrorPayload{Code: "card_declined", Type: "card_error"},
+		Latency:    &latency,
+		Livemode:   &livemode,
+		Method:     "POST",
+		RequestID:  "req_123",
+		Status:     402,
+		URL:        "/v1/charges",
+		APIVersion: "2019-09-09",
+	}
+
+	record := csvRecord(payload)
+	require.Len(t, record, len(csvHeader))
+	require.Equal(t, "2019-10-02T07:06:40Z,402,POST,/v1/charges,req_123,,2019-09-09,card_declined,card_error,,,120,false,,", csvLine(record))
+
+	// Missing optional fields are left empty
+	require.Equal(t, ",200,GET,/v1/balance,req_456,,,,,,,,,,", csvLine(csvRecord(&EventPayload{Method: "GET", RequestID: "req_456", Status: 200, URL: "/v1/balance"})))
+}
+
+func TestCSVLineQuoting(t *testing.T) {
+	payload := &EventPayload{
+		Method:    "GET",
+		Status:    200,
+		URL:       `/v1/customers?email="a,b"@example.com`,
+		UserAgent: "Stripe/v1 RubyBindings/5.0.0\nextra",
+	}
+
+	line := csvLine(csvRecord(payload))
+	require.Contains(t, line, `"/v1/customers?email=""a,b""@example.com"`)
+
+	records, err := csv.NewReader(strings.NewReader(line)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, payload.URL, records[0][3])
+	require.Equal(t, payload.UserAgent, records[0][14])
+}
+
+func TestProcessRequestLogEventCSVMalformed(t *testing.T) {
+	var stdout bytes.Buffer
+	tailer := New(&Config{OutputFormat: outputFormatCSV, Stdout: &stdout})
+
+	tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+		EventPayload: `{"status": 200, "url": "/v1/char`,
+		RequestLogID: "resp_123",
+	}})
+
+	// The row is marked malformed rather than filled with zero values, and
+	// keeps the columns of the header
+	records, err := csv.NewReader(strings.NewReader(stdout.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Len(t, records[0], len(csvHeader))
+	require.Equal(t, ",malformed,,,resp_123,,,,,,,,,,", csvLine(records[0]))
+}
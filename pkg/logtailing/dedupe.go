@@ -0,0 +1,174 @@
+package logtailing
+
+import (
+	"container/list"
+	"fmt"
+	"time"
+)
+
+// dedupeCacheSize is the maximum number of request IDs remembered to detect
+// repeated request logs. The least recently seen IDs are evicted first.
+const dedupeCacheSize = 1000
+
+// dedupeEntry tracks how many times the request log of a request ID was
+// received since it was first seen.
+type dedupeEntry struct {
+	requestID string
+	firstSeen time.Time
+	line      string
+
+	// count is the number of times the request log was received, and shown
+	// the count that has already been displayed
+	count int
+	shown int
+}
+
+// dedupeCache is a bounded LRU cache of the recently seen request IDs. It
+// isn't safe for concurrent use.
+type dedupeCache struct {
+	window time.Duration
+	size   int
+
+	entries map[string]*list.Element
+	order   *list.List
+
+	// evicted are the entries evicted to bound the size of the cache that
+	// still need to be reported
+	evicted []*dedupeEntry
+}
+
+func newDedupeCache(window time.Duration, size int) *dedupeCache {
+	return &dedupeCache{
+		window:  window,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// observe records that the request log of the request ID was received at
+// now. It returns the entry of the request ID, and true if the request log is
+// a repeat of one received within the window.
+func (c *dedupeCache) observe(requestID string, line string, now time.Time) (*dedupeEntry, bool) {
+	if element, ok := c.entries[requestID]; ok {
+		entry := element.Value.(*dedupeEntry)
+		if now.Sub(entry.firstSeen) <= c.window {
+			entry.count++
+			c.order.MoveToFront(element)
+			return entry, true
+		}
+
+		c.remove(element)
+	}
+
+	entry := &dedupeEntry{requestID: requestID, firstSeen: now, line: line, count: 1, shown: 1}
+	c.entries[requestID] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+
+	return entry, false
+}
+
+// expire removes the entries whose window has elapsed at now, and returns the
+// removed entries with repeats that haven't been displayed yet.
+func (c *dedupeCache) expire(now time.Time) []*dedupeEntry {
+	var element, prev *list.Element
+	for element = c.order.Back(); element != nil; element = prev {
+		prev = element.Prev()
+		if now.Sub(element.Value.(*dedupeEntry).firstSeen) > c.window {
+			c.remove(element)
+		}
+	}
+
+	pending := c.evicted
+	c.evicted = nil
+
+	return pending
+}
+
+func (c *dedupeCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*dedupeEntry)
+	delete(c.entries, entry.requestID)
+
+	if entry.count > entry.shown {
+		c.evicted = append(c.evicted, entry)
+	}
+}
+
+// dedupeRequestLogEvent returns the output for the line of a request log, and
+// false if nothing should be printed because the request log is a repeat.
+// When inPlace is true, a repeat of the last printed request log rewrites it
+// with the number of times it was received. The caller must hold outputMu.
+func (tailer *Tailer) dedupeRequestLogEvent(requestID string, line string, now time.Time, inPlace bool) (string, bool) {
+	if tailer.dedupe == nil || requestID == "" {
+		tailer.lastRequestID = ""
+		return line, true
+	}
+
+	entry, repeated := tailer.dedupe.observe(requestID, line, now)
+	if !repeated {
+		tailer.lastRequestID = requestID
+		return line, true
+	}
+
+	if inPlace && tailer.lastRequestID == requestID {
+		entry.shown = entry.count
+		// Move the cursor up to the previous line and clear it
+		return fmt.Sprintf("\x1b[1A\x1b[2K%s (x%d)", entry.line, entry.count), true
+	}
+
+	return "", false
+}
+
+// repeatedEventsMessages returns the summary lines of the request logs that
+// were repeated within the window, once their window has elapsed.
+func (tailer *Tailer) repeatedEventsMessages(now time.Time) []string {
+	var messages []string
+	for _, entry := range tailer.dedupe.expire(now) {
+		if tailer.cfg.structuredOutput() {
+			messages = append(messages, fmt.Sprintf("Received the request log of %s %d times", entry.requestID, entry.count))
+		} else {
+			messages = append(messages, fmt.Sprintf("%s (x%d)", entry.line, entry.count))
+		}
+	}
+	return messages
+}
+
+func (tailer *Tailer) reportRepeatedEvents(stopCh chan struct{}) {
+	if tailer.dedupe == nil {
+		return
+	}
+
+	ticker := time.NewTicker(tailer.cfg.DedupeWindow)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			tailer.flushRepeatedEvents(now)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// flushRepeatedEvents prints the summary lines of the repeated request logs
+// whose window has elapsed at now.
+func (tailer *Tailer) flushRepeatedEvents(now time.Time) {
+	tailer.outputMu.Lock()
+	defer tailer.outputMu.Unlock()
+
+	for _, message := range tailer.repeatedEventsMessages(now) {
+		if tailer.cfg.structuredOutput() {
+			tailer.cfg.Log.Info(message)
+			continue
+		}
+
+		tailer.lastRequestID = ""
+		tailer.clearLiveStats()
+		fmt.Fprintln(tailer.stdout, message)
+		tailer.drawLiveStats()
+	}
+}
@@ -0,0 +1,162 @@
+package logtailing
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupeDisabledByDefault(t *testing.T) {
+	tailer := New(&Config{})
+	require.Nil(t, tailer.dedupe)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		output, ok := tailer.dedupeRequestLogEvent("req_123", "line", now, true)
+		require.True(t, ok)
+		require.Equal(t, "line", output)
+	}
+}
+
+func TestDedupeRequestLogEventInPlace(t *testing.T) {
+	tailer := New(&Config{DedupeWindow: 5 * time.Second})
+	now := time.Now()
+
+	output, ok := tailer.dedupeRequestLogEvent("req_123", "line", now, true)
+	require.True(t, ok)
+	require.Equal(t, "line", output)
+
+	output, ok = tailer.dedupeRequestLogEvent("req_123", "line", now.Add(time.Second), true)
+	require.True(t, ok)
+	require.Equal(t, "\x1b[1A\x1b[2Kline (x2)", output)
+
+	output, ok = tailer.dedupeRequestLogEvent("req_123", "line", now.Add(2*time.Second), true)
+	require.True(t, ok)
+	require.Equal(t, "\x1b[1A\x1b[2Kline (x3)", output)
+
+	// Repeats already shown in place aren't summarized
+	require.Empty(t, tailer.repeatedEventsMessages(now.Add(time.Minute)))
+}
+
+func TestPrintRequestLogEventInPlace(t *testing.T) {
+	tests := []struct {
+		interactive bool
+		output      string
+	}{
+		{true, "line\n\x1b[1A\x1b[2Kline (x2)\n"},
+		// The cursor isn't moved when the output isn't interactive, e.g.
+		// redirected to a file, and the repeat is summarized instead
+		{false, "line\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("interactive %t", tt.interactive), func(t *testing.T) {
+			defer withInteractive(tt.interactive)()
+
+			var stdout bytes.Buffer
+			tailer := New(&Config{ColorMode: "always", DedupeWindow: 5 * time.Second, Stdout: &stdout})
+
+			require.NoError(t, tailer.printRequestLogEvent("req_123", "line", 200))
+			require.NoError(t, tailer.printRequestLogEvent("req_123", "line", 200))
+
+			require.Equal(t, tt.output, stdout.String())
+		})
+	}
+}
+
+func TestDedupeRequestLogEventSummary(t *testing.T) {
+	tailer := New(&Config{DedupeWindow: 5 * time.Second})
+	now := time.Now()
+
+	_, ok := tailer.dedupeRequestLogEvent("req_123", "line 1", now, false)
+	require.True(t, ok)
+	_, ok = tailer.dedupeRequestLogEvent("req_456", "line 2", now, false)
+	require.True(t, ok)
+
+	_, ok = tailer.dedupeRequestLogEvent("req_123", "line 1", now.Add(time.Second), false)
+	require.False(t, ok)
+	_, ok = tailer.dedupeRequestLogEvent("req_123", "line 1", now.Add(2*time.Second), false)
+	require.False(t, ok)
+
+	require.Empty(t, tailer.repeatedEventsMessages(now.Add(4*time.Second)))
+	require.Equal(t, []string{"line 1 (x3)"}, tailer.repeatedEventsMessages(now.Add(6*time.Second)))
+	require.Empty(t, tailer.repeatedEventsMessages(now.Add(7*time.Second)))
+}
+
+func TestDedupeRequestLogEventAfterWindow(t *testing.T) {
+	tailer := New(&Config{DedupeWindow: 5 * time.Second})
+	now := time.Now()
+
+	_, ok := tailer.dedupeRequestLogEvent("req_123", "line", now, false)
+	require.True(t, ok)
+
+	// A repeat after the window is shown again
+	output, ok := tailer.dedupeRequestLogEvent("req_123", "line", now.Add(6*time.Second), false)
+	require.True(t, ok)
+	require.Equal(t, "line", output)
+}
+
+func TestDedupeRequestLogEventNotLastLine(t *testing.T) {
+	tailer := New(&Config{DedupeWindow: 5 * time.Second})
+	now := time.Now()
+
+	tailer.dedupeRequestLogEvent("req_123", "line 1", now, true)
+	tailer.dedupeRequestLogEvent("req_456", "line 2", now, true)
+
+	// The repeated line isn't the last one anymore, so it's summarized
+	_, ok := tailer.dedupeRequestLogEvent("req_123", "line 1", now, true)
+	require.False(t, ok)
+	require.Equal(t, []string{"line 1 (x2)"}, tailer.repeatedEventsMessages(now.Add(time.Minute)))
+}
+
+func TestDedupeCacheBounded(t *testing.T) {
+	cache := newDedupeCache(time.Minute, 3)
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		cache.observe(fmt.Sprintf("req_%d", i), "line", now)
+	}
+	require.Equal(t, 3, cache.order.Len())
+	require.Len(t, cache.entries, 3)
+
+	_, repeated := cache.observe("req_0", "line", now)
+	require.False(t, repeated)
+	_, repeated = cache.observe("req_9", "line", now)
+	require.True(t, repeated)
+}
+
+func TestDedupeCacheEvictedRepeatsAreReported(t *testing.T) {
+	cache := newDedupeCache(time.Minute, 1)
+	now := time.Now()
+
+	cache.observe("req_1", "line 1", now)
+	cache.observe("req_1", "line 1", now)
+	cache.observe("req_2", "line 2", now)
+
+	pending := cache.expire(now)
+	require.Len(t, pending, 1)
+	require.Equal(t, "req_1", pending[0].requestID)
+	require.Equal(t, 2, pending[0].count)
+}
+
+func TestShutdownFlushesRepeatedEvents(t *testing.T) {
+	defer withInteractive(false)()
+
+	var stdout bytes.Buffer
+	tailer := New(&Config{ColorMode: "never", DedupeWindow: 5 * time.Second, Stdout: &stdout})
+
+	// The pending repeats are reported on the clock of the tailer
+	now := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	tailer.now = func() time.Time { return now }
+
+	require.NoError(t, tailer.printRequestLogEvent("req_123", "line", 200))
+	require.NoError(t, tailer.printRequestLogEvent("req_123", "line", 200))
+	now = now.Add(time.Second)
+
+	tailer.shutdown(make(chan struct{}))
+
+	require.Equal(t, "line\nline (x2)\n", stdout.String())
+}
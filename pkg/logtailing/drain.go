@@ -0,0 +1,149 @@
+package logtailing
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultDrainTimeout is how long shutdown waits for the request logs to be
+// written when cfg.DrainTimeout isn't set
+const defaultDrainTimeout = 3 * time.Second
+
+// handlerGroup tracks the request logs being handled for the websocket
+// clients, so that shutdown can wait for them. The request logs received once
+// it's closed are ignored.
+type handlerGroup struct {
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// begin returns true if the request log can be handled, in which case done
+// must be called once it's handled
+func (g *handlerGroup) begin() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return false
+	}
+
+	g.wg.Add(1)
+	return true
+}
+
+func (g *handlerGroup) done() {
+	g.wg.Done()
+}
+
+// close waits for the request logs being handled
+func (g *handlerGroup) close() {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	g.wg.Wait()
+}
+
+// drainTimeout returns cfg.DrainTimeout, or its default
+func (cfg *Config) drainTimeout() time.Duration {
+	if cfg.DrainTimeout == 0 {
+		return defaultDrainTimeout
+	}
+	return cfg.DrainTimeout
+}
+
+// drain waits for the request logs being handled and the queued ones to be
+// written, then flushes and closes the outputs. It gives up after
+// cfg.DrainTimeout, or when Ctrl+C is received again, in which case the
+// request logs that are still queued are dropped and the outputs are closed
+// right away, so that none is closed after it returns.
+func (tailer *Tailer) drain() {
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		tailer.drainOutputs()
+	}()
+
+	if tailer.awaitDrained(drained) {
+		return
+	}
+
+	if tailer.writer != nil {
+		tailer.writer.abort()
+	}
+
+	// The request log being written may still be blocking on an output, so
+	// the outputs are closed without waiting for drainOutputs
+	tailer.closeOutputs()
+}
+
+// awaitDrained returns true once drained is closed, or false if the drain
+// timed out or Ctrl+C was received again first
+func (tailer *Tailer) awaitDrained(drained chan struct{}) bool {
+	timeout := time.After(tailer.cfg.drainTimeout())
+
+	for {
+		select {
+		case <-drained:
+			return true
+		case <-timeout:
+			tailer.cfg.Log.Warnf("Stopped waiting for the request logs to be written after %s", tailer.cfg.drainTimeout())
+			return false
+		case sig := <-tailer.interruptCh:
+			if sig != os.Interrupt && sig != syscall.SIGTERM {
+				continue
+			}
+			tailer.cfg.Log.Warn("Interrupted while waiting for the request logs to be written")
+			return false
+		}
+	}
+}
+
+// drainOutputs waits for the request logs to go through the handlers, the
+// worker pool, the writer and the outputs that queue them, in that order,
+// then closes them
+func (tailer *Tailer) drainOutputs() {
+	tailer.handlers.close()
+	if tailer.pool != nil {
+		tailer.pool.close()
+	}
+
+	if tailer.writer != nil {
+		if dropped := tailer.writer.close(); dropped > 0 {
+			tailer.cfg.Log.Warnf("%d request logs were dropped because the output couldn't keep up", dropped)
+		}
+	}
+
+	tailer.closeOutputs()
+}
+
+// closeOutputs flushes and closes the sinks and the outputs that queue the
+// request logs, once, whether they were drained or drain gave up
+func (tailer *Tailer) closeOutputs() {
+	tailer.closeOutputsOnce.Do(func() {
+		if tailer.writer != nil {
+			tailer.sinks.close()
+		}
+
+		if tailer.recorder != nil {
+			if err := tailer.recorder.close(); err != nil {
+				tailer.cfg.Log.Warnf("Could not close the record file %s: %v", tailer.cfg.RecordFile, err)
+			}
+		}
+
+		if tailer.forwarder != nil {
+			if dropped := tailer.forwarder.close(); dropped > 0 {
+				tailer.cfg.Log.Warnf("%d request logs couldn't be forwarded to %s", dropped, tailer.cfg.ForwardURL)
+			}
+		}
+
+		if tailer.otlp != nil {
+			if dropped := tailer.otlp.close(); dropped > 0 {
+				tailer.cfg.Log.Warnf("%d request logs couldn't be exported to %s", dropped, tailer.cfg.OTLPEndpoint)
+			}
+		}
+	})
+}
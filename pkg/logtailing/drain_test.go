@@ -0,0 +1,198 @@
+package logtailing
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// slowSink takes delay to write each request log, or blocks until unblocked
+// if it's nil, and records the request IDs it wrote
+type slowSink struct {
+	delay   time.Duration
+	unblock chan struct{}
+
+	mu         sync.Mutex
+	requestIDs []string
+	closed     bool
+}
+
+func (s *slowSink) Write(event RenderedEvent) error {
+	if s.unblock != nil {
+		<-s.unblock
+	} else {
+		time.Sleep(s.delay)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requestIDs = append(s.requestIDs, event.RequestID)
+	return nil
+}
+
+func (s *slowSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return errors.New("already closed")
+}
+
+func (s *slowSink) written() ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requestIDs, s.closed
+}
+
+// startDrainTailer runs a tailer with the sink, and sends count request logs
+// once it's ready
+func startDrainTailer(t *testing.T, stripe *fakeStripe, sink Sink, drainTimeout time.Duration, count int) (*Tailer, *test.Hook, chan error) {
+	logger, hook := test.NewNullLogger()
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		DrainTimeout:     drainTimeout,
+		Key:              "sk_test_123",
+		Log:              logger,
+		NoSummary:        true,
+		RunDuration:      time.Hour,
+		Sinks:            []Sink{sink},
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	// The run duration is armed once the tailer is ready
+	ready := make(chan bool)
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		close(ready)
+		return nil
+	}
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the tailer to be ready")
+	}
+
+	for i := 0; i < count; i++ {
+		tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+			EventPayload: fmt.Sprintf(`{"request_id": "req_%d", "status": 200}`, i),
+			Type:         "request_log_event",
+		}})
+	}
+
+	return tailer, hook, done
+}
+
+func warnings(hook *test.Hook) []string {
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		if entry.Level <= log.WarnLevel {
+			messages = append(messages, entry.Message)
+		}
+	}
+	return messages
+}
+
+func TestRunDrainsSlowSink(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	sink := &slowSink{delay: 20 * time.Millisecond}
+	tailer, hook, done := startDrainTailer(t, stripe, sink, 5*time.Second, 5)
+
+	tailer.Stop()
+	requireReturns(t, done)
+
+	// The queued request logs were all written before Run returned, and the
+	// sink was closed after them
+	requestIDs, closed := sink.written()
+	require.Equal(t, []string{"req_0", "req_1", "req_2", "req_3", "req_4"}, requestIDs)
+	require.True(t, closed)
+
+	for _, warning := range warnings(hook) {
+		require.False(t, strings.Contains(warning, "Stopped waiting"), warning)
+	}
+	require.Contains(t, warnings(hook), "Could not close the sink: already closed")
+}
+
+func TestRunDrainTimeout(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	sink := &slowSink{unblock: make(chan struct{})}
+	defer close(sink.unblock)
+	tailer, hook, done := startDrainTailer(t, stripe, sink, 50*time.Millisecond, 3)
+
+	tailer.Stop()
+	requireReturns(t, done)
+
+	require.Contains(t, warnings(hook), "Stopped waiting for the request logs to be written after 50ms")
+
+	// The sink was closed before Run returned, even though it's still
+	// blocked writing a request log
+	_, closed := sink.written()
+	require.True(t, closed)
+}
+
+func TestRunDrainInterrupted(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	sink := &slowSink{unblock: make(chan struct{})}
+	defer close(sink.unblock)
+	tailer, hook, done := startDrainTailer(t, stripe, sink, time.Hour, 3)
+
+	// The first interrupt stops the tailer, the second one stops the drain
+	tailer.interruptCh <- os.Interrupt
+	tailer.interruptCh <- os.Interrupt
+	requireReturns(t, done)
+
+	require.Contains(t, warnings(hook), "Interrupted while waiting for the request logs to be written")
+
+	_, closed := sink.written()
+	require.True(t, closed)
+}
+
+func TestHandlerGroup(t *testing.T) {
+	var g handlerGroup
+
+	require.True(t, g.begin())
+
+	closed := make(chan struct{})
+	go func() {
+		g.close()
+		close(closed)
+	}()
+
+	// close waits for the request log being handled, and no new one is
+	// handled in the meantime. Polling with begin would handle one before
+	// close is called.
+	require.Eventually(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.closed
+	}, time.Second, time.Millisecond)
+	require.False(t, g.begin())
+	select {
+	case <-closed:
+		require.FailNow(t, "close returned while a request log was being handled")
+	default:
+	}
+
+	g.done()
+	<-closed
+}
@@ -0,0 +1,88 @@
+package logtailing
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventsBufferSize is the number of request logs buffered in the channel
+// returned by Events
+const eventsBufferSize = 1000
+
+// Event is a request log received by the channel returned by Events
+type Event struct {
+	EventPayload
+
+	// RawPayload is the JSON payload of the request log as it was received
+	RawPayload string
+
+	// RequestLogID is the `resp_` ID of the request log
+	RequestLogID string
+
+	// Label is the label of the key the request log was received with, when
+	// several keys are tailed or the key has a label
+	Label string
+
+	// ReceivedAt is when the request log was received
+	ReceivedAt time.Time
+}
+
+// eventStream is the channel returned by Events. Sending never blocks: the
+// request logs are dropped while the channel is full.
+type eventStream struct {
+	// mu guards closed, so that nothing is sent on the channel once it's
+	// closed, as request logs can still be handled while shutting down
+	mu     sync.RWMutex
+	ch     chan Event
+	closed bool
+
+	// dropped is the number of request logs dropped because the channel
+	// was full. It must be accessed atomically.
+	dropped uint64
+}
+
+func newEventStream(size int) *eventStream {
+	return &eventStream{ch: make(chan Event, size)}
+}
+
+// send sends a request log on the channel, unless it's full or closed
+func (s *eventStream) send(event Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- event:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// close closes the channel. It returns the number of request logs dropped.
+func (s *eventStream) close() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Events returns a channel receiving every request log matching the filters,
+// when cfg.EmitEvents is set, and nil otherwise. The channel buffers up to
+// 1000 request logs, and the newer ones are dropped while it's full, so that
+// a slow consumer never stalls the tailer. It's closed when Run or Replay
+// returns.
+func (tailer *Tailer) Events() <-chan Event {
+	if tailer.events == nil {
+		return nil
+	}
+	return tailer.events.ch
+}
@@ -0,0 +1,105 @@
+package logtailing
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	tailer, stdout, _ := newReplayTailer(&Config{ReplayNoDelay: true, OnlyErrors: true, EmitEvents: true})
+
+	require.NoError(t, tailer.Replay(strings.NewReader(replayInput)))
+
+	var events []Event
+	for event := range tailer.Events() {
+		events = append(events, event)
+	}
+
+	require.Len(t, events, 2)
+	require.Equal(t, "req_2", events[0].RequestID)
+	require.Equal(t, 402, events[0].Status)
+	require.Equal(t, "resp_2", events[0].RequestLogID)
+	require.Equal(t, `{"request_id":"req_2","status":402}`, events[0].RawPayload)
+	require.Equal(t, "req_3", events[1].RequestID)
+	require.Equal(t, "", events[1].RequestLogID)
+
+	// The request logs are still printed unless NoStdout is set
+	require.Contains(t, stdout.String(), "req_2")
+}
+
+func TestEventsNoStdout(t *testing.T) {
+	tailer, stdout, _ := newReplayTailer(&Config{ReplayNoDelay: true, EmitEvents: true, NoStdout: true})
+
+	require.NoError(t, tailer.Replay(strings.NewReader(replayInput)))
+
+	count := 0
+	for range tailer.Events() {
+		count++
+	}
+
+	require.Equal(t, 3, count)
+	require.Empty(t, stdout.String())
+}
+
+func TestEventsDisabled(t *testing.T) {
+	tailer, _, _ := newReplayTailer(&Config{})
+
+	require.Nil(t, tailer.Events())
+}
+
+func TestEventStreamDropsWhenFull(t *testing.T) {
+	stream := newEventStream(2)
+
+	for i := 0; i < 5; i++ {
+		stream.send(Event{RequestLogID: strings.Repeat("x", i)})
+	}
+
+	require.Equal(t, uint64(3), stream.close())
+
+	var ids []string
+	for event := range stream.ch {
+		ids = append(ids, event.RequestLogID)
+	}
+	require.Equal(t, []string{"", "x"}, ids)
+
+	// Sending after closing is a no-op rather than a panic
+	stream.send(Event{})
+	require.Equal(t, uint64(3), stream.close())
+}
+
+func TestEventsClosedWhenRunReturns(t *testing.T) {
+	stripe := newFakeStripe(t, 1)
+	defer stripe.close()
+
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		EmitEvents:       true,
+		Key:              "sk_test_123",
+		NoStdout:         true,
+		Quiet:            true,
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case event := <-tailer.Events():
+		require.Equal(t, "req_0", event.RequestID)
+		require.Equal(t, "resp_0", event.RequestLogID)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for a request log")
+	}
+
+	tailer.Stop()
+	requireReturns(t, done)
+
+	_, open := <-tailer.Events()
+	require.False(t, open)
+}
@@ -0,0 +1,105 @@
+package logtailing
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	// failOn4xx fails on client errors (4xx status codes)
+	failOn4xx = "4xx"
+
+	// failOn5xx fails on server errors (5xx status codes)
+	failOn5xx = "5xx"
+
+	// failOnAnyError fails on client and server errors (status codes 400 and
+	// above)
+	failOnAnyError = "any-error"
+)
+
+// failOnValues are the acceptable values of cfg.FailOn
+var failOnValues = []string{failOn5xx, failOn4xx, failOnAnyError}
+
+// FailOnError is returned by Run and Replay when request logs matching
+// cfg.FailOn were received during the session, e.g. to fail a CI pipeline.
+// The session still ends normally: the request logs are written and the
+// outputs are closed.
+type FailOnError struct {
+	// FailOn is the cfg.FailOn of the session, e.g. 5xx
+	FailOn string
+
+	// Count is the number of request logs matching FailOn
+	Count int
+
+	// FirstRequestID is the request ID of the first request log matching
+	// FailOn
+	FirstRequestID string
+}
+
+func (e *FailOnError) Error() string {
+	return fmt.Sprintf("%d request logs matching --fail-on %s were received, the first one for request %s", e.Count, e.FailOn, e.FirstRequestID)
+}
+
+// failOnTracker tracks the request logs matching cfg.FailOn. The websocket
+// client handles request logs concurrently, so it's guarded by a mutex.
+type failOnTracker struct {
+	failOn string
+
+	mu             sync.Mutex
+	count          int
+	firstRequestID string
+}
+
+func newFailOnTracker(failOn string) *failOnTracker {
+	return &failOnTracker{failOn: failOn}
+}
+
+// matches returns true if the status code matches the tracker's cfg.FailOn
+func (t *failOnTracker) matches(status int) bool {
+	switch t.failOn {
+	case failOn4xx:
+		return status >= 400 && status < 500
+	case failOn5xx:
+		return status >= 500 && status < 600
+	case failOnAnyError:
+		return status >= 400
+	default:
+		return false
+	}
+}
+
+// record counts the request log if it matches cfg.FailOn
+func (t *failOnTracker) record(payload *EventPayload) {
+	if !t.matches(payload.Status) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.count++
+	if t.count == 1 {
+		t.firstRequestID = payload.RequestID
+	}
+}
+
+// err returns a *FailOnError if request logs matching cfg.FailOn were
+// received, and nil otherwise
+func (t *failOnTracker) err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.count == 0 {
+		return nil
+	}
+
+	return &FailOnError{FailOn: t.failOn, Count: t.count, FirstRequestID: t.firstRequestID}
+}
+
+// failOnError returns the *FailOnError of the session, if any
+func (tailer *Tailer) failOnError() error {
+	if tailer.failOn == nil {
+		return nil
+	}
+	return tailer.failOn.err()
+}
@@ -0,0 +1,138 @@
+package logtailing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailOnTracker(t *testing.T) {
+	tests := []struct {
+		failOn  string
+		count   int
+		firstID string
+	}{
+		{failOn4xx, 2, "req_402"},
+		{failOn5xx, 1, "req_500"},
+		{failOnAnyError, 3, "req_402"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.failOn, func(t *testing.T) {
+			tracker := newFailOnTracker(tt.failOn)
+			for _, status := range []int{200, 402, 302, 500, 404} {
+				tracker.record(&EventPayload{Status: status, RequestID: fmt.Sprintf("req_%d", status)})
+			}
+
+			require.Equal(t, &FailOnError{FailOn: tt.failOn, Count: tt.count, FirstRequestID: tt.firstID}, tracker.err())
+		})
+	}
+
+	require.NoError(t, newFailOnTracker(failOn5xx).err())
+}
+
+func TestFailOnErrorMessage(t *testing.T) {
+	err := &FailOnError{FailOn: failOn5xx, Count: 3, FirstRequestID: "req_123"}
+	require.EqualError(t, err, "3 request logs matching --fail-on 5xx were received, the first one for request req_123")
+}
+
+func TestReplayFailOn(t *testing.T) {
+	tailer, stdout, _ := newReplayTailer(&Config{ReplayNoDelay: true, FailOn: failOnAnyError})
+
+	err := tailer.Replay(strings.NewReader(replayInput))
+
+	require.Equal(t, &FailOnError{FailOn: failOnAnyError, Count: 2, FirstRequestID: "req_2"}, err)
+	// The request logs are still all written
+	require.Equal(t, 3, strings.Count(stdout.String(), "\n"))
+}
+
+func TestRunFailOnWithLimit(t *testing.T) {
+	stripe := newFakeStripeStatuses(t, 500, 502, 503, 504)
+	defer stripe.close()
+
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		EventLimit:       2,
+		FailOn:           failOn5xx,
+		Key:              "sk_test_123",
+		Quiet:            true,
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case err := <-done:
+		// The request logs past the limit aren't counted. The websocket
+		// client handles them concurrently, so which one is first varies.
+		failOnErr, ok := err.(*FailOnError)
+		require.True(t, ok, "expected a *FailOnError, got %v", err)
+		require.Equal(t, 2, failOnErr.Count)
+		require.Regexp(t, `^req_[0-3]$`, failOnErr.FirstRequestID)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the tailer to stop")
+	}
+}
+
+func TestRunFailOnWithTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		err      error
+	}{
+		{"matching", []int{200, 404}, &FailOnError{FailOn: failOn4xx, Count: 1, FirstRequestID: "req_1"}},
+		{"not matching", []int{200, 500}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripe := newFakeStripeStatuses(t, tt.statuses...)
+			defer stripe.close()
+
+			received := make(channelSink, len(tt.statuses))
+			tailer := New(&Config{
+				APIBaseURL:       stripe.api.URL,
+				FailOn:           failOn4xx,
+				Key:              "sk_test_123",
+				Quiet:            true,
+				RunDuration:      time.Minute,
+				Sinks:            []Sink{received},
+				Stderr:           ioutil.Discard,
+				Stdout:           ioutil.Discard,
+				WebSocketFeature: "request_logs",
+			})
+
+			deadline := make(chan time.Time)
+			tailer.after = func(d time.Duration) <-chan time.Time {
+				return deadline
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- tailer.Run() }()
+
+			for range tt.statuses {
+				select {
+				case <-received:
+				case <-time.After(5 * time.Second):
+					require.FailNow(t, "Timed out waiting for the request logs")
+				}
+			}
+
+			close(deadline)
+
+			select {
+			case err := <-done:
+				require.Equal(t, tt.err, err)
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "Timed out waiting for the tailer to stop")
+			}
+		})
+	}
+}
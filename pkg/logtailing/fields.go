@@ -0,0 +1,85 @@
+package logtailing
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// extractFields returns the values at the dotted paths (e.g. `error.code`) of
+// a raw JSON payload. Segments that are numbers index into arrays, e.g.
+// `lines.0.amount`. Missing paths render as empty strings, strings as is,
+// numbers as they're formatted in the payload, and objects and arrays as
+// compact JSON. All the values are empty if the payload isn't valid JSON.
+func extractFields(rawPayload string, paths []string) []string {
+	values := make([]string, len(paths))
+
+	decoder := json.NewDecoder(strings.NewReader(rawPayload))
+	decoder.UseNumber()
+
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return values
+	}
+
+	for i, path := range paths {
+		if value, ok := lookupField(root, path); ok {
+			values[i] = fieldString(value)
+		}
+	}
+
+	return values
+}
+
+// fieldsLine renders the values at the paths of a raw JSON payload separated
+// by tabs.
+func fieldsLine(rawPayload string, paths []string) string {
+	return strings.Join(extractFields(rawPayload, paths), "\t")
+}
+
+// lookupField returns the value at the dotted path, and false if the path
+// doesn't exist.
+func lookupField(value interface{}, path string) (interface{}, bool) {
+	for _, segment := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+func fieldString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+
+		// Values decoded from JSON can always be encoded back
+		encoder.Encode(v) // #nosec G104
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+}
@@ -0,0 +1,52 @@
+package logtailing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const fieldsPayload = `{
+  "status": 402,
+  "amount": 1000,
+  "fee": 1.50,
+  "livemode": false,
+  "url": "/v1/charges",
+  "error": {"code": "card_declined", "param": null},
+  "lines": [{"id": "li_1"}, {"id": "li_2"}],
+  "tags": ["a", "<b>"]
+}`
+
+func TestExtractFields(t *testing.T) {
+	require.Equal(t,
+		[]string{"402", "/v1/charges", "card_declined", "false"},
+		extractFields(fieldsPayload, []string{"status", "url", "error.code", "livemode"}),
+	)
+}
+
+func TestExtractFieldsNumbers(t *testing.T) {
+	// Numbers are rendered as they're formatted in the payload
+	require.Equal(t, []string{"1000", "1.50"}, extractFields(fieldsPayload, []string{"amount", "fee"}))
+}
+
+func TestExtractFieldsMissing(t *testing.T) {
+	require.Equal(t,
+		[]string{"", "", "", ""},
+		extractFields(fieldsPayload, []string{"missing", "error.missing", "url.path", "error.param"}),
+	)
+}
+
+func TestExtractFieldsArraysAndObjects(t *testing.T) {
+	require.Equal(t,
+		[]string{`["a","<b>"]`, `{"id":"li_2"}`, "li_1", "", `{"code":"card_declined","param":null}`},
+		extractFields(fieldsPayload, []string{"tags", "lines.1", "lines.0.id", "lines.2", "error"}),
+	)
+}
+
+func TestExtractFieldsMalformed(t *testing.T) {
+	require.Equal(t, []string{"", ""}, extractFields(`{"status": 200`, []string{"status", "url"}))
+}
+
+func TestFieldsLine(t *testing.T) {
+	require.Equal(t, "402\t\tcard_declined", fieldsLine(fieldsPayload, []string{"status", "missing", "error.code"}))
+}
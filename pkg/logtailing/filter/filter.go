@@ -0,0 +1,401 @@
+// Package filter implements a small expression language to filter request
+// logs, e.g. `status>=500 and method=POST and path~=/v1/charges`.
+//
+// Expressions are made of comparisons between a field and a value, combined
+// with `and`, `or`, `not` (or `&&`, `||`, `!`) and parentheses. The supported
+// operators are `=`, `!=`, `<`, `<=`, `>`, `>=` and `~=`, which matches a
+// regular expression.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// Public types
+//
+
+// FieldKind is the type of a field, which determines how values are parsed
+// and compared.
+type FieldKind int
+
+const (
+	// String fields are compared exactly.
+	String FieldKind = iota
+
+	// FoldedString fields are compared case-insensitively.
+	FoldedString
+
+	// Number fields hold integers.
+	Number
+
+	// Time fields hold timestamps. Values are either RFC3339 timestamps, unix
+	// timestamps, or durations relative to when the expression was compiled.
+	Time
+)
+
+// Fields describes the fields that can be used in an expression.
+type Fields map[string]FieldKind
+
+// Event is what expressions are evaluated against.
+type Event interface {
+	// Lookup returns the value of a field: a string for String and
+	// FoldedString fields, an int for Number fields and a time.Time for Time
+	// fields. ok is false if the event doesn't have a value for the field.
+	Lookup(field string) (value interface{}, ok bool)
+}
+
+// Expression is a compiled filter expression.
+type Expression struct {
+	source string
+	root   node
+}
+
+// SyntaxError is returned when compiling an invalid expression.
+type SyntaxError struct {
+	Expr string
+	Pos  int
+	Msg  string
+}
+
+// Error returns the error message, followed by the expression and a caret
+// pointing at the position of the error.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter expression: %s\n  %s\n  %s^", e.Msg, e.Expr, strings.Repeat(" ", e.Pos))
+}
+
+//
+// Public functions
+//
+
+// Compile parses an expression using the given fields.
+func Compile(expr string, fields Fields) (*Expression, error) {
+	return compile(expr, fields, time.Now())
+}
+
+// Match returns true if the event matches the expression.
+func (e *Expression) Match(evt Event) bool {
+	return e.root.eval(evt)
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+//
+// Private types
+//
+
+type node interface {
+	eval(Event) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n *andNode) eval(evt Event) bool { return n.left.eval(evt) && n.right.eval(evt) }
+
+type orNode struct{ left, right node }
+
+func (n *orNode) eval(evt Event) bool { return n.left.eval(evt) || n.right.eval(evt) }
+
+type notNode struct{ operand node }
+
+func (n *notNode) eval(evt Event) bool { return !n.operand.eval(evt) }
+
+// comparisonNode compares a field with a literal value parsed according to
+// the kind of the field.
+type comparisonNode struct {
+	field string
+	kind  FieldKind
+	op    string
+
+	str    string
+	num    int
+	time   time.Time
+	regexp *regexp.Regexp
+}
+
+func (n *comparisonNode) eval(evt Event) bool {
+	value, ok := evt.Lookup(n.field)
+	if !ok {
+		// A missing field only satisfies inequality
+		return n.op == "!="
+	}
+
+	switch v := value.(type) {
+	case string:
+		switch n.op {
+		case "~=":
+			return n.regexp.MatchString(v)
+		case "=":
+			return n.equalStrings(v)
+		case "!=":
+			return !n.equalStrings(v)
+		}
+	case int:
+		return compareInts(v, n.op, n.num)
+	case time.Time:
+		return compareTimes(v, n.op, n.time)
+	}
+
+	return false
+}
+
+func (n *comparisonNode) equalStrings(value string) bool {
+	if n.kind == FoldedString {
+		return strings.EqualFold(value, n.str)
+	}
+
+	return value == n.str
+}
+
+type parser struct {
+	expr   string
+	fields Fields
+	now    time.Time
+	tokens []token
+	pos    int
+}
+
+//
+// Private functions
+//
+
+func compile(expr string, fields Fields, now time.Time) (*Expression, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{expr: expr, fields: fields, now: now, tokens: tokens}
+
+	if p.peek().typ == tokenEOF {
+		return nil, newSyntaxError(expr, 0, "empty expression")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.typ != tokenEOF {
+		return nil, newSyntaxError(expr, tok.pos, "unexpected %s, expected `and` or `or`", describe(tok))
+	}
+
+	return &Expression{source: expr, root: root}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.typ != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().typ == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().typ == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().typ == tokenNot {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.typ {
+	case tokenLeftParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.typ != tokenRightParen {
+			return nil, newSyntaxError(p.expr, closing.pos, "unexpected %s, expected `)`", describe(closing))
+		}
+		return inner, nil
+	case tokenWord:
+		return p.parseComparison(tok)
+	default:
+		return nil, newSyntaxError(p.expr, tok.pos, "unexpected %s, expected a field name", describe(tok))
+	}
+}
+
+func (p *parser) parseComparison(fieldTok token) (node, error) {
+	field := strings.ToLower(fieldTok.value)
+	kind, ok := p.fields[field]
+	if !ok {
+		return nil, newSyntaxError(p.expr, fieldTok.pos, "unknown field %q (%s)", fieldTok.value, p.fieldNames())
+	}
+
+	opTok := p.next()
+	if opTok.typ != tokenOperator {
+		return nil, newSyntaxError(p.expr, opTok.pos, "unexpected %s, expected an operator after %q", describe(opTok), fieldTok.value)
+	}
+
+	valueTok := p.next()
+	if valueTok.typ != tokenWord && valueTok.typ != tokenString {
+		return nil, newSyntaxError(p.expr, valueTok.pos, "unexpected %s, expected a value", describe(valueTok))
+	}
+
+	n := &comparisonNode{field: field, kind: kind, op: opTok.value}
+
+	switch kind {
+	case String, FoldedString:
+		switch opTok.value {
+		case "=", "!=":
+			n.str = valueTok.value
+		case "~=":
+			re, err := regexp.Compile(valueTok.value)
+			if err != nil {
+				return nil, newSyntaxError(p.expr, valueTok.pos, "invalid regular expression: %v", err)
+			}
+			n.regexp = re
+		default:
+			return nil, newSyntaxError(p.expr, opTok.pos, "operator %s can't be used with field %q", opTok.value, field)
+		}
+	case Number:
+		if opTok.value == "~=" {
+			return nil, newSyntaxError(p.expr, opTok.pos, "operator ~= can't be used with field %q", field)
+		}
+		num, err := strconv.Atoi(valueTok.value)
+		if err != nil {
+			return nil, newSyntaxError(p.expr, valueTok.pos, "field %q expects a number", field)
+		}
+		n.num = num
+	case Time:
+		if opTok.value == "~=" {
+			return nil, newSyntaxError(p.expr, opTok.pos, "operator ~= can't be used with field %q", field)
+		}
+		t, err := parseTime(valueTok.value, p.now)
+		if err != nil {
+			return nil, newSyntaxError(p.expr, valueTok.pos, "field %q expects an RFC3339 timestamp, a unix timestamp or a duration", field)
+		}
+		n.time = t
+	}
+
+	return n, nil
+}
+
+func (p *parser) fieldNames() string {
+	names := make([]string, 0, len(p.fields))
+	for name := range p.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return now.Add(-d), nil
+}
+
+func compareInts(a int, op string, b int) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	default:
+		return false
+	}
+}
+
+func compareTimes(a time.Time, op string, b time.Time) bool {
+	switch {
+	case a.Before(b):
+		return compareInts(-1, op, 0)
+	case a.After(b):
+		return compareInts(1, op, 0)
+	default:
+		return compareInts(0, op, 0)
+	}
+}
+
+func describe(tok token) string {
+	switch tok.typ {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return fmt.Sprintf("string %q", tok.value)
+	default:
+		return fmt.Sprintf("`%s`", tok.value)
+	}
+}
+
+func newSyntaxError(expr string, pos int, format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{Expr: expr, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
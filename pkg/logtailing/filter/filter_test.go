@@ -0,0 +1,143 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testFields = Fields{
+	"created":    Time,
+	"method":     FoldedString,
+	"path":       String,
+	"request_id": String,
+	"status":     Number,
+}
+
+type testEvent map[string]interface{}
+
+func (evt testEvent) Lookup(field string) (interface{}, bool) {
+	value, ok := evt[field]
+	return value, ok
+}
+
+func mustCompile(t *testing.T, expr string) *Expression {
+	e, err := Compile(expr, testFields)
+	require.Nil(t, err)
+	return e
+}
+
+func TestMatchComparisons(t *testing.T) {
+	evt := testEvent{
+		"created":    time.Unix(1500, 0),
+		"method":     "POST",
+		"path":       "/v1/charges/ch_123/refund",
+		"request_id": "req_123",
+		"status":     502,
+	}
+
+	matching := []string{
+		"status=502",
+		"status>=500",
+		"status>500",
+		"status<503",
+		"status<=502",
+		"status!=200",
+		"method=post",
+		"method!=GET",
+		"path~=/v1/charges",
+		"path~=^/v1/charges/ch_[0-9]+/refund$",
+		"request_id=req_123",
+		`request_id="req_123"`,
+		"created>=1000",
+		"created<2000",
+		"created=1500",
+	}
+	for _, expr := range matching {
+		require.True(t, mustCompile(t, expr).Match(evt), expr)
+	}
+
+	notMatching := []string{
+		"status=500",
+		"status<500",
+		"method=GET",
+		"path~=^/v1/refunds",
+		"request_id=REQ_123",
+		"created>2000",
+	}
+	for _, expr := range notMatching {
+		require.False(t, mustCompile(t, expr).Match(evt), expr)
+	}
+}
+
+func TestMatchBooleanOperators(t *testing.T) {
+	evt := testEvent{"method": "POST", "path": "/v1/charges", "status": 500}
+
+	require.True(t, mustCompile(t, "status>=500 and method=POST and path~=/v1/charges").Match(evt))
+	require.True(t, mustCompile(t, "status>=500 && method=POST").Match(evt))
+	require.False(t, mustCompile(t, "status>=500 AND method=GET").Match(evt))
+	require.True(t, mustCompile(t, "method=GET or status=500").Match(evt))
+	require.True(t, mustCompile(t, "method=GET || status=500").Match(evt))
+	require.False(t, mustCompile(t, "not status=500").Match(evt))
+	require.False(t, mustCompile(t, "!status=500").Match(evt))
+	require.True(t, mustCompile(t, "!(method=GET or path~=refunds)").Match(evt))
+
+	// and binds tighter than or
+	require.True(t, mustCompile(t, "method=GET and status=200 or status=500").Match(evt))
+	require.False(t, mustCompile(t, "method=GET and (status=200 or status=500)").Match(evt))
+}
+
+func TestMatchMissingField(t *testing.T) {
+	evt := testEvent{"status": 200}
+
+	require.False(t, mustCompile(t, "request_id=req_123").Match(evt))
+	require.True(t, mustCompile(t, "request_id!=req_123").Match(evt))
+	require.False(t, mustCompile(t, "created>0").Match(evt))
+}
+
+func TestCompileRelativeTime(t *testing.T) {
+	now := time.Unix(10000, 0)
+	e, err := compile("created>=5m", testFields, now)
+	require.Nil(t, err)
+
+	require.True(t, e.Match(testEvent{"created": time.Unix(9800, 0)}))
+	require.False(t, e.Match(testEvent{"created": time.Unix(9600, 0)}))
+}
+
+func TestCompileSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{"", 0, "empty expression"},
+		{"status", 6, "unexpected end of expression, expected an operator after \"status\""},
+		{"status>=", 8, "unexpected end of expression, expected a value"},
+		{"status>=abc", 8, "field \"status\" expects a number"},
+		{"statuz=200", 0, "unknown field \"statuz\" (created, method, path, request_id, status)"},
+		{"status=200 method=GET", 11, "unexpected `method`, expected `and` or `or`"},
+		{"(status=200", 11, "unexpected end of expression, expected `)`"},
+		{"status=200 and", 14, "unexpected end of expression, expected a field name"},
+		{"method>GET", 6, "operator > can't be used with field \"method\""},
+		{"status~=5", 6, "operator ~= can't be used with field \"status\""},
+		{`path~="("`, 6, "invalid regular expression: error parsing regexp: missing closing ): `(`"},
+		{"path='/v1", 5, "unterminated string"},
+		{"created>yesterday", 8, "field \"created\" expects an RFC3339 timestamp, a unix timestamp or a duration"},
+	}
+
+	for _, test := range tests {
+		_, err := Compile(test.expr, testFields)
+		require.NotNil(t, err, test.expr)
+
+		syntaxErr, ok := err.(*SyntaxError)
+		require.True(t, ok, test.expr)
+		require.Equal(t, test.pos, syntaxErr.Pos, test.expr)
+		require.Equal(t, test.msg, syntaxErr.Msg, test.expr)
+	}
+}
+
+func TestSyntaxErrorCaret(t *testing.T) {
+	_, err := Compile("status>=abc", testFields)
+	require.EqualError(t, err, "invalid filter expression: field \"status\" expects a number\n  status>=abc\n          ^")
+}
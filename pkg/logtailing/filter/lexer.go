@@ -0,0 +1,143 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLeftParen
+	tokenRightParen
+)
+
+// token is a lexical token of a filter expression. pos is the byte offset of
+// the token in the expression, used to point at syntax errors.
+type token struct {
+	typ   tokenType
+	value string
+	pos   int
+}
+
+// operators are the comparison operators, longest first so that the lexer
+// can match them greedily.
+var operators = []string{"!=", ">=", "<=", "~=", "=", ">", "<"}
+
+// tokenize splits a filter expression into tokens.
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+
+	for pos := 0; pos < len(expr); {
+		c := rune(expr[pos])
+
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+		case c == '(':
+			tokens = append(tokens, token{typ: tokenLeftParen, value: "(", pos: pos})
+			pos++
+		case c == ')':
+			tokens = append(tokens, token{typ: tokenRightParen, value: ")", pos: pos})
+			pos++
+		case c == '"' || c == '\'':
+			value, end, err := readString(expr, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{typ: tokenString, value: value, pos: pos})
+			pos = end
+		case strings.HasPrefix(expr[pos:], "&&"):
+			tokens = append(tokens, token{typ: tokenAnd, value: "&&", pos: pos})
+			pos += 2
+		case strings.HasPrefix(expr[pos:], "||"):
+			tokens = append(tokens, token{typ: tokenOr, value: "||", pos: pos})
+			pos += 2
+		default:
+			if op := readOperator(expr[pos:]); op != "" {
+				tokens = append(tokens, token{typ: tokenOperator, value: op, pos: pos})
+				pos += len(op)
+				continue
+			}
+
+			if c == '!' {
+				tokens = append(tokens, token{typ: tokenNot, value: "!", pos: pos})
+				pos++
+				continue
+			}
+
+			start := pos
+			for pos < len(expr) && !isDelimiter(expr[pos]) {
+				pos++
+			}
+			if start == pos {
+				return nil, newSyntaxError(expr, start, "unexpected character %q", expr[start])
+			}
+
+			word := expr[start:pos]
+			switch strings.ToLower(word) {
+			case "and":
+				tokens = append(tokens, token{typ: tokenAnd, value: word, pos: start})
+			case "or":
+				tokens = append(tokens, token{typ: tokenOr, value: word, pos: start})
+			case "not":
+				tokens = append(tokens, token{typ: tokenNot, value: word, pos: start})
+			default:
+				tokens = append(tokens, token{typ: tokenWord, value: word, pos: start})
+			}
+		}
+	}
+
+	tokens = append(tokens, token{typ: tokenEOF, pos: len(expr)})
+	return tokens, nil
+}
+
+// readString reads a quoted string starting at pos. Backslashes escape the
+// following character. It returns the unquoted value and the position right
+// after the closing quote.
+func readString(expr string, pos int) (string, int, error) {
+	quote := expr[pos]
+	var sb strings.Builder
+
+	for i := pos + 1; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			if i+1 < len(expr) {
+				i++
+				sb.WriteByte(expr[i])
+			}
+		case quote:
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(expr[i])
+		}
+	}
+
+	return "", 0, newSyntaxError(expr, pos, "unterminated string")
+}
+
+func readOperator(s string) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+
+	return ""
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '(', ')', '"', '\'', '=', '!', '<', '>', '~', '&', '|':
+		return true
+	default:
+		return false
+	}
+}
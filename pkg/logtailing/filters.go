@@ -0,0 +1,565 @@
+package logtailing
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/logtailing/filter"
+)
+
+// platformAccount is the account filter value matching requests made by the
+// platform itself rather than on behalf of a connected account.
+const platformAccount = "platform"
+
+// excludedReportInterval is the interval at which the number of excluded
+// request logs is logged.
+const excludedReportInterval = time.Minute
+
+// ParseTimeFilter parses the value of a time filter, which can either be an
+// RFC3339 timestamp or a duration (e.g. 5m) relative to now.
+func ParseTimeFilter(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%s is not a valid time filter (an RFC3339 timestamp like 2019-10-01T15:04:05Z or a duration like 5m)", value)
+}
+
+// hasLocalFilters returns true if any of the filters applied by the tailer is
+// set.
+func (cfg *Config) hasLocalFilters() bool {
+	return !reflect.DeepEqual(cfg.filterPreset(), FilterPreset{}) ||
+		!cfg.FilterSince.IsZero() ||
+		!cfg.FilterUntil.IsZero()
+}
+
+// compileFilters prepares the filters that are expensive to evaluate so that
+// they don't need to be recompiled for every event.
+func (tailer *Tailer) compileFilters() error {
+	tailer.ipNets = make(map[string]*net.IPNet)
+	for _, address := range tailer.cfg.FilterIPAddress {
+		address, _ = parseFilterValue(address)
+		ipNet, err := parseIPNet(address)
+		if err != nil {
+			return err
+		}
+		tailer.ipNets[address] = ipNet
+	}
+
+	if tailer.cfg.FilterExpression != "" {
+		expression, err := filter.Compile(tailer.cfg.FilterExpression, expressionFields)
+		if err != nil {
+			return err
+		}
+		tailer.expression = expression
+	}
+
+	if tailer.cfg.FilterURLRegex != "" {
+		re, err := regexp.Compile(tailer.cfg.FilterURLRegex)
+		if err != nil {
+			return fmt.Errorf("invalid URL filter regular expression: %v", err)
+		}
+		tailer.urlRegexp = re
+	}
+
+	return nil
+}
+
+// filterRawRequestLogEvent returns true if the event should be hidden based on
+// the filters that apply to the raw payload, before it's unmarshalled.
+func (tailer *Tailer) filterRawRequestLogEvent(rawPayload string) bool {
+	if len(tailer.cfg.FilterText) == 0 {
+		return false
+	}
+
+	lowerPayload := strings.ToLower(rawPayload)
+	for _, term := range tailer.cfg.FilterText {
+		// Unlike the other filters, all the terms must match, so a negated
+		// term simply hides the payloads containing it
+		term, negated := parseFilterValue(term)
+		if strings.Contains(lowerPayload, strings.ToLower(term)) == negated {
+			tailer.cfg.Log.WithFields(log.Fields{
+				"prefix":  "logs.Tailer.filterRawRequestLogEvent",
+				"term":    term,
+				"negated": negated,
+			}).Debug("Received event not matching the search term, ignoring")
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterRequestLogEvent returns true if the event should be hidden because it
+// doesn't match the client-side filters of the config.
+func (tailer *Tailer) filterRequestLogEvent(payload *EventPayload) bool {
+	if tailer.cfg.OnlyErrors && payload.Status < 400 {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.filterRequestLogEvent",
+			"status": payload.Status,
+		}).Debug("Received event for a successful request, ignoring")
+		return true
+	}
+
+	if !tailer.matchesStatus(payload.Status) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.filterRequestLogEvent",
+			"status": payload.Status,
+		}).Debug("Received event with non-matching status code, ignoring")
+		return true
+	}
+
+	if _, ok := payload.ConnectedAccount(); (tailer.cfg.RequireConnectedAccount && !ok) || (tailer.cfg.ExcludeConnectedAccount && ok) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":  "logs.Tailer.filterRequestLogEvent",
+			"account": payload.Account,
+		}).Debug("Received event with non-matching connected account presence, ignoring")
+		return true
+	}
+
+	if accounts := (matcher{tailer.cfg.FilterAccount, matchEqual}); accounts.Active() && !accounts.Matches(accountForFilter(payload)) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":  "logs.Tailer.filterRequestLogEvent",
+			"account": payload.Account,
+		}).Debug("Received event with non-matching account, ignoring")
+		return true
+	}
+
+	if apiVersions := (matcher{tailer.cfg.FilterAPIVersion, matchEqual}); apiVersions.Active() && !apiVersions.Matches(payload.APIVersion) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":      "logs.Tailer.filterRequestLogEvent",
+			"api_version": payload.APIVersion,
+		}).Debug("Received event with non-matching API version, ignoring")
+		return true
+	}
+
+	if errorCodes := (matcher{tailer.cfg.FilterErrorCode, matchEqual}); errorCodes.Active() && !matchesError(errorCodes, payload.Status, payload.Error.Code) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.filterRequestLogEvent",
+			"status":     payload.Status,
+			"error_code": payload.Error.Code,
+		}).Debug("Received event with non-matching error code, ignoring")
+		return true
+	}
+
+	if errorTypes := (matcher{tailer.cfg.FilterErrorType, strings.EqualFold}); errorTypes.Active() && !matchesError(errorTypes, payload.Status, payload.Error.Type) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.filterRequestLogEvent",
+			"status":     payload.Status,
+			"error_type": payload.Error.Type,
+		}).Debug("Received event with non-matching error type, ignoring")
+		return true
+	}
+
+	if methods := (matcher{tailer.cfg.FilterHTTPMethods, strings.EqualFold}); methods.Active() && !methods.Matches(payload.Method) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.filterRequestLogEvent",
+			"method": payload.Method,
+		}).Debug("Received event with non-matching HTTP method, ignoring")
+		return true
+	}
+
+	if addresses := (matcher{tailer.cfg.FilterIPAddress, tailer.matchIP}); addresses.Active() && !addresses.Matches(payload.IPAddress) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.filterRequestLogEvent",
+			"ip_address": payload.IPAddress,
+		}).Debug("Received event with non-matching IP address, ignoring")
+		return true
+	}
+
+	if tailer.cfg.FilterLivemode != nil && (payload.Livemode == nil || *payload.Livemode != *tailer.cfg.FilterLivemode) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.filterRequestLogEvent",
+		}).Debug("Received event with non-matching livemode, ignoring")
+		return true
+	}
+
+	if paths := (matcher{tailer.cfg.FilterRequestPaths, matchPathPrefix}); paths.Active() && !paths.Matches(payload.URL) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.filterRequestLogEvent",
+			"url":    payload.URL,
+		}).Debug("Received event with non-matching request path, ignoring")
+		return true
+	}
+
+	if resources := (matcher{tailer.cfg.FilterResources, matchResource}); resources.Active() && !resources.Matches(payload.URL) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.filterRequestLogEvent",
+			"url":    payload.URL,
+		}).Debug("Received event for a non-matching resource, ignoring")
+		return true
+	}
+
+	if tailer.urlRegexp != nil && !tailer.urlRegexp.MatchString(payload.URL) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.filterRequestLogEvent",
+			"url":    payload.URL,
+		}).Debug("Received event with URL not matching the regular expression, ignoring")
+		return true
+	}
+
+	if requestIDs := (matcher{tailer.cfg.FilterRequestIDs, matchWildcard}); requestIDs.Active() && !requestIDs.Matches(payload.RequestID) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.filterRequestLogEvent",
+			"request_id": payload.RequestID,
+		}).Debug("Received event with non-matching request ID, ignoring")
+		return true
+	}
+
+	if idempotencyKeys := (matcher{tailer.cfg.FilterIdempotencyKeys, matchEqual}); idempotencyKeys.Active() && !idempotencyKeys.Matches(payload.IdempotencyKey) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":          "logs.Tailer.filterRequestLogEvent",
+			"idempotency_key": payload.IdempotencyKey,
+		}).Debug("Received event with non-matching idempotency key, ignoring")
+		return true
+	}
+
+	if tailer.cfg.FilterSource != "" && !(matcher{[]string{tailer.cfg.FilterSource}, strings.EqualFold}).Matches(payload.Source) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.filterRequestLogEvent",
+			"source": payload.Source,
+		}).Debug("Received event with non-matching source, ignoring")
+		return true
+	}
+
+	if tailer.expression != nil && !tailer.expression.Match(expressionEvent{payload}) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.filterRequestLogEvent",
+			"request_id": payload.RequestID,
+		}).Debug("Received event not matching the filter expression, ignoring")
+		return true
+	}
+
+	if !tailer.matchesTimeWindow(payload) {
+		return true
+	}
+
+	if !tailer.matchesLatency(payload) {
+		return true
+	}
+
+	if userAgents := (matcher{tailer.cfg.FilterUserAgent, matchSubstringFold}); userAgents.Active() && !userAgents.Matches(payload.UserAgent) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.filterRequestLogEvent",
+			"user_agent": payload.UserAgent,
+		}).Debug("Received event with non-matching user agent, ignoring")
+		return true
+	}
+
+	if len(tailer.cfg.ExcludeRequestPaths) > 0 && hasPathPrefix(tailer.cfg.ExcludeRequestPaths, payload.URL) {
+		atomic.AddUint64(&tailer.excludedCount, 1)
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.filterRequestLogEvent",
+			"url":    payload.URL,
+		}).Debug("Received event with excluded request path, ignoring")
+		return true
+	}
+
+	return false
+}
+
+// matchesTimeWindow returns true if the request log was created between the
+// since and until filters. Request logs without a usable creation time are
+// never hidden, since this could hide exactly the request logs that matter.
+func (tailer *Tailer) matchesTimeWindow(payload *EventPayload) bool {
+	if tailer.cfg.FilterSince.IsZero() && tailer.cfg.FilterUntil.IsZero() {
+		return true
+	}
+
+	createdAt, ok := payload.CreatedAtTime()
+	if !ok {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.matchesTimeWindow",
+			"request_id": payload.RequestID,
+		}).Warn("Received request log without a valid creation time, the time filters can't be applied")
+		return true
+	}
+
+	if !tailer.cfg.FilterSince.IsZero() && createdAt.Before(tailer.cfg.FilterSince) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.matchesTimeWindow",
+			"created_at": payload.CreatedAt,
+		}).Debug("Received event created before the since filter, ignoring")
+		return false
+	}
+
+	if !tailer.cfg.FilterUntil.IsZero() && createdAt.After(tailer.cfg.FilterUntil) {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.matchesTimeWindow",
+			"created_at": payload.CreatedAt,
+		}).Debug("Received event created after the until filter, ignoring")
+		return false
+	}
+
+	return true
+}
+
+// expressionFields are the fields of request logs that can be used in filter
+// expressions.
+var expressionFields = filter.Fields{
+	"account":         filter.String,
+	"api_version":     filter.String,
+	"created":         filter.Time,
+	"error_code":      filter.String,
+	"error_type":      filter.String,
+	"idempotency_key": filter.String,
+	"ip_address":      filter.String,
+	"method":          filter.FoldedString,
+	"path":            filter.String,
+	"request_id":      filter.String,
+	"source":          filter.FoldedString,
+	"status":          filter.Number,
+	"url":             filter.String,
+	"user_agent":      filter.String,
+}
+
+// expressionEvent exposes the fields of a payload to filter expressions.
+type expressionEvent struct {
+	payload *EventPayload
+}
+
+// Lookup returns the value of one of the expressionFields.
+func (evt expressionEvent) Lookup(field string) (interface{}, bool) {
+	payload := evt.payload
+
+	switch field {
+	case "account":
+		return payload.Account, payload.Account != ""
+	case "api_version":
+		return payload.APIVersion, payload.APIVersion != ""
+	case "created":
+		return payload.CreatedAtTime()
+	case "error_code":
+		return payload.Error.Code, payload.Error.Code != ""
+	case "error_type":
+		return payload.Error.Type, payload.Error.Type != ""
+	case "idempotency_key":
+		return payload.IdempotencyKey, payload.IdempotencyKey != ""
+	case "ip_address":
+		return payload.IPAddress, payload.IPAddress != ""
+	case "method":
+		return payload.Method, payload.Method != ""
+	case "path":
+		return requestPath(payload.URL), payload.URL != ""
+	case "request_id":
+		return payload.RequestID, payload.RequestID != ""
+	case "source":
+		return payload.Source, payload.Source != ""
+	case "status":
+		return payload.Status, payload.Status != 0
+	case "url":
+		return payload.URL, payload.URL != ""
+	case "user_agent":
+		return payload.UserAgent, payload.UserAgent != ""
+	default:
+		return nil, false
+	}
+}
+
+// reportExcludedEvents periodically logs the number of request logs hidden by
+// the exclusion filters, until stopCh is closed.
+func (tailer *Tailer) reportExcludedEvents(stopCh chan struct{}) {
+	if len(tailer.cfg.ExcludeRequestPaths) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(excludedReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			tailer.cfg.Log.WithFields(log.Fields{
+				"prefix": "logs.Tailer.reportExcludedEvents",
+				"count":  atomic.SwapUint64(&tailer.excludedCount, 0),
+			}).Debugf("Excluded request logs in the last %s", excludedReportInterval)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// matchesLatency returns true if the request took between the minimum and
+// maximum latency filters to be served. Request logs without a latency are
+// never hidden.
+func (tailer *Tailer) matchesLatency(payload *EventPayload) bool {
+	if tailer.cfg.FilterMinLatency == 0 && tailer.cfg.FilterMaxLatency == 0 {
+		return true
+	}
+
+	latency, ok := payload.LatencyDuration()
+	if !ok {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.matchesLatency",
+			"request_id": payload.RequestID,
+		}).Debug("Received request log without a latency, the latency filters can't be applied")
+		return true
+	}
+
+	if tailer.cfg.FilterMinLatency > 0 && latency < tailer.cfg.FilterMinLatency {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":  "logs.Tailer.matchesLatency",
+			"latency": latency,
+		}).Debug("Received event faster than the minimum latency filter, ignoring")
+		return false
+	}
+
+	if tailer.cfg.FilterMaxLatency > 0 && latency > tailer.cfg.FilterMaxLatency {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":  "logs.Tailer.matchesLatency",
+			"latency": latency,
+		}).Debug("Received event slower than the maximum latency filter, ignoring")
+		return false
+	}
+
+	return true
+}
+
+// matchesStatus returns true if the status matches either the exact status
+// codes or the status classes of the config, or if neither filter is set.
+func (tailer *Tailer) matchesStatus(status int) bool {
+	for _, class := range tailer.cfg.FilterStatusClasses {
+		if class, negated := parseFilterValue(class); negated && statusClasses[strings.ToLower(class)](status) {
+			return false
+		}
+	}
+
+	if len(tailer.cfg.FilterStatusCodes) == 0 && !hasPositiveValues(tailer.cfg.FilterStatusClasses) {
+		return true
+	}
+
+	if containsInt(tailer.cfg.FilterStatusCodes, status) {
+		return true
+	}
+
+	for _, class := range tailer.cfg.FilterStatusClasses {
+		if _, negated := parseFilterValue(class); !negated && statusClasses[strings.ToLower(class)](status) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesError returns true if the error code or type of a request log
+// matches the error filter. Successful requests never match when the filter
+// has positive values, since they don't have an error.
+func matchesError(m matcher, status int, value string) bool {
+	if status < 400 && hasPositiveValues(m.values) {
+		return false
+	}
+
+	return m.Matches(value)
+}
+
+// accountForFilter returns the account a request log is matched on by the
+// account filter, which is the platform when the request wasn't made on
+// behalf of a connected account.
+func accountForFilter(payload *EventPayload) string {
+	account, ok := payload.ConnectedAccount()
+	if !ok {
+		return platformAccount
+	}
+
+	return account
+}
+
+// parseIPNet parses either an IP address or a CIDR range into a network. A
+// single IP address is converted to a network containing only that address.
+func parseIPNet(address string) (*net.IPNet, error) {
+	if strings.Contains(address, "/") {
+		_, ipNet, err := net.ParseCIDR(address)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid CIDR range", address)
+		}
+		return ipNet, nil
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("%s is not a valid IP address", address)
+	}
+
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 8 * net.IPv4len
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// matchIP returns true if the IP address belongs to the network of the
+// filter value, as parsed by compileFilters.
+func (tailer *Tailer) matchIP(filterValue, address string) bool {
+	ipNet, ok := tailer.ipNets[filterValue]
+	if !ok {
+		return false
+	}
+
+	ip := net.ParseIP(address)
+	return ip != nil && ipNet.Contains(ip)
+}
+
+// hasPathPrefix returns true if the path of the request URL starts with any of
+// the prefixes.
+func hasPathPrefix(prefixes []string, requestURL string) bool {
+	for _, prefix := range prefixes {
+		if matchPathPrefix(prefix, requestURL) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestPath extracts the path from a request URL, which can either be
+// absolute or just a path, and drops any query string.
+func requestPath(requestURL string) string {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return strings.SplitN(requestURL, "?", 2)[0]
+	}
+
+	return u.Path
+}
+
+// statusClasses maps the accepted status class names to a predicate over
+// status codes.
+var statusClasses = map[string]func(int) bool{
+	"succeeded":  func(status int) bool { return status >= 200 && status < 300 },
+	"client-err": func(status int) bool { return status >= 400 && status < 500 },
+	"server-err": func(status int) bool { return status >= 500 && status < 600 },
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,536 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateStatusCodes(t *testing.T) {
+	require.Nil(t, (&Config{FilterStatusCodes: []int{200, 402, 500}}).validateOptions())
+	require.EqualError(t, (&Config{FilterStatusCodes: []int{999}}).validateOptions(), "999 is not a valid HTTP status code")
+	require.EqualError(t, (&Config{FilterStatusCodes: []int{0}}).validateOptions(), "0 is not a valid HTTP status code")
+}
+
+func TestFilterRequestLogEventStatusCodes(t *testing.T) {
+	tailer := New(&Config{FilterStatusCodes: []int{402, 500}})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 402}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 500}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200}))
+}
+
+func TestFilterRequestLogEventNoFilters(t *testing.T) {
+	tailer := New(&Config{})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 500}))
+}
+
+func TestValidateStatusClasses(t *testing.T) {
+	require.Nil(t, (&Config{FilterStatusClasses: []string{"succeeded", "Client-Err", "server-err"}}).validateOptions())
+	require.EqualError(t, (&Config{FilterStatusClasses: []string{"failed"}}).validateOptions(), "failed is not an acceptable status class (succeeded, client-err, server-err)")
+}
+
+func TestFilterRequestLogEventStatusClasses(t *testing.T) {
+	tailer := New(&Config{FilterStatusClasses: []string{"client-err"}})
+
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 399}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 400}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 499}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 500}))
+
+	tailer = New(&Config{FilterStatusClasses: []string{"succeeded", "server-err"}})
+
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 199}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 299}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 499}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 500}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 599}))
+}
+
+func TestFilterRequestLogEventStatusCodesOrClasses(t *testing.T) {
+	tailer := New(&Config{
+		FilterStatusCodes:   []int{402},
+		FilterStatusClasses: []string{"server-err"},
+	})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 402}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 503}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 404}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200}))
+}
+
+func TestValidateHTTPMethods(t *testing.T) {
+	require.Nil(t, (&Config{FilterHTTPMethods: []string{"post", "DELETE"}}).validateOptions())
+	require.EqualError(t, (&Config{FilterHTTPMethods: []string{"PATCH"}}).validateOptions(), "PATCH is not an acceptable HTTP method (GET, POST, DELETE)")
+}
+
+func TestFilterRequestLogEventHTTPMethods(t *testing.T) {
+	tailer := New(&Config{FilterHTTPMethods: []string{"post", "DELETE"}})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Method: "POST"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Method: "delete"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Method: "GET"}))
+}
+
+func TestFilterRequestLogEventRequestPaths(t *testing.T) {
+	tailer := New(&Config{FilterRequestPaths: []string{"/v1/payment_intents", "/v1/refunds/"}})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/payment_intents"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/payment_intents/pi_123/confirm"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/payment_intents?limit=3"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "https://api.stripe.com/v1/payment_intents?limit=3"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/refunds"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/refunds/"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/charges"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/charges?q=/v1/refunds"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{URL: ""}))
+}
+
+func TestRequestPath(t *testing.T) {
+	require.Equal(t, "/v1/charges", requestPath("/v1/charges"))
+	require.Equal(t, "/v1/charges/", requestPath("/v1/charges/?limit=1"))
+	require.Equal(t, "/v1/charges", requestPath("https://api.stripe.com/v1/charges?limit=1"))
+}
+
+func TestCompileFiltersURLRegexInvalid(t *testing.T) {
+	tailer := New(&Config{FilterURLRegex: "/v1/customers/("})
+
+	err := tailer.compileFilters()
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "invalid URL filter regular expression")
+}
+
+func TestFilterRequestLogEventURLRegex(t *testing.T) {
+	tailer := New(&Config{FilterURLRegex: "^/v1/customers/cus_.*/sources"})
+	require.Nil(t, tailer.compileFilters())
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/customers/cus_123/sources"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/customers/cus_123/sources/card_123"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/customers/cus_123"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/charges"}))
+}
+
+func TestFilterRequestLogEventRequestIDs(t *testing.T) {
+	tailer := New(&Config{FilterRequestIDs: []string{"req_123", "req_abc*"}})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{RequestID: "req_123"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{RequestID: "req_abc"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{RequestID: "req_abcdef"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{RequestID: "req_1234"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{RequestID: "req_ab"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{RequestID: ""}))
+}
+
+func TestFilterRequestLogEventIdempotencyKeys(t *testing.T) {
+	tailer := New(&Config{FilterIdempotencyKeys: []string{"order-42"}})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{IdempotencyKey: "order-42"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{IdempotencyKey: "ORDER-42"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{IdempotencyKey: ""}))
+}
+
+func TestValidateSource(t *testing.T) {
+	require.Nil(t, (&Config{FilterSource: "api"}).validateOptions())
+	require.Nil(t, (&Config{FilterSource: "Dashboard"}).validateOptions())
+	require.EqualError(t, (&Config{FilterSource: "dashbaord"}).validateOptions(), "dashbaord is not an acceptable source (API, DASHBOARD), did you mean DASHBOARD?")
+}
+
+func TestFilterRequestLogEventSource(t *testing.T) {
+	tailer := New(&Config{FilterSource: "api"})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Source: "api"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Source: "API"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Source: "dashboard"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{}))
+}
+
+func TestFilterRequestLogEventAPIVersion(t *testing.T) {
+	tailer := New(&Config{FilterAPIVersion: []string{"2019-05-16", "2019-09-09"}})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{APIVersion: "2019-05-16"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{APIVersion: "2019-09-09"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{APIVersion: "2017-08-15"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{}))
+}
+
+func TestValidateAccount(t *testing.T) {
+	require.Nil(t, (&Config{FilterAccount: []string{"acct_123", "platform"}}).validateOptions())
+	require.EqualError(t, (&Config{FilterAccount: []string{"cus_123"}}).validateOptions(), "cus_123 is not an acceptable account filter (an acct_ ID or platform)")
+}
+
+func TestFilterRequestLogEventAccount(t *testing.T) {
+	var withAccount, withoutAccount EventPayload
+	require.Nil(t, json.Unmarshal([]byte(`{"account":"acct_123","status":200}`), &withAccount))
+	require.Nil(t, json.Unmarshal([]byte(`{"status":200}`), &withoutAccount))
+
+	tailer := New(&Config{FilterAccount: []string{"acct_123"}})
+	require.False(t, tailer.filterRequestLogEvent(&withAccount))
+	require.True(t, tailer.filterRequestLogEvent(&withoutAccount))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Account: "acct_456"}))
+
+	tailer = New(&Config{FilterAccount: []string{"platform"}})
+	require.True(t, tailer.filterRequestLogEvent(&withAccount))
+	require.False(t, tailer.filterRequestLogEvent(&withoutAccount))
+
+	tailer = New(&Config{FilterAccount: []string{"acct_123", "platform"}})
+	require.False(t, tailer.filterRequestLogEvent(&withAccount))
+	require.False(t, tailer.filterRequestLogEvent(&withoutAccount))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Account: "acct_456"}))
+}
+
+func TestFilterRequestLogEventLivemode(t *testing.T) {
+	var live, test, absent EventPayload
+	require.Nil(t, json.Unmarshal([]byte(`{"livemode":true}`), &live))
+	require.Nil(t, json.Unmarshal([]byte(`{"livemode":false}`), &test))
+	require.Nil(t, json.Unmarshal([]byte(`{}`), &absent))
+	require.Nil(t, absent.Livemode)
+
+	livemode := true
+	tailer := New(&Config{FilterLivemode: &livemode})
+	require.False(t, tailer.filterRequestLogEvent(&live))
+	require.True(t, tailer.filterRequestLogEvent(&test))
+	require.True(t, tailer.filterRequestLogEvent(&absent))
+
+	testmode := false
+	tailer = New(&Config{FilterLivemode: &testmode})
+	require.True(t, tailer.filterRequestLogEvent(&live))
+	require.False(t, tailer.filterRequestLogEvent(&test))
+	require.True(t, tailer.filterRequestLogEvent(&absent))
+
+	tailer = New(&Config{})
+	require.False(t, tailer.filterRequestLogEvent(&live))
+	require.False(t, tailer.filterRequestLogEvent(&test))
+	require.False(t, tailer.filterRequestLogEvent(&absent))
+}
+
+func TestFilterRequestLogEventExcludeRequestPaths(t *testing.T) {
+	tailer := New(&Config{
+		ExcludeRequestPaths: []string{"/v1/balance"},
+		FilterRequestPaths:  []string{"/v1/balance", "/v1/charges"},
+	})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/charges"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/balance"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/balance/history?limit=3"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/customers"}))
+	require.Equal(t, uint64(2), tailer.excludedCount)
+}
+
+func TestParseTimeFilter(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	since, err := ParseTimeFilter("2019-10-01T11:00:00Z", now)
+	require.Nil(t, err)
+	require.Equal(t, time.Date(2019, 10, 1, 11, 0, 0, 0, time.UTC), since)
+
+	since, err = ParseTimeFilter("5m", now)
+	require.Nil(t, err)
+	require.Equal(t, time.Date(2019, 10, 1, 11, 55, 0, 0, time.UTC), since)
+
+	_, err = ParseTimeFilter("yesterday", now)
+	require.EqualError(t, err, "yesterday is not a valid time filter (an RFC3339 timestamp like 2019-10-01T15:04:05Z or a duration like 5m)")
+}
+
+func TestValidateTimeWindow(t *testing.T) {
+	early := time.Date(2019, 10, 1, 11, 0, 0, 0, time.UTC)
+	late := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	require.Nil(t, (&Config{FilterSince: early, FilterUntil: late}).validateOptions())
+	require.Nil(t, (&Config{FilterSince: late}).validateOptions())
+	require.EqualError(t, (&Config{FilterSince: late, FilterUntil: early}).validateOptions(), "the since filter (2019-10-01T12:00:00Z) must be before the until filter (2019-10-01T11:00:00Z)")
+}
+
+func TestFilterRequestLogEventTimeWindow(t *testing.T) {
+	since := time.Unix(1000, 0)
+	until := time.Unix(2000, 0)
+	tailer := New(&Config{FilterSince: since, FilterUntil: until})
+
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{CreatedAt: 999}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{CreatedAt: 1000}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{CreatedAt: 2000}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{CreatedAt: 2001}))
+
+	// Request logs without a creation time are shown rather than dropped
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{}))
+}
+
+func TestFilterRequestLogEventErrorCode(t *testing.T) {
+	var declined, missingError, succeeded EventPayload
+	require.Nil(t, json.Unmarshal([]byte(`{"status":402,"error":{"type":"card_error","code":"card_declined","message":"Your card was declined."}}`), &declined))
+	require.Nil(t, json.Unmarshal([]byte(`{"status":500}`), &missingError))
+	require.Nil(t, json.Unmarshal([]byte(`{"status":200}`), &succeeded))
+	require.Equal(t, "card_declined", declined.Error.Code)
+	require.Equal(t, "card_error", declined.Error.Type)
+
+	tailer := New(&Config{FilterErrorCode: []string{"card_declined"}})
+
+	require.False(t, tailer.filterRequestLogEvent(&declined))
+	require.True(t, tailer.filterRequestLogEvent(&missingError))
+	require.True(t, tailer.filterRequestLogEvent(&succeeded))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 400, Error: ErrorPayload{Code: "parameter_missing"}}))
+
+	// A 2xx is hidden even if it somehow carries a matching error code
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200, Error: ErrorPayload{Code: "card_declined"}}))
+}
+
+func TestValidateErrorType(t *testing.T) {
+	require.Nil(t, (&Config{FilterErrorType: []string{"card_error", "API_ERROR"}}).validateOptions())
+	require.EqualError(t, (&Config{FilterErrorType: []string{"card_declined"}}).validateOptions(), "card_declined is not an acceptable error type (api_error, card_error, idempotency_error, invalid_request_error)")
+}
+
+func TestFilterRequestLogEventErrorTypeAndCode(t *testing.T) {
+	declined := &EventPayload{Status: 402, Error: ErrorPayload{Type: "card_error", Code: "card_declined"}}
+	expired := &EventPayload{Status: 402, Error: ErrorPayload{Type: "card_error", Code: "expired_card"}}
+	missing := &EventPayload{Status: 400, Error: ErrorPayload{Type: "invalid_request_error", Code: "parameter_missing"}}
+
+	tailer := New(&Config{FilterErrorType: []string{"card_error"}})
+	require.False(t, tailer.filterRequestLogEvent(declined))
+	require.False(t, tailer.filterRequestLogEvent(expired))
+	require.True(t, tailer.filterRequestLogEvent(missing))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200}))
+
+	tailer = New(&Config{
+		FilterErrorType: []string{"card_error"},
+		FilterErrorCode: []string{"card_declined", "parameter_missing"},
+	})
+	require.False(t, tailer.filterRequestLogEvent(declined))
+	require.True(t, tailer.filterRequestLogEvent(expired))
+	require.True(t, tailer.filterRequestLogEvent(missing))
+}
+
+func TestValidateIPAddress(t *testing.T) {
+	require.Nil(t, (&Config{FilterIPAddress: []string{"10.0.0.1", "192.168.0.0/16", "2001:db8::1", "2001:db8::/32"}}).validateOptions())
+	require.EqualError(t, (&Config{FilterIPAddress: []string{"10.0.0"}}).validateOptions(), "10.0.0 is not a valid IP address")
+	require.EqualError(t, (&Config{FilterIPAddress: []string{"10.0.0.0/33"}}).validateOptions(), "10.0.0.0/33 is not a valid CIDR range")
+}
+
+func TestFilterRequestLogEventIPAddress(t *testing.T) {
+	tailer := New(&Config{FilterIPAddress: []string{"10.0.0.1", "192.168.0.0/16", "2001:db8::/32"}})
+	require.Nil(t, tailer.compileFilters())
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{IPAddress: "10.0.0.1"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{IPAddress: "10.0.0.2"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{IPAddress: "192.168.42.42"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{IPAddress: "2001:db8:1234::1"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{IPAddress: "2001:db9::1"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{IPAddress: "not-an-ip"}))
+
+	var missing EventPayload
+	require.Nil(t, json.Unmarshal([]byte(`{"status":200}`), &missing))
+	require.True(t, tailer.filterRequestLogEvent(&missing))
+}
+
+func TestFilterRequestLogEventIPv6Address(t *testing.T) {
+	tailer := New(&Config{FilterIPAddress: []string{"2001:db8::1"}})
+	require.Nil(t, tailer.compileFilters())
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{IPAddress: "2001:0db8:0000:0000:0000:0000:0000:0001"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{IPAddress: "2001:db8::2"}))
+}
+
+func TestCompileFiltersExpressionInvalid(t *testing.T) {
+	tailer := New(&Config{FilterExpression: "status>=500 and"})
+
+	err := tailer.compileFilters()
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "invalid filter expression")
+}
+
+func TestFilterRequestLogEventExpression(t *testing.T) {
+	tailer := New(&Config{FilterExpression: "status>=500 and method=POST and path~=^/v1/charges"})
+	require.Nil(t, tailer.compileFilters())
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 500, Method: "POST", URL: "/v1/charges?expand[]=customer"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 500, Method: "GET", URL: "/v1/charges"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 402, Method: "POST", URL: "/v1/charges"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 500, Method: "POST", URL: "/v1/refunds"}))
+}
+
+func TestExpressionEventLookup(t *testing.T) {
+	evt := expressionEvent{&EventPayload{
+		CreatedAt: 1000,
+		Method:    "POST",
+		RequestID: "req_123",
+		Status:    200,
+		URL:       "https://api.stripe.com/v1/charges?limit=1",
+	}}
+
+	for field := range expressionFields {
+		// Every declared field must be handled by Lookup
+		value, _ := evt.Lookup(field)
+		require.NotNil(t, value, field)
+	}
+
+	path, ok := evt.Lookup("path")
+	require.True(t, ok)
+	require.Equal(t, "/v1/charges", path)
+
+	_, ok = evt.Lookup("request_id")
+	require.True(t, ok)
+
+	_, ok = evt.Lookup("error_code")
+	require.False(t, ok)
+
+	created, ok := evt.Lookup("created")
+	require.True(t, ok)
+	require.Equal(t, time.Unix(1000, 0), created)
+}
+
+func TestValidateOnlyErrors(t *testing.T) {
+	require.Nil(t, (&Config{OnlyErrors: true}).validateOptions())
+	require.EqualError(t, (&Config{OnlyErrors: true, FilterStatusCodes: []int{500}}).validateOptions(), "the only errors filter can't be combined with the status code or status class filters")
+	require.EqualError(t, (&Config{OnlyErrors: true, FilterStatusClasses: []string{"server-err"}}).validateOptions(), "the only errors filter can't be combined with the status code or status class filters")
+}
+
+func TestFilterRequestLogEventOnlyErrors(t *testing.T) {
+	tailer := New(&Config{OnlyErrors: true})
+
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 399}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 400}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 502}))
+}
+
+func TestFilterRawRequestLogEventText(t *testing.T) {
+	payload := `{"method":"POST","url":"/v1/customers/cus_123","email":"Jenny.Rosen@example.com"}`
+
+	tailer := New(&Config{FilterText: []string{"jenny.rosen@EXAMPLE.com"}})
+	require.False(t, tailer.filterRawRequestLogEvent(payload))
+
+	tailer = New(&Config{FilterText: []string{"cus_123", "jenny"}})
+	require.False(t, tailer.filterRawRequestLogEvent(payload))
+
+	tailer = New(&Config{FilterText: []string{"cus_123", "cus_456"}})
+	require.True(t, tailer.filterRawRequestLogEvent(payload))
+
+	tailer = New(&Config{})
+	require.False(t, tailer.filterRawRequestLogEvent(payload))
+}
+
+func TestFilterRequestLogEventUserAgent(t *testing.T) {
+	tailer := New(&Config{FilterUserAgent: []string{"stripe/v1 rubybindings", "billing-service"}})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{UserAgent: "Stripe/v1 RubyBindings/5.2.0"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{UserAgent: "billing-service/1.0 Stripe/v1 GoBindings/63.1.0"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{UserAgent: "Stripe/v1 NodeBindings/7.9.1"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{}))
+}
+
+func TestFilterRequestLogEventLatency(t *testing.T) {
+	fast, slow := 150, 2500
+
+	tailer := New(&Config{FilterMinLatency: 2 * time.Second})
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Latency: &fast}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Latency: &slow}))
+
+	tailer = New(&Config{FilterMaxLatency: time.Second})
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Latency: &fast}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Latency: &slow}))
+
+	tailer = New(&Config{FilterMinLatency: 100 * time.Millisecond, FilterMaxLatency: time.Second})
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Latency: &fast}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Latency: &slow}))
+
+	// Request logs without a latency aren't hidden
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{}))
+}
+
+func TestEventPayloadLatencyDuration(t *testing.T) {
+	var payload EventPayload
+	require.NoError(t, json.Unmarshal([]byte(`{"latency":1250}`), &payload))
+
+	latency, ok := payload.LatencyDuration()
+	require.True(t, ok)
+	require.Equal(t, 1250*time.Millisecond, latency)
+
+	_, ok = (&EventPayload{}).LatencyDuration()
+	require.False(t, ok)
+}
+
+func TestFilterRequestLogEventRequireConnectedAccount(t *testing.T) {
+	tailer := New(&Config{RequireConnectedAccount: true})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Account: "acct_123"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Account: " "}))
+}
+
+func TestFilterRequestLogEventExcludeConnectedAccount(t *testing.T) {
+	tailer := New(&Config{ExcludeConnectedAccount: true})
+
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Account: "acct_123"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Account: " "}))
+}
+
+func TestEventPayloadConnectedAccount(t *testing.T) {
+	for _, raw := range []string{`{}`, `{"account":null}`, `{"account":""}`, `{"account":"  "}`} {
+		var payload EventPayload
+		require.NoError(t, json.Unmarshal([]byte(raw), &payload))
+		_, ok := payload.ConnectedAccount()
+		require.False(t, ok, raw)
+	}
+
+	account, ok := (&EventPayload{Account: "acct_123"}).ConnectedAccount()
+	require.True(t, ok)
+	require.Equal(t, "acct_123", account)
+}
+
+func TestFilterRequestLogEventNegation(t *testing.T) {
+	tailer := New(&Config{FilterHTTPMethods: []string{"!GET"}, FilterRequestPaths: []string{"!/v1/balance"}})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Method: "POST", URL: "/v1/charges"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Method: "GET", URL: "/v1/charges"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Method: "POST", URL: "/v1/balance"}))
+}
+
+func TestFilterRequestLogEventNegatedErrorCode(t *testing.T) {
+	tailer := New(&Config{FilterErrorCode: []string{"!card_declined"}})
+
+	// Without positive values, successful requests aren't hidden
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 400, Error: ErrorPayload{Code: "parameter_missing"}}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 402, Error: ErrorPayload{Code: "card_declined"}}))
+
+	tailer = New(&Config{FilterErrorCode: []string{"card_declined", "!card_declined"}})
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200}))
+}
+
+func TestFilterRequestLogEventNegatedStatusClass(t *testing.T) {
+	tailer := New(&Config{FilterStatusClasses: []string{"!succeeded"}})
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 404}))
+
+	tailer = New(&Config{FilterStatusCodes: []int{200, 404}, FilterStatusClasses: []string{"!client-err"}})
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Status: 200}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 404}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Status: 500}))
+}
+
+func TestFilterRequestLogEventNegatedIPAddress(t *testing.T) {
+	tailer := New(&Config{FilterIPAddress: []string{"!10.0.0.0/8"}})
+	require.Nil(t, tailer.compileFilters())
+
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{IPAddress: "10.1.2.3"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{IPAddress: "192.168.0.1"}))
+}
+
+func TestFilterRequestLogEventNegatedAccount(t *testing.T) {
+	tailer := New(&Config{FilterAccount: []string{"!platform"}})
+
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Account: "acct_123"}))
+}
+
+func TestFilterRawRequestLogEventNegatedText(t *testing.T) {
+	tailer := New(&Config{FilterText: []string{"cus_123", "!card_declined"}})
+
+	require.False(t, tailer.filterRawRequestLogEvent(`{"url":"/v1/customers/cus_123"}`))
+	require.True(t, tailer.filterRawRequestLogEvent(`{"url":"/v1/customers/cus_123","error":{"code":"card_declined"}}`))
+	require.True(t, tailer.filterRawRequestLogEvent(`{"url":"/v1/customers/cus_456"}`))
+}
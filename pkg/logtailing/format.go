@@ -0,0 +1,458 @@
+package logtailing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/tidwall/pretty"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// highlight is how a request log line is emphasized in highlight mode
+type highlight int
+
+const (
+	// highlightNone is used when highlight mode is inactive
+	highlightNone highlight = iota
+
+	// highlightMatch is used for request logs matching the filters
+	highlightMatch
+
+	// highlightDimmed is used for request logs not matching the filters
+	highlightDimmed
+)
+
+// highlighting returns true if request logs not matching the filters should
+// be displayed dimmed rather than hidden.
+func (tailer *Tailer) highlighting() bool {
+	return tailer.cfg.HighlightMode &&
+		!tailer.cfg.structuredOutput() &&
+		tailer.cfg.hasLocalFilters() &&
+		tailer.cfg.colorMode().SupportsColors(tailer.cfg.Stdout)
+}
+
+// structuredOutput returns true if request logs are printed in a format meant
+// to be parsed, which must be left untouched by the display features of the
+// default format.
+func (cfg *Config) structuredOutput() bool {
+	switch cfg.OutputFormat {
+	case outputFormatCSV, outputFormatEnvelope, outputFormatJSON, outputFormatLogfmt, outputFormatNDJSON:
+		return true
+	default:
+		return false
+	}
+}
+
+// defaultJSONIndent is the indentation of the JSON output format when
+// cfg.JSONIndent isn't set
+const defaultJSONIndent = "  "
+
+// jsonPayload reformats a raw payload for the JSON output format, compacted
+// or indented with cfg.JSONIndent, with its keys sorted if cfg.SortJSONKeys is
+// set. Only the whitespace and the order of the keys are changed, so numbers
+// are kept exactly as Stripe sent them (e.g. 1000 never becomes 1e+03).
+// Payloads that aren't valid JSON are returned as is.
+func (tailer *Tailer) jsonPayload(rawPayload string) string {
+	if !json.Valid([]byte(rawPayload)) {
+		return rawPayload
+	}
+
+	if tailer.cfg.SortJSONKeys {
+		rawPayload = sortJSONKeys(rawPayload)
+	}
+
+	var buf bytes.Buffer
+	var err error
+
+	if tailer.cfg.JSONCompact {
+		err = json.Compact(&buf, []byte(rawPayload))
+	} else {
+		indent := tailer.cfg.JSONIndent
+		if indent == "" {
+			indent = defaultJSONIndent
+		}
+		err = json.Indent(&buf, []byte(rawPayload), "", indent)
+	}
+
+	if err != nil {
+		return rawPayload
+	}
+
+	return buf.String()
+}
+
+// sortJSONKeys returns a compact version of valid JSON with the keys of the
+// objects sorted at every nesting level. The values are copied as is.
+func sortJSONKeys(payload string) string {
+	return string(pretty.Ugly(pretty.PrettyOptions([]byte(payload), &pretty.Options{SortKeys: true})))
+}
+
+// malformedPayload replaces the payloads that aren't valid JSON in the NDJSON
+// output format.
+type malformedPayload struct {
+	Malformed    bool   `json:"malformed"`
+	Raw          string `json:"raw"`
+	RequestLogID string `json:"request_log_id,omitempty"`
+}
+
+// malformedRawLength is the number of characters the payloads that aren't
+// valid JSON are truncated to in the text output format
+const malformedRawLength = 200
+
+// malformedLine renders a request log whose payload isn't valid JSON in the
+// text output format, as its request log ID and its raw payload, e.g.
+// `[malformed payload] resp_123 "{\"status\": 200"`. The payload is quoted
+// so that it stays on a single line without ANSI sequences, and truncated.
+func malformedLine(event *websocket.RequestLogEvent) string {
+	raw := ansi.Truncate(strconv.Quote(event.EventPayload), malformedRawLength)
+
+	if event.RequestLogID == "" {
+		return "[malformed payload] " + raw
+	}
+	return fmt.Sprintf("[malformed payload] %s %s", event.RequestLogID, raw)
+}
+
+// ndjsonLine renders the payload of a request log as a single line of compact
+// JSON, with the `resp_` ID of the request log added as request_log_id.
+// Payloads that aren't valid JSON are wrapped so that the output stays
+// parseable.
+func ndjsonLine(event *websocket.RequestLogEvent) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(event.EventPayload)); err == nil {
+		return withRequestLogID(buf.String(), event.RequestLogID)
+	}
+
+	// Marshalling a struct of a bool and strings can't fail
+	line, _ := json.Marshal(malformedPayload{Malformed: true, Raw: event.EventPayload, RequestLogID: event.RequestLogID})
+	return string(line)
+}
+
+// envelope is a request log in the envelope output format
+type envelope struct {
+	ReceivedAt   string          `json:"received_at"`
+	RequestLogID string          `json:"request_log_id"`
+	Type         string          `json:"type"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+// envelopeLine renders a request log received at the given time as a single
+// line of compact JSON wrapping the payload, e.g.
+// `{"received_at":"2019-10-02T07:06:40.123Z","request_log_id":"resp_123","type":"request_log_event","payload":{...}}`.
+// Payloads that aren't valid JSON are wrapped the same way as in the NDJSON
+// output format.
+func envelopeLine(event *websocket.RequestLogEvent, receivedAt time.Time) string {
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, []byte(event.EventPayload)); err != nil {
+		// Marshalling a struct of a bool and a string can't fail
+		malformed, _ := json.Marshal(malformedPayload{Malformed: true, Raw: event.EventPayload})
+		payload.Reset()
+		payload.Write(malformed)
+	}
+
+	// The payload is valid JSON, so marshalling can't fail
+	line, _ := json.Marshal(envelope{
+		ReceivedAt:   receivedAt.UTC().Format(time.RFC3339Nano),
+		RequestLogID: event.RequestLogID,
+		Type:         event.Type,
+		Payload:      json.RawMessage(payload.Bytes()),
+	})
+	return string(line)
+}
+
+// withRequestLogID adds the request_log_id key to a compact JSON object. Other
+// JSON values are returned as is.
+func withRequestLogID(object string, requestLogID string) string {
+	return withJSONKey(object, "request_log_id", requestLogID)
+}
+
+// withLabel adds the label key to a JSON object, compacting it. Other JSON
+// values are returned as is.
+func withLabel(object string, label string) string {
+	if label == "" {
+		return object
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(object)); err != nil {
+		return object
+	}
+	return withJSONKey(buf.String(), "label", label)
+}
+
+// withJSONKey adds a key with a string value to a compact JSON object, unless
+// the value is empty. Other JSON values are returned as is.
+func withJSONKey(object string, key string, value string) string {
+	if value == "" || !strings.HasPrefix(object, "{") {
+		return object
+	}
+
+	// Marshalling a string can't fail
+	marshalled, _ := json.Marshal(value)
+	pair := strconv.Quote(key) + ":" + string(marshalled)
+
+	if object == "{}" {
+		return "{" + pair + "}"
+	}
+
+	return strings.TrimSuffix(object, "}") + "," + pair + "}"
+}
+
+// methodColumnWidth is the width of the method column when cfg.Aligned is set,
+// enough for DELETE
+const methodColumnWidth = 6
+
+// alignedStatusCodes are the status codes returned by Stripe, used to size
+// the status column when cfg.Aligned is set. Other status codes can overflow
+// the column.
+var alignedStatusCodes = []int{200, 400, 401, 402, 403, 404, 409, 429, 500, 502, 503, 504}
+
+// timestampColumnWidth returns the width of the timestamp column when
+// cfg.Aligned is set.
+func (tailer *Tailer) timestampColumnWidth() int {
+	width := len(tailer.timestampFormat().format(time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)))
+
+	if tailer.cfg.RelativeTimestamps {
+		for _, relative := range []string{"just now", "59s ago", "59m ago"} {
+			if len(relative) > width {
+				width = len(relative)
+			}
+		}
+	}
+
+	return width
+}
+
+// statusColumnWidth returns the width of the status column, brackets
+// included, when cfg.Aligned is set.
+func (tailer *Tailer) statusColumnWidth() int {
+	width := 0
+	for _, status := range alignedStatusCodes {
+		if labelWidth := len(tailer.statusLabel(status)) + 2; labelWidth > width {
+			width = labelWidth
+		}
+	}
+
+	return width
+}
+
+// formatRequestLogEvent renders a request log in the default output format.
+func (tailer *Tailer) formatRequestLogEvent(event *websocket.RequestLogEvent, payload *EventPayload, hl highlight) string {
+	color := tailer.cfg.colorMode().Color(tailer.cfg.Stdout)
+	coloredStatus := color.Colorize(tailer.statusLabel(payload.Status), tailer.cfg.Theme.StatusColor(payload.Status))
+	coloredMethod := tailer.colorizeMethod(payload.Method)
+	style := func(text string) string { return text }
+
+	switch hl {
+	case highlightMatch:
+		coloredStatus = coloredStatus.Reverse()
+		coloredMethod = coloredMethod.Bold()
+		style = func(text string) string { return color.Sprintf(color.Bold(text)) }
+	case highlightDimmed:
+		coloredStatus = coloredStatus.Faint()
+		coloredMethod = coloredMethod.Faint()
+		style = func(text string) string { return color.Sprintf(color.Faint(text)) }
+	}
+
+	url := fmt.Sprintf("https://dashboard.stripe.com/test/logs/%s", payload.RequestID)
+	requestLink := tailer.cfg.colorMode().Linkify(payload.RequestID, url, tailer.cfg.Stdout)
+
+	timestampColumn := style(tailer.formatTimestamp(payload))
+	statusColumn := fmt.Sprintf("[%s]", coloredStatus)
+	methodColumn := coloredMethod.String()
+
+	if tailer.cfg.Aligned {
+		timestampColumn = ansi.PadRight(timestampColumn, tailer.timestampColumnWidth())
+		statusColumn = ansi.PadRight(statusColumn, tailer.statusColumnWidth())
+		methodColumn = ansi.PadRight(methodColumn, methodColumnWidth)
+	}
+
+	// The URL is inserted last so that it can be truncated to fit the rest
+	// of the line within the terminal
+	prefix := fmt.Sprintf("%s %s %s ", timestampColumn, statusColumn, methodColumn)
+	// The labels of several keys already show their mode
+	if payload.Livemode != nil && !*payload.Livemode && !tailer.labeled() {
+		prefix = fmt.Sprintf("%s %s", color.Sprintf(color.Faint("[TEST]")), prefix)
+	}
+	if tailer.cfg.Glyphs {
+		glyph := tailer.glyph(payload.Status)
+		if hl == highlightDimmed {
+			glyph = glyph.Faint()
+		}
+		prefix = fmt.Sprintf("%s %s", glyph, prefix)
+	}
+
+	outputStr := " " + style(requestLink)
+
+	if tailer.cfg.ShowLogID && event.RequestLogID != "" {
+		outputStr += " " + style(event.RequestLogID)
+	}
+
+	if tailer.cfg.Wide {
+		for _, field := range wideFields(payload) {
+			outputStr += " " + style(field)
+		}
+	}
+
+	// Show the values of the active filters that aren't already part of the line
+	if len(tailer.cfg.FilterAPIVersion) > 0 && !tailer.cfg.Wide {
+		outputStr += style(fmt.Sprintf(" [api_version: %s]", payload.APIVersion))
+	}
+	if len(tailer.cfg.FilterErrorType) > 0 {
+		outputStr += style(fmt.Sprintf(" [error_type: %s]", payload.Error.Type))
+	}
+	if len(tailer.cfg.FilterIdempotencyKeys) > 0 {
+		outputStr += style(fmt.Sprintf(" [idempotency_key: %s]", payload.IdempotencyKey))
+	}
+	if tailer.cfg.FilterMinLatency > 0 || tailer.cfg.FilterMaxLatency > 0 {
+		if latency, ok := payload.LatencyDuration(); ok {
+			outputStr += style(fmt.Sprintf(" [latency: %s]", latency))
+		}
+	}
+
+	requestURL := "[View path in dashboard]"
+	if payload.URL != "" {
+		requestURL = truncateURL(payload.URL, tailer.maxURLLength(ansi.Width(prefix)+ansi.Width(outputStr)))
+	}
+
+	return prefix + style(requestURL) + outputStr
+}
+
+// minAutoURLLength is the length URLs are never truncated below when
+// cfg.MaxURLLength is negative, however narrow the terminal
+const minAutoURLLength = 20
+
+// maxURLLength returns the number of characters URLs are truncated to, or 0
+// if they aren't truncated. lineWidth is the width of the rest of the line,
+// used when the length is derived from the width of the terminal.
+func (tailer *Tailer) maxURLLength(lineWidth int) int {
+	if tailer.cfg.MaxURLLength >= 0 {
+		return tailer.cfg.MaxURLLength
+	}
+
+	width, ok := tailer.terminalWidth()
+	if !ok {
+		return 0
+	}
+
+	if length := width - lineWidth; length > minAutoURLLength {
+		return length
+	}
+
+	return minAutoURLLength
+}
+
+// truncateURL shortens the URL to at most maxLength characters, or returns it
+// as is if maxLength is 0. The query string is dropped before the path is
+// truncated so that as much of the path as possible is kept.
+func truncateURL(url string, maxLength int) string {
+	if maxLength <= 0 || ansi.Width(url) <= maxLength {
+		return url
+	}
+
+	if i := strings.IndexByte(url, '?'); i >= 0 {
+		path := url[:i]
+		if ansi.Width(path)+2 <= maxLength {
+			return ansi.Truncate(url, maxLength)
+		}
+		url = path
+	}
+
+	return ansi.Truncate(url, maxLength)
+}
+
+// wideFields returns the extra columns of the request log when cfg.Wide is
+// set: the API version, source, IP address and error code. Missing fields are
+// rendered as `-` so that the columns stay stable.
+func wideFields(payload *EventPayload) []string {
+	fields := []string{payload.APIVersion, payload.Source, payload.IPAddress, payload.Error.Code}
+	for i, field := range fields {
+		if field == "" {
+			fields[i] = "-"
+		}
+	}
+
+	return fields
+}
+
+// stripeStatusTexts are the texts of the status codes that can show up in
+// request logs but don't have a standard text, e.g. when the client closed
+// the connection before Stripe responded.
+var stripeStatusTexts = map[int]string{
+	499: "Client Closed Request",
+}
+
+// statusText returns the text of a status code, or an empty string if it's
+// unknown.
+func statusText(status int) string {
+	if text, ok := stripeStatusTexts[status]; ok {
+		return text
+	}
+
+	return http.StatusText(status)
+}
+
+// statusLabel returns the status code followed by its text unless
+// cfg.NoStatusText is set, e.g. `402 Payment Required`. Unknown status codes
+// are returned as is.
+func (tailer *Tailer) statusLabel(status int) string {
+	text := statusText(status)
+	if tailer.cfg.NoStatusText || text == "" {
+		return strconv.Itoa(status)
+	}
+
+	return fmt.Sprintf("%d %s", status, text)
+}
+
+// statusGlyph returns the glyph of a status code: ✓ for successes, ⚠ for
+// client errors and ✗ for server errors, or their ASCII equivalents OK, ?? and
+// !! if unicode is false. Like in Theme.StatusColor, any status code lower
+// than 400 is a success.
+func statusGlyph(status int, unicode bool) string {
+	switch {
+	case status >= 500:
+		if unicode {
+			return "✗"
+		}
+		return "!!"
+	case status >= 400:
+		if unicode {
+			return "⚠"
+		}
+		return "??"
+	default:
+		if unicode {
+			return "✓"
+		}
+		return "OK"
+	}
+}
+
+// glyph returns the colorized glyph of a status code. ASCII glyphs are used
+// when the locale doesn't support UTF-8 or colors are disabled, as both hint
+// at a terminal that can't display the other glyphs.
+func (tailer *Tailer) glyph(status int) aurora.Value {
+	color := tailer.cfg.colorMode().Color(tailer.cfg.Stdout)
+	unicode := tailer.cfg.colorMode().SupportsColors(tailer.cfg.Stdout) && tailer.supportsUnicode()
+
+	return color.Colorize(statusGlyph(status, unicode), tailer.cfg.Theme.StatusColor(status))
+}
+
+func (tailer *Tailer) colorizeStatus(status int) aurora.Value {
+	color := tailer.cfg.colorMode().Color(tailer.cfg.Stdout)
+
+	return color.Colorize(status, tailer.cfg.Theme.StatusColor(status))
+}
+
+func (tailer *Tailer) colorizeMethod(method string) aurora.Value {
+	color := tailer.cfg.colorMode().Color(tailer.cfg.Stdout)
+
+	return color.Colorize(method, tailer.cfg.Theme.MethodColor(method))
+}
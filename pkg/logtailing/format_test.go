@@ -0,0 +1,485 @@
+package logtailing
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// noEvent is the request log event of the payloads built by the tests, which
+// don't need a request log ID
+var noEvent = &websocket.RequestLogEvent{}
+
+// withColors forces colors on or off, and returns a function restoring the
+// previous settings.
+func withColors(enabled bool) func() {
+	forceColors, disableColors := ansi.ForceColors, ansi.DisableColors
+	ansi.ForceColors, ansi.DisableColors = enabled, !enabled
+	return func() {
+		ansi.ForceColors, ansi.DisableColors = forceColors, disableColors
+	}
+}
+
+// withInteractive forces the interactive output (e.g. cursor movements) on or
+// off, and returns a function restoring the previous settings
+func withInteractive(enabled bool) func() {
+	forceInteractive, disableInteractive := ansi.ForceInteractive, ansi.DisableInteractive
+	ansi.ForceInteractive, ansi.DisableInteractive = enabled, !enabled
+	return func() {
+		ansi.ForceInteractive, ansi.DisableInteractive = forceInteractive, disableInteractive
+	}
+}
+
+func localTime(createdAt int) string {
+	return time.Unix(int64(createdAt), 0).Format("2006-01-02 15:04:05")
+}
+
+func TestFormatRequestLogEvent(t *testing.T) {
+	defer withColors(false)()
+	tailer := New(&Config{})
+
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "POST", RequestID: "req_123", Status: 200, URL: "/v1/charges"}
+	require.Equal(t, fmt.Sprintf("%s [200 OK] POST /v1/charges req_123", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+
+	payload = &EventPayload{CreatedAt: 1570000000, Method: "GET", RequestID: "req_123", Status: 404}
+	require.Equal(t, fmt.Sprintf("%s [404 Not Found] GET [View path in dashboard] req_123", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+}
+
+func TestFormatRequestLogEventColorMode(t *testing.T) {
+	// The color mode takes precedence over the global settings
+	defer withColors(true)()
+
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "POST", RequestID: "req_123", Status: 500, URL: "/v1/charges"}
+
+	tailer := New(&Config{ColorMode: "never"})
+	require.Equal(t, fmt.Sprintf("%s [500 Internal Server Error] POST /v1/charges req_123", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+	require.Equal(t, `{"status":500}`, tailer.cfg.colorMode().ColorizeJSON(`{"status":500}`, tailer.cfg.Stdout))
+
+	ansi.ForceColors, ansi.DisableColors = false, true
+
+	tailer = New(&Config{ColorMode: "always"})
+	require.Contains(t, tailer.formatRequestLogEvent(noEvent, payload, highlightNone), "\x1b[1;31m500 Internal Server Error\x1b[0m")
+}
+
+func TestFormatRequestLogEventTheme(t *testing.T) {
+	defer withColors(true)()
+
+	tailer := New(&Config{Theme: ansi.Themes["light"]})
+
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "POST", RequestID: "req_123", Status: 402, URL: "/v1/charges"}
+	require.Contains(t, tailer.formatRequestLogEvent(noEvent, payload, highlightNone), "\x1b[1;35m402 Payment Required\x1b[0m")
+}
+
+func TestFormatRequestLogEventLatency(t *testing.T) {
+	defer withColors(false)()
+	latency := 2100
+	payload := &EventPayload{CreatedAt: 1570000000, Latency: &latency, Method: "POST", RequestID: "req_123", Status: 200, URL: "/v1/charges"}
+
+	tailer := New(&Config{})
+	require.NotContains(t, tailer.formatRequestLogEvent(noEvent, payload, highlightNone), "latency")
+
+	tailer = New(&Config{FilterMinLatency: 2 * time.Second})
+	require.Equal(t, fmt.Sprintf("%s [200 OK] POST /v1/charges req_123 [latency: 2.1s]", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+}
+
+func TestFormatRequestLogEventHighlight(t *testing.T) {
+	defer withColors(true)()
+	tailer := New(&Config{})
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "POST", RequestID: "req_123", Status: 500, URL: "/v1/charges"}
+
+	plain := tailer.formatRequestLogEvent(noEvent, payload, highlightNone)
+	require.NotContains(t, plain, "\x1b[7m")
+	require.NotContains(t, plain, "\x1b[2m")
+
+	// Matching lines are bold with an inverted status
+	matched := tailer.formatRequestLogEvent(noEvent, payload, highlightMatch)
+	require.Contains(t, matched, "\x1b[1;7;31m500 Internal Server Error")
+	require.Contains(t, matched, "\x1b[1;32mPOST")
+
+	// Other lines are dimmed, and the status keeps its color
+	dimmed := tailer.formatRequestLogEvent(noEvent, payload, highlightDimmed)
+	require.Contains(t, dimmed, "\x1b[2;31m500 Internal Server Error")
+	require.Contains(t, dimmed, "\x1b[2;32mPOST")
+}
+
+func TestColorizeMethod(t *testing.T) {
+	defer withColors(true)()
+	tailer := New(&Config{})
+
+	require.Equal(t, "\x1b[36mGET\x1b[0m", tailer.colorizeMethod("GET").String())
+	require.Equal(t, "\x1b[32mPOST\x1b[0m", tailer.colorizeMethod("POST").String())
+	require.Equal(t, "\x1b[31mDELETE\x1b[0m", tailer.colorizeMethod("DELETE").String())
+	require.Equal(t, "PATCH", tailer.colorizeMethod("PATCH").String())
+
+	// Themes set the colors of the methods
+	tailer = New(&Config{Theme: ansi.Themes["mono"]})
+	require.Equal(t, "GET", tailer.colorizeMethod("GET").String())
+	require.Equal(t, "\x1b[1mDELETE\x1b[0m", tailer.colorizeMethod("DELETE").String())
+
+	// Methods are printed as is without colors
+	tailer = New(&Config{ColorMode: "never"})
+	require.Equal(t, "DELETE", tailer.colorizeMethod("DELETE").String())
+
+	defer withColors(false)()
+	tailer = New(&Config{})
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "DELETE", RequestID: "req_123", Status: 200, URL: "/v1/customers/cus_123"}
+	require.Equal(t, fmt.Sprintf("%s [200 OK] DELETE /v1/customers/cus_123 req_123", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+}
+
+func TestHighlighting(t *testing.T) {
+	restore := withColors(true)
+	defer restore()
+	require.True(t, New(&Config{HighlightMode: true, FilterHTTPMethods: []string{"POST"}}).highlighting())
+	require.False(t, New(&Config{HighlightMode: true}).highlighting())
+	require.False(t, New(&Config{HighlightMode: true, FilterHTTPMethods: []string{"POST"}, OutputFormat: outputFormatJSON}).highlighting())
+	require.False(t, New(&Config{FilterHTTPMethods: []string{"POST"}}).highlighting())
+
+	withColors(false)
+	require.False(t, New(&Config{HighlightMode: true, FilterHTTPMethods: []string{"POST"}}).highlighting())
+}
+
+func TestJSONPayload(t *testing.T) {
+	rawPayload := "{\"amount\": 1000, \"fee\": 1e3,\n\"error\": {\"code\": \"card_declined\"}}"
+
+	tailer := New(&Config{})
+	require.Equal(t, "{\n  \"amount\": 1000,\n  \"fee\": 1e3,\n  \"error\": {\n    \"code\": \"card_declined\"\n  }\n}", tailer.jsonPayload(rawPayload))
+
+	tailer = New(&Config{JSONIndent: "\t"})
+	require.Equal(t, "{\n\t\"amount\": 1000,\n\t\"fee\": 1e3,\n\t\"error\": {\n\t\t\"code\": \"card_declined\"\n\t}\n}", tailer.jsonPayload(rawPayload))
+
+	tailer = New(&Config{JSONCompact: true})
+	require.Equal(t, `{"amount":1000,"fee":1e3,"error":{"code":"card_declined"}}`, tailer.jsonPayload(rawPayload))
+
+	// Malformed payloads are left untouched
+	require.Equal(t, `{"amount": 1000`, tailer.jsonPayload(`{"amount": 1000`))
+}
+
+func TestJSONPayloadSortKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{
+			"flat",
+			`{"url": "/v1/charges", "status": 200, "method": "POST"}`,
+			`{"method":"POST","status":200,"url":"/v1/charges"}`,
+		},
+		{
+			"nested objects",
+			`{"z": {"b": {"d": 1, "c": 2}, "a": true}, "a": null}`,
+			`{"a":null,"z":{"a":true,"b":{"c":2,"d":1}}}`,
+		},
+		{
+			"arrays keep their order",
+			`{"lines": [{"id": "li_2", "amount": 2}, {"id": "li_1", "amount": 1}], "tags": ["b", "a"]}`,
+			`{"lines":[{"amount":2,"id":"li_2"},{"amount":1,"id":"li_1"}],"tags":["b","a"]}`,
+		},
+		{
+			"numbers and strings are kept as is",
+			`{"fee": 1.50, "amount": 1000, "big": 1e3, "description": "a \"quoted\" key: {z, a}"}`,
+			`{"amount":1000,"big":1e3,"description":"a \"quoted\" key: {z, a}","fee":1.50}`,
+		},
+		{
+			"uppercase sorts first",
+			`{"b": 1, "B": 2, "a": 3}`,
+			`{"B":2,"a":3,"b":1}`,
+		},
+	}
+
+	tailer := New(&Config{JSONCompact: true, SortJSONKeys: true})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tailer.jsonPayload(tt.payload))
+		})
+	}
+
+	// The sorted payload is still indented
+	tailer = New(&Config{SortJSONKeys: true})
+	require.Equal(t, "{\n  \"a\": {\n    \"b\": 1,\n    \"c\": 2\n  },\n  \"b\": [\n    2,\n    1\n  ]\n}", tailer.jsonPayload(`{"b": [2, 1], "a": {"c": 2, "b": 1}}`))
+
+	// Malformed payloads are left untouched
+	require.Equal(t, `{"b": 1, "a": 2`, tailer.jsonPayload(`{"b": 1, "a": 2`))
+}
+
+func TestNDJSONLine(t *testing.T) {
+	event := func(payload string) *websocket.RequestLogEvent {
+		return &websocket.RequestLogEvent{EventPayload: payload}
+	}
+
+	require.Equal(t, `{"status":200,"url":"/v1/charges"}`, ndjsonLine(event("{\n  \"status\": 200,\n  \"url\": \"/v1/charges\"\n}")))
+	require.Equal(t, `{"malformed":true,"raw":"{\"status\": 200"}`, ndjsonLine(event(`{"status": 200`)))
+	require.Equal(t, `{"malformed":true,"raw":"line 1\nline 2"}`, ndjsonLine(event("line 1\nline 2")))
+}
+
+func TestMalformedLine(t *testing.T) {
+	require.Equal(t, `[malformed payload] resp_123 "{\"status\": 200"`, malformedLine(&websocket.RequestLogEvent{EventPayload: `{"status": 200`, RequestLogID: "resp_123"}))
+	require.Equal(t, `[malformed payload] ""`, malformedLine(&websocket.RequestLogEvent{}))
+
+	// The newlines and ANSI sequences are escaped, and long payloads are
+	// truncated
+	require.Equal(t, `[malformed payload] resp_123 "\x1b[31mline 1\nline 2"`, malformedLine(&websocket.RequestLogEvent{EventPayload: "\x1b[31mline 1\nline 2", RequestLogID: "resp_123"}))
+
+	line := malformedLine(&websocket.RequestLogEvent{EventPayload: strings.Repeat("a", 500), RequestLogID: "resp_123"})
+	require.Equal(t, `[malformed payload] resp_123 "`+strings.Repeat("a", malformedRawLength-2)+"…", line)
+}
+
+func TestProcessRequestLogEventMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"truncated", `{"status": 200, "url": "/v1/char`},
+		{"empty", ``},
+		{"unexpected status type", `{"status": "200", "url": "/v1/charges"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer withColors(true)()
+
+			var stdout bytes.Buffer
+			tailer := New(&Config{Stdout: &stdout})
+			tailer.stats = newSessionStats(time.Time{})
+
+			tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+				EventPayload: tt.payload,
+				RequestLogID: "resp_123",
+			}})
+
+			// The raw payload is printed instead of a line of zero values,
+			// uncolored
+			require.Equal(t, malformedLine(&websocket.RequestLogEvent{EventPayload: tt.payload, RequestLogID: "resp_123"})+"\n", stdout.String())
+
+			var summary bytes.Buffer
+			tailer.stats.write(&summary, time.Time{})
+			require.Equal(t, "Session summary (0s)\n  Malformed payloads: 1\n", summary.String())
+		})
+	}
+}
+
+func TestEnvelopeLine(t *testing.T) {
+	receivedAt := time.Date(2019, 10, 2, 7, 6, 40, 123000000, time.FixedZone("PDT", -7*60*60))
+
+	event := &websocket.RequestLogEvent{EventPayload: "{\"amount\": 1000,\n\"status\": 200}", RequestLogID: "resp_123", Type: "request_log_event"}
+	require.Equal(t,
+		`{"received_at":"2019-10-02T14:06:40.123Z","request_log_id":"resp_123","type":"request_log_event","payload":{"amount":1000,"status":200}}`,
+		envelopeLine(event, receivedAt),
+	)
+
+	event.EventPayload = `{"status": 200`
+	require.Equal(t,
+		`{"received_at":"2019-10-02T14:06:40.123Z","request_log_id":"resp_123","type":"request_log_event","payload":{"malformed":true,"raw":"{\"status\": 200"}}`,
+		envelopeLine(event, receivedAt),
+	)
+}
+
+func TestProcessRequestLogEventEnvelope(t *testing.T) {
+	var stdout bytes.Buffer
+	tailer := New(&Config{OutputFormat: outputFormatEnvelope, Stdout: &stdout})
+	tailer.now = func() time.Time { return time.Unix(1570000000, 0) }
+
+	tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+		EventPayload: `{"status": 200, "url": "/v1/charges"}`,
+		RequestLogID: "resp_123",
+		Type:         "request_log_event",
+	}})
+
+	require.Equal(t, `{"received_at":"2019-10-02T07:06:40Z","request_log_id":"resp_123","type":"request_log_event","payload":{"status":200,"url":"/v1/charges"}}`+"\n", stdout.String())
+}
+
+func TestNDJSONLineRequestLogID(t *testing.T) {
+	event := &websocket.RequestLogEvent{EventPayload: `{"status": 200}`, RequestLogID: "resp_123"}
+	require.Equal(t, `{"status":200,"request_log_id":"resp_123"}`, ndjsonLine(event))
+
+	event.EventPayload = `{}`
+	require.Equal(t, `{"request_log_id":"resp_123"}`, ndjsonLine(event))
+
+	event.EventPayload = `{"status": 200`
+	require.Equal(t, `{"malformed":true,"raw":"{\"status\": 200","request_log_id":"resp_123"}`, ndjsonLine(event))
+
+	// Only objects can carry the ID
+	event.EventPayload = `[1, 2]`
+	require.Equal(t, `[1,2]`, ndjsonLine(event))
+}
+
+func TestFormatRequestLogEventShowLogID(t *testing.T) {
+	defer withColors(false)()
+	event := &websocket.RequestLogEvent{RequestLogID: "resp_123"}
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "POST", RequestID: "req_123", Status: 200, URL: "/v1/charges"}
+
+	tailer := New(&Config{})
+	require.NotContains(t, tailer.formatRequestLogEvent(event, payload, highlightNone), "resp_123")
+
+	tailer = New(&Config{ShowLogID: true})
+	require.Equal(t, fmt.Sprintf("%s [200 OK] POST /v1/charges req_123 resp_123", localTime(1570000000)), tailer.formatRequestLogEvent(event, payload, highlightNone))
+}
+
+func TestStructuredOutput(t *testing.T) {
+	require.False(t, (&Config{}).structuredOutput())
+	require.True(t, (&Config{OutputFormat: outputFormatJSON}).structuredOutput())
+	require.True(t, (&Config{OutputFormat: outputFormatNDJSON}).structuredOutput())
+	require.True(t, (&Config{OutputFormat: outputFormatEnvelope}).structuredOutput())
+}
+
+func TestStatusLabel(t *testing.T) {
+	tailer := New(&Config{})
+	require.Equal(t, "402 Payment Required", tailer.statusLabel(402))
+	require.Equal(t, "499 Client Closed Request", tailer.statusLabel(499))
+	require.Equal(t, "299", tailer.statusLabel(299))
+
+	tailer = New(&Config{NoStatusText: true})
+	require.Equal(t, "402", tailer.statusLabel(402))
+
+	defer withColors(false)()
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "POST", RequestID: "req_123", Status: 402, URL: "/v1/charges"}
+	require.Equal(t, fmt.Sprintf("%s [402] POST /v1/charges req_123", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+}
+
+func TestFormatRequestLogEventAligned(t *testing.T) {
+	get := &EventPayload{CreatedAt: 1570000000, Method: "GET", RequestID: "req_123", Status: 200, URL: "/v1/charges"}
+	del := &EventPayload{CreatedAt: 1570000000, Method: "DELETE", RequestID: "req_456", Status: 500, URL: "/v1/customers/cus_123"}
+
+	for _, colors := range []bool{false, true} {
+		restore := withColors(colors)
+
+		tailer := New(&Config{Aligned: true})
+
+		// The URLs start at the same column regardless of colors
+		getLine := ansi.Strip(tailer.formatRequestLogEvent(noEvent, get, highlightNone))
+		delLine := ansi.Strip(tailer.formatRequestLogEvent(noEvent, del, highlightNone))
+		require.Equal(t, strings.Index(getLine, "/v1/"), strings.Index(delLine, "/v1/"), "colors: %v", colors)
+
+		restore()
+	}
+
+	defer withColors(false)()
+
+	tailer := New(&Config{Aligned: true, NoStatusText: true})
+	require.Equal(t, fmt.Sprintf("%s [200] GET    /v1/charges req_123", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, get, highlightNone))
+	require.Equal(t, fmt.Sprintf("%s [500] DELETE /v1/customers/cus_123 req_456", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, del, highlightNone))
+
+	tailer = New(&Config{Aligned: true, NoStatusText: true, RelativeTimestamps: true})
+	tailer.now = func() time.Time { return time.Unix(1570000003, 0) }
+	require.Equal(t, "3s ago              [200] GET    /v1/charges req_123", tailer.formatRequestLogEvent(noEvent, get, highlightNone))
+}
+
+func TestFormatRequestLogEventWide(t *testing.T) {
+	defer withColors(false)()
+	tailer := New(&Config{Wide: true})
+
+	payload := &EventPayload{
+		APIVersion: "2019-09-09",
+		CreatedAt:  1570000000,
+		Error:      ErrorPayload{Code: "card_declined"},
+		IPAddress:  "10.0.0.1",
+		Method:     "POST",
+		RequestID:  "req_123",
+		Source:     "dashboard",
+		Status:     402,
+		URL:        "/v1/charges",
+	}
+	require.Equal(t, fmt.Sprintf("%s [402 Payment Required] POST /v1/charges req_123 2019-09-09 dashboard 10.0.0.1 card_declined", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+
+	// Missing fields are rendered as -
+	payload = &EventPayload{CreatedAt: 1570000000, Method: "GET", RequestID: "req_123", Status: 200, URL: "/v1/charges"}
+	require.Equal(t, fmt.Sprintf("%s [200 OK] GET /v1/charges req_123 - - - -", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+
+	// The API version isn't repeated when filtering on it
+	tailer = New(&Config{Wide: true, FilterAPIVersion: []string{"2019-09-09"}})
+	require.NotContains(t, tailer.formatRequestLogEvent(noEvent, payload, highlightNone), "api_version")
+}
+
+func TestTruncateURL(t *testing.T) {
+	require.Equal(t, "/v1/charges?limit=100", truncateURL("/v1/charges?limit=100", 0))
+	require.Equal(t, "/v1/charges?limit=100", truncateURL("/v1/charges?limit=100", 21))
+
+	// The query string is truncated first
+	require.Equal(t, "/v1/charges?lim…", truncateURL("/v1/charges?limit=100", 16))
+	require.Equal(t, "/v1/charges?…", truncateURL("/v1/charges?limit=100", 13))
+
+	// Then the path
+	require.Equal(t, "/v1/charges", truncateURL("/v1/charges?limit=100", 12))
+	require.Equal(t, "/v1/cha…", truncateURL("/v1/charges?limit=100", 8))
+	require.Equal(t, "/v1/cus…", truncateURL("/v1/customers/cus_123", 8))
+}
+
+func TestFormatRequestLogEventMaxURLLength(t *testing.T) {
+	defer withColors(false)()
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "GET", RequestID: "req_123", Status: 200, URL: "/v1/charges?limit=100&starting_after=ch_123"}
+
+	tailer := New(&Config{MaxURLLength: 16, NoStatusText: true})
+	require.Equal(t, fmt.Sprintf("%s [200] GET /v1/charges?lim… req_123", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+
+	// The placeholder of missing URLs isn't truncated
+	tailer = New(&Config{MaxURLLength: 5, NoStatusText: true})
+	require.Contains(t, tailer.formatRequestLogEvent(noEvent, &EventPayload{Method: "GET", Status: 200}, highlightNone), "[View path in dashboard]")
+
+	// Negative lengths fit the line to the terminal
+	tailer = New(&Config{MaxURLLength: -1, NoStatusText: true})
+	tailer.terminalWidth = func() (int, bool) { return 60, true }
+	line := tailer.formatRequestLogEvent(noEvent, payload, highlightNone)
+	require.Equal(t, 60, ansi.Width(line))
+	require.Contains(t, line, "/v1/charges?limit=")
+
+	// With a minimum length on narrow terminals
+	tailer.terminalWidth = func() (int, bool) { return 30, true }
+	require.Contains(t, tailer.formatRequestLogEvent(noEvent, payload, highlightNone), " /v1/charges?limit=1… ")
+
+	// And no truncation when the output isn't a terminal
+	tailer = New(&Config{MaxURLLength: -1})
+	require.Contains(t, tailer.formatRequestLogEvent(noEvent, payload, highlightNone), payload.URL)
+}
+
+func TestStatusGlyph(t *testing.T) {
+	tests := []struct {
+		status  int
+		unicode string
+		ascii   string
+	}{
+		{200, "✓", "OK"},
+		{201, "✓", "OK"},
+		{302, "✓", "OK"},
+		{400, "⚠", "??"},
+		{402, "⚠", "??"},
+		{499, "⚠", "??"},
+		{500, "✗", "!!"},
+		{503, "✗", "!!"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.unicode, statusGlyph(tt.status, true), "status: %d", tt.status)
+		require.Equal(t, tt.ascii, statusGlyph(tt.status, false), "status: %d", tt.status)
+	}
+}
+
+func TestFormatRequestLogEventGlyphs(t *testing.T) {
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "POST", RequestID: "req_123", Status: 402, URL: "/v1/charges"}
+
+	restore := withColors(true)
+	tailer := New(&Config{Glyphs: true})
+	tailer.supportsUnicode = func() bool { return true }
+	require.True(t, strings.HasPrefix(tailer.formatRequestLogEvent(noEvent, payload, highlightNone), "\x1b[1;33m⚠\x1b[0m "))
+
+	// ASCII glyphs are used without UTF-8 support
+	tailer.supportsUnicode = func() bool { return false }
+	require.True(t, strings.HasPrefix(tailer.formatRequestLogEvent(noEvent, payload, highlightNone), "\x1b[1;33m??\x1b[0m "))
+	restore()
+
+	// Or without colors
+	defer withColors(false)()
+	tailer = New(&Config{Glyphs: true})
+	tailer.supportsUnicode = func() bool { return true }
+	require.Equal(t, fmt.Sprintf("?? %s [402 Payment Required] POST /v1/charges req_123", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+
+	livemode := false
+	payload.Livemode = &livemode
+	require.Equal(t, fmt.Sprintf("?? [TEST] %s [402 Payment Required] POST /v1/charges req_123", localTime(1570000000)), tailer.formatRequestLogEvent(noEvent, payload, highlightNone))
+}
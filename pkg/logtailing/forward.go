@@ -0,0 +1,180 @@
+package logtailing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// forwardAttempts is the number of times the POST of a request log is
+	// attempted before it's dropped
+	forwardAttempts = 3
+
+	// forwardQueueSize is the number of request logs buffered while waiting
+	// to be forwarded. Newer request logs are dropped once the queue is
+	// full.
+	forwardQueueSize = 1000
+
+	forwardRetryDelay = 500 * time.Millisecond
+	forwardTimeout    = 10 * time.Second
+)
+
+// forwarder POSTs the payload of request logs to cfg.ForwardURL. Request logs
+// are forwarded from a separate goroutine so that a slow endpoint never
+// stalls the reads of the websocket client.
+type forwarder struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	log     *log.Logger
+
+	retryDelay time.Duration
+
+	payloads chan string
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+
+	// dropped is the number of request logs that couldn't be forwarded. It
+	// must be accessed atomically.
+	dropped uint64
+}
+
+func newForwarder(url string, headers map[string]string, logger *log.Logger) *forwarder {
+	return &forwarder{
+		url:        url,
+		headers:    headers,
+		client:     &http.Client{Timeout: forwardTimeout},
+		log:        logger,
+		retryDelay: forwardRetryDelay,
+		payloads:   make(chan string, forwardQueueSize),
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+}
+
+// send queues the payload of a request log to be forwarded. The payload is
+// dropped if the queue is full.
+func (f *forwarder) send(payload string) error {
+	select {
+	case f.payloads <- payload:
+		return nil
+	default:
+		atomic.AddUint64(&f.dropped, 1)
+		return errors.New("the forward queue is full")
+	}
+}
+
+// start starts forwarding the queued payloads.
+func (f *forwarder) start() {
+	go f.run()
+}
+
+// close stops forwarding payloads once the queued ones are forwarded, without
+// retrying them. The queued payloads are given up on as soon as one can't be
+// forwarded, as the endpoint is likely down. It returns the number of request
+// logs that couldn't be forwarded, including the ones still queued.
+func (f *forwarder) close() uint64 {
+	close(f.stopCh)
+	<-f.doneCh
+
+	return atomic.LoadUint64(&f.dropped) + uint64(len(f.payloads))
+}
+
+func (f *forwarder) run() {
+	defer close(f.doneCh)
+
+	for {
+		select {
+		case payload := <-f.payloads:
+			if err := f.forward(payload); err != nil {
+				atomic.AddUint64(&f.dropped, 1)
+				f.log.WithFields(log.Fields{
+					"prefix": "logs.forwarder.run",
+					"url":    f.url,
+				}).Debugf("Could not forward request log: %v", err)
+			}
+		case <-f.stopCh:
+			f.drain()
+			return
+		}
+	}
+}
+
+func (f *forwarder) drain() {
+	for {
+		select {
+		case payload := <-f.payloads:
+			if err := f.forward(payload); err != nil {
+				atomic.AddUint64(&f.dropped, 1)
+				f.log.WithFields(log.Fields{
+					"prefix": "logs.forwarder.drain",
+					"url":    f.url,
+				}).Debugf("Could not forward request log: %v", err)
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// forward POSTs the payload, retrying on network errors and on 429 and 5xx
+// responses.
+func (f *forwarder) forward(payload string) error {
+	var err error
+
+	for attempt := 1; attempt <= forwardAttempts; attempt++ {
+		var retryable bool
+		retryable, err = f.post(payload)
+		if err == nil || !retryable || attempt == forwardAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(f.retryDelay):
+		case <-f.stopCh:
+			return err
+		}
+	}
+
+	return err
+}
+
+// post POSTs the payload once. It returns whether a failed POST is worth
+// retrying.
+func (f *forwarder) post(payload string) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, f.url, bytes.NewBufferString(payload))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range f.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so that the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body) // #nosec G104
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+
+	return retryable, fmt.Errorf("unexpected response status %s", resp.Status)
+}
@@ -0,0 +1,131 @@
+package logtailing
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwarder(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- r
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	logger, _ := test.NewNullLogger()
+	f := newForwarder(server.URL, map[string]string{"Authorization": "Bearer token"}, logger)
+	f.start()
+
+	f.send(`{"status":200,"method":"GET"}`)
+
+	req := <-received
+	require.Equal(t, http.MethodPost, req.Method)
+	require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	require.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	require.Equal(t, `{"status":200,"method":"GET"}`, <-bodies)
+
+	require.Equal(t, uint64(0), f.close())
+}
+
+func TestForwarderRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	logger, _ := test.NewNullLogger()
+	f := newForwarder(server.URL, nil, logger)
+	f.retryDelay = time.Millisecond
+
+	require.NoError(t, f.forward(`{}`))
+	require.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestForwarderGivesUp(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	logger, _ := test.NewNullLogger()
+	f := newForwarder(server.URL, nil, logger)
+	f.retryDelay = time.Millisecond
+
+	// Client errors aren't retried
+	err := f.forward(`{}`)
+	require.EqualError(t, err, "unexpected response status 400 Bad Request")
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestForwarderDropsWhenFull(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	f := newForwarder("http://127.0.0.1:0", nil, logger)
+
+	// The forwarder isn't started, so the queue fills up without blocking
+	for i := 0; i < forwardQueueSize+10; i++ {
+		f.send(`{}`)
+	}
+
+	f.start()
+
+	// The request logs left in the queue are dropped as well
+	require.Equal(t, uint64(forwardQueueSize+10), f.close())
+}
+
+func TestForwarderCloseForwardsQueued(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+
+	logger, _ := test.NewNullLogger()
+	f := newForwarder(server.URL, nil, logger)
+	for i := 0; i < 3; i++ {
+		f.send(`{}`)
+	}
+
+	f.start()
+
+	require.Equal(t, uint64(0), f.close())
+	require.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestForwarderCloseGivesUp(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	logger, _ := test.NewNullLogger()
+	f := newForwarder(server.URL, nil, logger)
+	for i := 0; i < 3; i++ {
+		f.send(`{}`)
+	}
+
+	// The queued request logs aren't retried once closing, and the rest of
+	// them are given up on after the first failure
+	close(f.stopCh)
+	f.drain()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	require.Equal(t, uint64(1), atomic.LoadUint64(&f.dropped))
+	require.Len(t, f.payloads, 2)
+}
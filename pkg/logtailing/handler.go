@@ -0,0 +1,35 @@
+package logtailing
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// Handler handles the request logs matching the filters, e.g. for programs
+// embedding the tailer, with the parsed payload of the request log and the
+// request log as it was received. It's called concurrently from the
+// goroutines of the websocket clients, so it must be safe for concurrent use.
+type Handler func(payload *EventPayload, event *websocket.RequestLogEvent)
+
+// printsOutput returns true if the request logs are printed to Stdout, rather
+// than only handled by cfg.Handler or other sinks
+func (cfg *Config) printsOutput() bool {
+	return !cfg.NoStdout && (cfg.Handler == nil || cfg.KeepOutput)
+}
+
+// callHandler calls cfg.Handler with copies of the request log, so that it
+// can't alter its output. A panicking handler is recovered and logged, as it
+// would stop the websocket client otherwise.
+func (tailer *Tailer) callHandler(payload EventPayload, event websocket.RequestLogEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			tailer.cfg.Log.WithFields(log.Fields{
+				"prefix":         "logs.Tailer.callHandler",
+				"request_log_id": event.RequestLogID,
+			}).Errorf("The request log handler panicked: %v", r)
+		}
+	}()
+
+	tailer.cfg.Handler(&payload, &event)
+}
@@ -0,0 +1,98 @@
+package logtailing
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func requestLogMessage(payload string) websocket.IncomingMessage {
+	return websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+		EventPayload: payload,
+		RequestLogID: "resp_123",
+		Type:         "request_log_event",
+	}}
+}
+
+func TestHandler(t *testing.T) {
+	var payloads []EventPayload
+	var events []websocket.RequestLogEvent
+
+	var stdout bytes.Buffer
+	tailer := New(&Config{
+		FilterStatusCodes: []int{402},
+		Handler: func(payload *EventPayload, event *websocket.RequestLogEvent) {
+			payloads = append(payloads, *payload)
+			events = append(events, *event)
+
+			// The handler can't alter the output
+			payload.Status = 500
+		},
+		NoSummary: true,
+		Stdout:    &stdout,
+	})
+	require.NoError(t, tailer.cfg.validateOptions())
+
+	tailer.processRequestLogEvent(requestLogMessage(`{"request_id": "req_123", "status": 402}`))
+	tailer.processRequestLogEvent(requestLogMessage(`{"request_id": "req_456", "status": 200}`))
+
+	// The request logs hidden by the filters aren't handled, and the handled
+	// ones aren't printed
+	require.Len(t, payloads, 1)
+	require.Equal(t, "req_123", payloads[0].RequestID)
+	require.Equal(t, 402, payloads[0].Status)
+	require.Equal(t, `{"request_id": "req_123", "status": 402}`, events[0].EventPayload)
+	require.Equal(t, "resp_123", events[0].RequestLogID)
+	require.Empty(t, stdout.String())
+}
+
+func TestHandlerKeepOutput(t *testing.T) {
+	handled := 0
+
+	var stdout bytes.Buffer
+	tailer := New(&Config{
+		OutputFormat: outputFormatCSV,
+		Handler: func(payload *EventPayload, event *websocket.RequestLogEvent) {
+			handled++
+			payload.Status = 500
+		},
+		KeepOutput: true,
+		NoSummary:  true,
+		Stdout:     &stdout,
+	})
+
+	tailer.processRequestLogEvent(requestLogMessage(`{"request_id": "req_123", "status": 402}`))
+
+	require.Equal(t, 1, handled)
+	require.Contains(t, stdout.String(), ",402,")
+	require.Contains(t, stdout.String(), ",req_123,")
+}
+
+func TestHandlerPanic(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	handled := 0
+
+	tailer := New(&Config{
+		Handler: func(payload *EventPayload, event *websocket.RequestLogEvent) {
+			handled++
+			if payload.RequestID == "req_123" {
+				panic("boom")
+			}
+		},
+		Log:       logger,
+		NoSummary: true,
+	})
+
+	// The panic doesn't escape to the websocket client, and the next
+	// request logs are still handled
+	tailer.processRequestLogEvent(requestLogMessage(`{"request_id": "req_123", "status": 200}`))
+	tailer.processRequestLogEvent(requestLogMessage(`{"request_id": "req_456", "status": 200}`))
+
+	require.Equal(t, 2, handled)
+	require.Equal(t, "The request log handler panicked: boom", hook.LastEntry().Message)
+	require.Equal(t, "resp_123", hook.LastEntry().Data["request_log_id"])
+}
@@ -0,0 +1,86 @@
+package logtailing
+
+import (
+	"fmt"
+	"time"
+)
+
+// resetIdle resets the idle timer of cfg.IdleAfter when a request log is
+// received. It never blocks the websocket clients, as a pending reset is
+// enough.
+func (tailer *Tailer) resetIdle() {
+	if tailer.idleResetCh == nil {
+		return
+	}
+
+	select {
+	case tailer.idleResetCh <- struct{}{}:
+	default:
+	}
+}
+
+// watchIdle prints a notice when no request log was received for
+// cfg.IdleAfter, until stopCh is closed. The notice is printed once per idle
+// period, as the timer is only reset by the next request log.
+func (tailer *Tailer) watchIdle(stopCh chan struct{}) {
+	if tailer.idleResetCh == nil || tailer.cfg.Quiet {
+		return
+	}
+
+	timer := time.NewTimer(tailer.cfg.IdleAfter)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-tailer.idleResetCh:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(tailer.cfg.IdleAfter)
+		case <-timer.C:
+			tailer.printNotice(tailer.idleMessage(tailer.now()))
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// idleMessage returns the notice printed when no request log was received
+// for cfg.IdleAfter, with the state of the connection and when Stripe last
+// answered a ping, so that a quiet account can be told apart from a broken
+// connection, e.g.
+//
+//	No request logs received in the last 5m0s (connection healthy, last ping 12s ago)
+func (tailer *Tailer) idleMessage(now time.Time) string {
+	liveness := "connection healthy"
+	switch tailer.state() {
+	case StateConnecting:
+		liveness = "still connecting"
+	case StateReconnecting:
+		liveness = "reconnecting"
+	}
+
+	if lastPong := tailer.lastPong(); !lastPong.IsZero() {
+		liveness += fmt.Sprintf(", last ping %s ago", now.Sub(lastPong).Round(time.Second))
+	}
+
+	return fmt.Sprintf("No request logs received in the last %s (%s)", tailer.cfg.IdleAfter, liveness)
+}
+
+// lastPong returns when Stripe last answered the pings of the websocket
+// clients, or the zero time if it never did
+func (tailer *Tailer) lastPong() time.Time {
+	var last time.Time
+	for _, st := range tailer.streams {
+		if st.client == nil {
+			continue
+		}
+		if pong := st.client.LastPong(); pong.After(last) {
+			last = pong
+		}
+	}
+	return last
+}
@@ -0,0 +1,67 @@
+package logtailing
+
+import (
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestIdleMessage(t *testing.T) {
+	tailer := New(&Config{IdleAfter: 5 * time.Minute})
+	atomic.StoreInt32(&tailer.ready, 1)
+
+	require.Equal(t, "No request logs received in the last 5m0s (connection healthy)", tailer.idleMessage(time.Now()))
+
+	tailer.streams = []*stream{{client: websocket.NewClient("ws://127.0.0.1", "ws_123", "request_logs", nil)}}
+	require.Equal(t, "No request logs received in the last 5m0s (reconnecting)", tailer.idleMessage(time.Now()))
+}
+
+func TestWatchIdle(t *testing.T) {
+	var stderr lockedBuffer
+	tailer := New(&Config{
+		ColorMode: "never",
+		IdleAfter: 20 * time.Millisecond,
+		Stderr:    &stderr,
+		Stdout:    ioutil.Discard,
+	})
+	atomic.StoreInt32(&tailer.ready, 1)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go tailer.watchIdle(stopCh)
+
+	notices := func() int {
+		return strings.Count(stderr.String(), "No request logs received in the last 20ms (connection healthy)\n")
+	}
+
+	require.Eventually(t, func() bool { return notices() == 1 }, time.Second, time.Millisecond)
+
+	// The notice isn't repeated until a request log resets the timer
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, 1, notices())
+
+	tailer.processRequestLogEvent(requestLogMessage(`{"status": 200}`))
+	require.Eventually(t, func() bool { return notices() == 2 }, time.Second, time.Millisecond)
+}
+
+func TestWatchIdleQuiet(t *testing.T) {
+	var stderr lockedBuffer
+	tailer := New(&Config{
+		IdleAfter: time.Millisecond,
+		Quiet:     true,
+		Stderr:    &stderr,
+	})
+
+	stopCh := make(chan struct{})
+	close(stopCh)
+
+	// It returns right away in quiet mode
+	tailer.watchIdle(stopCh)
+	require.Empty(t, stderr.String())
+}
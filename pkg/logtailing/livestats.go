@@ -0,0 +1,126 @@
+package logtailing
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+)
+
+// liveStatsInterval is how often the live stats line is redrawn
+const liveStatsInterval = 1 * time.Second
+
+// clearLine moves the cursor to the start of the current line and clears it
+const clearLine = "\r\x1b[2K"
+
+// liveStats tracks the throughput shown on the live stats line, e.g.
+// `⠧ 142 req/s · 2.1% errors · last: 200 POST /v1/charges`. Request logs
+// are recorded concurrently with the redraws, so it's guarded by a mutex.
+type liveStats struct {
+	mu sync.Mutex
+
+	frames []string
+	frame  int
+
+	// windowCount is the number of request logs received since windowStart,
+	// which are used to compute rate on every tick
+	windowCount int
+	windowStart time.Time
+	rate        float64
+
+	total  int
+	errors int
+	last   string
+}
+
+func newLiveStats(start time.Time) *liveStats {
+	return &liveStats{
+		frames:      ansi.SpinnerFrames(),
+		windowStart: start,
+	}
+}
+
+// record adds a request log to the stats.
+func (s *liveStats) record(event RenderedEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.windowCount++
+	s.total++
+	if event.Status >= 400 {
+		s.errors++
+	}
+	s.last = fmt.Sprintf("%d %s %s", event.Status, event.Method, event.URL)
+}
+
+// tick computes the throughput since the previous tick and advances the
+// spinner.
+func (s *liveStats) tick(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elapsed := now.Sub(s.windowStart); elapsed > 0 {
+		s.rate = float64(s.windowCount) / elapsed.Seconds()
+	}
+	s.windowCount = 0
+	s.windowStart = now
+
+	s.frame = (s.frame + 1) % len(s.frames)
+}
+
+// line renders the stats line.
+func (s *liveStats) line() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errorRate := 0.0
+	if s.total > 0 {
+		errorRate = float64(s.errors) * 100 / float64(s.total)
+	}
+
+	line := fmt.Sprintf("%s %d req/s · %.1f%% errors", s.frames[s.frame], int(math.Round(s.rate)), errorRate)
+	if s.last != "" {
+		line += " · last: " + s.last
+	}
+
+	return line
+}
+
+// clearLiveStats clears the live stats line, if any, so that other output can
+// be printed in its place. The caller must hold outputMu.
+func (tailer *Tailer) clearLiveStats() {
+	if tailer.live != nil {
+		fmt.Fprint(tailer.stdout, clearLine)
+	}
+}
+
+// drawLiveStats draws the live stats line, if any, below the output. The line
+// is truncated to the width of the terminal, as a wrapped line couldn't be
+// cleared. The caller must hold outputMu.
+func (tailer *Tailer) drawLiveStats() {
+	if tailer.live == nil {
+		return
+	}
+
+	line := tailer.live.line()
+	if width, ok := tailer.terminalWidth(); ok {
+		line = ansi.Truncate(line, width-1)
+	}
+
+	color := tailer.cfg.colorMode().Color(tailer.cfg.Stdout)
+	fmt.Fprint(tailer.stdout, clearLine+color.Faint(line).String())
+}
+
+// tickLiveStats updates and redraws the live stats line. It's called from
+// the goroutine of the event writer so that the redraws never interleave
+// with the request logs.
+func (tailer *Tailer) tickLiveStats(now time.Time) {
+	tailer.live.tick(now)
+
+	tailer.outputMu.Lock()
+	defer tailer.outputMu.Unlock()
+
+	tailer.drawLiveStats()
+}
@@ -0,0 +1,70 @@
+package logtailing
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveStats(t *testing.T) {
+	start := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	stats := newLiveStats(start)
+	stats.frames = []string{"-", "\\"}
+
+	require.Equal(t, "- 0 req/s · 0.0% errors", stats.line())
+
+	for i := 0; i < 9; i++ {
+		stats.record(RenderedEvent{Method: "GET", Status: 200, URL: "/v1/charges"})
+	}
+	stats.record(RenderedEvent{Method: "POST", Status: 500, URL: "/v1/charges"})
+
+	stats.tick(start.Add(2 * time.Second))
+	require.Equal(t, "\\ 5 req/s · 10.0% errors · last: 500 POST /v1/charges", stats.line())
+
+	// The throughput only counts the request logs since the previous tick,
+	// and the error rate all of them
+	stats.record(RenderedEvent{Method: "GET", Status: 200, URL: "/v1/customers"})
+	stats.tick(start.Add(3 * time.Second))
+	require.Equal(t, "- 1 req/s · 9.1% errors · last: 200 GET /v1/customers", stats.line())
+}
+
+func TestPrintRequestLogEventLiveStats(t *testing.T) {
+	defer withColors(false)()
+
+	var stdout bytes.Buffer
+	tailer := New(&Config{Stdout: &stdout})
+	tailer.live = newLiveStats(time.Now())
+	tailer.live.frames = []string{"-"}
+	tailer.terminalWidth = func() (int, bool) { return 30, true }
+
+	require.NoError(t, stdoutSink{tailer}.Write(RenderedEvent{Line: "200 GET /v1/charges", Method: "GET", Status: 200, URL: "/v1/charges"}))
+
+	// The stats line is cleared before the request log, then redrawn below
+	// it and truncated to the width of the terminal
+	require.Equal(t, clearLine+"200 GET /v1/charges\n"+clearLine+"- 0 req/s · 0.0% errors · la…", stdout.String())
+
+	stdout.Reset()
+	tailer.printNotice("Hid 2 request logs")
+	require.Equal(t, clearLine+clearLine+"- 0 req/s · 0.0% errors · la…", stdout.String())
+}
+
+func TestEventWriterTicks(t *testing.T) {
+	ticks := make(chan time.Time, 1)
+	w := newEventWriter(&recordingSink{}, 1)
+	w.tick(time.Millisecond, func(now time.Time) {
+		select {
+		case ticks <- now:
+		default:
+		}
+	})
+	w.start()
+	defer w.close()
+
+	select {
+	case <-ticks:
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for a tick")
+	}
+}
@@ -0,0 +1,144 @@
+package logtailing
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// logfmtLeadingKeys are the keys rendered first in the logfmt output format,
+// in this order. The other fields of EventPayload follow in the order they're
+// declared, so that new fields show up without changes to the renderer.
+var logfmtLeadingKeys = []string{"time", "status", "method", "url", "request_id"}
+
+// logfmtPair is a key and its value in a logfmt line
+type logfmtPair struct {
+	key   string
+	value string
+}
+
+// logfmtLine renders a request log as a logfmt line, e.g.
+// `time=2019-10-02T07:06:40Z status=200 method=POST url=/v1/charges`.
+// Optional fields are left out when they're empty, and the `resp_` ID of the
+// request log follows the leading keys as request_log_id. The creation time is
+// rendered with the timestamp format.
+func logfmtLine(event *websocket.RequestLogEvent, payload *EventPayload, tf timestampFormat) string {
+	pairs := logfmtPairs("", reflect.ValueOf(*payload), tf)
+
+	var leading, trailing []string
+	for _, key := range logfmtLeadingKeys {
+		for _, pair := range pairs {
+			if pair.key == key {
+				leading = append(leading, pair.String())
+			}
+		}
+	}
+	if event.RequestLogID != "" {
+		leading = append(leading, logfmtPair{key: "request_log_id", value: event.RequestLogID}.String())
+	}
+	for _, pair := range pairs {
+		if !isLeadingLogfmtKey(pair.key) && pair.value != "" {
+			trailing = append(trailing, pair.String())
+		}
+	}
+
+	return strings.Join(append(leading, trailing...), " ")
+}
+
+// logfmtMalformedLine renders a request log whose payload isn't valid JSON as
+// a logfmt line, e.g. `malformed=true request_log_id=resp_123 raw="{"`, like
+// the NDJSON output format does.
+func logfmtMalformedLine(event *websocket.RequestLogEvent) string {
+	pairs := []string{logfmtPair{key: "malformed", value: "true"}.String()}
+	if event.RequestLogID != "" {
+		pairs = append(pairs, logfmtPair{key: "request_log_id", value: event.RequestLogID}.String())
+	}
+	pairs = append(pairs, logfmtPair{key: "raw", value: event.EventPayload}.String())
+
+	return strings.Join(pairs, " ")
+}
+
+// logfmtPairs returns the pairs for the fields of a struct, keyed by their JSON
+// names. The fields of nested structs are prefixed with the name of the
+// struct field, e.g. `error_code`.
+func logfmtPairs(prefix string, v reflect.Value, tf timestampFormat) []logfmtPair {
+	var pairs []logfmtPair
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		key := prefix + name
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				pairs = append(pairs, logfmtPair{key: logfmtKey(key)})
+				continue
+			}
+			value = value.Elem()
+		}
+
+		if value.Kind() == reflect.Struct {
+			pairs = append(pairs, logfmtPairs(key+"_", value, tf)...)
+			continue
+		}
+
+		pairs = append(pairs, logfmtPair{key: logfmtKey(key), value: logfmtValue(key, value, tf)})
+	}
+
+	return pairs
+}
+
+// logfmtKey renames the keys that have a more conventional name in logfmt
+func logfmtKey(key string) string {
+	if key == "created_at" {
+		return "time"
+	}
+	return key
+}
+
+func logfmtValue(key string, value reflect.Value, tf timestampFormat) string {
+	if key == "created_at" {
+		if value.Int() <= 0 {
+			return ""
+		}
+		return tf.format(time.Unix(value.Int(), 0))
+	}
+
+	return fmt.Sprint(value.Interface())
+}
+
+func isLeadingLogfmtKey(key string) bool {
+	for _, leading := range logfmtLeadingKeys {
+		if key == leading {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the pair, quoting the value if it's empty or contains
+// spaces, quotes, equal signs or control characters.
+func (pair logfmtPair) String() string {
+	needsQuoting := pair.value == "" || strings.IndexFunc(pair.value, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || unicode.IsSpace(r) || unicode.IsControl(r)
+	}) != -1
+
+	if needsQuoting {
+		return pair.key + "=" + strconv.Quote(pair.value)
+	}
+
+	return pair.key + "=" + pair.value
+}
@@ -0,0 +1,95 @@
+package logtailing
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestLogfmtLine(t *testing.T) {
+	livemode := true
+	latency := 85
+	payload := &EventPayload{
+		Account:   "acct_123",
+		CreatedAt: 1570000000,
+		Error:     ErrorPayload{Code: "card_declined", Message: "Your card was declined."},
+		Latency:   &latency,
+		Livemode:  &livemode,
+		Method:    "POST",
+		RequestID: "req_123",
+		Status:    402,
+		URL:       "/v1/charges",
+	}
+
+	require.Equal(t,
+		`time=2019-10-02T07:06:40Z status=402 method=POST url=/v1/charges request_id=req_123 account=acct_123 error_code=card_declined error_message="Your card was declined." latency=85 livemode=true`,
+		logfmtLine(noEvent, payload, logfmtTimestampFormat),
+	)
+}
+
+func TestLogfmtLineRequestLogID(t *testing.T) {
+	event := &websocket.RequestLogEvent{RequestLogID: "resp_123"}
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "GET", RequestID: "req_123", Status: 200, URL: "/v1/charges", Source: "dashboard"}
+
+	require.Equal(t,
+		`time=2019-10-02T07:06:40Z status=200 method=GET url=/v1/charges request_id=req_123 request_log_id=resp_123 source=dashboard`,
+		logfmtLine(event, payload, logfmtTimestampFormat),
+	)
+}
+
+func TestLogfmtLineEmptyFields(t *testing.T) {
+	require.Equal(t, `time="" status=0 method="" url="" request_id=""`, logfmtLine(noEvent, &EventPayload{}, logfmtTimestampFormat))
+}
+
+func TestLogfmtLineQuoting(t *testing.T) {
+	payload := &EventPayload{
+		CreatedAt: 1570000000,
+		Method:    "GET",
+		RequestID: "req_123",
+		Status:    200,
+		URL:       `/v1/customers?email="a b"`,
+		UserAgent: "Stripe/v1 GoBindings/70.0.0",
+	}
+
+	require.Equal(t,
+		`time=2019-10-02T07:06:40Z status=200 method=GET url="/v1/customers?email=\"a b\"" request_id=req_123 user_agent="Stripe/v1 GoBindings/70.0.0"`,
+		logfmtLine(noEvent, payload, logfmtTimestampFormat),
+	)
+}
+
+func TestLogfmtPairsCoverEventPayload(t *testing.T) {
+	// Every field of the payload gets a key, including nested ones
+	keys := map[string]bool{}
+	for _, pair := range logfmtPairs("", reflect.ValueOf(EventPayload{}), logfmtTimestampFormat) {
+		keys[pair.key] = true
+	}
+
+	for _, key := range []string{"time", "status", "method", "url", "request_id", "account", "api_version", "error_code", "error_message", "error_param", "error_type", "idempotency_key", "ip_address", "latency", "livemode", "source", "user_agent"} {
+		require.True(t, keys[key], key)
+	}
+}
+
+func TestLogfmtMalformedLine(t *testing.T) {
+	require.Equal(t,
+		`malformed=true request_log_id=resp_123 raw="{\"status\": 200"`,
+		logfmtMalformedLine(&websocket.RequestLogEvent{EventPayload: `{"status": 200`, RequestLogID: "resp_123"}),
+	)
+	require.Equal(t, `malformed=true raw=""`, logfmtMalformedLine(&websocket.RequestLogEvent{}))
+}
+
+func TestProcessRequestLogEventLogfmtMalformed(t *testing.T) {
+	var stdout bytes.Buffer
+	tailer := New(&Config{OutputFormat: outputFormatLogfmt, Stdout: &stdout})
+
+	tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+		EventPayload: `{"status": 200, "url": "/v1/char`,
+		RequestLogID: "resp_123",
+	}})
+
+	// The raw payload is printed instead of a line of zero values
+	require.Equal(t, `malformed=true request_log_id=resp_123 raw="{\"status\": 200, \"url\": \"/v1/char"`+"\n", stdout.String())
+}
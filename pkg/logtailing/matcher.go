@@ -0,0 +1,99 @@
+package logtailing
+
+import (
+	"strings"
+)
+
+// negationPrefix negates a filter value, e.g. `!GET` matches any HTTP method
+// but GET.
+const negationPrefix = "!"
+
+// matchFunc returns true if the value matches a single filter value, stripped
+// of its negation prefix.
+type matchFunc func(filterValue, value string) bool
+
+// matcher matches values against a list of filter values, which can be
+// negated with a leading `!`. A value matches if it matches any of the
+// positive filter values, or there are none, and none of the negative filter
+// values.
+type matcher struct {
+	values []string
+	match  matchFunc
+}
+
+// Active returns true if the matcher has any filter values. A matcher without
+// filter values matches everything.
+func (m matcher) Active() bool {
+	return len(m.values) > 0
+}
+
+// Matches returns true if the value matches the filter values.
+func (m matcher) Matches(value string) bool {
+	hasPositives := false
+	matchesPositive := false
+
+	for _, filterValue := range m.values {
+		filterValue, negated := parseFilterValue(filterValue)
+		if negated {
+			if m.match(filterValue, value) {
+				return false
+			}
+			continue
+		}
+
+		hasPositives = true
+		if !matchesPositive && m.match(filterValue, value) {
+			matchesPositive = true
+		}
+	}
+
+	return !hasPositives || matchesPositive
+}
+
+// parseFilterValue strips the negation prefix from a filter value, and
+// returns true if the value was negated.
+func parseFilterValue(filterValue string) (string, bool) {
+	if strings.HasPrefix(filterValue, negationPrefix) {
+		return strings.TrimPrefix(filterValue, negationPrefix), true
+	}
+
+	return filterValue, false
+}
+
+// hasPositiveValues returns true if any of the filter values isn't negated.
+func hasPositiveValues(filterValues []string) bool {
+	for _, filterValue := range filterValues {
+		if _, negated := parseFilterValue(filterValue); !negated {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchEqual matches values equal to the filter value.
+func matchEqual(filterValue, value string) bool {
+	return filterValue == value
+}
+
+// matchPathPrefix matches request URLs whose path starts with the filter
+// value, ignoring query strings.
+func matchPathPrefix(prefix, requestURL string) bool {
+	return strings.HasPrefix(requestPath(requestURL), strings.TrimSuffix(prefix, "/"))
+}
+
+// matchSubstringFold matches values containing the filter value, ignoring
+// case.
+func matchSubstringFold(substring, value string) bool {
+	return strings.Contains(strings.ToLower(value), strings.ToLower(substring))
+}
+
+// matchWildcard matches values equal to the filter value, or starting with
+// it if it ends with `*`.
+func matchWildcard(pattern, value string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return pattern == value
+}
@@ -0,0 +1,83 @@
+package logtailing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		value   string
+		matches bool
+	}{
+		{"no values", nil, "GET", true},
+		{"positive match", []string{"GET"}, "GET", true},
+		{"positive mismatch", []string{"GET"}, "POST", false},
+		{"any positive", []string{"GET", "POST"}, "POST", true},
+		{"negative match", []string{"!GET"}, "GET", false},
+		{"negative mismatch", []string{"!GET"}, "POST", true},
+		{"all negatives", []string{"!GET", "!DELETE"}, "DELETE", false},
+		{"none of the negatives", []string{"!GET", "!DELETE"}, "POST", true},
+		{"positive and negative", []string{"POST", "!GET"}, "POST", true},
+		{"positive and negative mismatch", []string{"POST", "!GET"}, "DELETE", false},
+		{"negative wins", []string{"GET", "!GET"}, "GET", false},
+		{"empty value", []string{"!GET"}, "", true},
+		{"lone negation prefix", []string{"!"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := matcher{tt.values, matchEqual}
+			require.Equal(t, tt.values != nil, m.Active())
+			require.Equal(t, tt.matches, m.Matches(tt.value))
+		})
+	}
+}
+
+func TestMatcherMatchFunc(t *testing.T) {
+	m := matcher{[]string{"/v1/charges", "!/v1/charges/ch_123"}, matchPathPrefix}
+	require.True(t, m.Matches("/v1/charges/ch_456?expand[]=customer"))
+	require.False(t, m.Matches("/v1/charges/ch_123/refunds"))
+	require.False(t, m.Matches("/v1/customers"))
+
+	m = matcher{[]string{"!get"}, strings.EqualFold}
+	require.False(t, m.Matches("GET"))
+	require.True(t, m.Matches("POST"))
+}
+
+func TestParseFilterValue(t *testing.T) {
+	value, negated := parseFilterValue("!GET")
+	require.True(t, negated)
+	require.Equal(t, "GET", value)
+
+	value, negated = parseFilterValue("GET")
+	require.False(t, negated)
+	require.Equal(t, "GET", value)
+
+	// Only the first prefix is a negation
+	value, negated = parseFilterValue("!!GET")
+	require.True(t, negated)
+	require.Equal(t, "!GET", value)
+}
+
+func TestHasPositiveValues(t *testing.T) {
+	require.False(t, hasPositiveValues(nil))
+	require.False(t, hasPositiveValues([]string{"!GET", "!POST"}))
+	require.True(t, hasPositiveValues([]string{"!GET", "POST"}))
+}
+
+func TestMatchWildcard(t *testing.T) {
+	require.True(t, matchWildcard("req_123", "req_123"))
+	require.False(t, matchWildcard("req_123", "req_1234"))
+	require.True(t, matchWildcard("req_12*", "req_1234"))
+	require.True(t, matchWildcard("*", "req_1234"))
+}
+
+func TestMatchSubstringFold(t *testing.T) {
+	require.True(t, matchSubstringFold("stripe-node", "Stripe-Node/8.0.0"))
+	require.False(t, matchSubstringFold("stripe-ruby", "Stripe-Node/8.0.0"))
+}
@@ -0,0 +1,150 @@
+package logtailing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// metricsShutdownTimeout is how long the metrics listener waits for the
+// pending scrapes to complete when shutting down
+const metricsShutdownTimeout = 5 * time.Second
+
+// metricsStatusClasses are the status classes counted by the metrics, in the order
+// they're exposed
+var metricsStatusClasses = []string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// tailMetrics are the counters exposed on cfg.MetricsAddr. They must be
+// accessed atomically.
+type tailMetrics struct {
+	received   uint64
+	malformed  uint64
+	duplicates uint64
+	reconnects uint64
+	byClass    [5]uint64
+	otherClass uint64
+
+	dropped func() uint64
+}
+
+// newTailMetrics returns counters reading the number of dropped request logs
+// with dropped, as they're counted by the eventWriter.
+func newTailMetrics(dropped func() uint64) *tailMetrics {
+	return &tailMetrics{dropped: dropped}
+}
+
+// recordEvent counts a request log received with the status code
+func (m *tailMetrics) recordEvent(status int) {
+	atomic.AddUint64(&m.received, 1)
+
+	if class := status/100 - 1; class >= 0 && class < len(m.byClass) {
+		atomic.AddUint64(&m.byClass[class], 1)
+	} else {
+		atomic.AddUint64(&m.otherClass, 1)
+	}
+}
+
+// recordMalformed counts a request log whose payload couldn't be read
+func (m *tailMetrics) recordMalformed() {
+	atomic.AddUint64(&m.malformed, 1)
+}
+
+// recordDuplicate counts a redelivered request log that was dropped
+func (m *tailMetrics) recordDuplicate() {
+	atomic.AddUint64(&m.duplicates, 1)
+}
+
+// recordConnect counts a connection of a websocket client, if it's a
+// reconnect
+func (m *tailMetrics) recordConnect(reconnect bool) {
+	if reconnect {
+		atomic.AddUint64(&m.reconnects, 1)
+	}
+}
+
+// write writes the counters in the Prometheus text exposition format
+func (m *tailMetrics) write(w io.Writer) {
+	writeCounter(w, "stripe_logs_events_received_total", "Request logs received from Stripe.")
+	fmt.Fprintf(w, "stripe_logs_events_received_total %d\n", atomic.LoadUint64(&m.received))
+
+	writeCounter(w, "stripe_logs_events_by_status_total", "Request logs received from Stripe, by status class.")
+	for i, class := range metricsStatusClasses {
+		fmt.Fprintf(w, "stripe_logs_events_by_status_total{class=%q} %d\n", class, atomic.LoadUint64(&m.byClass[i]))
+	}
+	fmt.Fprintf(w, "stripe_logs_events_by_status_total{class=\"other\"} %d\n", atomic.LoadUint64(&m.otherClass))
+
+	writeCounter(w, "stripe_logs_malformed_payloads_total", "Request logs whose payload couldn't be read.")
+	fmt.Fprintf(w, "stripe_logs_malformed_payloads_total %d\n", atomic.LoadUint64(&m.malformed))
+
+	writeCounter(w, "stripe_logs_duplicates_suppressed_total", "Request logs redelivered by Stripe that were dropped.")
+	fmt.Fprintf(w, "stripe_logs_duplicates_suppressed_total %d\n", atomic.LoadUint64(&m.duplicates))
+
+	writeCounter(w, "stripe_logs_websocket_reconnects_total", "Reconnections of the websocket clients.")
+	fmt.Fprintf(w, "stripe_logs_websocket_reconnects_total %d\n", atomic.LoadUint64(&m.reconnects))
+
+	var dropped uint64
+	if m.dropped != nil {
+		dropped = m.dropped()
+	}
+	writeCounter(w, "stripe_logs_events_dropped_total", "Request logs dropped because the queue or the output couldn't keep up.")
+	fmt.Fprintf(w, "stripe_logs_events_dropped_total %d\n", dropped)
+}
+
+func writeCounter(w io.Writer, name string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+}
+
+// ServeHTTP exposes the counters to Prometheus scrapes
+func (m *tailMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// metricsServer exposes the metrics on /metrics
+type metricsServer struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+// listenMetrics listens on the address for the metrics. It returns an error if
+// it can't listen on the address, e.g. because it's already in use. The
+// metrics aren't served until serve is called.
+func listenMetrics(addr string, metrics *tailMetrics, status http.Handler) (*metricsServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %s for the metrics: %v", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	if status != nil {
+		mux.Handle("/status", status)
+	}
+
+	return &metricsServer{
+		server:   &http.Server{Handler: mux, ReadHeaderTimeout: metricsShutdownTimeout},
+		listener: listener,
+	}, nil
+}
+
+// serve serves the metrics from a separate goroutine
+func (s *metricsServer) serve() {
+	go s.server.Serve(s.listener) // #nosec G104
+}
+
+// addr returns the address the server listens on
+func (s *metricsServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+// close stops the server once the pending scrapes are complete
+func (s *metricsServer) close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancel()
+
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,112 @@
+package logtailing
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestTailMetricsWrite(t *testing.T) {
+	m := newTailMetrics(func() uint64 { return 7 })
+	m.recordEvent(200)
+	m.recordEvent(201)
+	m.recordEvent(402)
+	m.recordEvent(0)
+	m.recordMalformed()
+	m.recordDuplicate()
+	m.recordDuplicate()
+	m.recordConnect(false)
+	m.recordConnect(true)
+	m.recordConnect(true)
+
+	var buf bytes.Buffer
+	m.write(&buf)
+
+	require.Equal(t, `# HELP stripe_logs_events_received_total Request logs received from Stripe.
+# TYPE stripe_logs_events_received_total counter
+stripe_logs_events_received_total 4
+# HELP stripe_logs_events_by_status_total Request logs received from Stripe, by status class.
+# TYPE stripe_logs_events_by_status_total counter
+stripe_logs_events_by_status_total{class="1xx"} 0
+stripe_logs_events_by_status_total{class="2xx"} 2
+stripe_logs_events_by_status_total{class="3xx"} 0
+stripe_logs_events_by_status_total{class="4xx"} 1
+stripe_logs_events_by_status_total{class="5xx"} 0
+stripe_logs_events_by_status_total{class="other"} 1
+# HELP stripe_logs_malformed_payloads_total Request logs whose payload couldn't be read.
+# TYPE stripe_logs_malformed_payloads_total counter
+stripe_logs_malformed_payloads_total 1
+# HELP stripe_logs_duplicates_suppressed_total Request logs redelivered by Stripe that were dropped.
+# TYPE stripe_logs_duplicates_suppressed_total counter
+stripe_logs_duplicates_suppressed_total 2
+# HELP stripe_logs_websocket_reconnects_total Reconnections of the websocket clients.
+# TYPE stripe_logs_websocket_reconnects_total counter
+stripe_logs_websocket_reconnects_total 2
+# HELP stripe_logs_events_dropped_total Request logs dropped because the queue or the output couldn't keep up.
+# TYPE stripe_logs_events_dropped_total counter
+stripe_logs_events_dropped_total 7
+`, buf.String())
+}
+
+func TestProcessRequestLogEventMetrics(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	tailer := New(&Config{Log: logger, Stdout: ioutil.Discard, FilterText: []string{"charges"}})
+	tailer.metrics = newTailMetrics(nil)
+
+	tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+		EventPayload: `{"status": 200, "url": "/v1/charges"}`,
+	}})
+	// Request logs hidden by the filters are counted too
+	tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+		EventPayload: `{"status": 500, "url": "/v1/customers"}`,
+	}})
+	tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+		EventPayload: `{"status": charges`,
+	}})
+
+	require.Equal(t, uint64(3), tailer.metrics.received)
+	require.Equal(t, uint64(1), tailer.metrics.byClass[1])
+	require.Equal(t, uint64(1), tailer.metrics.byClass[4])
+	require.Equal(t, uint64(1), tailer.metrics.malformed)
+}
+
+func TestMetricsServer(t *testing.T) {
+	m := newTailMetrics(nil)
+	m.recordEvent(200)
+
+	server, err := listenMetrics("127.0.0.1:0", m, nil)
+	require.NoError(t, err)
+	server.serve()
+
+	resp, err := http.Get("http://" + server.addr() + "/metrics")
+	require.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header.Get("Content-Type"))
+	require.Contains(t, string(body), "stripe_logs_events_received_total 1\n")
+
+	require.NoError(t, server.close())
+
+	// The listener is shut down
+	_, err = http.Get("http://" + server.addr() + "/metrics")
+	require.Error(t, err)
+}
+
+func TestListenMetricsAddressInUse(t *testing.T) {
+	first, err := listenMetrics("127.0.0.1:0", newTailMetrics(nil), nil)
+	require.NoError(t, err)
+	first.serve()
+	defer first.close()
+
+	_, err = listenMetrics(first.addr(), newTailMetrics(nil), nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "could not listen on "+first.addr()+" for the metrics")
+}
@@ -0,0 +1,200 @@
+package logtailing
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/logtailing/filter"
+)
+
+const (
+	// notifyOn5xx notifies of the server errors (5xx status codes)
+	notifyOn5xx = "5xx"
+
+	// notifyOn4xxAndAbove notifies of the client and server errors (status
+	// codes 400 and above)
+	notifyOn4xxAndAbove = "4xx+"
+)
+
+// defaultNotifyInterval is the minimum time between two desktop
+// notifications when cfg.NotifyInterval isn't set
+const defaultNotifyInterval = 30 * time.Second
+
+// errNotifierUnavailable is returned by the desktop notifiers when the
+// platform has no way of showing desktop notifications
+var errNotifierUnavailable = errors.New("desktop notifications aren't supported on this platform")
+
+// desktopNotifier shows native desktop notifications. The implementations
+// are platform-specific, see newDesktopNotifier.
+type desktopNotifier interface {
+	notify(title string, message string) error
+}
+
+// commandNotifier shows desktop notifications by running a command, e.g.
+// notify-send, with the arguments returned by args
+type commandNotifier struct {
+	name string
+	args func(title string, message string) []string
+}
+
+func (n commandNotifier) notify(title string, message string) error {
+	path, err := exec.LookPath(n.name)
+	if err != nil {
+		return fmt.Errorf("%s isn't available: %v", n.name, err)
+	}
+
+	output, err := exec.Command(path, n.args(title, message)...).CombinedOutput() // #nosec G204
+	if err != nil {
+		return fmt.Errorf("%s failed: %v: %s", n.name, err, output)
+	}
+
+	return nil
+}
+
+// unavailableNotifier is the desktop notifier of the platforms without
+// desktop notifications
+type unavailableNotifier struct{}
+
+func (unavailableNotifier) notify(title string, message string) error {
+	return errNotifierUnavailable
+}
+
+// notificationThrottle allows a desktop notification at most once per
+// interval, counting the ones suppressed in between. The request logs are
+// handled concurrently, so it's guarded by a mutex.
+type notificationThrottle struct {
+	interval time.Duration
+
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+// allow returns true if a notification can be shown at now, with the number
+// of notifications suppressed since the last one shown
+func (t *notificationThrottle) allow(now time.Time) (bool, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.last.IsZero() && now.Sub(t.last) < t.interval {
+		t.suppressed++
+		return false, 0
+	}
+
+	suppressed := t.suppressed
+	t.last = now
+	t.suppressed = 0
+
+	return true, suppressed
+}
+
+// desktopNotifications notifies of the request logs matching cfg.NotifyOn
+type desktopNotifications struct {
+	notifier desktopNotifier
+	throttle *notificationThrottle
+
+	// expression matches the request logs when cfg.NotifyOn is a filter
+	// expression
+	notifyOn   string
+	expression *filter.Expression
+
+	// disabled is set to 1 once the notifier failed, as it's unlikely to
+	// work the next time. It must be accessed atomically.
+	disabled int32
+	warning  sync.Once
+}
+
+// newDesktopNotifications returns the notifications of cfg.NotifyOn, which is
+// 5xx, 4xx+ or a filter expression
+func newDesktopNotifications(notifyOn string, interval time.Duration, notifier desktopNotifier) (*desktopNotifications, error) {
+	if interval == 0 {
+		interval = defaultNotifyInterval
+	}
+
+	n := &desktopNotifications{
+		notifier: notifier,
+		throttle: &notificationThrottle{interval: interval},
+		notifyOn: notifyOn,
+	}
+
+	if notifyOn != notifyOn5xx && notifyOn != notifyOn4xxAndAbove {
+		expression, err := filter.Compile(notifyOn, expressionFields)
+		if err != nil {
+			return nil, fmt.Errorf("the NotifyOn field (%s) must be 5xx, 4xx+ or a filter expression: %v", notifyOn, err)
+		}
+		n.expression = expression
+	}
+
+	return n, nil
+}
+
+// matches returns true if the request log matches cfg.NotifyOn
+func (n *desktopNotifications) matches(payload *EventPayload) bool {
+	switch n.notifyOn {
+	case notifyOn5xx:
+		return payload.Status >= 500 && payload.Status < 600
+	case notifyOn4xxAndAbove:
+		return payload.Status >= 400
+	default:
+		return n.expression.Match(expressionEvent{payload})
+	}
+}
+
+// notificationText returns the title and the message of the notification of
+// a request log, e.g. `500 POST /v1/charges` and `Request req_123`, with the
+// number of request logs suppressed since the last notification
+func notificationText(payload *EventPayload, suppressed int) (string, string) {
+	title := fmt.Sprintf("%d %s %s", payload.Status, payload.Method, requestPath(payload.URL))
+
+	message := fmt.Sprintf("Request %s", payload.RequestID)
+	if suppressed > 0 {
+		message += fmt.Sprintf(" (%d more since the last notification)", suppressed)
+	}
+
+	return title, message
+}
+
+// notifyMiddleware shows a desktop notification for the request logs
+// matching cfg.NotifyOn and the filters, at most once per cfg.NotifyInterval
+func (tailer *Tailer) notifyMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		if tailer.notifications != nil && !event.Hidden && !event.Malformed {
+			tailer.notify(&event.Payload)
+		}
+
+		next(event)
+	}
+}
+
+// notify shows the desktop notification of a request log matching
+// cfg.NotifyOn, unless it's throttled. The notifier runs on its own goroutine
+// so that it doesn't hold up the request logs.
+func (tailer *Tailer) notify(payload *EventPayload) {
+	n := tailer.notifications
+	if atomic.LoadInt32(&n.disabled) == 1 || !n.matches(payload) {
+		return
+	}
+
+	allowed, suppressed := n.throttle.allow(tailer.now())
+	if !allowed {
+		return
+	}
+
+	title, message := notificationText(payload, suppressed)
+	go func() {
+		if err := n.notifier.notify(title, message); err != nil {
+			atomic.StoreInt32(&n.disabled, 1)
+			n.warning.Do(func() {
+				tailer.cfg.Log.WithFields(log.Fields{
+					"prefix": "logs.Tailer.notify",
+				}).Warnf("Could not show a desktop notification, they're disabled for this session: %v", err)
+			})
+		}
+	}()
+}
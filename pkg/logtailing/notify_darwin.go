@@ -0,0 +1,24 @@
+//go:build darwin
+// +build darwin
+
+package logtailing
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// newDesktopNotifier returns a notifier running terminal-notifier if it's
+// installed, or osascript otherwise
+func newDesktopNotifier() desktopNotifier {
+	if _, err := exec.LookPath("terminal-notifier"); err == nil {
+		return commandNotifier{name: "terminal-notifier", args: func(title string, message string) []string {
+			return []string{"-title", title, "-message", message}
+		}}
+	}
+
+	return commandNotifier{name: "osascript", args: func(title string, message string) []string {
+		// AppleScript strings are quoted like Go strings
+		return []string{"-e", "display notification " + strconv.Quote(message) + " with title " + strconv.Quote(title)}
+	}}
+}
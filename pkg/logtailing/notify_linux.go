@@ -0,0 +1,12 @@
+//go:build linux
+// +build linux
+
+package logtailing
+
+// newDesktopNotifier returns a notifier running notify-send, which is
+// installed with most desktop environments
+func newDesktopNotifier() desktopNotifier {
+	return commandNotifier{name: "notify-send", args: func(title string, message string) []string {
+		return []string{"--app-name", "Stripe CLI", title, message}
+	}}
+}
@@ -0,0 +1,11 @@
+//go:build !darwin && !linux && !windows
+// +build !darwin,!linux,!windows
+
+package logtailing
+
+// newDesktopNotifier returns a notifier failing with errNotifierUnavailable,
+// as there's no standard way of showing desktop notifications on this
+// platform
+func newDesktopNotifier() desktopNotifier {
+	return unavailableNotifier{}
+}
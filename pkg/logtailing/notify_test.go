@@ -0,0 +1,149 @@
+package logtailing
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNotifier sends the notifications it shows on a channel, or fails with
+// err if it's set
+type fakeNotifier struct {
+	shown chan [2]string
+	err   error
+}
+
+func (n *fakeNotifier) notify(title string, message string) error {
+	n.shown <- [2]string{title, message}
+	return n.err
+}
+
+func TestNotificationThrottle(t *testing.T) {
+	throttle := &notificationThrottle{interval: 30 * time.Second}
+	now := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+
+	allowed, suppressed := throttle.allow(now)
+	require.True(t, allowed)
+	require.Equal(t, 0, suppressed)
+
+	for _, delay := range []time.Duration{time.Second, 10 * time.Second, 29 * time.Second} {
+		allowed, _ = throttle.allow(now.Add(delay))
+		require.False(t, allowed)
+	}
+
+	// The next notification counts the suppressed ones
+	allowed, suppressed = throttle.allow(now.Add(30 * time.Second))
+	require.True(t, allowed)
+	require.Equal(t, 3, suppressed)
+
+	allowed, _ = throttle.allow(now.Add(31 * time.Second))
+	require.False(t, allowed)
+	allowed, suppressed = throttle.allow(now.Add(time.Hour))
+	require.True(t, allowed)
+	require.Equal(t, 1, suppressed)
+}
+
+func TestDesktopNotificationsMatches(t *testing.T) {
+	tests := []struct {
+		notifyOn string
+		status   int
+		url      string
+		matches  bool
+	}{
+		{"5xx", 500, "/v1/charges", true},
+		{"5xx", 402, "/v1/charges", false},
+		{"4xx+", 402, "/v1/charges", true},
+		{"4xx+", 503, "/v1/charges", true},
+		{"4xx+", 200, "/v1/charges", false},
+		{`status >= 400 and path = "/v1/charges"`, 402, "/v1/charges", true},
+		{`status >= 400 and path = "/v1/charges"`, 402, "/v1/customers", false},
+	}
+
+	for _, tt := range tests {
+		n, err := newDesktopNotifications(tt.notifyOn, 0, &fakeNotifier{})
+		require.NoError(t, err)
+		require.Equal(t, tt.matches, n.matches(&EventPayload{Status: tt.status, URL: tt.url}), "%s %d %s", tt.notifyOn, tt.status, tt.url)
+	}
+
+	_, err := newDesktopNotifications("5xxx", 0, &fakeNotifier{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the NotifyOn field (5xxx) must be 5xx, 4xx+ or a filter expression: ")
+}
+
+func TestNotificationText(t *testing.T) {
+	payload := &EventPayload{Status: 500, Method: "POST", URL: "/v1/charges?expand[]=customer", RequestID: "req_123"}
+
+	title, message := notificationText(payload, 0)
+	require.Equal(t, "500 POST /v1/charges", title)
+	require.Equal(t, "Request req_123", message)
+
+	_, message = notificationText(payload, 4)
+	require.Equal(t, "Request req_123 (4 more since the last notification)", message)
+}
+
+func TestProcessRequestLogEventNotifies(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	tailer := New(&Config{Log: logger, Stdout: ioutil.Discard})
+
+	notifier := &fakeNotifier{shown: make(chan [2]string, 10)}
+	notifications, err := newDesktopNotifications("5xx", time.Minute, notifier)
+	require.NoError(t, err)
+	tailer.notifications = notifications
+
+	now := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	tailer.now = func() time.Time { return now }
+
+	tailer.processRequestLogEvent(requestLogMessage(`{"status": 200, "method": "GET", "url": "/v1/charges", "request_id": "req_1"}`))
+	tailer.processRequestLogEvent(requestLogMessage(`{"status": 500, "method": "POST", "url": "/v1/charges", "request_id": "req_2"}`))
+	tailer.processRequestLogEvent(requestLogMessage(`{"status": 502, "method": "POST", "url": "/v1/charges", "request_id": "req_3"}`))
+
+	now = now.Add(time.Minute)
+	tailer.processRequestLogEvent(requestLogMessage(`{"status": 503, "method": "GET", "url": "/v1/customers", "request_id": "req_4"}`))
+
+	// The notifiers run concurrently, so they can show them in any order
+	require.ElementsMatch(t, [][2]string{
+		{"500 POST /v1/charges", "Request req_2"},
+		{"503 GET /v1/customers", "Request req_4 (1 more since the last notification)"},
+	}, [][2]string{receiveNotification(t, notifier), receiveNotification(t, notifier)})
+}
+
+func TestNotifyUnavailable(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	tailer := New(&Config{Log: logger, Stdout: ioutil.Discard})
+
+	notifier := &fakeNotifier{shown: make(chan [2]string, 10), err: errNotifierUnavailable}
+	notifications, err := newDesktopNotifications("5xx", time.Nanosecond, notifier)
+	require.NoError(t, err)
+	tailer.notifications = notifications
+
+	tailer.notify(&EventPayload{Status: 500})
+	receiveNotification(t, notifier)
+	require.Eventually(t, func() bool { return len(hook.AllEntries()) == 1 }, time.Second, time.Millisecond)
+	require.Equal(t, "Could not show a desktop notification, they're disabled for this session: desktop notifications aren't supported on this platform", hook.LastEntry().Message)
+
+	// The notifications are disabled once the notifier failed
+	tailer.notify(&EventPayload{Status: 500})
+	require.Empty(t, notifier.shown)
+	require.Len(t, hook.AllEntries(), 1)
+}
+
+func TestCommandNotifierUnavailable(t *testing.T) {
+	notifier := commandNotifier{name: "stripe-cli-missing-notifier", args: func(title string, message string) []string { return nil }}
+
+	err := notifier.notify("500 POST /v1/charges", "Request req_123")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "stripe-cli-missing-notifier isn't available: ")
+}
+
+func receiveNotification(t *testing.T, notifier *fakeNotifier) [2]string {
+	select {
+	case shown := <-notifier.shown:
+		return shown
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for the notification")
+		return [2]string{}
+	}
+}
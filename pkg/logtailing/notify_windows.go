@@ -0,0 +1,33 @@
+//go:build windows
+// +build windows
+
+package logtailing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// toastScript shows a toast notification with the title and the message,
+// quoted for PowerShell
+const toastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode(%s)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode(%s)) > $null
+$toast = [Windows.UI.Notifications.ToastNotification]::new($template)
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('Stripe CLI').Show($toast)`
+
+// newDesktopNotifier returns a notifier showing toast notifications with
+// PowerShell
+func newDesktopNotifier() desktopNotifier {
+	return commandNotifier{name: "powershell", args: func(title string, message string) []string {
+		return []string{"-NoProfile", "-NonInteractive", "-Command", fmt.Sprintf(toastScript, powershellQuote(title), powershellQuote(message))}
+	}}
+}
+
+// powershellQuote quotes a string for PowerShell, where single-quoted strings
+// are literal and single quotes are escaped by doubling them
+func powershellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,141 @@
+package logtailing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultWebSocketFeature is the websocket feature of the request logs, used
+// by the tailers created with NewTailer
+const defaultWebSocketFeature = "request_logs"
+
+// outputFormats are the acceptable output formats, the default one being the
+// empty string
+var outputFormats = []string{outputFormatCSV, outputFormatEnvelope, outputFormatJSON, outputFormatLogfmt, outputFormatNDJSON}
+
+// Option configures a tailer created with NewTailer. Options return an error
+// when their value is invalid.
+type Option func(cfg *Config) error
+
+// NewTailer creates a new Tailer tailing the request logs with the API key,
+// configured with the options. It's an alternative to New for callers who only
+// need a few settings. Every invalid option is reported in the returned error,
+// and the resulting config is validated like in Run.
+func NewTailer(key string, opts ...Option) (*Tailer, error) {
+	cfg := &Config{
+		Key:              key,
+		WebSocketFeature: defaultWebSocketFeature,
+	}
+
+	var problems []string
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if err := cfg.validateConnection(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid tailer options: %s", strings.Join(problems, "; "))
+	}
+
+	if err := cfg.validateOptions(); err != nil {
+		return nil, err
+	}
+
+	return New(cfg), nil
+}
+
+// WithAPIBaseURL sets the base URL of the Stripe API the tailer authorizes
+// with, e.g. for a mock server. It must be an http:// or https:// URL.
+func WithAPIBaseURL(baseURL string) Option {
+	return func(cfg *Config) error {
+		if err := validateAPIBaseURL(baseURL); err != nil {
+			return err
+		}
+
+		cfg.APIBaseURL = baseURL
+		return nil
+	}
+}
+
+// WithDeviceName sets the name of the device sent to Stripe
+func WithDeviceName(name string) Option {
+	return func(cfg *Config) error {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("the device name can't be empty")
+		}
+
+		cfg.DeviceName = name
+		return nil
+	}
+}
+
+// WithFilters sets the filters applied by Stripe to the request logs
+func WithFilters(filters *LogFilters) Option {
+	return func(cfg *Config) error {
+		if filters == nil {
+			return errors.New("the filters can't be nil")
+		}
+
+		cfg.Filters = filters
+		return nil
+	}
+}
+
+// WithLogger sets the logger the tailer reports its warnings and debug
+// messages to. They're discarded by default.
+func WithLogger(logger *log.Logger) Option {
+	return func(cfg *Config) error {
+		if logger == nil {
+			return errors.New("the logger can't be nil")
+		}
+
+		cfg.Log = logger
+		return nil
+	}
+}
+
+// WithOutputFormat sets the output format of the request logs, e.g. json or
+// ndjson. The format is case insensitive.
+func WithOutputFormat(format string) Option {
+	return func(cfg *Config) error {
+		format = strings.ToUpper(format)
+		if err := validateOutputFormat(format); err != nil {
+			return err
+		}
+
+		cfg.OutputFormat = format
+		return nil
+	}
+}
+
+// WithStdout sets where the request logs are printed, instead of os.Stdout
+func WithStdout(w io.Writer) Option {
+	return func(cfg *Config) error {
+		if w == nil {
+			return errors.New("stdout can't be nil")
+		}
+
+		cfg.Stdout = w
+		return nil
+	}
+}
+
+// WithStderr sets where the warnings and the summary are printed, instead
+// of os.Stderr
+func WithStderr(w io.Writer) Option {
+	return func(cfg *Config) error {
+		if w == nil {
+			return errors.New("stderr can't be nil")
+		}
+
+		cfg.Stderr = w
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package logtailing
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+)
+
+func TestNewTailerDefaults(t *testing.T) {
+	tailer, err := NewTailer("sk_test_123")
+	require.NoError(t, err)
+
+	cfg := tailer.cfg
+	require.Equal(t, "sk_test_123", cfg.Key)
+	require.Equal(t, "request_logs", cfg.WebSocketFeature)
+	require.Equal(t, "", cfg.APIBaseURL)
+	require.Equal(t, "", cfg.DeviceName)
+	require.Equal(t, "", cfg.OutputFormat)
+	require.Nil(t, cfg.Filters)
+	require.NotNil(t, cfg.Log)
+	require.Equal(t, ansi.DefaultTheme, cfg.Theme)
+	require.Equal(t, os.Stdout, cfg.Stdout)
+	require.Equal(t, os.Stderr, cfg.Stderr)
+}
+
+func TestNewTailerOptions(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	filters := &LogFilters{FilterHTTPMethod: []string{"POST"}}
+	var stdout, stderr bytes.Buffer
+
+	tailer, err := NewTailer("sk_test_123",
+		WithAPIBaseURL("http://localhost:12111"),
+		WithDeviceName("my-laptop"),
+		WithFilters(filters),
+		WithLogger(logger),
+		WithOutputFormat("ndjson"),
+		WithStdout(&stdout),
+		WithStderr(&stderr),
+	)
+	require.NoError(t, err)
+
+	cfg := tailer.cfg
+	require.Equal(t, "http://localhost:12111", cfg.APIBaseURL)
+	require.Equal(t, "my-laptop", cfg.DeviceName)
+	require.Equal(t, filters, cfg.Filters)
+	require.Equal(t, logger, cfg.Log)
+	require.Equal(t, outputFormatNDJSON, cfg.OutputFormat)
+	require.Equal(t, &stdout, cfg.Stdout)
+	require.Equal(t, &stderr, cfg.Stderr)
+}
+
+func TestNewTailerInvalidOptions(t *testing.T) {
+	tailer, err := NewTailer("",
+		WithAPIBaseURL("localhost:12111"),
+		WithDeviceName(" "),
+		WithFilters(nil),
+		WithLogger(nil),
+		WithOutputFormat("yaml"),
+		WithStdout(nil),
+	)
+
+	require.Nil(t, tailer)
+	require.EqualError(t, err, "invalid tailer options: "+
+		"the APIBaseURL field (localhost:12111) is not valid, it must be an http:// or https:// URL such as https://api.stripe.com; "+
+		"the device name can't be empty; "+
+		"the filters can't be nil; "+
+		"the logger can't be nil; "+
+		"the OutputFormat field (YAML) is not acceptable, it must be empty for the default format or one of CSV, ENVELOPE, JSON, LOGFMT, NDJSON; "+
+		"stdout can't be nil; "+
+		"the Key field can't be empty, it must be a Stripe secret or restricted API key (sk_... or rk_...)")
+}
+
+func TestNewTailerValidatesConfig(t *testing.T) {
+	// The options are valid on their own, but JSON compact output is only
+	// compatible with the JSON output format
+	_, err := NewTailer("sk_test_123", WithOutputFormat("ndjson"), func(cfg *Config) error {
+		cfg.JSONCompact = true
+		return nil
+	})
+
+	require.EqualError(t, err, "the JSON compact and indent options can only be used with the JSON output format")
+}
@@ -0,0 +1,315 @@
+package logtailing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+)
+
+const (
+	// otlpBatchSize is the maximum number of log records exported at once
+	otlpBatchSize = 100
+
+	// otlpFlushInterval is the maximum time a log record waits to be
+	// exported when the batch isn't full
+	otlpFlushInterval = 1 * time.Second
+
+	// otlpQueueSize is the number of request logs buffered while waiting to
+	// be exported. Newer request logs are dropped once the queue is full.
+	otlpQueueSize = 1000
+
+	otlpLogsPath = "/v1/logs"
+	otlpTimeout  = 10 * time.Second
+)
+
+// OpenTelemetry severity numbers, cf. the OpenTelemetry logs data model
+const (
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+)
+
+// otlpLogRecord is a log record of the OTLP/HTTP JSON encoding. The 64 bit
+// integers are encoded as strings, as required by the encoding.
+type otlpLogRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityNumber       int             `json:"severityNumber"`
+	SeverityText         string          `json:"severityText"`
+	Body                 otlpAnyValue    `json:"body"`
+	Attributes           []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+func otlpString(value string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &value}
+}
+
+func otlpInt(value int) otlpAnyValue {
+	s := strconv.Itoa(value)
+	return otlpAnyValue{IntValue: &s}
+}
+
+// otlpSeverity returns the severity number and text of the log record of a
+// request log with the status code.
+func otlpSeverity(status int) (int, string) {
+	switch {
+	case status >= 500:
+		return otlpSeverityError, "ERROR"
+	case status >= 400:
+		return otlpSeverityWarn, "WARN"
+	default:
+		return otlpSeverityInfo, "INFO"
+	}
+}
+
+// newOTLPLogRecord returns the log record of a request log received at
+// observedAt. The log line is the body, stripped of ANSI sequences, and the
+// record is timestamped with the creation time of the request log if it has
+// one.
+func newOTLPLogRecord(event RenderedEvent, createdAt time.Time, observedAt time.Time) otlpLogRecord {
+	severity, severityText := otlpSeverity(event.Status)
+
+	timestamp := observedAt
+	if !createdAt.IsZero() {
+		timestamp = createdAt
+	}
+
+	attributes := []otlpAttribute{
+		{Key: "http.request.method", Value: otlpString(event.Method)},
+		{Key: "url.path", Value: otlpString(event.URL)},
+		{Key: "http.response.status_code", Value: otlpInt(event.Status)},
+		{Key: "stripe.request_id", Value: otlpString(event.RequestID)},
+	}
+	if event.RequestLogID != "" {
+		attributes = append(attributes, otlpAttribute{Key: "stripe.request_log_id", Value: otlpString(event.RequestLogID)})
+	}
+
+	return otlpLogRecord{
+		TimeUnixNano:         strconv.FormatInt(timestamp.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(observedAt.UnixNano(), 10),
+		SeverityNumber:       severity,
+		SeverityText:         severityText,
+		Body:                 otlpString(ansi.Strip(event.Line)),
+		Attributes:           attributes,
+	}
+}
+
+// otlpCollector receives the batches of log records exported by an
+// otlpExporter
+type otlpCollector interface {
+	export(records []otlpLogRecord) error
+}
+
+// otlpHTTPCollector exports log records to an OTLP/HTTP endpoint in the JSON
+// encoding
+type otlpHTTPCollector struct {
+	url    string
+	client *http.Client
+}
+
+// newOTLPHTTPCollector returns a collector for the endpoint, e.g.
+// http://localhost:4318. The default path of the logs is used unless the
+// endpoint has a path.
+func newOTLPHTTPCollector(endpoint string) *otlpHTTPCollector {
+	// The endpoint is validated by Config.Validate
+	u, _ := url.Parse(endpoint)
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpLogsPath
+	}
+
+	return &otlpHTTPCollector{
+		url:    u.String(),
+		client: &http.Client{Timeout: otlpTimeout},
+	}
+}
+
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+func (c *otlpHTTPCollector) export(records []otlpLogRecord) error {
+	body, err := json.Marshal(otlpExportRequest{
+		ResourceLogs: []otlpResourceLogs{{
+			Resource: otlpResource{Attributes: []otlpAttribute{
+				{Key: "service.name", Value: otlpString("stripe-cli")},
+			}},
+			ScopeLogs: []otlpScopeLogs{{
+				Scope:      otlpScope{Name: "stripe-cli/logs"},
+				LogRecords: records,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Post(c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so that the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body) // #nosec G104
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// otlpExporter is a sink exporting the request logs as OpenTelemetry log
+// records. The records are batched and exported from a separate goroutine,
+// so that an unreachable collector never affects the other sinks.
+type otlpExporter struct {
+	collector otlpCollector
+	log       *log.Logger
+	now       func() time.Time
+
+	batchSize     int
+	flushInterval time.Duration
+
+	records chan otlpLogRecord
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+
+	// dropped is the number of request logs that couldn't be exported. It
+	// must be accessed atomically.
+	dropped uint64
+}
+
+func newOTLPExporter(collector otlpCollector, logger *log.Logger) *otlpExporter {
+	return &otlpExporter{
+		collector:     collector,
+		log:           logger,
+		now:           time.Now,
+		batchSize:     otlpBatchSize,
+		flushInterval: otlpFlushInterval,
+		records:       make(chan otlpLogRecord, otlpQueueSize),
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+}
+
+// Write queues the log record of a request log to be exported. The record is
+// dropped if the queue is full.
+func (e *otlpExporter) Write(event RenderedEvent) error {
+	var createdAt time.Time
+	var payload EventPayload
+	if err := json.Unmarshal([]byte(event.Payload), &payload); err == nil {
+		createdAt, _ = payload.CreatedAtTime()
+	}
+
+	select {
+	case e.records <- newOTLPLogRecord(event, createdAt, e.now()):
+		return nil
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+		return fmt.Errorf("the OTLP queue is full")
+	}
+}
+
+// start starts exporting the queued log records.
+func (e *otlpExporter) start() {
+	go e.run()
+}
+
+// close exports the queued log records, then stops the exporter. It returns
+// the number of request logs that couldn't be exported.
+func (e *otlpExporter) close() uint64 {
+	close(e.stopCh)
+	<-e.doneCh
+
+	return atomic.LoadUint64(&e.dropped)
+}
+
+func (e *otlpExporter) run() {
+	defer close(e.doneCh)
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]otlpLogRecord, 0, e.batchSize)
+
+	for {
+		select {
+		case record := <-e.records:
+			batch = append(batch, record)
+			if len(batch) >= e.batchSize {
+				batch = e.flush(batch)
+			}
+		case <-ticker.C:
+			batch = e.flush(batch)
+		case <-e.stopCh:
+			for {
+				select {
+				case record := <-e.records:
+					batch = append(batch, record)
+					if len(batch) >= e.batchSize {
+						batch = e.flush(batch)
+					}
+				default:
+					e.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush exports the batch, if it isn't empty, and returns an empty batch to
+// fill.
+func (e *otlpExporter) flush(batch []otlpLogRecord) []otlpLogRecord {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := e.collector.export(batch); err != nil {
+		atomic.AddUint64(&e.dropped, uint64(len(batch)))
+		e.log.WithFields(log.Fields{
+			"prefix": "logs.otlpExporter.flush",
+		}).Debugf("Could not export %d request logs: %v", len(batch), err)
+	}
+
+	return make([]otlpLogRecord, 0, e.batchSize)
+}
@@ -0,0 +1,211 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryCollector is an otlpCollector keeping the exported batches in memory
+type memoryCollector struct {
+	mu      sync.Mutex
+	batches [][]otlpLogRecord
+	err     error
+}
+
+func (c *memoryCollector) export(records []otlpLogRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+	c.batches = append(c.batches, append([]otlpLogRecord(nil), records...))
+	return nil
+}
+
+func (c *memoryCollector) records() []otlpLogRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var records []otlpLogRecord
+	for _, batch := range c.batches {
+		records = append(records, batch...)
+	}
+	return records
+}
+
+func otlpAttributes(record otlpLogRecord) map[string]string {
+	attributes := make(map[string]string)
+	for _, attribute := range record.Attributes {
+		if attribute.Value.StringValue != nil {
+			attributes[attribute.Key] = *attribute.Value.StringValue
+		} else if attribute.Value.IntValue != nil {
+			attributes[attribute.Key] = *attribute.Value.IntValue
+		}
+	}
+	return attributes
+}
+
+func TestOTLPSeverity(t *testing.T) {
+	tests := []struct {
+		status       int
+		severity     int
+		severityText string
+	}{
+		{200, otlpSeverityInfo, "INFO"},
+		{302, otlpSeverityInfo, "INFO"},
+		{402, otlpSeverityWarn, "WARN"},
+		{503, otlpSeverityError, "ERROR"},
+	}
+
+	for _, tt := range tests {
+		severity, severityText := otlpSeverity(tt.status)
+		require.Equal(t, tt.severity, severity, tt.status)
+		require.Equal(t, tt.severityText, severityText, tt.status)
+	}
+}
+
+func TestOTLPExporterFlushesOnClose(t *testing.T) {
+	collector := &memoryCollector{}
+	logger, _ := test.NewNullLogger()
+	e := newOTLPExporter(collector, logger)
+	e.now = func() time.Time { return time.Unix(1570000000, 0) }
+	e.flushInterval = time.Hour
+	e.start()
+
+	require.NoError(t, e.Write(RenderedEvent{
+		Line:         "\x1b[32m200\x1b[0m POST /v1/charges [req_123]",
+		Payload:      `{"created_at":1569999990,"status":200}`,
+		Method:       "POST",
+		RequestID:    "req_123",
+		RequestLogID: "resp_123",
+		Status:       200,
+		URL:          "/v1/charges",
+	}))
+	require.NoError(t, e.Write(RenderedEvent{Line: "500 GET /v1/customers", Payload: `{}`, Method: "GET", Status: 500, URL: "/v1/customers"}))
+
+	// Nothing is exported before the batch is full or the exporter closed
+	require.Empty(t, collector.records())
+	require.Equal(t, uint64(0), e.close())
+
+	records := collector.records()
+	require.Len(t, records, 2)
+
+	require.Equal(t, "1569999990000000000", records[0].TimeUnixNano)
+	require.Equal(t, "1570000000000000000", records[0].ObservedTimeUnixNano)
+	require.Equal(t, otlpSeverityInfo, records[0].SeverityNumber)
+	require.Equal(t, "200 POST /v1/charges [req_123]", *records[0].Body.StringValue)
+	require.Equal(t, map[string]string{
+		"http.request.method":       "POST",
+		"url.path":                  "/v1/charges",
+		"http.response.status_code": "200",
+		"stripe.request_id":         "req_123",
+		"stripe.request_log_id":     "resp_123",
+	}, otlpAttributes(records[0]))
+
+	// Request logs without a creation time are timestamped when observed
+	require.Equal(t, records[1].ObservedTimeUnixNano, records[1].TimeUnixNano)
+	require.Equal(t, otlpSeverityError, records[1].SeverityNumber)
+	require.Equal(t, "ERROR", records[1].SeverityText)
+}
+
+func TestOTLPExporterBatches(t *testing.T) {
+	collector := &memoryCollector{}
+	logger, _ := test.NewNullLogger()
+	e := newOTLPExporter(collector, logger)
+	e.batchSize = 2
+	e.flushInterval = time.Hour
+	e.start()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, e.Write(RenderedEvent{Payload: `{}`, Status: 200}))
+	}
+
+	require.Eventually(t, func() bool {
+		return len(collector.records()) == 4
+	}, time.Second, time.Millisecond)
+
+	require.Equal(t, uint64(0), e.close())
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	require.Len(t, collector.batches, 3)
+	require.Len(t, collector.batches[2], 1)
+}
+
+func TestOTLPExporterCountsFailedBatches(t *testing.T) {
+	collector := &memoryCollector{err: errors.New("connection refused")}
+	logger, _ := test.NewNullLogger()
+	e := newOTLPExporter(collector, logger)
+	e.start()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, e.Write(RenderedEvent{Payload: `{}`, Status: 200}))
+	}
+
+	require.Equal(t, uint64(3), e.close())
+}
+
+func TestOTLPExporterDropsWhenFull(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	e := newOTLPExporter(&memoryCollector{}, logger)
+
+	// The exporter isn't started, so the queue fills up without blocking
+	for i := 0; i < otlpQueueSize; i++ {
+		require.NoError(t, e.Write(RenderedEvent{Payload: `{}`}))
+	}
+	require.EqualError(t, e.Write(RenderedEvent{Payload: `{}`}), "the OTLP queue is full")
+	require.Equal(t, uint64(1), e.dropped)
+}
+
+func TestOTLPHTTPCollector(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan otlpExportRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body otlpExportRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	collector := newOTLPHTTPCollector(server.URL)
+	require.NoError(t, collector.export([]otlpLogRecord{
+		newOTLPLogRecord(RenderedEvent{Line: "200 GET /v1/balance", Method: "GET", Status: 200, URL: "/v1/balance"}, time.Time{}, time.Unix(1570000000, 0)),
+	}))
+
+	req := <-requests
+	require.Equal(t, http.MethodPost, req.Method)
+	require.Equal(t, "/v1/logs", req.URL.Path)
+	require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+
+	body := <-bodies
+	require.Len(t, body.ResourceLogs, 1)
+	require.Equal(t, "stripe-cli", *body.ResourceLogs[0].Resource.Attributes[0].Value.StringValue)
+	require.Len(t, body.ResourceLogs[0].ScopeLogs[0].LogRecords, 1)
+	require.Equal(t, "200 GET /v1/balance", *body.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body.StringValue)
+}
+
+func TestOTLPHTTPCollectorPath(t *testing.T) {
+	require.Equal(t, "http://localhost:4318/v1/logs", newOTLPHTTPCollector("http://localhost:4318").url)
+	require.Equal(t, "http://localhost:4318/v1/logs", newOTLPHTTPCollector("http://localhost:4318/").url)
+	require.Equal(t, "https://collector.example.com/otlp/logs", newOTLPHTTPCollector("https://collector.example.com/otlp/logs").url)
+}
+
+func TestOTLPHTTPCollectorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := newOTLPHTTPCollector(server.URL).export([]otlpLogRecord{})
+	require.EqualError(t, err, "unexpected response status 400 Bad Request")
+}
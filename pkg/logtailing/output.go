@@ -0,0 +1,269 @@
+package logtailing
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+)
+
+// colorMode returns the parsed cfg.ColorMode. Invalid values are reported by
+// Validate, and behave like ansi.ColorModeAuto until then.
+func (cfg *Config) colorMode() ansi.ColorMode {
+	mode, err := ansi.ParseColorMode(cfg.ColorMode)
+	if err != nil {
+		return ansi.ColorModeAuto
+	}
+
+	return mode
+}
+
+// printNotice prints a message that isn't a request log to stderr. Notices
+// are logged instead when the output format is meant to be parsed.
+func (tailer *Tailer) printNotice(message string) {
+	if tailer.cfg.Quiet {
+		return
+	}
+
+	if tailer.cfg.structuredOutput() {
+		tailer.cfg.Log.Info(message)
+		return
+	}
+
+	tailer.outputMu.Lock()
+	defer tailer.outputMu.Unlock()
+
+	// Repeats of the previous request log mustn't be updated in place over
+	// the notice
+	tailer.lastRequestID = ""
+
+	// Stderr is usually the same terminal as the live stats line
+	tailer.clearLiveStats()
+	fmt.Fprintln(tailer.cfg.Stderr, tailer.cfg.colorMode().Color(tailer.cfg.Stderr).Faint(message))
+	tailer.drawLiveStats()
+}
+
+// printStats prints the stats of the session so far to stderr, serialized
+// with the request logs so that they don't interleave
+func (tailer *Tailer) printStats() {
+	if tailer.stats == nil {
+		return
+	}
+
+	var stats bytes.Buffer
+	tailer.stats.writeStats(&stats, tailer.now(), tailer.reconnects(), tailer.dropped())
+
+	tailer.outputMu.Lock()
+	defer tailer.outputMu.Unlock()
+
+	tailer.lastRequestID = ""
+
+	tailer.clearLiveStats()
+	tailer.cfg.Stderr.Write(stats.Bytes()) // #nosec G104
+	tailer.drawLiveStats()
+}
+
+// printRequestLogEvent prints the line of a request log with the status
+// code, collapsing the repeated request logs of a request ID when
+// cfg.DedupeWindow is set, and ringing the bell when cfg.Bell is set.
+func (tailer *Tailer) printRequestLogEvent(requestID string, line string, status int) error {
+	tailer.outputMu.Lock()
+	defer tailer.outputMu.Unlock()
+
+	inPlace := !tailer.cfg.structuredOutput() && tailer.cfg.colorMode().SupportsColors(tailer.cfg.Stdout) && ansi.IsInteractive(tailer.cfg.Stdout)
+
+	now := tailer.now()
+	output, ok := tailer.dedupeRequestLogEvent(requestID, line, now, inPlace)
+	if !ok {
+		return nil
+	}
+
+	// The bell is written with the line, so that it can't be interleaved
+	// with other output
+	if tailer.ringsBell(status, now) {
+		output += bell
+	}
+
+	tailer.clearLiveStats()
+	_, err := fmt.Fprintln(tailer.stdout, output)
+	tailer.drawLiveStats()
+
+	return err
+}
+
+// defaultFlushInterval is how often the request logs are flushed when
+// cfg.FlushInterval isn't set
+const defaultFlushInterval = 1 * time.Second
+
+// bufferStdout buffers the request logs printed to cfg.Stdout if it isn't a
+// terminal, and flushes them every cfg.FlushInterval until stopCh is closed.
+// Terminals aren't buffered so that request logs show up as they're received.
+func (tailer *Tailer) bufferStdout(stopCh chan struct{}) {
+	interval := tailer.cfg.FlushInterval
+	if interval == 0 {
+		interval = defaultFlushInterval
+	}
+
+	if interval < 0 || ansi.IsTerminal(tailer.cfg.Stdout) {
+		return
+	}
+
+	tailer.outputMu.Lock()
+	tailer.stdoutBuffer = bufio.NewWriter(tailer.cfg.Stdout)
+	tailer.stdout = tailer.stdoutBuffer
+	tailer.outputMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				tailer.flushStdout()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// flushStdout writes the buffered request logs, if any, to cfg.Stdout.
+func (tailer *Tailer) flushStdout() {
+	tailer.outputMu.Lock()
+	defer tailer.outputMu.Unlock()
+
+	if tailer.stdoutBuffer == nil {
+		return
+	}
+
+	if err := tailer.stdoutBuffer.Flush(); err != nil {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.flushStdout",
+		}).Debugf("Could not flush the request logs: %v", err)
+	}
+}
+
+// openOutputFile opens cfg.OutputFile for appending, creating it if needed.
+// The file is rotated according to cfg.OutputFileMaxSize and
+// cfg.OutputFileMaxBackups.
+func (tailer *Tailer) openOutputFile() error {
+	if tailer.cfg.OutputFile == "" {
+		return nil
+	}
+
+	file, err := openRotatingFile(tailer.cfg.OutputFile, tailer.cfg.OutputFileMaxSize, tailer.cfg.OutputFileMaxBackups)
+	if err != nil {
+		return fmt.Errorf("the output file %s can't be opened: %v", tailer.cfg.OutputFile, err)
+	}
+
+	tailer.outputFile = file
+	tailer.outputFileEmpty = file.size == 0
+
+	return nil
+}
+
+// reopenOutputFile closes and reopens the output file, so that the request
+// logs go to a new file once logrotate renamed it. Holding outputMu holds the
+// writes in the meantime, so request logs are neither lost nor written twice.
+func (tailer *Tailer) reopenOutputFile() error {
+	tailer.outputMu.Lock()
+	defer tailer.outputMu.Unlock()
+
+	file, ok := tailer.outputFile.(*rotatingFile)
+	if !ok {
+		return nil
+	}
+
+	if err := file.reopen(); err != nil {
+		return fmt.Errorf("the output file %s can't be reopened: %v", tailer.cfg.OutputFile, err)
+	}
+
+	tailer.cfg.Log.WithFields(log.Fields{
+		"prefix": "logs.Tailer.reopenOutputFile",
+		"path":   tailer.cfg.OutputFile,
+	}).Debug("Reopened the output file")
+
+	return nil
+}
+
+// writeOutputFile appends a line to the output file, stripped of ANSI
+// sequences. Write errors are only reported once, to avoid flooding the
+// terminal if e.g. the disk is full. The caller must hold outputMu.
+func (tailer *Tailer) writeOutputFile(line string) error {
+	if tailer.outputFile == nil {
+		return nil
+	}
+
+	_, err := io.WriteString(tailer.outputFile, ansi.Strip(line)+"\n")
+	if err != nil {
+		tailer.outputFileWarning.Do(func() {
+			tailer.cfg.Log.WithFields(log.Fields{
+				"prefix": "logs.Tailer.writeOutputFile",
+				"path":   tailer.cfg.OutputFile,
+			}).Warnf("Could not write request logs to the output file: %v", err)
+		})
+	}
+
+	return err
+}
+
+// closeOutputFile closes the output file, if any.
+func (tailer *Tailer) closeOutputFile() {
+	tailer.outputMu.Lock()
+	defer tailer.outputMu.Unlock()
+
+	if tailer.outputFile == nil {
+		return
+	}
+
+	if err := tailer.outputFile.Close(); err != nil {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.closeOutputFile",
+			"path":   tailer.cfg.OutputFile,
+		}).Warnf("Could not close the output file: %v", err)
+	}
+	tailer.outputFile = nil
+}
+
+// printHeader prints the header of the output format, e.g. the CSV header
+// row. The header is only written to the output file if the file is empty, so
+// that appending to an existing file doesn't repeat it.
+func (tailer *Tailer) printHeader(header string) {
+	tailer.outputMu.Lock()
+	defer tailer.outputMu.Unlock()
+
+	fmt.Fprintln(tailer.stdout, header)
+
+	// Rotated files start with the header as well
+	if file, ok := tailer.outputFile.(*rotatingFile); ok {
+		file.header = ansi.Strip(header) + "\n"
+	}
+
+	if tailer.outputFileEmpty {
+		tailer.writeOutputFile(header)
+	}
+}
+
+// quietLogger returns a logger writing to the same output as the logger, that
+// only logs errors unless the logger is set to debug messages.
+func quietLogger(logger *log.Logger) *log.Logger {
+	level := logger.GetLevel()
+	if level < log.DebugLevel && level > log.ErrorLevel {
+		level = log.ErrorLevel
+	}
+
+	return &log.Logger{
+		Out:          logger.Out,
+		Hooks:        logger.Hooks,
+		Formatter:    logger.Formatter,
+		ReportCaller: logger.ReportCaller,
+		Level:        level,
+		ExitFunc:     logger.ExitFunc,
+	}
+}
@@ -0,0 +1,226 @@
+package logtailing
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+type failingWriteCloser struct {
+	writes int
+}
+
+func (w *failingWriteCloser) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("disk full")
+}
+
+func (w *failingWriteCloser) Close() error {
+	return nil
+}
+
+func TestOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtailing")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tail.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("previous session\n"), 0600))
+
+	tailer := New(&Config{OutputFile: path})
+	require.NoError(t, tailer.openOutputFile())
+	require.False(t, tailer.outputFileEmpty)
+
+	tailer.writeOutputFile("\x1b[1;32m200\x1b[0m POST /v1/charges")
+	tailer.writeOutputFile("plain")
+	tailer.closeOutputFile()
+	require.Nil(t, tailer.outputFile)
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "previous session\n200 POST /v1/charges\nplain\n", string(content))
+}
+
+func TestOpenOutputFileError(t *testing.T) {
+	tailer := New(&Config{OutputFile: "/nonexistent/directory/tail.log"})
+
+	err := tailer.openOutputFile()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the output file /nonexistent/directory/tail.log can't be opened")
+}
+
+func TestPrintHeaderOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtailing")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tail.csv")
+
+	for i := 0; i < 2; i++ {
+		tailer := New(&Config{OutputFile: path, Stdout: ioutil.Discard})
+		require.NoError(t, tailer.openOutputFile())
+		tailer.printHeader("header")
+		tailer.outputMu.Lock()
+		tailer.writeOutputFile("row")
+		tailer.outputMu.Unlock()
+		tailer.closeOutputFile()
+	}
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "header\nrow\nrow\n", string(content))
+}
+
+func TestWriteOutputFileWarnsOnce(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	writer := &failingWriteCloser{}
+
+	tailer := New(&Config{Log: logger})
+	tailer.outputFile = writer
+
+	for i := 0; i < 3; i++ {
+		tailer.writeOutputFile("line")
+	}
+
+	require.Equal(t, 3, writer.writes)
+	require.Len(t, hook.AllEntries(), 1)
+	require.Contains(t, hook.LastEntry().Message, "disk full")
+}
+
+func TestOutputStreams(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	tailer := New(&Config{Stdout: &stdout, Stderr: &stderr})
+
+	tailer.printHeader("header")
+	tailer.printRequestLogEvent("req_123", "200 POST /v1/charges", 200)
+	tailer.printNotice("Hid 2 request logs matching the exclusions")
+
+	// Only the request logs are printed to stdout, so that they can be piped
+	require.Equal(t, "header\n200 POST /v1/charges\n", stdout.String())
+	require.Equal(t, "Hid 2 request logs matching the exclusions\n", stderr.String())
+}
+
+func TestQuiet(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+
+	var stdout, stderr bytes.Buffer
+	tailer := New(&Config{Log: logger, Quiet: true, Stdout: &stdout, Stderr: &stderr})
+
+	tailer.printRequestLogEvent("req_123", "200 POST /v1/charges", 200)
+	tailer.printNotice("Hid 2 request logs matching the exclusions")
+	tailer.cfg.Log.Warn("Received malformed payload")
+	tailer.cfg.Log.Error("read error")
+
+	require.Equal(t, "200 POST /v1/charges\n", stdout.String())
+	require.Empty(t, stderr.String())
+
+	// Only the errors are logged, and the original logger is left untouched
+	require.Len(t, hook.AllEntries(), 1)
+	require.Equal(t, "read error", hook.LastEntry().Message)
+	require.Equal(t, log.InfoLevel, logger.GetLevel())
+}
+
+func TestQuietLoggerKeepsDebug(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	logger.SetLevel(log.DebugLevel)
+
+	require.Equal(t, log.DebugLevel, quietLogger(logger).GetLevel())
+}
+
+// channelSink sends the request logs written to it to a channel
+type channelSink chan RenderedEvent
+
+func (s channelSink) Write(event RenderedEvent) error {
+	s <- event
+	return nil
+}
+
+func TestRunFlushesOnInterrupt(t *testing.T) {
+	stripe := newFakeStripe(t, 3)
+	defer stripe.close()
+
+	var stdout bytes.Buffer
+	received := make(channelSink, 3)
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		FlushInterval:    time.Hour,
+		Key:              "sk_test_123",
+		OutputFormat:     outputFormatNDJSON,
+		Quiet:            true,
+		Sinks:            []Sink{received},
+		Stderr:           ioutil.Discard,
+		Stdout:           &stdout,
+		WebSocketFeature: "request_logs",
+	})
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Timed out waiting for the request logs")
+		}
+	}
+
+	// The request logs are still buffered
+	tailer.outputMu.Lock()
+	require.Empty(t, stdout.String())
+	tailer.outputMu.Unlock()
+
+	tailer.interruptCh <- os.Interrupt
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the tailer to stop")
+	}
+
+	// The websocket client handles the request logs concurrently, so they can
+	// be printed in any order
+	require.ElementsMatch(t,
+		[]string{
+			`{"request_id":"req_0","status":200,"request_log_id":"resp_0"}`,
+			`{"request_id":"req_1","status":200,"request_log_id":"resp_1"}`,
+			`{"request_id":"req_2","status":200,"request_log_id":"resp_2"}`,
+		},
+		strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n"),
+	)
+}
+
+func TestBufferStdout(t *testing.T) {
+	var stdout bytes.Buffer
+
+	// Buffering can be disabled
+	tailer := New(&Config{FlushInterval: -1, Stdout: &stdout})
+	stopCh := make(chan struct{})
+	tailer.bufferStdout(stopCh)
+	close(stopCh)
+	require.NoError(t, tailer.printRequestLogEvent("req_123", "200 POST /v1/charges", 200))
+	require.Equal(t, "200 POST /v1/charges\n", stdout.String())
+
+	// Otherwise the request logs are flushed periodically
+	stdout.Reset()
+	tailer = New(&Config{FlushInterval: 10 * time.Millisecond, Stdout: &stdout})
+	stopCh = make(chan struct{})
+	defer close(stopCh)
+	tailer.bufferStdout(stopCh)
+	require.NoError(t, tailer.printRequestLogEvent("req_123", "200 POST /v1/charges", 200))
+
+	require.Eventually(t, func() bool {
+		tailer.outputMu.Lock()
+		defer tailer.outputMu.Unlock()
+		return stdout.String() == "200 POST /v1/charges\n"
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,76 @@
+package logtailing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// pauseKey is the key pausing and resuming the output when cfg.PauseKey is
+// set
+const pauseKey = ' '
+
+var errNotTerminal = errors.New("stdin isn't a terminal")
+
+// watchKeys puts the terminal of cfg.Stdin into cbreak mode and toggles the
+// pause of the output every time the pause key is pressed. It returns a
+// function restoring the terminal, which is safe to call several times, and
+// false if cfg.Stdin isn't a terminal, in which case the keys aren't watched.
+func (tailer *Tailer) watchKeys() (func(), bool) {
+	restoreTerminal, err := tailer.cbreak(tailer.cfg.Stdin)
+	if err != nil {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix": "logs.Tailer.watchKeys",
+		}).Debugf("Not watching the keypresses: %v", err)
+		return func() {}, false
+	}
+
+	tailer.writer.pauseNotify(tailer.notifyPause)
+
+	// The goroutine is blocked reading cfg.Stdin until the next keypress
+	// once Run returns, and the writer ignores the pauses once it's stopped
+	go tailer.readKeys(tailer.cfg.Stdin)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if err := restoreTerminal(); err != nil {
+				tailer.cfg.Log.Warnf("Could not restore the terminal: %v", err)
+			}
+		})
+	}, true
+}
+
+// readKeys reads the keypresses of stdin until it fails, pausing and
+// resuming the writer on the pause key
+func (tailer *Tailer) readKeys(stdin io.Reader) {
+	paused := false
+	buf := make([]byte, 1)
+
+	for {
+		n, err := stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		if n == 0 || buf[0] != pauseKey {
+			continue
+		}
+
+		paused = !paused
+		tailer.writer.pause(paused)
+	}
+}
+
+// notifyPause prints a divider when the output is paused or resumed, from
+// the goroutine of the writer so that it's ordered with the request logs
+func (tailer *Tailer) notifyPause(paused bool, queued int) {
+	if paused {
+		tailer.printNotice("— paused, press space to resume —")
+		return
+	}
+
+	tailer.printNotice(fmt.Sprintf("— resumed, %d buffered —", queued))
+}
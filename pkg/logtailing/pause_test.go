@@ -0,0 +1,151 @@
+package logtailing
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTerminal counts how many times the terminal is put into cbreak mode
+// and restored
+type fakeTerminal struct {
+	mu       sync.Mutex
+	cbreaks  int
+	restores int
+}
+
+func (f *fakeTerminal) cbreak(stdin io.Reader) (func() error, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.cbreaks++
+	return func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		f.restores++
+		return nil
+	}, nil
+}
+
+func (f *fakeTerminal) counts() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cbreaks, f.restores
+}
+
+func newPauseKeyTailer(stripe *fakeStripe, stdin io.Reader, stderr io.Writer) *Tailer {
+	return New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		ColorMode:        "never",
+		Key:              "sk_test_123",
+		NoSummary:        true,
+		PauseKey:         true,
+		Stderr:           stderr,
+		Stdin:            stdin,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+}
+
+func TestRunPauseKey(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	stdin, keys := io.Pipe()
+	defer keys.Close()
+
+	var stderr lockedBuffer
+	tailer := newPauseKeyTailer(stripe, stdin, &stderr)
+	terminal := &fakeTerminal{}
+	tailer.cbreak = terminal.cbreak
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- tailer.RunContext(ctx) }()
+
+	// The other keys are ignored
+	_, err := keys.Write([]byte("a "))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return strings.Contains(stderr.String(), "— paused, press space to resume —")
+	}, 5*time.Second, time.Millisecond)
+
+	_, err = keys.Write([]byte(" "))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return strings.Contains(stderr.String(), "— resumed, 0 buffered —")
+	}, 5*time.Second, time.Millisecond)
+
+	require.Contains(t, stderr.String(), "(space to pause, ^C to quit)")
+
+	cancel()
+	requireReturns(t, done)
+
+	cbreaks, restores := terminal.counts()
+	require.Equal(t, 1, cbreaks)
+	require.Equal(t, 1, restores)
+}
+
+func TestRunPauseKeyRestoresOnPanic(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	stdin, keys := io.Pipe()
+	defer keys.Close()
+
+	tailer := newPauseKeyTailer(stripe, stdin, ioutil.Discard)
+	tailer.cfg.RunDuration = time.Minute
+	terminal := &fakeTerminal{}
+	tailer.cbreak = terminal.cbreak
+
+	// Arming the run duration is the last thing Run does once it's ready
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		panic("boom")
+	}
+
+	recovered := make(chan interface{})
+	go func() {
+		defer func() { recovered <- recover() }()
+		tailer.Run() // #nosec G104
+	}()
+
+	select {
+	case r := <-recovered:
+		require.Equal(t, "boom", r)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the tailer to panic")
+	}
+
+	_, restores := terminal.counts()
+	require.Equal(t, 1, restores)
+}
+
+func TestRunPauseKeyNotTerminal(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	var stderr lockedBuffer
+	tailer := newPauseKeyTailer(stripe, strings.NewReader(" "), &stderr)
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(stderr.String(), "Ready!")
+	}, 5*time.Second, time.Millisecond)
+
+	tailer.Stop()
+	requireReturns(t, done)
+
+	require.Contains(t, stderr.String(), "(^C to quit)")
+	require.NotContains(t, stderr.String(), "paused")
+}
@@ -0,0 +1,394 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// PipelineEvent is a request log going through the middlewares of the
+// pipeline. The middlewares can change it for the next ones, e.g. to redact
+// fields of the payload.
+type PipelineEvent struct {
+	// RequestLogEvent is the request log as it was received. The raw payload
+	// is printed as is by the JSON, NDJSON and envelope output formats, and
+	// passed to the sinks.
+	RequestLogEvent *websocket.RequestLogEvent
+
+	// Payload is the payload of the request log, once it's parsed. It's the
+	// zero value before, and when the payload is malformed.
+	Payload EventPayload
+
+	// Malformed is true if the payload isn't valid JSON
+	Malformed bool
+
+	// Hidden is true if the request log doesn't match the filters, and is
+	// only going through the pipeline to be shown dimmed in highlight mode
+	Hidden bool
+
+	// Label is the label of the key the request log was received with, when
+	// several keys are tailed or the key has a label
+	Label string
+
+	// ReceivedAt is when the request log was received
+	ReceivedAt time.Time
+
+	// pooled is true if the request log is processed by the worker pool,
+	// in which case it's rendered in rendered instead of being written, so
+	// that the pool writes it in order
+	pooled   bool
+	rendered *RenderedEvent
+}
+
+// EventFunc processes a request log of the pipeline
+type EventFunc func(event *PipelineEvent)
+
+// Middleware is a step of the pipeline processing the request logs. It
+// returns an EventFunc processing a request log then calling next to pass it
+// to the next step, or not calling it to drop the request log.
+type Middleware func(next EventFunc) EventFunc
+
+// chain returns an EventFunc passing the request logs through the
+// middlewares in order, then to last
+func chain(last EventFunc, middlewares ...Middleware) EventFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		last = middlewares[i](last)
+	}
+	return last
+}
+
+// pipeline returns the chain of middlewares processing the request logs, in
+// this order:
+//
+//  1. receiveMiddleware counts every request log received
+//  2. filterRawMiddleware applies the filters reading the raw payload
+//  3. parseMiddleware parses the payload
+//  4. filterMiddleware applies the filters reading the parsed payload
+//  5. cfg.Middlewares, in order
+//  6. recordMiddleware writes the request logs to cfg.RecordFile
+//  7. sampleMiddleware applies cfg.SampleRate
+//  8. limitMiddleware applies cfg.EventLimit
+//  9. failOnMiddleware tracks the request logs matching cfg.FailOn
+//  10. notifyMiddleware shows the desktop notifications of cfg.NotifyOn
+//  11. eventsMiddleware sends the request logs to the channel of Events
+//  12. statsMiddleware adds the request logs to the summary of the session
+//  13. handlerMiddleware calls cfg.Handler
+//
+// The request logs are then rendered and written to the outputs, where the
+// terminal output collapses the repeats when cfg.DedupeWindow is set.
+func (tailer *Tailer) pipeline() EventFunc {
+	middlewares := []Middleware{
+		tailer.receiveMiddleware,
+		tailer.filterRawMiddleware,
+		tailer.parseMiddleware,
+		tailer.filterMiddleware,
+	}
+	middlewares = append(middlewares, tailer.cfg.Middlewares...)
+	middlewares = append(middlewares,
+		tailer.recordMiddleware,
+		tailer.sampleMiddleware,
+		tailer.limitMiddleware,
+		tailer.failOnMiddleware,
+		tailer.notifyMiddleware,
+		tailer.eventsMiddleware,
+		tailer.statsMiddleware,
+		tailer.handlerMiddleware,
+	)
+
+	return chain(tailer.outputEvent, middlewares...)
+}
+
+// receiveMiddleware records every request log received when
+// cfg.RecordFiltered is set, and counts them in the metrics, including the
+// ones hidden by the filters
+func (tailer *Tailer) receiveMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		if tailer.recorder != nil && tailer.cfg.RecordFiltered {
+			tailer.recorder.record(event.RequestLogEvent, event.ReceivedAt)
+		}
+
+		if tailer.metrics != nil {
+			tailer.metrics.recordEvent(int(gjson.Get(event.RequestLogEvent.EventPayload, "status").Int()))
+		}
+
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.processRequestLogEvent",
+			"webhook_id": event.RequestLogEvent.RequestLogID,
+		}).Debugf("Processing request log event")
+
+		next(event)
+	}
+}
+
+// filterRawMiddleware hides the request logs whose raw payload doesn't match
+// the filters, dropping them unless they're shown dimmed in highlight mode
+func (tailer *Tailer) filterRawMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		event.Hidden = event.Hidden || tailer.filterRawRequestLogEvent(event.RequestLogEvent.EventPayload)
+		if event.Hidden && !tailer.highlighting() {
+			tailer.recordFiltered()
+			return
+		}
+
+		next(event)
+	}
+}
+
+// recordFiltered counts a request log dropped by the filters in the summary
+func (tailer *Tailer) recordFiltered() {
+	if tailer.stats != nil {
+		tailer.stats.recordFiltered()
+	}
+}
+
+// parseMiddleware parses the payload of the request logs. The malformed
+// payloads are counted and passed on with a zero payload.
+func (tailer *Tailer) parseMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		if err := json.Unmarshal([]byte(event.RequestLogEvent.EventPayload), &event.Payload); err != nil {
+			event.Malformed = true
+			tailer.warnLimited(warningMalformedPayload, log.Fields{
+				"prefix":         "logs.Tailer.processRequestLogEvent",
+				"request_log_id": event.RequestLogEvent.RequestLogID,
+			}, "Received malformed payload: ", err)
+
+			if tailer.metrics != nil {
+				tailer.metrics.recordMalformed()
+			}
+			if tailer.stats != nil {
+				tailer.stats.recordMalformed()
+			}
+
+			if len(tailer.cfg.FilterRequestIDs) > 0 {
+				tailer.cfg.Log.WithFields(log.Fields{
+					"prefix":  "logs.Tailer.processRequestLogEvent",
+					"payload": event.RequestLogEvent.EventPayload,
+				}).Debug("Could not read the request ID of the malformed payload")
+			}
+		}
+
+		next(event)
+	}
+}
+
+// filterMiddleware drops the request logs of the CLI sessions, and hides the
+// request logs whose parsed payload doesn't match the filters, dropping them
+// unless they're shown dimmed in highlight mode
+func (tailer *Tailer) filterMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		// Don't show stripecli/sessions logs since they're generated by the CLI
+		if event.Payload.URL == "/v1/stripecli/sessions" {
+			tailer.cfg.Log.Debug("Filtering out /v1/stripecli/sessions from logs")
+			return
+		}
+
+		event.Hidden = event.Hidden || tailer.filterRequestLogEvent(&event.Payload)
+		if event.Hidden && !tailer.highlighting() {
+			tailer.recordFiltered()
+			return
+		}
+
+		next(event)
+	}
+}
+
+// recordMiddleware writes the request logs matching the filters to
+// cfg.RecordFile, unless cfg.RecordFiltered is set and they were all
+// recorded when received
+func (tailer *Tailer) recordMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		if tailer.recorder != nil && !tailer.cfg.RecordFiltered && !event.Hidden {
+			tailer.recorder.record(event.RequestLogEvent, event.ReceivedAt)
+		}
+
+		next(event)
+	}
+}
+
+// sampleMiddleware drops the request logs suppressed by cfg.SampleRate
+func (tailer *Tailer) sampleMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		if tailer.sampleRequestLogEvent(&event.Payload) {
+			return
+		}
+
+		next(event)
+	}
+}
+
+// limitMiddleware drops the request logs past cfg.EventLimit, and stops the
+// tailer once the last one is passed on
+func (tailer *Tailer) limitMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		if tailer.cfg.EventLimit <= 0 {
+			next(event)
+			return
+		}
+
+		counted, last := tailer.countEvent(event.Hidden)
+		if !counted {
+			return
+		}
+
+		next(event)
+
+		if last {
+			// Stop once the request log is queued, so that it's written
+			// before the tailer shuts down
+			tailer.Stop()
+		}
+	}
+}
+
+// failOnMiddleware tracks the request logs matching cfg.FailOn
+func (tailer *Tailer) failOnMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		if tailer.failOn != nil && !event.Hidden {
+			tailer.failOn.record(&event.Payload)
+		}
+
+		next(event)
+	}
+}
+
+// eventsMiddleware sends the request logs matching the filters to the
+// channel of Events, when cfg.EmitEvents is set
+func (tailer *Tailer) eventsMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		if tailer.events != nil && !event.Hidden {
+			tailer.events.send(Event{
+				EventPayload: event.Payload,
+				RawPayload:   event.RequestLogEvent.EventPayload,
+				RequestLogID: event.RequestLogEvent.RequestLogID,
+				Label:        event.Label,
+				ReceivedAt:   event.ReceivedAt,
+			})
+		}
+
+		next(event)
+	}
+}
+
+// statsMiddleware adds the request logs to the stats of the session
+func (tailer *Tailer) statsMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		// The zero values of a malformed payload would skew the stats, it's
+		// only counted as malformed
+		if tailer.stats != nil && !event.Malformed {
+			tailer.stats.record(event.Label, &event.Payload)
+		}
+
+		next(event)
+	}
+}
+
+// handlerMiddleware calls cfg.Handler with the request logs matching the
+// filters, and drops them unless cfg.KeepOutput is set
+func (tailer *Tailer) handlerMiddleware(next EventFunc) EventFunc {
+	return func(event *PipelineEvent) {
+		if tailer.cfg.Handler == nil {
+			next(event)
+			return
+		}
+
+		if !event.Hidden {
+			tailer.callHandler(event.Payload, *event.RequestLogEvent)
+		}
+		if tailer.cfg.KeepOutput {
+			next(event)
+		}
+	}
+}
+
+// outputEvent renders the request log in cfg.OutputFormat and writes it to
+// the outputs
+func (tailer *Tailer) outputEvent(event *PipelineEvent) {
+	requestLogEvent := event.RequestLogEvent
+	payload := &event.Payload
+	label := event.Label
+
+	highlighting := tailer.highlighting()
+	highlightState := highlightNone
+	switch {
+	case highlighting && event.Hidden:
+		highlightState = highlightDimmed
+	case highlighting:
+		highlightState = highlightMatch
+	}
+
+	var line string
+	switch tailer.cfg.OutputFormat {
+	case outputFormatJSON:
+		line = tailer.cfg.Theme.ColorizeJSON(tailer.jsonPayload(withLabel(requestLogEvent.EventPayload, label)), tailer.cfg.colorMode(), tailer.cfg.Stdout)
+	case outputFormatNDJSON:
+		line = withJSONKey(ndjsonLine(requestLogEvent), "label", label)
+	case outputFormatEnvelope:
+		line = withJSONKey(envelopeLine(requestLogEvent, event.ReceivedAt), "label", label)
+	case outputFormatCSV:
+		var record []string
+		if event.Malformed {
+			record = csvMalformedRecord(requestLogEvent)
+		} else {
+			record = csvRecord(payload)
+		}
+		if tailer.labeled() {
+			record = append(record, label)
+		}
+		line = csvLine(record)
+	case outputFormatLogfmt:
+		if event.Malformed {
+			line = logfmtMalformedLine(requestLogEvent)
+		} else {
+			line = logfmtLine(requestLogEvent, payload, tailer.logfmtTimestampFormat())
+		}
+		if label != "" {
+			line = logfmtPair{key: "label", value: label}.String() + " " + line
+		}
+	default:
+		switch {
+		case event.Malformed:
+			line = malformedLine(requestLogEvent)
+		case len(tailer.cfg.Fields) > 0:
+			line = fieldsLine(requestLogEvent.EventPayload, tailer.cfg.Fields)
+		default:
+			line = tailer.renderRequestLogEvent(requestLogEvent, payload, highlightState)
+		}
+		if label != "" {
+			line = fmt.Sprintf("[%s] %s", label, line)
+		}
+	}
+
+	rendered := RenderedEvent{
+		Line:         line,
+		Payload:      requestLogEvent.EventPayload,
+		Label:        label,
+		Hidden:       event.Hidden,
+		Method:       payload.Method,
+		RequestID:    payload.RequestID,
+		RequestLogID: requestLogEvent.RequestLogID,
+		Status:       payload.Status,
+		URL:          payload.URL,
+	}
+
+	if event.pooled {
+		event.rendered = &rendered
+		return
+	}
+
+	tailer.writeRendered(rendered)
+}
+
+// writeRendered writes a rendered request log to the outputs, through the
+// writer when it's running
+func (tailer *Tailer) writeRendered(event RenderedEvent) {
+	if tailer.writer != nil {
+		tailer.writer.send(event)
+	} else {
+		tailer.sinks.Write(event) // #nosec G104
+	}
+}
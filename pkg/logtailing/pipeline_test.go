@@ -0,0 +1,247 @@
+package logtailing
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// pipelineEvent returns a request log of the pipeline with the payload
+func pipelineEvent(payload string) *PipelineEvent {
+	return &PipelineEvent{RequestLogEvent: &websocket.RequestLogEvent{EventPayload: payload, RequestLogID: "resp_123"}}
+}
+
+// passes returns true if the middleware passes the request log on to the
+// next step
+func passes(m Middleware, event *PipelineEvent) bool {
+	passed := false
+	m(func(*PipelineEvent) { passed = true })(event)
+	return passed
+}
+
+func TestChain(t *testing.T) {
+	var steps []string
+	step := func(name string) Middleware {
+		return func(next EventFunc) EventFunc {
+			return func(event *PipelineEvent) {
+				steps = append(steps, name)
+				next(event)
+			}
+		}
+	}
+
+	process := chain(func(*PipelineEvent) { steps = append(steps, "last") }, step("first"), step("second"))
+	process(pipelineEvent(`{}`))
+
+	require.Equal(t, []string{"first", "second", "last"}, steps)
+}
+
+func TestReceiveMiddleware(t *testing.T) {
+	tailer := New(&Config{})
+	tailer.metrics = newTailMetrics(nil)
+
+	require.True(t, passes(tailer.receiveMiddleware, pipelineEvent(`{"status": 500}`)))
+	require.Equal(t, uint64(1), tailer.metrics.received)
+	require.Equal(t, uint64(1), tailer.metrics.byClass[4])
+}
+
+func TestFilterRawMiddleware(t *testing.T) {
+	tailer := New(&Config{FilterText: []string{"charges"}})
+
+	require.True(t, passes(tailer.filterRawMiddleware, pipelineEvent(`{"url": "/v1/charges"}`)))
+	require.False(t, passes(tailer.filterRawMiddleware, pipelineEvent(`{"url": "/v1/customers"}`)))
+
+	// The hidden request logs are passed on in highlight mode
+	tailer = New(&Config{ColorMode: "always", FilterText: []string{"charges"}, HighlightMode: true})
+	event := pipelineEvent(`{"url": "/v1/customers"}`)
+	require.True(t, passes(tailer.filterRawMiddleware, event))
+	require.True(t, event.Hidden)
+}
+
+func TestParseMiddleware(t *testing.T) {
+	tailer := New(&Config{NoStdout: true})
+
+	event := pipelineEvent(`{"status": 200, "url": "/v1/charges"}`)
+	require.True(t, passes(tailer.parseMiddleware, event))
+	require.Equal(t, EventPayload{Status: 200, URL: "/v1/charges"}, event.Payload)
+	require.False(t, event.Malformed)
+
+	// The malformed payloads are passed on to be printed raw
+	event = pipelineEvent(`{"status": 200`)
+	require.True(t, passes(tailer.parseMiddleware, event))
+	require.True(t, event.Malformed)
+}
+
+func TestFilterMiddleware(t *testing.T) {
+	tailer := New(&Config{FilterStatusCodes: []int{500}})
+
+	require.True(t, passes(tailer.filterMiddleware, &PipelineEvent{Payload: EventPayload{Status: 500}}))
+	require.False(t, passes(tailer.filterMiddleware, &PipelineEvent{Payload: EventPayload{Status: 200}}))
+
+	// The request logs of the CLI sessions are always dropped
+	tailer = New(&Config{})
+	require.False(t, passes(tailer.filterMiddleware, &PipelineEvent{Payload: EventPayload{URL: "/v1/stripecli/sessions"}}))
+}
+
+func TestSampleMiddleware(t *testing.T) {
+	tailer := New(&Config{SampleRate: 2})
+
+	require.False(t, passes(tailer.sampleMiddleware, &PipelineEvent{Payload: EventPayload{Status: 200}}))
+	require.True(t, passes(tailer.sampleMiddleware, &PipelineEvent{Payload: EventPayload{Status: 200}}))
+	// The server errors are always kept
+	require.True(t, passes(tailer.sampleMiddleware, &PipelineEvent{Payload: EventPayload{Status: 500}}))
+}
+
+func TestLimitMiddleware(t *testing.T) {
+	tailer := New(&Config{EventLimit: 2})
+
+	require.True(t, passes(tailer.limitMiddleware, &PipelineEvent{}))
+	require.True(t, passes(tailer.limitMiddleware, &PipelineEvent{}))
+
+	// The tailer is stopped once the last request log is passed on
+	select {
+	case <-tailer.stopCh:
+	default:
+		require.FailNow(t, "The tailer wasn't stopped")
+	}
+
+	require.False(t, passes(tailer.limitMiddleware, &PipelineEvent{}))
+}
+
+func TestFailOnMiddleware(t *testing.T) {
+	tailer := New(&Config{FailOn: failOn5xx})
+
+	require.True(t, passes(tailer.failOnMiddleware, &PipelineEvent{Payload: EventPayload{Status: 500, RequestID: "req_1"}}))
+	// The hidden request logs aren't tracked
+	require.True(t, passes(tailer.failOnMiddleware, &PipelineEvent{Payload: EventPayload{Status: 502, RequestID: "req_2"}, Hidden: true}))
+
+	require.Equal(t, &FailOnError{FailOn: failOn5xx, Count: 1, FirstRequestID: "req_1"}, tailer.failOnError())
+}
+
+func TestEventsMiddleware(t *testing.T) {
+	tailer := New(&Config{EmitEvents: true})
+
+	require.True(t, passes(tailer.eventsMiddleware, &PipelineEvent{
+		RequestLogEvent: &websocket.RequestLogEvent{EventPayload: `{"status": 200}`, RequestLogID: "resp_123"},
+		Payload:         EventPayload{Status: 200},
+		Label:           "acme",
+	}))
+	require.True(t, passes(tailer.eventsMiddleware, &PipelineEvent{RequestLogEvent: &websocket.RequestLogEvent{}, Hidden: true}))
+
+	require.Len(t, tailer.Events(), 1)
+	event := <-tailer.Events()
+	require.Equal(t, "resp_123", event.RequestLogID)
+	require.Equal(t, "acme", event.Label)
+	require.Equal(t, 200, event.Status)
+}
+
+func TestStatsMiddleware(t *testing.T) {
+	tailer := New(&Config{})
+	tailer.stats = newSessionStats(time.Time{})
+
+	require.True(t, passes(tailer.statsMiddleware, &PipelineEvent{Payload: EventPayload{Status: 200}}))
+	require.True(t, passes(tailer.statsMiddleware, &PipelineEvent{Malformed: true}))
+
+	require.Equal(t, 1, tailer.stats.total)
+}
+
+func TestSummaryCountsFiltered(t *testing.T) {
+	tailer := New(&Config{OnlyErrors: true, Stdout: ioutil.Discard})
+	tailer.stats = newSessionStats(time.Time{})
+
+	tailer.processRequestLogEvent(requestLogMessage(`{"status": 200, "url": "/v1/charges"}`))
+	tailer.processRequestLogEvent(requestLogMessage(`{"status": 500, "url": "/v1/charges"}`))
+
+	var summary bytes.Buffer
+	tailer.stats.write(&summary, time.Time{})
+
+	require.Equal(t, 1, tailer.stats.total)
+	require.Equal(t, 1, tailer.stats.filtered)
+	require.Contains(t, summary.String(), "  Filtered out: 1\n")
+}
+
+func TestHandlerMiddleware(t *testing.T) {
+	var handled []string
+	handler := func(payload *EventPayload, event *websocket.RequestLogEvent) {
+		handled = append(handled, event.RequestLogID)
+	}
+
+	tailer := New(&Config{Handler: handler})
+	require.False(t, passes(tailer.handlerMiddleware, pipelineEvent(`{}`)))
+
+	tailer = New(&Config{Handler: handler, KeepOutput: true})
+	require.True(t, passes(tailer.handlerMiddleware, pipelineEvent(`{}`)))
+
+	require.Equal(t, []string{"resp_123", "resp_123"}, handled)
+
+	require.True(t, passes(New(&Config{}).handlerMiddleware, pipelineEvent(`{}`)))
+}
+
+func TestPipeline(t *testing.T) {
+	// A custom middleware redacting the customers, and one dropping the
+	// request logs of the refunds
+	redact := func(next EventFunc) EventFunc {
+		return func(event *PipelineEvent) {
+			event.Payload.URL = strings.Replace(event.Payload.URL, "cus_123", "cus_***", 1)
+			next(event)
+		}
+	}
+	var seen []int
+	dropRefunds := func(next EventFunc) EventFunc {
+		return func(event *PipelineEvent) {
+			seen = append(seen, event.Payload.Status)
+			if strings.HasPrefix(event.Payload.URL, "/v1/refunds") {
+				return
+			}
+			next(event)
+		}
+	}
+
+	var stdout bytes.Buffer
+	tailer := New(&Config{
+		ColorMode:         "never",
+		FailOn:            failOn5xx,
+		FilterStatusCodes: []int{200, 404, 500},
+		Middlewares:       []Middleware{redact, dropRefunds},
+		NoStatusText:      true,
+		Stdout:            &stdout,
+	})
+	tailer.stats = newSessionStats(time.Time{})
+	tailer.metrics = newTailMetrics(nil)
+
+	payloads := []string{
+		`{"status": 200, "method": "GET", "url": "/v1/customers/cus_123", "request_id": "req_0"}`,
+		`{"status": 402, "method": "POST", "url": "/v1/charges", "request_id": "req_1"}`,
+		`{"status": 404, "method": "GET", "url": "/v1/refunds/re_123", "request_id": "req_2"}`,
+		`{"status": 500, "method": "POST", "url": "/v1/charges", "request_id": "req_3"}`,
+		`{"status": 200, "method": "POST", "url": "/v1/stripecli/sessions", "request_id": "req_4"}`,
+		`{"status": 200`,
+	}
+	for i, payload := range payloads {
+		tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+			EventPayload: payload,
+			RequestLogID: fmt.Sprintf("resp_%d", i),
+		}})
+	}
+
+	// Every request log is received, the custom middlewares only see the
+	// ones matching the filters, and the outputs the ones they pass on
+	require.Equal(t, uint64(len(payloads)), tailer.metrics.received)
+	require.Equal(t, []int{200, 404, 500}, seen)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], "/v1/customers/cus_***")
+	require.Contains(t, lines[1], "[500] POST /v1/charges")
+
+	require.Equal(t, 2, tailer.stats.total)
+	require.Equal(t, 1, tailer.stats.malformed)
+	require.Equal(t, &FailOnError{FailOn: failOn5xx, Count: 1, FirstRequestID: "req_3"}, tailer.failOnError())
+}
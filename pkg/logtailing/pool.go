@@ -0,0 +1,183 @@
+package logtailing
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// poolQueueSize is the number of request logs queued for each worker of the
+// pool before the websocket clients stop reading, when cfg.QueueSize isn't
+// set
+const poolQueueSize = 64
+
+// poolJob is a request log queued for the workers, with its position in the
+// order the request logs were received
+type poolJob struct {
+	seq uint64
+	st  *stream
+	msg websocket.IncomingMessage
+}
+
+// workerPool processes the request logs received by the websocket clients on
+// cfg.Workers goroutines, then hands the rendered request logs to a
+// sequencer writing them in the order they were received, so that the
+// output is never out of order. The request logs wait for the workers in a
+// queue, which drops them when it's full if cfg.QueueSize is set.
+type workerPool struct {
+	queue *eventQueue
+
+	// process passes a request log through the pipeline, returning it
+	// rendered, or nil if it was dropped
+	process func(st *stream, msg websocket.IncomingMessage) *RenderedEvent
+
+	// write writes a rendered request log to the outputs, in order
+	write func(event RenderedEvent)
+
+	// done is called once a request log is written or dropped
+	done func()
+
+	// onDrop is called when the queue drops a request log, if set
+	onDrop func()
+
+	// dropped is the number of request logs dropped by the queue. It must
+	// be accessed atomically.
+	dropped uint64
+
+	// next is the position of the next request log submitted. It must be
+	// accessed atomically.
+	next uint64
+
+	// pending are the request logs processed before the ones received
+	// earlier, by position, and written is the position of the next request
+	// log to write
+	mu      sync.Mutex
+	pending map[uint64]*RenderedEvent
+	written uint64
+
+	wg sync.WaitGroup
+}
+
+func newWorkerPool(process func(st *stream, msg websocket.IncomingMessage) *RenderedEvent, write func(event RenderedEvent), done func()) *workerPool {
+	return &workerPool{
+		process: process,
+		write:   write,
+		done:    done,
+		pending: make(map[uint64]*RenderedEvent),
+	}
+}
+
+// start starts the workers, taking the request logs from the queue
+func (p *workerPool) start(size int, queue *eventQueue) {
+	p.queue = queue
+
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go func() {
+			defer p.wg.Done()
+
+			for {
+				job, ok := p.queue.pop()
+				if !ok {
+					return
+				}
+
+				p.complete(job.seq, p.process(job.st, job.msg))
+				p.done()
+			}
+		}()
+	}
+}
+
+// submit queues a request log for the workers, which may drop another one,
+// or block while the queue is full, depending on its policy. The request logs
+// are written in the order they're submitted, so the websocket clients
+// submit them from the goroutine reading the connection.
+func (p *workerPool) submit(st *stream, msg websocket.IncomingMessage) {
+	seq := atomic.AddUint64(&p.next, 1) - 1
+
+	dropped, ok := p.queue.push(poolJob{seq: seq, st: st, msg: msg})
+	if !ok {
+		return
+	}
+
+	// The sequencer mustn't wait for the dropped request log
+	atomic.AddUint64(&p.dropped, 1)
+	if p.onDrop != nil {
+		p.onDrop()
+	}
+	p.complete(dropped.seq, nil)
+	p.done()
+}
+
+// complete records the rendered request log at the position, nil if it was
+// dropped, then writes the request logs that are next in order
+func (p *workerPool) complete(seq uint64, event *RenderedEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending[seq] = event
+
+	for {
+		event, ok := p.pending[p.written]
+		if !ok {
+			return
+		}
+
+		delete(p.pending, p.written)
+		p.written++
+
+		if event != nil {
+			p.write(*event)
+		}
+	}
+}
+
+// close waits for the workers to process the queued request logs. Nothing
+// can be submitted once it's called.
+func (p *workerPool) close() {
+	p.queue.close()
+	p.wg.Wait()
+}
+
+// startPool starts the worker pool with cfg.Workers workers, or one, taking
+// the request logs from a queue of cfg.QueueSize request logs if it's set
+func (tailer *Tailer) startPool() {
+	workers := tailer.cfg.Workers
+	if workers == 0 {
+		workers = 1
+	}
+
+	queue := newEventQueue(workers*poolQueueSize, queuePolicyBlock)
+	if tailer.cfg.QueueSize > 0 {
+		policy := tailer.cfg.QueuePolicy
+		if policy == "" {
+			policy = queuePolicyDropOldest
+		}
+		queue = newEventQueue(tailer.cfg.QueueSize, policy)
+	}
+
+	tailer.pool = newWorkerPool(func(st *stream, msg websocket.IncomingMessage) *RenderedEvent {
+		return tailer.runPipeline(st, msg, true)
+	}, tailer.writeRendered, tailer.handlers.done)
+	tailer.pool.onDrop = func() {
+		if tailer.stats != nil {
+			tailer.stats.recordDropped()
+		}
+	}
+	tailer.pool.start(workers, queue)
+}
+
+// dropped returns the number of request logs dropped because the tailer
+// couldn't keep up, by the queue of the pool or by the writer
+func (tailer *Tailer) dropped() uint64 {
+	var dropped uint64
+	if tailer.pool != nil {
+		dropped += atomic.LoadUint64(&tailer.pool.dropped)
+	}
+	if tailer.writer != nil {
+		dropped += atomic.LoadUint64(&tailer.writer.dropped)
+	}
+	return dropped
+}
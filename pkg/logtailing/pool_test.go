@@ -0,0 +1,125 @@
+package logtailing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestWorkerPoolOrder(t *testing.T) {
+	const count = 200
+
+	var mu sync.Mutex
+	var written []string
+	var done sync.WaitGroup
+	done.Add(count)
+
+	pool := newWorkerPool(func(st *stream, msg websocket.IncomingMessage) *RenderedEvent {
+		// The workers finish in random order
+		time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond) // #nosec G404
+
+		// Every third request log is dropped
+		id := msg.RequestLogEvent.RequestLogID
+		var i int
+		fmt.Sscanf(id, "resp_%d", &i) // #nosec G104
+		if i%3 == 2 {
+			return nil
+		}
+		return &RenderedEvent{RequestLogID: id}
+	}, func(event RenderedEvent) {
+		mu.Lock()
+		written = append(written, event.RequestLogID)
+		mu.Unlock()
+	}, done.Done)
+	pool.start(8, newEventQueue(8*poolQueueSize, queuePolicyBlock))
+
+	var expected []string
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("resp_%d", i)
+		if i%3 != 2 {
+			expected = append(expected, id)
+		}
+		pool.submit(nil, websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{RequestLogID: id}})
+	}
+
+	done.Wait()
+	pool.close()
+
+	require.Equal(t, expected, written)
+	require.Empty(t, pool.pending)
+}
+
+func TestRunWorkers(t *testing.T) {
+	stripe := newFakeStripe(t, 50)
+	defer stripe.close()
+
+	received := make(channelSink, 50)
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		EventLimit:       50,
+		Key:              "sk_test_123",
+		NoStdout:         true,
+		Quiet:            true,
+		Sinks:            []Sink{received},
+		Stderr:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+		Workers:          4,
+	})
+
+	require.NoError(t, tailer.Run())
+
+	// The request logs are written in the order the websocket sent them
+	require.Len(t, received, 50)
+	for i := 0; i < 50; i++ {
+		require.Equal(t, fmt.Sprintf("resp_%d", i), (<-received).RequestLogID)
+	}
+}
+
+// benchmarkPayload is a request log heavy enough to parse, filter and render
+// for the benchmarks
+const benchmarkPayload = `{"status": 402, "method": "POST", "url": "/v1/payment_intents/pi_123/confirm?expand[]=latest_charge", "request_id": "req_123", "created_at": 1570000000, "livemode": false, "api_version": "2019-10-17", "source": "dashboard", "ip_address": "127.0.0.1", "error": {"type": "card_error", "code": "card_declined", "message": "Your card was declined."}, "request_body": {"amount": 1000, "currency": "usd", "metadata": {"order": "123"}}}`
+
+func newBenchmarkTailer() *Tailer {
+	return New(&Config{
+		ColorMode:         "never",
+		FilterHTTPMethods: []string{"POST"},
+		FilterText:        []string{"card"},
+		OutputFormat:      outputFormatJSON,
+		SortJSONKeys:      true,
+		Stdout:            ioutil.Discard,
+	})
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	msg := websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{EventPayload: benchmarkPayload, RequestLogID: "resp_123"}}
+
+	b.Run("single", func(b *testing.B) {
+		tailer := newBenchmarkTailer()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			tailer.processStreamEvent(nil, msg)
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		tailer := newBenchmarkTailer()
+		pool := newWorkerPool(func(st *stream, msg websocket.IncomingMessage) *RenderedEvent {
+			return tailer.runPipeline(st, msg, true)
+		}, tailer.writeRendered, func() {})
+		pool.start(8, newEventQueue(8*poolQueueSize, queuePolicyBlock))
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			pool.submit(nil, msg)
+		}
+		pool.close()
+	})
+}
@@ -0,0 +1,207 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PresetStore persists named filter presets, e.g. in the CLI profile.
+type PresetStore interface {
+	// GetFilterPreset returns the serialized preset, and false if there's no
+	// preset with that name
+	GetFilterPreset(name string) (string, bool)
+
+	// ListFilterPresets returns the names of all the saved presets
+	ListFilterPresets() []string
+
+	// SaveFilterPreset saves the serialized preset under the name
+	SaveFilterPreset(name string, preset string) error
+}
+
+// FilterPreset is the serializable set of local filters saved in a preset.
+// Time window filters aren't included since they're relative to when the
+// tail was started.
+type FilterPreset struct {
+	ExcludeConnectedAccount bool          `json:"exclude_connected_account,omitempty"`
+	ExcludeRequestPaths     []string      `json:"exclude_request_paths,omitempty"`
+	FilterAccount           []string      `json:"filter_account,omitempty"`
+	FilterAPIVersion        []string      `json:"filter_api_version,omitempty"`
+	FilterErrorCode         []string      `json:"filter_error_code,omitempty"`
+	FilterErrorType         []string      `json:"filter_error_type,omitempty"`
+	FilterExpression        string        `json:"filter_expression,omitempty"`
+	FilterHTTPMethods       []string      `json:"filter_http_methods,omitempty"`
+	FilterIdempotencyKeys   []string      `json:"filter_idempotency_keys,omitempty"`
+	FilterIPAddress         []string      `json:"filter_ip_address,omitempty"`
+	FilterLivemode          *bool         `json:"filter_livemode,omitempty"`
+	FilterMaxLatency        time.Duration `json:"filter_max_latency,omitempty"`
+	FilterMinLatency        time.Duration `json:"filter_min_latency,omitempty"`
+	FilterRequestIDs        []string      `json:"filter_request_ids,omitempty"`
+	FilterRequestPaths      []string      `json:"filter_request_paths,omitempty"`
+	FilterResources         []string      `json:"filter_resources,omitempty"`
+	FilterSource            string        `json:"filter_source,omitempty"`
+	FilterStatusClasses     []string      `json:"filter_status_classes,omitempty"`
+	FilterStatusCodes       []int         `json:"filter_status_codes,omitempty"`
+	FilterText              []string      `json:"filter_text,omitempty"`
+	FilterURLRegex          string        `json:"filter_url_regex,omitempty"`
+	FilterUserAgent         []string      `json:"filter_user_agent,omitempty"`
+	OnlyErrors              bool          `json:"only_errors,omitempty"`
+	RequireConnectedAccount bool          `json:"require_connected_account,omitempty"`
+}
+
+// SavePreset saves the local filters of the config under the name.
+func SavePreset(store PresetStore, name string, cfg *Config) error {
+	if !presetNameRegexp.MatchString(name) {
+		return fmt.Errorf("%s is not a valid preset name, only letters, digits, dashes and underscores are allowed", name)
+	}
+
+	bytes, err := json.Marshal(cfg.filterPreset())
+	if err != nil {
+		return err
+	}
+
+	return store.SaveFilterPreset(strings.ToLower(name), string(bytes))
+}
+
+// loadPreset loads the preset named cfg.PresetName from cfg.Presets and
+// merges it into the config. Filters that are already set on the config take
+// precedence over the ones from the preset.
+func (cfg *Config) loadPreset() error {
+	if cfg.Presets == nil {
+		return fmt.Errorf("can't load the preset %s, no preset store is configured", cfg.PresetName)
+	}
+
+	serialized, ok := cfg.Presets.GetFilterPreset(strings.ToLower(cfg.PresetName))
+	if !ok {
+		names := cfg.Presets.ListFilterPresets()
+		if len(names) == 0 {
+			return fmt.Errorf("the preset %s doesn't exist, no presets have been saved yet", cfg.PresetName)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("the preset %s doesn't exist (available presets: %s)", cfg.PresetName, strings.Join(names, ", "))
+	}
+
+	var preset FilterPreset
+	if err := json.Unmarshal([]byte(serialized), &preset); err != nil {
+		return fmt.Errorf("the preset %s can't be read: %v", cfg.PresetName, err)
+	}
+
+	cfg.mergeFilterPreset(preset)
+
+	return nil
+}
+
+func (cfg *Config) filterPreset() FilterPreset {
+	return FilterPreset{
+		ExcludeConnectedAccount: cfg.ExcludeConnectedAccount,
+		ExcludeRequestPaths:     cfg.ExcludeRequestPaths,
+		FilterAccount:           cfg.FilterAccount,
+		FilterAPIVersion:        cfg.FilterAPIVersion,
+		FilterErrorCode:         cfg.FilterErrorCode,
+		FilterErrorType:         cfg.FilterErrorType,
+		FilterExpression:        cfg.FilterExpression,
+		FilterHTTPMethods:       cfg.FilterHTTPMethods,
+		FilterIdempotencyKeys:   cfg.FilterIdempotencyKeys,
+		FilterIPAddress:         cfg.FilterIPAddress,
+		FilterLivemode:          cfg.FilterLivemode,
+		FilterMaxLatency:        cfg.FilterMaxLatency,
+		FilterMinLatency:        cfg.FilterMinLatency,
+		FilterRequestIDs:        cfg.FilterRequestIDs,
+		FilterRequestPaths:      cfg.FilterRequestPaths,
+		FilterResources:         cfg.FilterResources,
+		FilterSource:            cfg.FilterSource,
+		FilterStatusClasses:     cfg.FilterStatusClasses,
+		FilterStatusCodes:       cfg.FilterStatusCodes,
+		FilterText:              cfg.FilterText,
+		FilterURLRegex:          cfg.FilterURLRegex,
+		FilterUserAgent:         cfg.FilterUserAgent,
+		OnlyErrors:              cfg.OnlyErrors,
+		RequireConnectedAccount: cfg.RequireConnectedAccount,
+	}
+}
+
+// setFilterPreset replaces the local filters of the config with the ones from
+// the preset.
+func (cfg *Config) setFilterPreset(preset FilterPreset) {
+	cfg.ExcludeConnectedAccount = preset.ExcludeConnectedAccount
+	cfg.ExcludeRequestPaths = preset.ExcludeRequestPaths
+	cfg.FilterAccount = preset.FilterAccount
+	cfg.FilterAPIVersion = preset.FilterAPIVersion
+	cfg.FilterErrorCode = preset.FilterErrorCode
+	cfg.FilterErrorType = preset.FilterErrorType
+	cfg.FilterExpression = preset.FilterExpression
+	cfg.FilterHTTPMethods = preset.FilterHTTPMethods
+	cfg.FilterIdempotencyKeys = preset.FilterIdempotencyKeys
+	cfg.FilterIPAddress = preset.FilterIPAddress
+	cfg.FilterLivemode = preset.FilterLivemode
+	cfg.FilterMaxLatency = preset.FilterMaxLatency
+	cfg.FilterMinLatency = preset.FilterMinLatency
+	cfg.FilterRequestIDs = preset.FilterRequestIDs
+	cfg.FilterRequestPaths = preset.FilterRequestPaths
+	cfg.FilterResources = preset.FilterResources
+	cfg.FilterSource = preset.FilterSource
+	cfg.FilterStatusClasses = preset.FilterStatusClasses
+	cfg.FilterStatusCodes = preset.FilterStatusCodes
+	cfg.FilterText = preset.FilterText
+	cfg.FilterURLRegex = preset.FilterURLRegex
+	cfg.FilterUserAgent = preset.FilterUserAgent
+	cfg.OnlyErrors = preset.OnlyErrors
+	cfg.RequireConnectedAccount = preset.RequireConnectedAccount
+}
+
+func (cfg *Config) mergeFilterPreset(preset FilterPreset) {
+	mergeStrings(&cfg.ExcludeRequestPaths, preset.ExcludeRequestPaths)
+	mergeStrings(&cfg.FilterAccount, preset.FilterAccount)
+	mergeStrings(&cfg.FilterAPIVersion, preset.FilterAPIVersion)
+	mergeStrings(&cfg.FilterErrorCode, preset.FilterErrorCode)
+	mergeStrings(&cfg.FilterErrorType, preset.FilterErrorType)
+	mergeStrings(&cfg.FilterHTTPMethods, preset.FilterHTTPMethods)
+	mergeStrings(&cfg.FilterIdempotencyKeys, preset.FilterIdempotencyKeys)
+	mergeStrings(&cfg.FilterIPAddress, preset.FilterIPAddress)
+	mergeStrings(&cfg.FilterRequestIDs, preset.FilterRequestIDs)
+	mergeStrings(&cfg.FilterRequestPaths, preset.FilterRequestPaths)
+	mergeStrings(&cfg.FilterResources, preset.FilterResources)
+	mergeStrings(&cfg.FilterStatusClasses, preset.FilterStatusClasses)
+	mergeStrings(&cfg.FilterText, preset.FilterText)
+	mergeStrings(&cfg.FilterUserAgent, preset.FilterUserAgent)
+
+	if cfg.FilterExpression == "" {
+		cfg.FilterExpression = preset.FilterExpression
+	}
+	if cfg.FilterLivemode == nil {
+		cfg.FilterLivemode = preset.FilterLivemode
+	}
+	if cfg.FilterMaxLatency == 0 {
+		cfg.FilterMaxLatency = preset.FilterMaxLatency
+	}
+	if cfg.FilterMinLatency == 0 {
+		cfg.FilterMinLatency = preset.FilterMinLatency
+	}
+	if cfg.FilterSource == "" {
+		cfg.FilterSource = preset.FilterSource
+	}
+	if len(cfg.FilterStatusCodes) == 0 {
+		cfg.FilterStatusCodes = preset.FilterStatusCodes
+	}
+	if cfg.FilterURLRegex == "" {
+		cfg.FilterURLRegex = preset.FilterURLRegex
+	}
+	if !cfg.OnlyErrors {
+		cfg.OnlyErrors = preset.OnlyErrors
+	}
+	if !cfg.RequireConnectedAccount && !cfg.ExcludeConnectedAccount {
+		cfg.ExcludeConnectedAccount = preset.ExcludeConnectedAccount
+		cfg.RequireConnectedAccount = preset.RequireConnectedAccount
+	}
+}
+
+func mergeStrings(dst *[]string, src []string) {
+	if len(*dst) == 0 {
+		*dst = src
+	}
+}
+
+var presetNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
@@ -0,0 +1,79 @@
+package logtailing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryPresetStore map[string]string
+
+func (store memoryPresetStore) GetFilterPreset(name string) (string, bool) {
+	preset, ok := store[name]
+	return preset, ok
+}
+
+func (store memoryPresetStore) ListFilterPresets() []string {
+	names := make([]string, 0, len(store))
+	for name := range store {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (store memoryPresetStore) SaveFilterPreset(name string, preset string) error {
+	store[name] = preset
+	return nil
+}
+
+func TestSaveAndLoadPreset(t *testing.T) {
+	store := memoryPresetStore{}
+	livemode := false
+
+	err := SavePreset(store, "Daily", &Config{
+		FilterHTTPMethods:  []string{"POST"},
+		FilterLivemode:     &livemode,
+		FilterRequestPaths: []string{"/v1/charges"},
+		FilterStatusCodes:  []int{402},
+	})
+	require.Nil(t, err)
+	require.Equal(t, `{"filter_http_methods":["POST"],"filter_livemode":false,"filter_request_paths":["/v1/charges"],"filter_status_codes":[402]}`, store["daily"])
+
+	cfg := &Config{PresetName: "daily", Presets: store}
+	require.Nil(t, cfg.loadPreset())
+	require.Equal(t, []string{"POST"}, cfg.FilterHTTPMethods)
+	require.Equal(t, []string{"/v1/charges"}, cfg.FilterRequestPaths)
+	require.Equal(t, []int{402}, cfg.FilterStatusCodes)
+	require.False(t, *cfg.FilterLivemode)
+}
+
+func TestLoadPresetExplicitFiltersOverride(t *testing.T) {
+	store := memoryPresetStore{
+		"daily": `{"filter_http_methods":["POST"],"filter_source":"api","filter_status_codes":[402]}`,
+	}
+
+	cfg := &Config{
+		FilterHTTPMethods: []string{"DELETE"},
+		PresetName:        "daily",
+		Presets:           store,
+	}
+	require.Nil(t, cfg.loadPreset())
+	require.Equal(t, []string{"DELETE"}, cfg.FilterHTTPMethods)
+	require.Equal(t, "api", cfg.FilterSource)
+	require.Equal(t, []int{402}, cfg.FilterStatusCodes)
+}
+
+func TestLoadPresetMissing(t *testing.T) {
+	store := memoryPresetStore{"daily": "{}", "errors": "{}"}
+
+	err := (&Config{PresetName: "weekly", Presets: store}).loadPreset()
+	require.EqualError(t, err, "the preset weekly doesn't exist (available presets: daily, errors)")
+
+	err = (&Config{PresetName: "weekly", Presets: memoryPresetStore{}}).loadPreset()
+	require.EqualError(t, err, "the preset weekly doesn't exist, no presets have been saved yet")
+}
+
+func TestSavePresetInvalidName(t *testing.T) {
+	err := SavePreset(memoryPresetStore{}, "my.preset", &Config{})
+	require.EqualError(t, err, "my.preset is not a valid preset name, only letters, digits, dashes and underscores are allowed")
+}
@@ -0,0 +1,152 @@
+package logtailing
+
+import (
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// queuePolicyDropOldest drops the oldest queued request log when the
+	// queue is full
+	queuePolicyDropOldest = "drop-oldest"
+
+	// queuePolicyDropNewest drops the request log received when the queue is
+	// full
+	queuePolicyDropNewest = "drop-newest"
+
+	// queuePolicyBlock blocks the websocket clients while the queue is full.
+	// It's the policy of the worker pool when cfg.QueueSize isn't set.
+	queuePolicyBlock = "block"
+)
+
+// queuePolicies are the acceptable values of cfg.QueuePolicy
+var queuePolicies = []string{queuePolicyDropOldest, queuePolicyDropNewest}
+
+// eventQueue is the bounded queue of the request logs received by the
+// websocket clients, waiting to go through the pipeline. When it's full, a
+// request log is dropped according to the policy, sparing the server errors
+// (5xx status codes) unless all of them are.
+type eventQueue struct {
+	capacity int
+	policy   string
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	jobs   []poolJob
+	closed bool
+}
+
+func newEventQueue(capacity int, policy string) *eventQueue {
+	q := &eventQueue{capacity: capacity, policy: policy}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// isServerError returns true if the request log of the job has a 5xx status
+// code. It's read from the raw payload, as it isn't parsed yet.
+func (job poolJob) isServerError() bool {
+	if job.msg.RequestLogEvent == nil {
+		return false
+	}
+	return gjson.Get(job.msg.RequestLogEvent.EventPayload, "status").Int() >= 500
+}
+
+// push queues a job. It returns the job dropped to make room for it, if any,
+// which may be the job itself. The jobs pushed once the queue is closed are
+// dropped, rather than a queued one.
+func (q *eventQueue) push(job poolJob) (poolJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.policy == queuePolicyBlock && len(q.jobs) >= q.capacity && !q.closed {
+		q.cond.Wait()
+	}
+
+	if q.closed {
+		return job, true
+	}
+
+	if len(q.jobs) >= q.capacity {
+		dropped := q.drop(job)
+		if dropped.seq != job.seq {
+			q.jobs = append(q.jobs, job)
+			q.cond.Broadcast()
+		}
+		return dropped, true
+	}
+
+	q.jobs = append(q.jobs, job)
+	q.cond.Broadcast()
+
+	return poolJob{}, false
+}
+
+// drop removes the queued job to drop to make room for job, according to the
+// policy. It returns job itself if it's the one to drop.
+func (q *eventQueue) drop(job poolJob) poolJob {
+	if q.policy == queuePolicyDropNewest {
+		if !job.isServerError() {
+			return job
+		}
+
+		// Drop the newest queued job that isn't a server error instead
+		for i := len(q.jobs) - 1; i >= 0; i-- {
+			if !q.jobs[i].isServerError() {
+				return q.remove(i)
+			}
+		}
+		return job
+	}
+
+	// Drop the oldest queued job that isn't a server error, or job if they
+	// all are, or the oldest one if job is one too
+	for i := range q.jobs {
+		if !q.jobs[i].isServerError() {
+			return q.remove(i)
+		}
+	}
+	if !job.isServerError() {
+		return job
+	}
+	return q.remove(0)
+}
+
+// remove removes the queued job at the index and returns it
+func (q *eventQueue) remove(i int) poolJob {
+	job := q.jobs[i]
+	q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+	return job
+}
+
+// pop returns the oldest queued job, blocking until there is one. It returns
+// false once the queue is closed and empty.
+func (q *eventQueue) pop() (poolJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.jobs) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+
+	if len(q.jobs) == 0 {
+		return poolJob{}, false
+	}
+
+	job := q.jobs[0]
+	q.jobs = q.jobs[1:]
+
+	// Wake up the blocked pushes
+	q.cond.Broadcast()
+
+	return job, true
+}
+
+// close makes pop return false once the queued jobs are popped
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+}
@@ -0,0 +1,171 @@
+package logtailing
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// queueJob returns a job at the position for a request log with the status
+func queueJob(seq uint64, status int) poolJob {
+	return poolJob{seq: seq, msg: websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+		EventPayload: fmt.Sprintf(`{"status": %d}`, status),
+	}}}
+}
+
+// queuedSeqs pops the queued jobs and returns their positions
+func queuedSeqs(q *eventQueue) []uint64 {
+	q.close()
+
+	var seqs []uint64
+	for {
+		job, ok := q.pop()
+		if !ok {
+			return seqs
+		}
+		seqs = append(seqs, job.seq)
+	}
+}
+
+func TestEventQueueDropPolicies(t *testing.T) {
+	tests := []struct {
+		policy   string
+		statuses []int
+		dropped  []uint64
+		queued   []uint64
+	}{
+		{queuePolicyDropOldest, []int{200, 200, 200, 200, 200}, []uint64{0, 1}, []uint64{2, 3, 4}},
+		{queuePolicyDropNewest, []int{200, 200, 200, 200, 200}, []uint64{3, 4}, []uint64{0, 1, 2}},
+		// The server errors are spared
+		{queuePolicyDropOldest, []int{500, 200, 200, 200, 200}, []uint64{1, 2}, []uint64{0, 3, 4}},
+		{queuePolicyDropNewest, []int{200, 200, 200, 500, 502}, []uint64{2, 1}, []uint64{0, 3, 4}},
+		// Unless they're all server errors
+		{queuePolicyDropOldest, []int{500, 500, 500, 200, 502}, []uint64{3, 0}, []uint64{1, 2, 4}},
+		{queuePolicyDropNewest, []int{500, 500, 500, 502, 200}, []uint64{3, 4}, []uint64{0, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.policy, tt.statuses), func(t *testing.T) {
+			q := newEventQueue(3, tt.policy)
+
+			var dropped []uint64
+			for i, status := range tt.statuses {
+				if job, ok := q.push(queueJob(uint64(i), status)); ok {
+					dropped = append(dropped, job.seq)
+				}
+			}
+
+			require.Equal(t, tt.dropped, dropped)
+			require.Equal(t, tt.queued, queuedSeqs(q))
+		})
+	}
+}
+
+func TestEventQueueBlock(t *testing.T) {
+	q := newEventQueue(1, queuePolicyBlock)
+	q.push(queueJob(0, 200))
+
+	pushed := make(chan struct{})
+	go func() {
+		defer close(pushed)
+		_, ok := q.push(queueJob(1, 200))
+		require.False(t, ok)
+	}()
+
+	select {
+	case <-pushed:
+		require.FailNow(t, "The push didn't block while the queue was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	job, ok := q.pop()
+	require.True(t, ok)
+	require.Equal(t, uint64(0), job.seq)
+
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for the push")
+	}
+
+	require.Equal(t, []uint64{1}, queuedSeqs(q))
+}
+
+func TestEventQueueBlockClosed(t *testing.T) {
+	q := newEventQueue(1, queuePolicyBlock)
+	q.push(queueJob(0, 200))
+
+	pushed := make(chan poolJob)
+	go func() {
+		dropped, ok := q.push(queueJob(1, 200))
+		require.True(t, ok)
+		pushed <- dropped
+	}()
+
+	// Closing the queue unblocks the push, which drops its own job rather
+	// than a queued one
+	time.Sleep(20 * time.Millisecond)
+	q.close()
+
+	select {
+	case dropped := <-pushed:
+		require.Equal(t, uint64(1), dropped.seq)
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for the push")
+	}
+
+	// So does a push once it's closed
+	dropped, ok := q.push(queueJob(2, 500))
+	require.True(t, ok)
+	require.Equal(t, uint64(2), dropped.seq)
+	require.Equal(t, []uint64{0}, queuedSeqs(q))
+}
+
+func TestWorkerPoolDrops(t *testing.T) {
+	var written []string
+	block := make(chan struct{})
+	started := make(chan struct{}, 5)
+
+	pool := newWorkerPool(func(st *stream, msg websocket.IncomingMessage) *RenderedEvent {
+		started <- struct{}{}
+		<-block
+		return &RenderedEvent{RequestLogID: msg.RequestLogEvent.RequestLogID}
+	}, func(event RenderedEvent) {
+		written = append(written, event.RequestLogID)
+	}, func() {})
+
+	stats := newSessionStats(time.Time{})
+	pool.onDrop = stats.recordDropped
+	pool.start(1, newEventQueue(2, queuePolicyDropOldest))
+
+	submit := func(i int) {
+		pool.submit(nil, websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+			EventPayload: `{"status": 200}`,
+			RequestLogID: fmt.Sprintf("resp_%d", i),
+		}})
+	}
+
+	// The worker blocks on the first request log, then the queue fills up
+	// and drops the oldest ones
+	submit(0)
+	<-started
+	for i := 1; i <= 4; i++ {
+		submit(i)
+	}
+
+	close(block)
+	pool.close()
+
+	// The request logs after the dropped ones are still written in order
+	require.Equal(t, []string{"resp_0", "resp_3", "resp_4"}, written)
+	require.Equal(t, uint64(2), pool.dropped)
+
+	var summary bytes.Buffer
+	stats.write(&summary, time.Time{})
+	require.Equal(t, "Session summary (0s)\n  Dropped: 2\n", summary.String())
+}
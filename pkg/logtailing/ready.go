@@ -0,0 +1,66 @@
+package logtailing
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// readyLine is printed to Stdout when the tailer is ready if cfg.PrintReady
+// is set, for scripts waiting for the tail to start
+const readyLine = `{"ready": true}`
+
+// expectConnects sets the number of websocket clients that must connect for
+// the tailer to be ready. It must be called before the clients are started.
+func (tailer *Tailer) expectConnects(count int) {
+	tailer.pendingConnects = int64(count)
+	tailer.connectedCh = make(chan struct{})
+}
+
+// onFirstConnect is called the first time a websocket client connects, and
+// closes connectedCh once they all have
+func (tailer *Tailer) onFirstConnect() {
+	if tailer.connectedCh == nil {
+		return
+	}
+
+	if atomic.AddInt64(&tailer.pendingConnects, -1) == 0 {
+		close(tailer.connectedCh)
+	}
+}
+
+// awaitReady notifies that the tailer is ready once the websocket clients
+// all connected, unless stopCh is closed first
+func (tailer *Tailer) awaitReady(stopCh chan struct{}) {
+	select {
+	case <-tailer.connectedCh:
+		atomic.StoreInt32(&tailer.ready, 1)
+		tailer.notifyReady()
+	case <-stopCh:
+	}
+}
+
+// onReconnect notifies that the tailer is ready again after a websocket
+// client reconnected, if cfg.NotifyReconnects is set
+func (tailer *Tailer) onReconnect() {
+	if tailer.cfg.NotifyReconnects && atomic.LoadInt32(&tailer.ready) == 1 {
+		tailer.notifyReady()
+	}
+}
+
+// notifyReady prints the ready line if cfg.PrintReady is set, flushing it
+// right away for the scripts reading it, then calls cfg.OnReady
+func (tailer *Tailer) notifyReady() {
+	if tailer.cfg.PrintReady {
+		tailer.outputMu.Lock()
+		tailer.lastRequestID = ""
+		fmt.Fprintln(tailer.stdout, readyLine)
+		if tailer.stdoutBuffer != nil {
+			tailer.stdoutBuffer.Flush() // #nosec G104
+		}
+		tailer.outputMu.Unlock()
+	}
+
+	if tailer.cfg.OnReady != nil {
+		tailer.cfg.OnReady()
+	}
+}
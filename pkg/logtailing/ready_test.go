@@ -0,0 +1,88 @@
+package logtailing
+
+import (
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunOnReady(t *testing.T) {
+	tests := []struct {
+		name             string
+		notifyReconnects bool
+		readies          int32
+	}{
+		{"without reconnects", false, 1},
+		{"with reconnects", true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripe := newFakeStripe(t, 0)
+			defer stripe.close()
+
+			var readies int32
+			readyCh := make(chan bool, 2)
+			var stdout lockedBuffer
+			tailer := New(&Config{
+				APIBaseURL:       stripe.api.URL,
+				Keys:             []string{"sk_test_123", "sk_test_456"},
+				NoSummary:        true,
+				NotifyReconnects: tt.notifyReconnects,
+				OnReady: func() {
+					atomic.AddInt32(&readies, 1)
+					readyCh <- true
+				},
+				OutputFormat:     outputFormatNDJSON,
+				PrintReady:       true,
+				Quiet:            true,
+				Stderr:           ioutil.Discard,
+				Stdout:           &stdout,
+				WebSocketFeature: "request_logs",
+			})
+
+			done := make(chan error)
+			go func() { done <- tailer.Run() }()
+
+			select {
+			case <-readyCh:
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "Timed out waiting for the tailer to be ready")
+			}
+
+			// Both websocket clients connected once the tailer is ready
+			for _, st := range tailer.streams {
+				require.Equal(t, uint64(1), atomic.LoadUint64(&st.connects))
+			}
+
+			// Simulates a reconnect of a websocket client
+			tailer.onStreamConnect(tailer.streams[0], 1)
+
+			tailer.Stop()
+			requireReturns(t, done)
+
+			require.Equal(t, tt.readies, atomic.LoadInt32(&readies))
+			require.Equal(t, int(tt.readies), strings.Count(stdout.String(), "{\"ready\": true}\n"))
+		})
+	}
+}
+
+func TestRunOnReadyNotCalledWhenStoppedFirst(t *testing.T) {
+	var readies int32
+	tailer := New(&Config{
+		Key:              "sk_test_123",
+		OnReady:          func() { atomic.AddInt32(&readies, 1) },
+		Quiet:            true,
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	tailer.Stop()
+	require.NoError(t, tailer.Run())
+	require.Equal(t, int32(0), atomic.LoadInt32(&readies))
+}
@@ -0,0 +1,136 @@
+package logtailing
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// recordSyncInterval is how often the recorded request logs are flushed and
+// synced to disk
+const recordSyncInterval = 1 * time.Second
+
+// recordedEvent is a line of a record file: a request log exactly as it was
+// received, and when it was received
+type recordedEvent struct {
+	ReceivedAt time.Time `json:"received_at"`
+
+	websocket.RequestLogEvent
+}
+
+// recorder appends the raw request logs to cfg.RecordFile, one JSON object
+// per line. Writes are buffered, and synced to disk every
+// recordSyncInterval and when closing the recorder.
+type recorder struct {
+	path string
+	log  *log.Logger
+
+	// mu guards the file and its buffer, as request logs are recorded from
+	// the goroutines of the websocket client
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	closed bool
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+
+	warning sync.Once
+}
+
+func newRecorder(path string, logger *log.Logger) (*recorder, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recorder{
+		path:   path,
+		log:    logger,
+		file:   file,
+		writer: bufio.NewWriter(file),
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}, nil
+}
+
+// record appends a request log to the file. Write errors are only reported
+// once, to avoid flooding the terminal if e.g. the disk is full. Request logs
+// received after closing the recorder are ignored.
+func (r *recorder) record(event *websocket.RequestLogEvent, receivedAt time.Time) {
+	line, err := json.Marshal(recordedEvent{ReceivedAt: receivedAt.UTC(), RequestLogEvent: *event})
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+	if _, err := r.writer.Write(append(line, '\n')); err != nil {
+		r.warn(err)
+	}
+}
+
+// start starts syncing the file periodically.
+func (r *recorder) start() {
+	go func() {
+		defer close(r.doneCh)
+
+		ticker := time.NewTicker(recordSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.mu.Lock()
+				r.sync()
+				r.mu.Unlock()
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// sync flushes the buffered request logs and syncs the file to disk. The
+// caller must hold mu.
+func (r *recorder) sync() {
+	if err := r.writer.Flush(); err != nil {
+		r.warn(err)
+		return
+	}
+	if err := r.file.Sync(); err != nil {
+		r.warn(err)
+	}
+}
+
+func (r *recorder) warn(err error) {
+	r.warning.Do(func() {
+		r.log.WithFields(log.Fields{
+			"prefix": "logs.recorder",
+			"path":   r.path,
+		}).Warnf("Could not record request logs: %v", err)
+	})
+}
+
+// close stops syncing the file periodically, then syncs and closes it.
+func (r *recorder) close() error {
+	close(r.stopCh)
+	<-r.doneCh
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sync()
+	r.closed = true
+	return r.file.Close()
+}
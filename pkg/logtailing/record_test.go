@@ -0,0 +1,123 @@
+package logtailing
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func readRecordFile(t *testing.T, path string) []recordedEvent {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var events []recordedEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event recordedEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+
+	return events
+}
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.jsonl")
+
+	logger, _ := test.NewNullLogger()
+	r, err := newRecorder(path, logger)
+	require.NoError(t, err)
+	r.start()
+
+	receivedAt := time.Date(2019, 10, 2, 7, 6, 40, 123000000, time.UTC)
+	r.record(&websocket.RequestLogEvent{EventPayload: `{"status": 200}`, RequestLogID: "resp_123", Type: "request_log_event"}, receivedAt)
+	require.NoError(t, r.close())
+
+	// Request logs received after closing are ignored
+	r.record(&websocket.RequestLogEvent{EventPayload: `{}`}, receivedAt)
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `{"received_at":"2019-10-02T07:06:40.123Z","event_payload":"{\"status\": 200}","request_log_id":"resp_123","type":"request_log_event"}`+"\n", string(content))
+}
+
+func TestRecorderAppends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.jsonl")
+
+	logger, _ := test.NewNullLogger()
+	for i := 0; i < 2; i++ {
+		r, err := newRecorder(path, logger)
+		require.NoError(t, err)
+		r.start()
+		r.record(&websocket.RequestLogEvent{EventPayload: `{}`}, time.Now())
+		require.NoError(t, r.close())
+	}
+
+	require.Len(t, readRecordFile(t, path), 2)
+}
+
+func TestProcessRequestLogEventRecords(t *testing.T) {
+	tests := []struct {
+		name           string
+		recordFiltered bool
+		expected       []string
+	}{
+		{"matching", false, []string{"resp_1"}},
+		{"filtered", true, []string{"resp_1", "resp_2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "record")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "session.jsonl")
+
+			logger, _ := test.NewNullLogger()
+			tailer := New(&Config{
+				FilterText:     []string{"charges"},
+				Log:            logger,
+				OutputFormat:   outputFormatNDJSON,
+				RecordFile:     path,
+				RecordFiltered: tt.recordFiltered,
+				Stdout:         ioutil.Discard,
+			})
+			tailer.recorder, err = newRecorder(path, logger)
+			require.NoError(t, err)
+			tailer.recorder.start()
+
+			tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+				EventPayload: `{"status": 200, "url": "/v1/charges"}`,
+				RequestLogID: "resp_1",
+			}})
+			tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+				EventPayload: `{"status": 200, "url": "/v1/customers"}`,
+				RequestLogID: "resp_2",
+			}})
+			require.NoError(t, tailer.recorder.close())
+
+			var ids []string
+			for _, event := range readRecordFile(t, path) {
+				ids = append(ids, event.RequestLogID)
+			}
+			require.Equal(t, tt.expected, ids)
+		})
+	}
+}
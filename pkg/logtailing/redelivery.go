@@ -0,0 +1,87 @@
+package logtailing
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// redeliveryWindowSize is the number of request log IDs remembered to drop
+// the redelivered request logs when cfg.RedeliveryWindow isn't set
+const redeliveryWindowSize = 1000
+
+// redeliveryID is the ID of a request log received by a stream. The IDs are
+// scoped to the streams, as each websocket connection redelivers its own
+// request logs.
+type redeliveryID struct {
+	st *stream
+	id string
+}
+
+// redeliveryRing remembers the IDs of the most recently received request
+// logs in a ring of fixed size, so that its memory stays bounded however long
+// the session is. Stripe sometimes redelivers the last request logs after a
+// websocket reconnect. The workers of the pool check it concurrently, so it's
+// guarded by a mutex.
+type redeliveryRing struct {
+	mu   sync.Mutex
+	ids  []redeliveryID
+	next int
+	seen map[redeliveryID]struct{}
+}
+
+func newRedeliveryRing(size int) *redeliveryRing {
+	return &redeliveryRing{
+		ids:  make([]redeliveryID, size),
+		seen: make(map[redeliveryID]struct{}, size),
+	}
+}
+
+// observe records the ID of a request log received by the stream. It returns
+// true if it's one of the IDs in the ring, in which case the request log is a
+// redelivery.
+func (r *redeliveryRing) observe(st *stream, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := redeliveryID{st: st, id: id}
+	if _, ok := r.seen[key]; ok {
+		return true
+	}
+
+	// Evict the oldest ID to make room for the new one
+	if evicted := r.ids[r.next]; evicted.id != "" {
+		delete(r.seen, evicted)
+	}
+
+	r.ids[r.next] = key
+	r.seen[key] = struct{}{}
+	r.next = (r.next + 1) % len(r.ids)
+
+	return false
+}
+
+// redelivered returns true if the request log received by the stream is a
+// redelivery, counting it as a duplicate
+func (tailer *Tailer) redelivered(st *stream, msg websocket.IncomingMessage) bool {
+	id := msg.RequestLogEvent.RequestLogID
+	if tailer.redeliveries == nil || id == "" || !tailer.redeliveries.observe(st, id) {
+		return false
+	}
+
+	tailer.cfg.Log.WithFields(log.Fields{
+		"prefix":         "logs.Tailer.processStreamEvent",
+		"request_log_id": id,
+	}).Debug("Dropping redelivered request log")
+
+	if tailer.metrics != nil {
+		tailer.metrics.recordDuplicate()
+	}
+	if tailer.stats != nil {
+		tailer.stats.recordDuplicate()
+	}
+
+	return true
+}
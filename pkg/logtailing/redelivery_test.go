@@ -0,0 +1,133 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestRedeliveryRing(t *testing.T) {
+	ring := newRedeliveryRing(2)
+	test, live := &stream{}, &stream{}
+
+	require.False(t, ring.observe(test, "resp_1"))
+	require.False(t, ring.observe(test, "resp_2"))
+	require.True(t, ring.observe(test, "resp_1"))
+
+	// The IDs are scoped to the streams
+	require.False(t, ring.observe(live, "resp_2"))
+
+	// resp_1 was evicted by the ID received by the other stream, and the
+	// ring stays at its size
+	require.False(t, ring.observe(test, "resp_1"))
+	require.Len(t, ring.seen, 2)
+	require.True(t, ring.observe(live, "resp_2"))
+	require.False(t, ring.observe(test, "resp_2"))
+}
+
+// newFakeStripeRedelivering returns a fake Stripe closing the first websocket
+// connection after sending resp_0 to resp_2, then redelivering resp_1 and
+// resp_2 before sending resp_3 on the next one
+func newFakeStripeRedelivering(t *testing.T) *fakeStripe {
+	var connections int32
+	upgrader := ws.Upgrader{}
+	wsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close()
+
+		ids := []int{0, 1, 2}
+		if atomic.AddInt32(&connections, 1) > 1 {
+			ids = []int{1, 2, 3}
+		}
+
+		for _, i := range ids {
+			msg, err := json.Marshal(websocket.RequestLogEvent{
+				EventPayload: fmt.Sprintf(`{"request_id": "req_%d", "status": 200}`, i),
+				RequestLogID: fmt.Sprintf("resp_%d", i),
+				Type:         "request_log_event",
+			})
+			require.NoError(t, err)
+			require.NoError(t, c.WriteMessage(ws.TextMessage, msg))
+		}
+
+		if ids[0] > 0 {
+			// Keep the connection open until the tailer stops
+			c.ReadMessage() // #nosec G104
+		}
+	}))
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeSession(w, wsServer)
+	}))
+
+	return &fakeStripe{api: apiServer, ws: wsServer}
+}
+
+// receivedIDs returns the IDs of the request logs received by the sink
+func receivedIDs(received channelSink) []string {
+	var ids []string
+	for len(received) > 0 {
+		ids = append(ids, (<-received).RequestLogID)
+	}
+	return ids
+}
+
+func TestRunRedelivered(t *testing.T) {
+	stripe := newFakeStripeRedelivering(t)
+	defer stripe.close()
+
+	received := make(channelSink, 6)
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		EventLimit:       4,
+		Key:              "sk_test_123",
+		NoStdout:         true,
+		Quiet:            true,
+		Sinks:            []Sink{received},
+		Stderr:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+		// The request logs are processed in order by a single worker
+		Workers: 1,
+	})
+
+	require.NoError(t, tailer.Run())
+
+	// The redelivered request logs are dropped, and counted in the summary
+	require.Equal(t, []string{"resp_0", "resp_1", "resp_2", "resp_3"}, receivedIDs(received))
+	require.Equal(t, 2, tailer.stats.duplicates)
+}
+
+func TestRunRedeliveryDisabled(t *testing.T) {
+	stripe := newFakeStripeRedelivering(t)
+	defer stripe.close()
+
+	received := make(channelSink, 6)
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		EventLimit:       6,
+		Key:              "sk_test_123",
+		NoStdout:         true,
+		Quiet:            true,
+		RedeliveryWindow: -1,
+		Sinks:            []Sink{received},
+		Stderr:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+		// The request logs are processed in order by a single worker
+		Workers: 1,
+	})
+
+	require.NoError(t, tailer.Run())
+
+	require.Equal(t, []string{"resp_0", "resp_1", "resp_2", "resp_1", "resp_2", "resp_3"}, receivedIDs(received))
+	require.Equal(t, 0, tailer.stats.duplicates)
+}
@@ -0,0 +1,84 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// readFiltersFile reads the filter set stored in the file at path. The file
+// uses the same JSON format as the filter presets.
+func readFiltersFile(path string) (FilterPreset, error) {
+	var preset FilterPreset
+
+	bytes, err := ioutil.ReadFile(path) // #nosec G304
+	if err != nil {
+		return preset, fmt.Errorf("the filters file %s can't be read: %v", path, err)
+	}
+
+	if err := json.Unmarshal(bytes, &preset); err != nil {
+		return preset, fmt.Errorf("the filters file %s can't be parsed: %v", path, err)
+	}
+
+	return preset, nil
+}
+
+// loadFiltersFile merges the filters from cfg.FiltersFile into the config.
+// The filters set on the config when the tailer started are kept aside so
+// that reloading the file replaces the previous filters from the file instead
+// of piling up on top of them.
+func (tailer *Tailer) loadFiltersFile() error {
+	tailer.baseFilters = tailer.cfg.filterPreset()
+
+	preset, err := readFiltersFile(tailer.cfg.FiltersFile)
+	if err != nil {
+		return err
+	}
+
+	tailer.cfg.mergeFilterPreset(preset)
+
+	return nil
+}
+
+// reloadFilters re-reads cfg.FiltersFile and swaps the active filters for
+// the ones from the file. If the file can't be read or contains invalid
+// filters, the active filters are left untouched.
+func (tailer *Tailer) reloadFilters() error {
+	preset, err := readFiltersFile(tailer.cfg.FiltersFile)
+	if err != nil {
+		return err
+	}
+
+	// Only processRequestLogEvent reads the filters concurrently, and
+	// reloadFilters is the only writer, so reading the config here without
+	// holding the lock is safe.
+	cfg := *tailer.cfg
+	cfg.setFilterPreset(tailer.baseFilters)
+	cfg.mergeFilterPreset(preset)
+
+	if err := cfg.validateOptions(); err != nil {
+		return err
+	}
+
+	candidate := &Tailer{cfg: &cfg}
+	if err := candidate.compileFilters(); err != nil {
+		return err
+	}
+
+	tailer.filtersMu.Lock()
+	defer tailer.filtersMu.Unlock()
+
+	tailer.cfg.setFilterPreset(cfg.filterPreset())
+	tailer.expression = candidate.expression
+	tailer.ipNets = candidate.ipNets
+	tailer.urlRegexp = candidate.urlRegexp
+
+	tailer.cfg.Log.WithFields(log.Fields{
+		"prefix": "logs.Tailer.reloadFilters",
+		"path":   tailer.cfg.FiltersFile,
+	}).Debug("Reloaded filters")
+
+	return nil
+}
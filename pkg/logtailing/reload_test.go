@@ -0,0 +1,82 @@
+package logtailing
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiltersFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "filters-*.json")
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	require.NoError(t, err)
+
+	return file.Name()
+}
+
+func TestLoadFiltersFile(t *testing.T) {
+	path := writeFiltersFile(t, `{"filter_request_paths":["/v1/charges"],"filter_http_methods":["POST"]}`)
+	defer os.Remove(path)
+
+	tailer := New(&Config{
+		FiltersFile:       path,
+		FilterHTTPMethods: []string{"GET"},
+	})
+
+	require.NoError(t, tailer.loadFiltersFile())
+	require.Equal(t, []string{"/v1/charges"}, tailer.cfg.FilterRequestPaths)
+	require.Equal(t, []string{"GET"}, tailer.cfg.FilterHTTPMethods)
+}
+
+func TestLoadFiltersFileMissing(t *testing.T) {
+	tailer := New(&Config{FiltersFile: "/nonexistent/filters.json"})
+
+	err := tailer.loadFiltersFile()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "can't be read")
+}
+
+func TestReloadFilters(t *testing.T) {
+	path := writeFiltersFile(t, `{"filter_request_paths":["/v1/charges"]}`)
+	defer os.Remove(path)
+
+	tailer := New(&Config{
+		FiltersFile:       path,
+		FilterHTTPMethods: []string{"POST"},
+	})
+	require.NoError(t, tailer.loadFiltersFile())
+	require.NoError(t, tailer.compileFilters())
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"filter_request_paths":["/v1/customers"],"filter_url_regex":"cus_"}`), 0600))
+	require.NoError(t, tailer.reloadFilters())
+
+	require.Equal(t, []string{"/v1/customers"}, tailer.cfg.FilterRequestPaths)
+	require.Equal(t, []string{"POST"}, tailer.cfg.FilterHTTPMethods)
+	require.NotNil(t, tailer.urlRegexp)
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{Method: "POST", URL: "/v1/customers/cus_123"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{Method: "POST", URL: "/v1/charges/ch_123"}))
+}
+
+func TestReloadFiltersKeepsFiltersOnError(t *testing.T) {
+	path := writeFiltersFile(t, `{"filter_request_paths":["/v1/charges"]}`)
+	defer os.Remove(path)
+
+	tailer := New(&Config{FiltersFile: path})
+	require.NoError(t, tailer.loadFiltersFile())
+	require.NoError(t, tailer.compileFilters())
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"filter_request_paths":`), 0600))
+	require.Error(t, tailer.reloadFilters())
+	require.Equal(t, []string{"/v1/charges"}, tailer.cfg.FilterRequestPaths)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"filter_url_regex":"("}`), 0600))
+	require.Error(t, tailer.reloadFilters())
+	require.Equal(t, []string{"/v1/charges"}, tailer.cfg.FilterRequestPaths)
+	require.Nil(t, tailer.urlRegexp)
+}
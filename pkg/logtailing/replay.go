@@ -0,0 +1,189 @@
+package logtailing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// replayMaxLineSize is the size of the longest line Replay can read
+const replayMaxLineSize = 10 * 1024 * 1024
+
+// Replay feeds the request logs read from the reader through the filters and
+// the output format, as if they were received from Stripe, without
+// connecting to it. The reader can be a file written with cfg.RecordFile, or
+// any file with a JSON payload per line.
+//
+// The request logs are replayed with the time elapsed between them when they
+// were recorded, unless cfg.ReplayNoDelay is set, until the end of the reader,
+// an interrupt or a call to Stop. Lines that can't be read are skipped, and
+// counted at the end. The filters of cfg.Filters are applied by Stripe rather
+// than by the tailer, so they don't apply to replayed request logs. Like Run,
+// it returns a *FailOnError if request logs matching cfg.FailOn are replayed.
+func (tailer *Tailer) Replay(reader io.Reader) error {
+	if err := tailer.prepare(false); err != nil {
+		return err
+	}
+
+	if err := tailer.openOutputFile(); err != nil {
+		return err
+	}
+	defer tailer.closeOutputFile()
+	if tailer.outputFile != nil {
+		tailer.sinks.addDisplay("file", fileSink{tailer})
+	}
+
+	for i, sink := range tailer.cfg.Sinks {
+		tailer.sinks.add(configSinkName(i), sink)
+	}
+	defer tailer.logSinkErrors()
+
+	// Intercept Ctrl+c so that the output is flushed when interrupted
+	signal.Notify(tailer.interruptCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(tailer.interruptCh)
+
+	stopFlushCh := make(chan struct{})
+	tailer.bufferStdout(stopFlushCh)
+
+	if tailer.cfg.OutputFormat == outputFormatCSV && tailer.cfg.printsOutput() {
+		tailer.printHeader(csvLine(tailer.csvHeader()))
+	}
+
+	if !tailer.cfg.NoSummary && !tailer.cfg.Quiet {
+		tailer.stats = newSessionStats(tailer.now())
+	}
+
+	skipped, err := tailer.replayLines(reader)
+	tailer.sinks.close()
+
+	if tailer.dedupe != nil {
+		// Report the pending repeats without waiting for their window to elapse
+		tailer.flushRepeatedEvents(tailer.now().Add(tailer.cfg.DedupeWindow))
+	}
+
+	if tailer.events != nil {
+		if dropped := tailer.events.close(); dropped > 0 {
+			tailer.cfg.Log.Warnf("%d request logs were dropped because the events channel was full", dropped)
+		}
+	}
+
+	close(stopFlushCh)
+	tailer.flushStdout()
+
+	if tailer.stats != nil {
+		tailer.stats.write(tailer.cfg.Stderr, tailer.now())
+	}
+
+	if skipped > 0 && !tailer.cfg.Quiet {
+		color := tailer.cfg.colorMode().Color(tailer.cfg.Stderr)
+		fmt.Fprintln(tailer.cfg.Stderr, fmt.Sprintf("%s skipped %d lines that aren't request logs", color.Yellow("Warning"), skipped))
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not read the request logs to replay: %v", err)
+	}
+
+	return tailer.failOnError()
+}
+
+// replayLines processes the request logs read from the reader until its end,
+// an interrupt or a call to Stop. It returns the number of lines skipped.
+func (tailer *Tailer) replayLines(reader io.Reader) (int, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), replayMaxLineSize)
+
+	var previous time.Time
+	skipped := 0
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		event, ok := parseReplayLine(line)
+		if !ok {
+			tailer.cfg.Log.WithFields(log.Fields{
+				"prefix": "logs.Tailer.replayLines",
+				"line":   lineNumber,
+			}).Debug("Skipping a line that isn't a request log")
+			skipped++
+			continue
+		}
+
+		var delay time.Duration
+		if !tailer.cfg.ReplayNoDelay && !previous.IsZero() && !event.ReceivedAt.IsZero() {
+			delay = event.ReceivedAt.Sub(previous)
+		}
+		if !event.ReceivedAt.IsZero() {
+			previous = event.ReceivedAt
+		}
+
+		if !tailer.waitReplay(delay) {
+			break
+		}
+
+		tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &event.RequestLogEvent})
+	}
+
+	return skipped, scanner.Err()
+}
+
+// waitReplay waits for the delay before the next request log is replayed. It
+// returns false if the replay was interrupted or stopped.
+func (tailer *Tailer) waitReplay(delay time.Duration) bool {
+	if delay <= 0 {
+		select {
+		case <-tailer.interruptCh:
+			return false
+		case <-tailer.stopCh:
+			return false
+		default:
+			return true
+		}
+	}
+
+	select {
+	case <-tailer.interruptCh:
+		return false
+	case <-tailer.stopCh:
+		return false
+	case <-tailer.after(delay):
+		return true
+	}
+}
+
+// parseReplayLine reads a line written with cfg.RecordFile, or a line made of
+// the JSON payload of a request log. It returns false if the line is neither.
+func parseReplayLine(line []byte) (recordedEvent, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return recordedEvent{}, false
+	}
+
+	if _, recorded := fields["event_payload"]; recorded {
+		var event recordedEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return recordedEvent{}, false
+		}
+		return event, true
+	}
+
+	return recordedEvent{RequestLogEvent: websocket.RequestLogEvent{
+		EventPayload: string(line),
+		Type:         "request_log_event",
+	}}, true
+}
@@ -0,0 +1,117 @@
+package logtailing
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+const replayInput = `{"received_at":"2019-10-02T07:06:40Z","event_payload":"{\"request_id\":\"req_1\",\"status\":200}","request_log_id":"resp_1","type":"request_log_event"}
+not a request log
+
+{"received_at":"2019-10-02T07:06:42.5Z","event_payload":"{\"request_id\":\"req_2\",\"status\":402}","request_log_id":"resp_2","type":"request_log_event"}
+{"request_id":"req_3","status":500}
+`
+
+func newReplayTailer(cfg *Config) (*Tailer, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	logger, _ := test.NewNullLogger()
+
+	cfg.Log = logger
+	cfg.NoSummary = true
+	cfg.OutputFormat = outputFormatNDJSON
+	cfg.Stdout = &stdout
+	cfg.Stderr = &stderr
+
+	return New(cfg), &stdout, &stderr
+}
+
+func TestReplay(t *testing.T) {
+	tailer, stdout, stderr := newReplayTailer(&Config{ReplayNoDelay: true})
+
+	require.NoError(t, tailer.Replay(strings.NewReader(replayInput)))
+
+	require.Equal(t, `{"request_id":"req_1","status":200,"request_log_id":"resp_1"}
+{"request_id":"req_2","status":402,"request_log_id":"resp_2"}
+{"request_id":"req_3","status":500}
+`, stdout.String())
+	require.Equal(t, "Warning skipped 1 lines that aren't request logs\n", stderr.String())
+}
+
+func TestReplayFilters(t *testing.T) {
+	tailer, stdout, stderr := newReplayTailer(&Config{ReplayNoDelay: true, OnlyErrors: true})
+
+	require.NoError(t, tailer.Replay(strings.NewReader(replayInput)))
+
+	require.Equal(t, `{"request_id":"req_2","status":402,"request_log_id":"resp_2"}
+{"request_id":"req_3","status":500}
+`, stdout.String())
+	require.Contains(t, stderr.String(), "skipped 1 lines")
+}
+
+func TestReplayHonorsTiming(t *testing.T) {
+	tailer, _, _ := newReplayTailer(&Config{})
+
+	var delays []time.Duration
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+
+	require.NoError(t, tailer.Replay(strings.NewReader(replayInput)))
+
+	// The payload without a recorded time is replayed right away
+	require.Equal(t, []time.Duration{2500 * time.Millisecond}, delays)
+}
+
+func TestReplayNoDelay(t *testing.T) {
+	tailer, _, _ := newReplayTailer(&Config{ReplayNoDelay: true})
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		require.FailNow(t, "The replay shouldn't wait")
+		return nil
+	}
+
+	require.NoError(t, tailer.Replay(strings.NewReader(replayInput)))
+}
+
+func TestReplayInterrupted(t *testing.T) {
+	tailer, stdout, _ := newReplayTailer(&Config{})
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		// Interrupt the replay while it waits for the second request log
+		tailer.interruptCh <- os.Interrupt
+		return make(chan time.Time)
+	}
+
+	require.NoError(t, tailer.Replay(strings.NewReader(replayInput)))
+	require.Equal(t, `{"request_id":"req_1","status":200,"request_log_id":"resp_1"}`+"\n", stdout.String())
+}
+
+func TestParseReplayLine(t *testing.T) {
+	event, ok := parseReplayLine([]byte(`{"status":200}`))
+	require.True(t, ok)
+	require.Equal(t, `{"status":200}`, event.EventPayload)
+	require.True(t, event.ReceivedAt.IsZero())
+
+	_, ok = parseReplayLine([]byte(`{"received_at":"yesterday","event_payload":"{}"}`))
+	require.False(t, ok)
+
+	_, ok = parseReplayLine([]byte(`[1, 2]`))
+	require.False(t, ok)
+}
+
+func TestReplayEventLimit(t *testing.T) {
+	// The limit only counts the request logs matching the filters
+	tailer, stdout, _ := newReplayTailer(&Config{ReplayNoDelay: true, OnlyErrors: true, EventLimit: 1})
+
+	require.NoError(t, tailer.Replay(strings.NewReader(replayInput)))
+
+	require.Equal(t, `{"request_id":"req_2","status":402,"request_log_id":"resp_2"}
+`, stdout.String())
+}
@@ -0,0 +1,66 @@
+package logtailing
+
+import (
+	"regexp"
+	"strings"
+)
+
+// namespaces are the path segments that group resources rather than being
+// resources themselves, e.g. `/v1/checkout/sessions`.
+var namespaces = map[string]bool{
+	"billing_portal": true,
+	"checkout":       true,
+	"issuing":        true,
+	"radar":          true,
+	"reporting":      true,
+	"sigma":          true,
+	"terminal":       true,
+}
+
+var apiVersionSegmentRegexp = regexp.MustCompile(`^v[0-9]+$`)
+
+// resourcesFromURL extracts the resource types from a request URL, from the
+// outermost to the innermost. For example `/v1/customers/cus_123/sources`
+// returns `customers` and `sources`, and `/v1/checkout/sessions` returns
+// `checkout.sessions`.
+//
+// Paths are expected to alternate between resource types and IDs, so the
+// segment following a resource type is skipped. There's no list of known
+// resources, which means actions like `confirm` in
+// `/v1/payment_intents/pi_123/confirm` are returned as well.
+func resourcesFromURL(requestURL string) []string {
+	var segments []string
+	for _, segment := range strings.Split(requestPath(requestURL), "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	if len(segments) > 0 && apiVersionSegmentRegexp.MatchString(segments[0]) {
+		segments = segments[1:]
+	}
+
+	var resources []string
+	for i := 0; i < len(segments); i += 2 {
+		resource := segments[i]
+		if namespaces[resource] && i+1 < len(segments) {
+			i++
+			resource += "." + segments[i]
+		}
+		resources = append(resources, resource)
+	}
+
+	return resources
+}
+
+// matchResource returns true if the resource is one of the resource types of
+// the request URL, ignoring case.
+func matchResource(resource, requestURL string) bool {
+	for _, urlResource := range resourcesFromURL(requestURL) {
+		if strings.EqualFold(resource, urlResource) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,33 @@
+package logtailing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourcesFromURL(t *testing.T) {
+	require.Equal(t, []string{"payment_intents"}, resourcesFromURL("/v1/payment_intents"))
+	require.Equal(t, []string{"payment_intents"}, resourcesFromURL("/v1/payment_intents/pi_123"))
+	require.Equal(t, []string{"payment_intents", "confirm"}, resourcesFromURL("/v1/payment_intents/pi_123/confirm"))
+	require.Equal(t, []string{"customers", "sources"}, resourcesFromURL("/v1/customers/cus_x/sources"))
+	require.Equal(t, []string{"customers", "sources"}, resourcesFromURL("/v1/customers/cus_x/sources/card_y"))
+	require.Equal(t, []string{"refunds"}, resourcesFromURL("https://api.stripe.com/v1/refunds?limit=3"))
+	require.Equal(t, []string{"checkout.sessions"}, resourcesFromURL("/v1/checkout/sessions/cs_123"))
+	require.Equal(t, []string{"issuing.cards", "details"}, resourcesFromURL("/v1/issuing/cards/ic_123/details"))
+	require.Equal(t, []string{"charges"}, resourcesFromURL("/v1/charges/"))
+	require.Nil(t, resourcesFromURL(""))
+	require.Nil(t, resourcesFromURL("/v1"))
+}
+
+func TestFilterRequestLogEventResources(t *testing.T) {
+	tailer := New(&Config{FilterResources: []string{"payment_intents", "Refunds", "sources", "some_new_resource"}})
+
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/payment_intents/pi_123/confirm"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/refunds"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/charges/ch_123/refunds"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/customers/cus_x/sources"}))
+	require.False(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/some_new_resource/id_123"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/customers/cus_x"}))
+	require.True(t, tailer.filterRequestLogEvent(&EventPayload{URL: "/v1/charges"}))
+}
@@ -0,0 +1,176 @@
+package logtailing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// rotatedFileTimeFormat is the format of the timestamp suffix of rotated
+// output files. It sorts chronologically.
+const rotatedFileTimeFormat = "20060102T150405.000000000"
+
+// rotatingFile is an append-only file that's rotated when it exceeds a
+// maximum size: the file is renamed with a timestamp suffix and a new file is
+// started. It isn't safe for concurrent use, the tailer only writes to it
+// while holding outputMu.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+
+	// header is written at the start of every new file, e.g. the CSV header
+	// row
+	header string
+
+	now func() time.Time
+}
+
+// openRotatingFile opens the file at path for appending, creating it if
+// needed. No rotation is done when maxSize is 0, and all the rotated files
+// are kept when maxBackups is 0.
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+// Write appends to the file, rotating it first if the write would make it
+// exceed the maximum size. A write larger than the maximum size still goes to
+// a single file.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Close closes the file.
+func (f *rotatingFile) Close() error {
+	return f.file.Close()
+}
+
+// reopen closes the file and opens it again at its path, e.g. when it was
+// renamed by an external log rotation. A new file starts with the header.
+func (f *rotatingFile) reopen() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	if f.size == 0 && f.header != "" {
+		n, err := f.file.WriteString(f.header)
+		f.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.%s", f.path, f.now().Format(rotatedFileTimeFormat))
+	if err := os.Rename(f.path, backup); err != nil {
+		return err
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	if f.header != "" {
+		n, err := f.file.WriteString(f.header)
+		f.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	return f.prune()
+}
+
+// prune removes the oldest rotated files beyond maxBackups.
+func (f *rotatingFile) prune() error {
+	if f.maxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > f.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// backups returns the paths of the rotated files, oldest first.
+func (f *rotatingFile) backups() ([]string, error) {
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, match := range matches {
+		suffix := match[len(f.path)+1:]
+		if _, err := time.Parse(rotatedFileTimeFormat, suffix); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+
+	return backups, nil
+}
@@ -0,0 +1,170 @@
+package logtailing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock returns a time that advances by a second on every call, so that
+// rotated files get distinct suffixes.
+func fakeClock() func() time.Time {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func writeEvents(t *testing.T, f *rotatingFile, count int) {
+	for i := 0; i < count; i++ {
+		_, err := fmt.Fprintf(f, "event %02d\n", i)
+		require.NoError(t, err)
+	}
+}
+
+func TestRotatingFileRotates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtailing")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tail.log")
+
+	// Each event is 9 bytes, so 3 of them fit in a file
+	f, err := openRotatingFile(path, 30, 0)
+	require.NoError(t, err)
+	f.now = fakeClock()
+
+	writeEvents(t, f, 10)
+	require.NoError(t, f.Close())
+
+	backups, err := f.backups()
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		path + ".20191001T120001.000000000",
+		path + ".20191001T120002.000000000",
+		path + ".20191001T120003.000000000",
+	}, backups)
+
+	content, err := ioutil.ReadFile(backups[0])
+	require.NoError(t, err)
+	require.Equal(t, "event 00\nevent 01\nevent 02\n", string(content))
+
+	content, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "event 09\n", string(content))
+}
+
+func TestRotatingFilePrunesBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtailing")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tail.log")
+
+	f, err := openRotatingFile(path, 30, 2)
+	require.NoError(t, err)
+	f.now = fakeClock()
+
+	writeEvents(t, f, 20)
+	require.NoError(t, f.Close())
+
+	backups, err := f.backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+
+	// The most recent backups are kept
+	content, err := ioutil.ReadFile(backups[1])
+	require.NoError(t, err)
+	require.Equal(t, "event 15\nevent 16\nevent 17\n", string(content))
+}
+
+func TestRotatingFileNoRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtailing")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tail.log")
+
+	f, err := openRotatingFile(path, 0, 0)
+	require.NoError(t, err)
+
+	writeEvents(t, f, 100)
+	require.NoError(t, f.Close())
+
+	backups, err := f.backups()
+	require.NoError(t, err)
+	require.Empty(t, backups)
+}
+
+func TestRotatingFileHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtailing")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tail.csv")
+
+	tailer := New(&Config{OutputFile: path, OutputFileMaxSize: 14})
+	require.NoError(t, tailer.openOutputFile())
+	tailer.outputFile.(*rotatingFile).now = fakeClock()
+
+	tailer.printHeader("a,b")
+	tailer.outputMu.Lock()
+	for i := 0; i < 4; i++ {
+		tailer.writeOutputFile(fmt.Sprintf("%d,%d", i, i))
+	}
+	tailer.outputMu.Unlock()
+	tailer.closeOutputFile()
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "a,b\n2,2\n3,3\n", string(content))
+
+	content, err = ioutil.ReadFile(path + ".20191001T120001.000000000")
+	require.NoError(t, err)
+	require.Equal(t, "a,b\n0,0\n1,1\n", string(content))
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtailing")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tail.csv")
+
+	f, err := openRotatingFile(path, 0, 0)
+	require.NoError(t, err)
+	f.header = "a,b\n"
+
+	_, err = f.Write([]byte("0,0\n"))
+	require.NoError(t, err)
+
+	// An external rotation renames the file, which is still written to until
+	// it's reopened
+	require.NoError(t, os.Rename(path, path+".1"))
+	_, err = f.Write([]byte("1,1\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, f.reopen())
+	_, err = f.Write([]byte("2,2\n"))
+	require.NoError(t, err)
+
+	// Reopening a file that wasn't renamed keeps appending to it
+	require.NoError(t, f.reopen())
+	_, err = f.Write([]byte("3,3\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	content, err := ioutil.ReadFile(path + ".1")
+	require.NoError(t, err)
+	require.Equal(t, "0,0\n1,1\n", string(content))
+
+	content, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "a,b\n2,2\n3,3\n", string(content))
+}
@@ -0,0 +1,737 @@
+package logtailing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// fakeStripe is an API server initiating CLI sessions, and the websocket
+// server of the sessions, which sends request logs
+type fakeStripe struct {
+	api *httptest.Server
+	ws  *httptest.Server
+}
+
+// newFakeStripe returns a fake Stripe sending count request logs on every
+// websocket connection, with the IDs req_0, req_1, etc.
+func newFakeStripe(t *testing.T, count int) *fakeStripe {
+	statuses := make([]int, count)
+	for i := range statuses {
+		statuses[i] = 200
+	}
+	return newFakeStripeStatuses(t, statuses...)
+}
+
+// newFakeStripeStatuses returns a fake Stripe sending a request log per
+// status on every websocket connection, with the IDs req_0, req_1, etc.
+func newFakeStripeStatuses(t *testing.T, statuses ...int) *fakeStripe {
+	upgrader := ws.Upgrader{}
+	wsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer c.Close()
+
+		for i, status := range statuses {
+			msg, err := json.Marshal(websocket.RequestLogEvent{
+				EventPayload: fmt.Sprintf(`{"request_id": "req_%d", "status": %d}`, i, status),
+				RequestLogID: fmt.Sprintf("resp_%d", i),
+				Type:         "request_log_event",
+			})
+			require.NoError(t, err)
+			require.NoError(t, c.WriteMessage(ws.TextMessage, msg))
+		}
+
+		// Keep the connection open until the tailer stops
+		c.ReadMessage() // #nosec G104
+	}))
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeSession(w, wsServer)
+	}))
+
+	return &fakeStripe{api: apiServer, ws: wsServer}
+}
+
+// writeSession responds with a CLI session connecting to the websocket server
+func writeSession(w http.ResponseWriter, wsServer *httptest.Server) {
+	w.Write([]byte(fmt.Sprintf(`{"websocket_url": "ws%s", "websocket_id": "ws_123", "websocket_authorized_feature": "request_logs", "reconnect_delay": 60, "secret": "secret_123"}`, strings.TrimPrefix(wsServer.URL, "http")))) // #nosec G104
+}
+
+func (f *fakeStripe) close() {
+	f.api.Close()
+	f.ws.Close()
+}
+
+// firstWriteWriter closes written the first time it's written to
+type firstWriteWriter struct {
+	once    sync.Once
+	written chan struct{}
+}
+
+func (w *firstWriteWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.written) })
+	return len(p), nil
+}
+
+func requireReturns(t *testing.T, done <-chan error) {
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the tailer to stop")
+	}
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	stripe := newFakeStripe(t, 3)
+	defer stripe.close()
+
+	received := make(channelSink, 3)
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		Key:              "sk_test_123",
+		OutputFormat:     outputFormatNDJSON,
+		Quiet:            true,
+		Sinks:            []Sink{received},
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- tailer.RunContext(ctx) }()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Timed out waiting for the request logs")
+		}
+	}
+
+	cancel()
+	requireReturns(t, done)
+}
+
+func TestRunContextCancelledWhileAuthorizing(t *testing.T) {
+	// The API never answers, so the spinner keeps spinning
+	unblock := make(chan struct{})
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer apiServer.Close()
+	defer close(unblock)
+
+	stderr := &firstWriteWriter{written: make(chan struct{})}
+	tailer := New(&Config{
+		APIBaseURL:       apiServer.URL,
+		ColorMode:        "always",
+		Key:              "sk_test_123",
+		Stderr:           stderr,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- tailer.RunContext(ctx) }()
+
+	select {
+	case <-stderr.written:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the spinner")
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		require.FailNow(t, "The tailer didn't stop promptly")
+	}
+}
+
+func TestStop(t *testing.T) {
+	stripe := newFakeStripe(t, 3)
+	defer stripe.close()
+
+	received := make(channelSink, 3)
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		Key:              "sk_test_123",
+		OutputFormat:     outputFormatNDJSON,
+		Quiet:            true,
+		Sinks:            []Sink{received},
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	done := make(chan error, 2)
+	go func() { done <- tailer.Run() }()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Timed out waiting for the request logs")
+		}
+	}
+
+	// Stop can be called concurrently, and several times
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tailer.Stop()
+		}()
+	}
+	wg.Wait()
+
+	requireReturns(t, done)
+
+	select {
+	case <-done:
+		require.FailNow(t, "Run returned twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestStopBeforeRun(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		Key:              "sk_test_123",
+		Quiet:            true,
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+	tailer.Stop()
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+	requireReturns(t, done)
+}
+
+func TestStopReplay(t *testing.T) {
+	tailer, stdout, _ := newReplayTailer(&Config{})
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		tailer.Stop()
+		return make(chan time.Time)
+	}
+
+	require.NoError(t, tailer.Replay(strings.NewReader(replayInput)))
+	require.Equal(t, `{"request_id":"req_1","status":200,"request_log_id":"resp_1"}`+"\n", stdout.String())
+}
+
+// lockedBuffer is a buffer that can be written to from several goroutines,
+// e.g. by a spinner
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Len()
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRunAuthorizationError(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": {"message": "Invalid API Key provided"}}`)) // #nosec G104
+	}))
+	defer apiServer.Close()
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"sk_test_123", "error while authenticating with Stripe with a test mode key: Authorization failed, status=401"},
+		{"rk_live_123", "error while authenticating with Stripe with a live mode key: Authorization failed, status=401"},
+		{"123", "error while authenticating with Stripe: Authorization failed, status=401"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			stderr := &lockedBuffer{}
+			tailer := New(&Config{
+				APIBaseURL:       apiServer.URL,
+				ColorMode:        "always",
+				Key:              tt.key,
+				Stderr:           stderr,
+				Stdout:           ioutil.Discard,
+				WebSocketFeature: "request_logs",
+			})
+
+			err := tailer.Run()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expected)
+
+			// The spinner is stopped, so nothing is written past this point
+			written := stderr.Len()
+			time.Sleep(300 * time.Millisecond)
+			require.Equal(t, written, stderr.Len())
+		})
+	}
+}
+
+func TestRunMaxReconnectAttempts(t *testing.T) {
+	defer func(backoff websocket.ReconnectBackoff) { reconnectBackoff = backoff }(reconnectBackoff)
+	reconnectBackoff = websocket.ReconnectBackoff{Initial: time.Millisecond}
+
+	wsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer wsServer.Close()
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeSession(w, wsServer)
+	}))
+	defer apiServer.Close()
+
+	tailer := New(&Config{
+		APIBaseURL:           apiServer.URL,
+		Key:                  "sk_test_123",
+		MaxReconnectAttempts: 2,
+		NoSummary:            true,
+		Stderr:               ioutil.Discard,
+		Stdout:               ioutil.Discard,
+		WebSocketFeature:     "request_logs",
+	})
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case err := <-done:
+		require.Equal(t, &websocket.ReconnectError{Attempts: 2, Err: ws.ErrBadHandshake}, err)
+		require.Equal(t, "could not connect to Stripe after 2 attempts: websocket: bad handshake", err.Error())
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the tailer to give up")
+	}
+}
+
+func TestRunSetupErrorClosesOutputs(t *testing.T) {
+	// The metrics can't be served on an address that's already in use
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	dir, err := ioutil.TempDir("", "stripe-cli-run")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tailer := New(&Config{
+		ForwardURL:       "http://127.0.0.1:1/forward",
+		Key:              "sk_test_123",
+		MetricsAddr:      listener.Addr().String(),
+		OTLPEndpoint:     "http://127.0.0.1:1",
+		RecordFile:       filepath.Join(dir, "record.ndjson"),
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	err = tailer.Run()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "could not listen on")
+
+	// The outputs started before the error were all stopped
+	for name, doneCh := range map[string]chan struct{}{
+		"forwarder": tailer.forwarder.doneCh,
+		"otlp":      tailer.otlp.doneCh,
+		"recorder":  tailer.recorder.doneCh,
+	} {
+		select {
+		case <-doneCh:
+		default:
+			require.FailNow(t, "The output is still running", name)
+		}
+	}
+}
+
+func TestRunEventLimit(t *testing.T) {
+	stripe := newFakeStripe(t, 3)
+	defer stripe.close()
+
+	received := make(channelSink, 3)
+	stderr := &lockedBuffer{}
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		ColorMode:        "never",
+		EventLimit:       2,
+		Key:              "sk_test_123",
+		OutputFormat:     outputFormatNDJSON,
+		Sinks:            []Sink{received},
+		Stderr:           stderr,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- tailer.Run() }()
+
+	// The tailer stops by itself once the limit is reached
+	requireReturns(t, done)
+
+	require.Len(t, received, 2)
+	require.Contains(t, stderr.buf.String(), "Session summary")
+	require.Contains(t, stderr.buf.String(), "Request logs: 2 (2xx: 2)")
+}
+
+func TestRunHighlightModeForwardsMatching(t *testing.T) {
+	stripe := newFakeStripeStatuses(t, 200, 500, 200)
+	defer stripe.close()
+
+	forwarded := make(chan string, 3)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		forwarded <- string(body)
+	}))
+	defer collector.Close()
+
+	stdout := &lockedBuffer{}
+	tailer := New(&Config{
+		APIBaseURL:        stripe.api.URL,
+		ColorMode:         "always",
+		FilterStatusCodes: []int{500},
+		ForwardURL:        collector.URL,
+		HighlightMode:     true,
+		Key:               "sk_test_123",
+		Quiet:             true,
+		Stderr:            ioutil.Discard,
+		Stdout:            stdout,
+		WebSocketFeature:  "request_logs",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- tailer.RunContext(ctx) }()
+
+	// The request logs not matching the filters are still displayed dimmed
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(stdout.String(), "req_2") {
+		if time.Now().After(deadline) {
+			require.FailNow(t, "Timed out waiting for the request logs")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	requireReturns(t, done)
+
+	require.Contains(t, stdout.String(), "req_0")
+
+	// but only the matching one is forwarded
+	require.Len(t, forwarded, 1)
+	require.Contains(t, <-forwarded, `"request_id": "req_1"`)
+}
+
+func TestRunDuration(t *testing.T) {
+	stripe := newFakeStripe(t, 1)
+	defer stripe.close()
+
+	received := make(channelSink, 1)
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		Key:              "sk_test_123",
+		OutputFormat:     outputFormatNDJSON,
+		Quiet:            true,
+		RunDuration:      2 * time.Minute,
+		Sinks:            []Sink{received},
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	armed := make(chan time.Duration, 1)
+	deadline := make(chan time.Time)
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		armed <- d
+		return deadline
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case d := <-armed:
+		require.Equal(t, 2*time.Minute, d)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the run duration to be armed")
+	}
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the request logs")
+	}
+
+	select {
+	case <-done:
+		require.FailNow(t, "Run returned before the run duration")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(deadline)
+	requireReturns(t, done)
+}
+
+func TestRunDurationInterrupted(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		Key:              "sk_test_123",
+		Quiet:            true,
+		RunDuration:      time.Hour,
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	armed := make(chan struct{})
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		close(armed)
+		return make(chan time.Time)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case <-armed:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the run duration to be armed")
+	}
+
+	// An interrupt still stops the tailer before the run duration
+	tailer.interruptCh <- os.Interrupt
+	requireReturns(t, done)
+}
+
+func TestRunStatsSignal(t *testing.T) {
+	if statsSignal == nil {
+		t.Skip("the stats signal isn't supported on this platform")
+	}
+
+	stripe := newFakeStripe(t, 2)
+	defer stripe.close()
+
+	var stderr lockedBuffer
+	received := make(channelSink, 2)
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		Key:              "sk_test_123",
+		NoSummary:        true,
+		Quiet:            true,
+		Sinks:            []Sink{received},
+		Stderr:           &stderr,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Timed out waiting for the request logs")
+		}
+	}
+
+	// The stats are printed without stopping the tailer, even when the
+	// summary is disabled
+	tailer.interruptCh <- statsSignal
+	require.Eventually(t, func() bool {
+		return strings.Contains(stderr.String(), "  Request logs: 2 (2xx: 2)\n  Error rate: 0.0%\n  Reconnects: 0\n  Dropped: 0\n")
+	}, 5*time.Second, time.Millisecond)
+
+	select {
+	case <-done:
+		require.FailNow(t, "The tailer stopped on the stats signal")
+	default:
+	}
+
+	tailer.Stop()
+	requireReturns(t, done)
+
+	require.NotContains(t, stderr.String(), "Session summary")
+}
+
+func TestRunStatsSignalWhileAuthorizing(t *testing.T) {
+	if statsSignal == nil {
+		t.Skip("the stats signal isn't supported on this platform")
+	}
+
+	stripe := newFakeStripe(t, 1)
+	defer stripe.close()
+
+	// The session is initiated once the stats signal was received
+	authorizing := make(chan struct{})
+	release := make(chan struct{})
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(authorizing)
+		<-release
+		writeSession(w, stripe.ws)
+	}))
+	defer apiServer.Close()
+
+	received := make(channelSink, 1)
+	tailer := New(&Config{
+		APIBaseURL:       apiServer.URL,
+		Key:              "sk_test_123",
+		NoSummary:        true,
+		Quiet:            true,
+		Sinks:            []Sink{received},
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case <-authorizing:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the authorization")
+	}
+
+	tailer.interruptCh <- statsSignal
+	close(release)
+
+	// The tailer kept authorizing, then tailed the request logs
+	select {
+	case <-received:
+	case err := <-done:
+		require.FailNow(t, "The tailer stopped on the stats signal", "%v", err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the request logs")
+	}
+
+	tailer.Stop()
+	requireReturns(t, done)
+}
+
+func TestRunReopensOutputFileOnSIGHUP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtailing")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tail.log")
+
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		Fields:           []string{"request_id"},
+		Key:              "sk_test_123",
+		NoSummary:        true,
+		OutputFile:       path,
+		Quiet:            true,
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	ready := make(chan bool)
+	tailer.after = func(d time.Duration) <-chan time.Time {
+		close(ready)
+		return nil
+	}
+	tailer.cfg.RunDuration = time.Hour
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the tailer to be ready")
+	}
+
+	requireFileContent := func(path string, content string) {
+		require.Eventually(t, func() bool {
+			got, err := ioutil.ReadFile(path)
+			return err == nil && string(got) == content
+		}, 5*time.Second, time.Millisecond)
+	}
+
+	sendRequestLog := func(requestID string) {
+		tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+			EventPayload: fmt.Sprintf(`{"request_id": "%s", "status": 200}`, requestID),
+			Type:         "request_log_event",
+		}})
+	}
+
+	sendRequestLog("req_0")
+	requireFileContent(path, "req_0\n")
+
+	// logrotate renames the file, then sends SIGHUP
+	require.NoError(t, os.Rename(path, path+".1"))
+	sendRequestLog("req_1")
+	requireFileContent(path+".1", "req_0\nreq_1\n")
+	tailer.interruptCh <- syscall.SIGHUP
+
+	// The file is created again once it's reopened
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, 5*time.Second, time.Millisecond)
+
+	sendRequestLog("req_2")
+
+	tailer.Stop()
+	requireReturns(t, done)
+
+	requireFileContent(path+".1", "req_0\nreq_1\n")
+	requireFileContent(path, "req_2\n")
+}
@@ -0,0 +1,66 @@
+package logtailing
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sampleReportInterval is the interval at which the number of request logs
+// suppressed by sampling is printed.
+const sampleReportInterval = 10 * time.Second
+
+// sampleRequestLogEvent returns true if the event should be suppressed by
+// sampling. Only one in cfg.SampleRate events is kept, except for server
+// errors which are always kept.
+func (tailer *Tailer) sampleRequestLogEvent(payload *EventPayload) bool {
+	if tailer.cfg.SampleRate <= 1 || payload.Status >= 500 {
+		return false
+	}
+
+	if atomic.AddUint64(&tailer.sampledCount, 1)%uint64(tailer.cfg.SampleRate) == 0 {
+		return false
+	}
+
+	atomic.AddUint64(&tailer.suppressedCount, 1)
+
+	tailer.cfg.Log.WithFields(log.Fields{
+		"prefix":     "logs.Tailer.sampleRequestLogEvent",
+		"request_id": payload.RequestID,
+	}).Debug("Suppressed event by sampling")
+
+	return true
+}
+
+// suppressedEventsMessage returns the message reporting the number of events
+// suppressed by sampling since the last call, and false if none were.
+func (tailer *Tailer) suppressedEventsMessage(interval time.Duration) (string, bool) {
+	count := atomic.SwapUint64(&tailer.suppressedCount, 0)
+	if count == 0 {
+		return "", false
+	}
+
+	return fmt.Sprintf("Suppressed %d events in the last %s (showing 1 in %d)", count, interval, tailer.cfg.SampleRate), true
+}
+
+func (tailer *Tailer) reportSuppressedEvents(stopCh chan struct{}) {
+	if tailer.cfg.SampleRate <= 1 {
+		return
+	}
+
+	ticker := time.NewTicker(sampleReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if message, ok := tailer.suppressedEventsMessage(sampleReportInterval); ok {
+				tailer.printNotice(message)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package logtailing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleRequestLogEventDisabled(t *testing.T) {
+	for _, rate := range []uint{0, 1} {
+		tailer := New(&Config{SampleRate: rate})
+
+		for i := 0; i < 10; i++ {
+			require.False(t, tailer.sampleRequestLogEvent(&EventPayload{Status: 200}))
+		}
+
+		_, ok := tailer.suppressedEventsMessage(sampleReportInterval)
+		require.False(t, ok)
+	}
+}
+
+func TestSampleRequestLogEvent(t *testing.T) {
+	tailer := New(&Config{SampleRate: 10})
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		if !tailer.sampleRequestLogEvent(&EventPayload{Status: 200}) {
+			kept++
+		}
+	}
+	require.Equal(t, 100, kept)
+
+	message, ok := tailer.suppressedEventsMessage(sampleReportInterval)
+	require.True(t, ok)
+	require.Equal(t, "Suppressed 900 events in the last 10s (showing 1 in 10)", message)
+
+	// The counter is reset after each report
+	_, ok = tailer.suppressedEventsMessage(sampleReportInterval)
+	require.False(t, ok)
+}
+
+func TestSampleRequestLogEventKeepsServerErrors(t *testing.T) {
+	tailer := New(&Config{SampleRate: 1000})
+
+	for i := 0; i < 100; i++ {
+		require.False(t, tailer.sampleRequestLogEvent(&EventPayload{Status: 500}))
+		require.False(t, tailer.sampleRequestLogEvent(&EventPayload{Status: 503}))
+	}
+
+	// Server errors don't count towards the sample either
+	_, ok := tailer.suppressedEventsMessage(sampleReportInterval)
+	require.False(t, ok)
+	require.True(t, tailer.sampleRequestLogEvent(&EventPayload{Status: 402}))
+}
@@ -0,0 +1,97 @@
+package logtailing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/stripeauth"
+)
+
+// Session is a read-only copy of the CLI session Stripe authorized for
+// tailing the request logs, without its secret
+type Session struct {
+	// Label is the label of the key the session was authorized for, when
+	// several keys are tailed or the key has a label
+	Label string
+
+	// ReconnectDelay is how long the websocket client waits before
+	// reconnecting
+	ReconnectDelay time.Duration
+
+	// WebSocketAuthorizedFeature is the feature the websocket is authorized
+	// for, e.g. request_logs
+	WebSocketAuthorizedFeature string
+
+	// WebSocketID is the ID of the websocket, which Stripe support may ask
+	// for
+	WebSocketID string
+
+	// WebSocketURL is the endpoint the websocket client connects to
+	WebSocketURL string
+}
+
+func newSession(label string, session *stripeauth.StripeCLISession) Session {
+	return Session{
+		Label:                      label,
+		ReconnectDelay:             time.Duration(session.ReconnectDelay) * time.Second,
+		WebSocketAuthorizedFeature: session.WebSocketAuthorizedFeature,
+		WebSocketID:                session.WebSocketID,
+		WebSocketURL:               session.WebSocketURL,
+	}
+}
+
+// String describes the session for the Ready line of cfg.ShowSession, e.g.
+// `Session wss_123 on wss://stripe-cli.stripe.com/subscribe/acct_123`
+func (s Session) String() string {
+	line := fmt.Sprintf("Session %s on %s", s.WebSocketID, s.WebSocketURL)
+	if s.Label != "" {
+		line = fmt.Sprintf("[%s] %s", s.Label, line)
+	}
+	return line
+}
+
+// setSessions records the sessions authorized for the streams, nil for the
+// ones that couldn't be authorized
+func (tailer *Tailer) setSessions(sessions []*stripeauth.StripeCLISession) {
+	authorized := make([]Session, 0, len(sessions))
+	for i, session := range sessions {
+		if session != nil {
+			authorized = append(authorized, newSession(tailer.streams[i].label, session))
+		}
+	}
+
+	tailer.sessionsMu.Lock()
+	tailer.sessions = authorized
+	tailer.sessionsMu.Unlock()
+}
+
+// Session returns a copy of the session authorized by Stripe, or nil until
+// Run authorized it. When several keys are tailed, it's the session of the
+// first key that could be authorized. It's safe to call from any goroutine.
+func (tailer *Tailer) Session() *Session {
+	tailer.sessionsMu.RLock()
+	defer tailer.sessionsMu.RUnlock()
+
+	if len(tailer.sessions) == 0 {
+		return nil
+	}
+
+	session := tailer.sessions[0]
+	return &session
+}
+
+// printSessions prints the sessions authorized by Stripe when
+// cfg.ShowSession is set, once the tailer is ready
+func (tailer *Tailer) printSessions() {
+	if !tailer.cfg.ShowSession {
+		return
+	}
+
+	tailer.sessionsMu.RLock()
+	sessions := tailer.sessions
+	tailer.sessionsMu.RUnlock()
+
+	for _, session := range sessions {
+		tailer.printNotice(session.String())
+	}
+}
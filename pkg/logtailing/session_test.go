@@ -0,0 +1,66 @@
+package logtailing
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/stripeauth"
+)
+
+func TestSessionString(t *testing.T) {
+	session := newSession("", &stripeauth.StripeCLISession{
+		ReconnectDelay: 5,
+		Secret:         "secret_123",
+		WebSocketID:    "ws_123",
+		WebSocketURL:   "wss://stripe-cli.stripe.com/subscribe/acct_123",
+	})
+	require.Equal(t, 5*time.Second, session.ReconnectDelay)
+	require.Equal(t, "Session ws_123 on wss://stripe-cli.stripe.com/subscribe/acct_123", session.String())
+
+	session.Label = "acme"
+	require.Equal(t, "[acme] Session ws_123 on wss://stripe-cli.stripe.com/subscribe/acct_123", session.String())
+}
+
+func TestRunSession(t *testing.T) {
+	stripe := newFakeStripe(t, 0)
+	defer stripe.close()
+
+	ready := make(chan struct{})
+	var stderr lockedBuffer
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		Key:              "sk_test_123",
+		NoSummary:        true,
+		OnReady:          func() { close(ready) },
+		ShowSession:      true,
+		Stderr:           &stderr,
+		Stdout:           &lockedBuffer{},
+		WebSocketFeature: "request_logs",
+	})
+	require.Nil(t, tailer.Session())
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the tailer to be ready")
+	}
+
+	session := tailer.Session()
+	require.NotNil(t, session)
+	require.Equal(t, "ws_123", session.WebSocketID)
+	require.Equal(t, "request_logs", session.WebSocketAuthorizedFeature)
+	require.Equal(t, time.Minute, session.ReconnectDelay)
+	require.True(t, strings.HasPrefix(session.WebSocketURL, "ws://127.0.0.1:"))
+
+	tailer.Stop()
+	require.NoError(t, <-done)
+
+	require.Contains(t, stderr.String(), "Session ws_123 on "+session.WebSocketURL+"\n")
+	require.NotContains(t, stderr.String(), "secret_123")
+}
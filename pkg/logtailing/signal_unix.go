@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package logtailing
+
+import (
+	"os"
+	"syscall"
+)
+
+// statsSignal prints the stats of the session while Run is running
+var statsSignal os.Signal = syscall.SIGUSR1
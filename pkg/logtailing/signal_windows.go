@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package logtailing
+
+import "os"
+
+// statsSignal is nil as Windows doesn't have SIGUSR1, so the stats of the
+// session are only printed in the summary
+var statsSignal os.Signal
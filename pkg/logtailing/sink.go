@@ -0,0 +1,187 @@
+package logtailing
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// RenderedEvent is a request log as it's handed to the sinks
+type RenderedEvent struct {
+	// Line is the request log rendered in the output format. It can contain
+	// ANSI sequences, and spans multiple lines in the JSON output format.
+	Line string
+
+	// Payload is the raw JSON payload of the request log
+	Payload string
+
+	// Label is the label of the key the request log was received with, when
+	// several keys are tailed or the key has a label
+	Label string
+
+	// Hidden is true if the request log doesn't match the filters, and is
+	// only shown dimmed in highlight mode. Only the terminal and the output
+	// file receive the hidden request logs.
+	Hidden bool
+
+	Method       string
+	RequestID    string
+	RequestLogID string
+	Status       int
+	URL          string
+}
+
+// Sink is a destination of the request logs, such as the terminal or a file.
+// Write is called for every request log that isn't filtered out, except the
+// ones only shown dimmed in highlight mode, one at a time from a single
+// goroutine, so slow sinks must queue the request logs rather than block the
+// other sinks. Sinks implementing io.Closer are closed
+// on shutdown, once the last request log is written.
+type Sink interface {
+	Write(event RenderedEvent) error
+}
+
+// multiSinkEntry is a sink of a multiSink and the number of request logs it
+// failed to write, which must be accessed atomically. showsHidden is true if
+// the sink displays the request logs hidden by the filters in highlight mode.
+type multiSinkEntry struct {
+	name        string
+	sink        Sink
+	showsHidden bool
+	errors      uint64
+}
+
+// multiSink fans the request logs out to several sinks. A sink failing to
+// write a request log doesn't prevent the other sinks from writing it.
+type multiSink struct {
+	entries []*multiSinkEntry
+	log     *log.Logger
+}
+
+func newMultiSink(logger *log.Logger) *multiSink {
+	return &multiSink{log: logger}
+}
+
+// add registers a sink. It must not be called while request logs are being
+// written.
+func (m *multiSink) add(name string, sink Sink) {
+	m.entries = append(m.entries, &multiSinkEntry{name: name, sink: sink})
+}
+
+// addDisplay registers a sink that also receives the request logs hidden by
+// the filters, to show them dimmed in highlight mode, like the terminal
+func (m *multiSink) addDisplay(name string, sink Sink) {
+	m.entries = append(m.entries, &multiSinkEntry{name: name, sink: sink, showsHidden: true})
+}
+
+// Write writes the request log to all the sinks, or only to the ones
+// displaying it if it's hidden by the filters. Write errors are counted per
+// sink rather than returned.
+func (m *multiSink) Write(event RenderedEvent) error {
+	for _, entry := range m.entries {
+		if event.Hidden && !entry.showsHidden {
+			continue
+		}
+
+		if err := entry.sink.Write(event); err != nil {
+			atomic.AddUint64(&entry.errors, 1)
+			m.log.WithFields(log.Fields{
+				"prefix": "logs.multiSink.Write",
+				"sink":   entry.name,
+			}).Debugf("Could not write request log: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// close closes the sinks implementing io.Closer, once the last request log is
+// written
+func (m *multiSink) close() {
+	for _, entry := range m.entries {
+		closer, ok := entry.sink.(io.Closer)
+		if !ok {
+			continue
+		}
+
+		if err := closer.Close(); err != nil {
+			m.log.WithFields(log.Fields{
+				"prefix": "logs.multiSink.close",
+				"sink":   entry.name,
+			}).Warnf("Could not close the sink: %v", err)
+		}
+	}
+}
+
+// errors returns the number of request logs each sink failed to write, by
+// sink name.
+func (m *multiSink) errors() map[string]uint64 {
+	errors := make(map[string]uint64, len(m.entries))
+	for _, entry := range m.entries {
+		errors[entry.name] = atomic.LoadUint64(&entry.errors)
+	}
+
+	return errors
+}
+
+// stdoutSink prints the request logs to cfg.Stdout
+type stdoutSink struct {
+	tailer *Tailer
+}
+
+func (s stdoutSink) Write(event RenderedEvent) error {
+	if s.tailer.live != nil {
+		s.tailer.live.record(event)
+	}
+
+	return s.tailer.printRequestLogEvent(event.RequestID, event.Line, event.Status)
+}
+
+// fileSink appends the request logs to cfg.OutputFile
+type fileSink struct {
+	tailer *Tailer
+}
+
+func (s fileSink) Write(event RenderedEvent) error {
+	s.tailer.outputMu.Lock()
+	defer s.tailer.outputMu.Unlock()
+
+	return s.tailer.writeOutputFile(event.Line)
+}
+
+// forwardSink queues the payloads of the request logs to be POSTed to
+// cfg.ForwardURL
+type forwardSink struct {
+	forwarder *forwarder
+}
+
+func (s forwardSink) Write(event RenderedEvent) error {
+	return s.forwarder.send(event.Payload)
+}
+
+// syslogSink queues the request logs to be sent to cfg.SyslogAddress
+type syslogSink struct {
+	writer *syslogWriter
+
+	// ndjson is true if the lines have to be compacted, as syslog messages
+	// are a single line each
+	ndjson bool
+}
+
+func (s syslogSink) Write(event RenderedEvent) error {
+	line := event.Line
+	if s.ndjson {
+		line = ndjsonLine(&websocket.RequestLogEvent{EventPayload: event.Payload, RequestLogID: event.RequestLogID})
+	}
+
+	return s.writer.send(event.Status, line)
+}
+
+// configSinkName returns the name of the i-th sink of cfg.Sinks
+func configSinkName(i int) string {
+	return fmt.Sprintf("sink #%d", i+1)
+}
@@ -0,0 +1,88 @@
+package logtailing
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// recordingSink records the request logs written to it
+type recordingSink struct {
+	events []RenderedEvent
+}
+
+func (s *recordingSink) Write(event RenderedEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+// failingSink fails to write every request log
+type failingSink struct {
+	writes int
+}
+
+func (s *failingSink) Write(event RenderedEvent) error {
+	s.writes++
+	return errors.New("unreachable")
+}
+
+func TestMultiSink(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	first, failing, last := &recordingSink{}, &failingSink{}, &recordingSink{}
+
+	sinks := newMultiSink(logger)
+	sinks.add("first", first)
+	sinks.add("failing", failing)
+	sinks.add("last", last)
+
+	event := RenderedEvent{Line: "200 POST /v1/charges", RequestID: "req_123", Status: 200}
+	require.NoError(t, sinks.Write(event))
+	require.NoError(t, sinks.Write(event))
+
+	// The sinks after the failing one still receive the request logs
+	require.Equal(t, []RenderedEvent{event, event}, first.events)
+	require.Equal(t, []RenderedEvent{event, event}, last.events)
+	require.Equal(t, 2, failing.writes)
+
+	require.Equal(t, map[string]uint64{"first": 0, "failing": 2, "last": 0}, sinks.errors())
+}
+
+func TestProcessRequestLogEventSinks(t *testing.T) {
+	var stdout bytes.Buffer
+	recording := &recordingSink{}
+
+	tailer := New(&Config{OutputFormat: outputFormatNDJSON, Stdout: &stdout})
+	tailer.sinks.add(configSinkName(0), &failingSink{})
+	tailer.sinks.add(configSinkName(1), recording)
+
+	tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+		EventPayload: `{"request_id": "req_123", "status": 402}`,
+		RequestLogID: "resp_123",
+	}})
+
+	require.Equal(t, `{"request_id":"req_123","status":402,"request_log_id":"resp_123"}`+"\n", stdout.String())
+	require.Equal(t, []RenderedEvent{{
+		Line:         `{"request_id":"req_123","status":402,"request_log_id":"resp_123"}`,
+		Payload:      `{"request_id": "req_123", "status": 402}`,
+		RequestID:    "req_123",
+		RequestLogID: "resp_123",
+		Status:       402,
+	}}, recording.events)
+	require.Equal(t, uint64(1), tailer.sinks.errors()["sink #1"])
+}
+
+func TestSyslogSinkCompactsJSON(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	w := newSyslogWriter("", "localhost:514", logger)
+
+	sink := syslogSink{writer: w, ndjson: true}
+	require.NoError(t, sink.Write(RenderedEvent{Line: "{\n  \"status\": 200\n}", Payload: "{\n  \"status\": 200\n}", Status: 200}))
+
+	message := <-w.messages
+	require.Equal(t, `{"status":200}`, message.text)
+}
@@ -0,0 +1,155 @@
+package logtailing
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+const (
+	// statsdTagStyleDatadog appends the tags to the metrics in the DogStatsD
+	// format, e.g. `stripe.requestlogs.count:1|c|#status_class:2xx`
+	statsdTagStyleDatadog = "datadog"
+
+	// statsdTagStylePlain appends the values of the tags to the metric names,
+	// for statsd servers that don't support tags, e.g.
+	// `stripe.requestlogs.count.2xx:1|c`
+	statsdTagStylePlain = "plain"
+)
+
+const (
+	statsdRequestLogsCount = "stripe.requestlogs.count"
+	statsdReconnects       = "stripe.requestlogs.reconnects"
+
+	// statsdBufferSize is the size of the buffer the packets are formatted
+	// in, and the maximum size of the packets. Longer packets are truncated.
+	statsdBufferSize = 512
+)
+
+// statsdTag is a tag of a statsd metric
+type statsdTag struct {
+	key   string
+	value string
+}
+
+// statsdClient sends metrics to a statsd server over UDP. Sends are fire and
+// forget: errors are ignored, so that an unreachable server never slows down
+// the tail.
+type statsdClient struct {
+	conn     net.Conn
+	tagStyle string
+
+	// mu guards buf, which is reused for every packet
+	mu  sync.Mutex
+	buf []byte
+
+	reconnects int64
+}
+
+// newStatsdClient returns a client sending the metrics to the address, with
+// the tag style. Defaults to the Datadog style.
+func newStatsdClient(addr string, tagStyle string) (*statsdClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the statsd server at %s: %v", addr, err)
+	}
+
+	if tagStyle == "" {
+		tagStyle = statsdTagStyleDatadog
+	}
+
+	return &statsdClient{
+		conn:     conn,
+		tagStyle: tagStyle,
+		buf:      make([]byte, 0, statsdBufferSize),
+	}, nil
+}
+
+// count increments a counter
+func (c *statsdClient) count(name string, value int64, tags []statsdTag) {
+	c.send(name, value, "c", tags)
+}
+
+// gauge sets a gauge
+func (c *statsdClient) gauge(name string, value int64, tags []statsdTag) {
+	c.send(name, value, "g", tags)
+}
+
+// recordConnect counts a connection of a websocket client, and reports the
+// number of reconnects as a gauge.
+func (c *statsdClient) recordConnect(reconnect bool) {
+	c.mu.Lock()
+	if reconnect {
+		c.reconnects++
+	}
+	reconnects := c.reconnects
+	c.mu.Unlock()
+
+	c.gauge(statsdReconnects, reconnects, nil)
+}
+
+func (c *statsdClient) send(name string, value int64, kind string, tags []statsdTag) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buf := append(c.buf[:0], name...)
+	if c.tagStyle == statsdTagStylePlain {
+		for _, tag := range tags {
+			buf = append(buf, '.')
+			buf = append(buf, tag.value...)
+		}
+	}
+
+	buf = append(buf, ':')
+	buf = strconv.AppendInt(buf, value, 10)
+	buf = append(buf, '|')
+	buf = append(buf, kind...)
+
+	if c.tagStyle == statsdTagStyleDatadog && len(tags) > 0 {
+		buf = append(buf, "|#"...)
+		for i, tag := range tags {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, tag.key...)
+			buf = append(buf, ':')
+			buf = append(buf, tag.value...)
+		}
+	}
+
+	if len(buf) > statsdBufferSize {
+		buf = buf[:statsdBufferSize]
+	}
+	c.conn.Write(buf) // #nosec G104
+
+	// Keep the buffer if it had to grow, so that it's only reallocated once
+	c.buf = buf[:0]
+}
+
+func (c *statsdClient) close() error {
+	return c.conn.Close()
+}
+
+// statsdStatusClass returns the status class of the status code, e.g. 2xx
+func statsdStatusClass(status int) string {
+	if class := status/100 - 1; class >= 0 && class < len(metricsStatusClasses) {
+		return metricsStatusClasses[class]
+	}
+	return "other"
+}
+
+// statsdSink counts the request logs passing the filters on a statsd server
+type statsdSink struct {
+	client *statsdClient
+}
+
+func (s statsdSink) Write(event RenderedEvent) error {
+	tags := [2]statsdTag{
+		{key: "status_class", value: statsdStatusClass(event.Status)},
+		{key: "method", value: event.Method},
+	}
+	s.client.count(statsdRequestLogsCount, 1, tags[:])
+
+	return nil
+}
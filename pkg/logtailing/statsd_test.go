@@ -0,0 +1,104 @@
+package logtailing
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// listenStatsd listens for statsd packets on a random local UDP port
+func listenStatsd(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return conn
+}
+
+func readStatsdPacket(t *testing.T, conn net.PacketConn) string {
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestStatsdSink(t *testing.T) {
+	tests := []struct {
+		tagStyle string
+		expected string
+	}{
+		{"", "stripe.requestlogs.count:1|c|#status_class:4xx,method:POST"},
+		{statsdTagStyleDatadog, "stripe.requestlogs.count:1|c|#status_class:4xx,method:POST"},
+		{statsdTagStylePlain, "stripe.requestlogs.count.4xx.POST:1|c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tagStyle, func(t *testing.T) {
+			server := listenStatsd(t)
+			defer server.Close()
+
+			client, err := newStatsdClient(server.LocalAddr().String(), tt.tagStyle)
+			require.NoError(t, err)
+			defer client.close()
+
+			require.NoError(t, statsdSink{client}.Write(RenderedEvent{Method: "POST", Status: 402}))
+			require.Equal(t, tt.expected, readStatsdPacket(t, server))
+		})
+	}
+}
+
+func TestStatsdReconnectsGauge(t *testing.T) {
+	server := listenStatsd(t)
+	defer server.Close()
+
+	client, err := newStatsdClient(server.LocalAddr().String(), statsdTagStyleDatadog)
+	require.NoError(t, err)
+	defer client.close()
+
+	client.recordConnect(false)
+	require.Equal(t, "stripe.requestlogs.reconnects:0|g", readStatsdPacket(t, server))
+
+	client.recordConnect(true)
+	require.Equal(t, "stripe.requestlogs.reconnects:1|g", readStatsdPacket(t, server))
+}
+
+func TestStatsdTruncatesPackets(t *testing.T) {
+	server := listenStatsd(t)
+	defer server.Close()
+
+	client, err := newStatsdClient(server.LocalAddr().String(), statsdTagStyleDatadog)
+	require.NoError(t, err)
+	defer client.close()
+
+	long := make([]byte, 2*statsdBufferSize)
+	for i := range long {
+		long[i] = 'a'
+	}
+	client.count(string(long), 1, nil)
+
+	require.Len(t, readStatsdPacket(t, server), statsdBufferSize)
+}
+
+func TestStatsdSinkAllocations(t *testing.T) {
+	server := listenStatsd(t)
+	defer server.Close()
+
+	client, err := newStatsdClient(server.LocalAddr().String(), statsdTagStyleDatadog)
+	require.NoError(t, err)
+	defer client.close()
+
+	sink := statsdSink{client}
+	event := RenderedEvent{Method: "GET", Status: 200}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		sink.Write(event)
+	})
+	require.Zero(t, allocs)
+}
+
+func TestStatsdStatusClass(t *testing.T) {
+	require.Equal(t, "2xx", statsdStatusClass(204))
+	require.Equal(t, "5xx", statsdStatusClass(503))
+	require.Equal(t, "other", statsdStatusClass(0))
+}
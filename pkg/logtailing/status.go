@@ -0,0 +1,114 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State is the state of the connection of a running tailer
+type State string
+
+const (
+	// StateConnecting is the state of the tailer until its websocket clients
+	// all connected for the first time
+	StateConnecting State = "connecting"
+
+	// StateReady is the state of the tailer while its websocket clients are
+	// all connected
+	StateReady State = "ready"
+
+	// StateReconnecting is the state of the tailer while one of its websocket
+	// clients lost its connection and is reconnecting
+	StateReconnecting State = "reconnecting"
+
+	// StateStopped is the state of the tailer once Run returned
+	StateStopped State = "stopped"
+)
+
+// Status is the health of a running tailer, as returned by Status
+type Status struct {
+	// State is the state of the connection
+	State State `json:"state"`
+
+	// LastEventAt is when the last request log was received, or the zero
+	// time if none were
+	LastEventAt time.Time `json:"last_event_at"`
+
+	// EventsSeen is the number of request logs received, including the ones
+	// hidden by the filters
+	EventsSeen int `json:"events_seen"`
+
+	// Reconnects is the number of times the websocket clients reconnected
+	Reconnects int `json:"reconnects"`
+}
+
+// eventTracker tracks the request logs received for Status. The websocket
+// client handles request logs concurrently, so it's guarded by a mutex.
+type eventTracker struct {
+	mu          sync.Mutex
+	lastEventAt time.Time
+	eventsSeen  int
+}
+
+// record counts a request log received at receivedAt
+func (t *eventTracker) record(receivedAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.eventsSeen++
+	if receivedAt.After(t.lastEventAt) {
+		t.lastEventAt = receivedAt
+	}
+}
+
+// state returns the state of the connection of the tailer
+func (tailer *Tailer) state() State {
+	if atomic.LoadInt32(&tailer.stopped) == 1 {
+		return StateStopped
+	}
+	if atomic.LoadInt32(&tailer.ready) == 0 {
+		return StateConnecting
+	}
+
+	for _, st := range tailer.streams {
+		if st.client != nil && atomic.LoadInt32(&st.connected) == 0 {
+			return StateReconnecting
+		}
+	}
+
+	return StateReady
+}
+
+// Status returns the health of the tailer, e.g. for a supervisor checking
+// that it's connected. It's safe to call from any goroutine, including
+// while Run is running.
+func (tailer *Tailer) Status() Status {
+	tailer.received.mu.Lock()
+	defer tailer.received.mu.Unlock()
+
+	return Status{
+		State:       tailer.state(),
+		LastEventAt: tailer.received.lastEventAt,
+		EventsSeen:  tailer.received.eventsSeen,
+		Reconnects:  int(tailer.reconnects()),
+	}
+}
+
+// StatusHandler returns an HTTP handler serving Status as JSON, with a 503
+// status code unless the tailer is ready, so that it can be used as a health
+// check. It's also served on /status of cfg.MetricsAddr.
+func (tailer *Tailer) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := tailer.Status()
+
+		w.Header().Set("Content-Type", "application/json")
+		if status.State != StateReady {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		json.NewEncoder(w).Encode(status) // #nosec G104
+	})
+}
@@ -0,0 +1,116 @@
+package logtailing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestStatusState(t *testing.T) {
+	tailer := New(&Config{Keys: []string{"sk_test_123", "sk_live_456"}})
+	require.Equal(t, Status{State: StateConnecting}, tailer.Status())
+
+	for _, st := range tailer.streams {
+		st.client = &websocket.Client{}
+		tailer.onStreamConnect(st, 1)
+	}
+	atomic.StoreInt32(&tailer.ready, 1)
+	require.Equal(t, StateReady, tailer.Status().State)
+
+	// A single disconnected stream is enough to be reconnecting
+	atomic.StoreInt32(&tailer.streams[1].connected, 0)
+	require.Equal(t, StateReconnecting, tailer.Status().State)
+
+	tailer.onStreamConnect(tailer.streams[1], 1)
+	require.Equal(t, Status{State: StateReady, Reconnects: 1}, tailer.Status())
+
+	atomic.StoreInt32(&tailer.stopped, 1)
+	require.Equal(t, StateStopped, tailer.Status().State)
+}
+
+func TestStatusEvents(t *testing.T) {
+	now := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	tailer := New(&Config{NoStdout: true, FilterStatusCodes: []int{500}})
+	tailer.now = func() time.Time { return now }
+
+	tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{EventPayload: `{"status": 500}`}})
+	now = now.Add(time.Second)
+	// The request logs hidden by the filters are seen too
+	tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{EventPayload: `{"status": 200}`}})
+
+	status := tailer.Status()
+	require.Equal(t, 2, status.EventsSeen)
+	require.Equal(t, now, status.LastEventAt)
+}
+
+func TestStatusHandler(t *testing.T) {
+	tailer := New(&Config{})
+
+	rec := httptest.NewRecorder()
+	tailer.StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.JSONEq(t, `{"state": "connecting", "last_event_at": "0001-01-01T00:00:00Z", "events_seen": 0, "reconnects": 0}`, rec.Body.String())
+
+	atomic.StoreInt32(&tailer.ready, 1)
+
+	rec = httptest.NewRecorder()
+	tailer.StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var status Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Equal(t, StateReady, status.State)
+}
+
+func TestRunStatus(t *testing.T) {
+	stripe := newFakeStripe(t, 2)
+	defer stripe.close()
+
+	ready := make(chan struct{})
+	received := make(channelSink, 2)
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		Key:              "sk_test_123",
+		OnReady:          func() { close(ready) },
+		Quiet:            true,
+		Sinks:            []Sink{received},
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the tailer to be ready")
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Timed out waiting for the request logs")
+		}
+	}
+
+	status := tailer.Status()
+	require.Equal(t, StateReady, status.State)
+	require.Equal(t, 2, status.EventsSeen)
+	require.False(t, status.LastEventAt.IsZero())
+	require.Equal(t, 0, status.Reconnects)
+
+	tailer.Stop()
+	require.NoError(t, <-done)
+	require.Equal(t, StateStopped, tailer.Status().State)
+}
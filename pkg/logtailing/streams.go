@@ -0,0 +1,313 @@
+package logtailing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/briandowns/spinner"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/stripeauth"
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// reconnectBackoff is the backoff of the websocket clients between the
+// attempts to reconnect to Stripe, randomized so that the tails of an outage
+// don't all reconnect at once
+var reconnectBackoff = websocket.ReconnectBackoff{
+	Initial:    time.Second,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+	ResetAfter: 30 * time.Second,
+}
+
+// stream tails the request logs of one API key, with its own CLI session and
+// websocket client. Their request logs all go through the same output.
+type stream struct {
+	key string
+
+	// label labels the request logs of the stream when several keys are
+	// tailed, e.g. TEST or LIVE, or when the key has a label in
+	// cfg.KeyLabels. It's empty otherwise.
+	label string
+
+	authClient *stripeauth.Client
+	client     *websocket.Client
+
+	// connects is the number of times the websocket client connected. It
+	// must be accessed atomically.
+	connects uint64
+
+	// connected is 1 while the websocket client is connected, and lost is 1
+	// once a lost connection was announced, until it's restored. They must
+	// be accessed atomically.
+	connected int32
+	lost      int32
+}
+
+// newStreams returns a stream per key of the config: cfg.Key, or each key of
+// cfg.Keys
+func newStreams(cfg *Config) []*stream {
+	keys := cfg.Keys
+	if len(keys) == 0 {
+		keys = []string{cfg.Key}
+	}
+
+	labels := streamLabels(keys, cfg.KeyLabels)
+
+	streams := make([]*stream, len(keys))
+	for i, key := range keys {
+		streams[i] = &stream{
+			key:   key,
+			label: labels[i],
+			authClient: stripeauth.NewClient(key, &stripeauth.Config{
+				Log:        cfg.Log,
+				APIBaseURL: cfg.APIBaseURL,
+			}),
+		}
+	}
+
+	return streams
+}
+
+// streamLabels returns the labels of the streams of the keys: their label in
+// keyLabels, or their mode, suffixed with the end of the key when several
+// keys share a label. A single key is only labeled if it's in keyLabels.
+func streamLabels(keys []string, keyLabels map[string]string) []string {
+	labels := make([]string, len(keys))
+	if len(keys) == 1 {
+		labels[0] = keyLabels[keys[0]]
+		return labels
+	}
+
+	counts := make(map[string]int)
+	for i, key := range keys {
+		labels[i] = keyLabels[key]
+		if labels[i] == "" {
+			labels[i] = keyMode(key)
+		}
+		counts[labels[i]]++
+	}
+
+	for i, key := range keys {
+		if counts[labels[i]] > 1 {
+			labels[i] += " " + keySuffix(key)
+		}
+	}
+
+	return labels
+}
+
+// labeled returns true if the request logs of the tailer are labeled with
+// the key they're received with
+func (tailer *Tailer) labeled() bool {
+	for _, st := range tailer.streams {
+		if st.label != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// keyMode returns TEST or LIVE for test mode and live mode keys, and KEY
+// otherwise
+func keyMode(key string) string {
+	switch {
+	case strings.Contains(key, "_test_"):
+		return "TEST"
+	case strings.Contains(key, "_live_"):
+		return "LIVE"
+	default:
+		return "KEY"
+	}
+}
+
+// keySuffix returns the last 4 characters of the key, which is enough to tell
+// keys apart without printing them
+func keySuffix(key string) string {
+	if len(key) <= 4 {
+		return key
+	}
+	return key[len(key)-4:]
+}
+
+// onStreamConnect is called by the websocket client of the stream every time
+// it connects, after attempt attempts. Every connection but the first one is
+// a reconnect, which is announced if the connection was lost.
+func (tailer *Tailer) onStreamConnect(s *stream, attempt int) {
+	atomic.StoreInt32(&s.connected, 1)
+	connects := atomic.AddUint64(&s.connects, 1)
+	reconnect := connects > 1
+
+	if reconnect {
+		tailer.cfg.Log.WithFields(log.Fields{
+			"prefix":     "logs.Tailer.onStreamConnect",
+			"stream":     s.label,
+			"reconnects": connects - 1,
+			"attempt":    attempt,
+		}).Debug("Reconnected to Stripe")
+
+		if atomic.CompareAndSwapInt32(&s.lost, 1, 0) {
+			message := "Reconnected"
+			if attempt > 1 {
+				message += fmt.Sprintf(" after %d attempts", attempt)
+			}
+			tailer.printNotice(s.notice(message))
+		}
+		tailer.onReconnect()
+	} else {
+		tailer.onFirstConnect()
+	}
+
+	if tailer.metrics != nil {
+		tailer.metrics.recordConnect(reconnect)
+	}
+	if tailer.statsd != nil {
+		tailer.statsd.recordConnect(reconnect)
+	}
+}
+
+// clientErrors returns a channel receiving the errors the websocket clients of
+// the streams give up with, until stopCh is closed
+func (tailer *Tailer) clientErrors(stopCh chan struct{}) <-chan error {
+	errCh := make(chan error, len(tailer.streams))
+
+	for _, st := range tailer.streams {
+		if st.client == nil {
+			continue
+		}
+
+		go func(st *stream) {
+			select {
+			case err := <-st.client.Errors():
+				tailer.cfg.Log.WithFields(log.Fields{
+					"prefix": "logs.Tailer.clientErrors",
+					"stream": st.label,
+				}).Debug("Gave up connecting to Stripe")
+				errCh <- err
+			case <-stopCh:
+			}
+		}(st)
+	}
+
+	return errCh
+}
+
+// onStreamDisconnect is called by the websocket client of the stream every
+// time the connection is lost or reset. Losing the connection once the
+// tailer is ready is announced, as the request logs stop until it's
+// restored, but the periodic resets aren't.
+func (tailer *Tailer) onStreamDisconnect(s *stream, err error, willRetry bool) {
+	atomic.StoreInt32(&s.connected, 0)
+
+	if err == nil || !willRetry || atomic.LoadInt32(&tailer.ready) == 0 {
+		return
+	}
+
+	tailer.cfg.Log.WithFields(log.Fields{
+		"prefix": "logs.Tailer.onStreamDisconnect",
+		"stream": s.label,
+		"error":  err,
+	}).Debug("Lost the connection to Stripe")
+
+	if atomic.CompareAndSwapInt32(&s.lost, 0, 1) {
+		tailer.printNotice(s.notice("Connection lost, reconnecting…"))
+	}
+}
+
+// notice prefixes a notice about the stream with its label, if it has one
+func (s *stream) notice(message string) string {
+	if s.label == "" {
+		return message
+	}
+	return fmt.Sprintf("[%s] %s", s.label, message)
+}
+
+// reconnects returns the number of times the websocket clients of the
+// streams reconnected
+func (tailer *Tailer) reconnects() uint64 {
+	var reconnects uint64
+	for _, st := range tailer.streams {
+		if connects := atomic.LoadUint64(&st.connects); connects > 1 {
+			reconnects += connects - 1
+		}
+	}
+	return reconnects
+}
+
+// authorizeStreams initiates a CLI session for each stream, in the order of
+// the keys. It returns the sessions of the streams, nil for the ones that
+// couldn't be authorized, and their errors. When several keys are tailed, a
+// key that can't be authorized doesn't prevent tailing the other ones, unless
+// cfg.Strict is set or none of them can be authorized.
+func (tailer *Tailer) authorizeStreams(ctx context.Context, filters *string, s *spinner.Spinner) ([]*stripeauth.StripeCLISession, []error, error) {
+	sessions := make([]*stripeauth.StripeCLISession, len(tailer.streams))
+	var failures []error
+
+	for i, st := range tailer.streams {
+		session, err := tailer.authorize(ctx, st, filters, s)
+		if err == errStopped {
+			return nil, nil, err
+		}
+		if err != nil {
+			err = authorizationError(st.key, err)
+			if len(tailer.streams) == 1 || tailer.cfg.Strict {
+				return nil, nil, err
+			}
+			failures = append(failures, err)
+			continue
+		}
+
+		sessions[i] = session
+	}
+
+	if len(failures) == len(tailer.streams) {
+		return nil, nil, failures[0]
+	}
+
+	return sessions, failures, nil
+}
+
+// newWebSocketClient returns the websocket client of the stream for the
+// session
+func (tailer *Tailer) newWebSocketClient(st *stream, session *stripeauth.StripeCLISession) *websocket.Client {
+	return websocket.NewClient(
+		session.WebSocketURL,
+		session.WebSocketID,
+		session.WebSocketAuthorizedFeature,
+		&websocket.Config{
+			EventHandler: websocket.EventHandlerFunc(func(msg websocket.IncomingMessage) {
+				// The request logs received while shutting down are
+				// ignored, as the outputs are being closed
+				if !tailer.handlers.begin() {
+					return
+				}
+
+				// The pool calls done once the request log is written
+				if tailer.pool != nil {
+					tailer.pool.submit(st, msg)
+					return
+				}
+				defer tailer.handlers.done()
+
+				tailer.processStreamEvent(st, msg)
+			}),
+			Log:                  tailer.cfg.Log,
+			MaxReconnectAttempts: tailer.cfg.MaxReconnectAttempts,
+			NoWSS:                tailer.cfg.NoWSS,
+			OnConnect: func(attempt int) {
+				tailer.onStreamConnect(st, attempt)
+			},
+			OnDisconnect: func(err error, willRetry bool) {
+				tailer.onStreamDisconnect(st, err, willRetry)
+			},
+			ReconnectBackoff:   reconnectBackoff,
+			ReconnectInterval:  time.Duration(session.ReconnectDelay) * time.Second,
+			SerialEventHandler: tailer.pool != nil,
+		},
+	)
+}
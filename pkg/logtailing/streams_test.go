@@ -0,0 +1,257 @@
+package logtailing
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestStreamLabels(t *testing.T) {
+	require.Equal(t, []string{""}, streamLabels([]string{"sk_test_123"}, nil))
+	require.Equal(t, []string{"TEST", "LIVE"}, streamLabels([]string{"sk_test_123", "rk_live_456"}, nil))
+	require.Equal(t, []string{"TEST 1234", "TEST 5678", "LIVE"}, streamLabels([]string{"sk_test_1234", "rk_test_5678", "sk_live_123"}, nil))
+	require.Equal(t, []string{"TEST", "KEY"}, streamLabels([]string{"sk_test_123", "abc"}, nil))
+}
+
+func TestStreamLabelsKeyLabels(t *testing.T) {
+	labels := map[string]string{"sk_live_1234": "acme", "sk_live_5678": "globex", "sk_test_123": "acme"}
+
+	require.Equal(t, []string{"acme"}, streamLabels([]string{"sk_live_1234"}, labels))
+	require.Equal(t, []string{"globex", "LIVE"}, streamLabels([]string{"sk_live_5678", "sk_live_999"}, labels))
+	require.Equal(t, []string{"acme 1234", "globex", "acme _123"}, streamLabels([]string{"sk_live_1234", "sk_live_5678", "sk_test_123"}, labels))
+}
+
+// keyAPI initiates sessions connecting to the fake Stripe for the keys
+// starting with sk_test_, and rejects the other keys
+func keyAPI(stripe *fakeStripe) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer sk_test_") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeSession(w, stripe.ws)
+	}))
+}
+
+func receiveLines(t *testing.T, received channelSink, count int) []string {
+	var lines []string
+	for len(lines) < count {
+		select {
+		case event := <-received:
+			lines = append(lines, event.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "Timed out waiting for the request logs")
+		}
+	}
+	return lines
+}
+
+func TestRunKeys(t *testing.T) {
+	stripe := newFakeStripe(t, 1)
+	defer stripe.close()
+
+	received := make(channelSink, 2)
+	tailer := New(&Config{
+		APIBaseURL:       stripe.api.URL,
+		ColorMode:        "never",
+		Fields:           []string{"request_id"},
+		Keys:             []string{"sk_test_123", "sk_live_456"},
+		NoSummary:        true,
+		Quiet:            true,
+		Sinks:            []Sink{received},
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	lines := receiveLines(t, received, 2)
+	require.ElementsMatch(t, []string{"[TEST] req_0", "[LIVE] req_0"}, lines)
+
+	tailer.Stop()
+	requireReturns(t, done)
+
+	for _, st := range tailer.streams {
+		require.NotNil(t, st.client)
+	}
+}
+
+func TestRunKeysAuthorizationFailure(t *testing.T) {
+	stripe := newFakeStripe(t, 1)
+	defer stripe.close()
+	apiServer := keyAPI(stripe)
+	defer apiServer.Close()
+
+	logger, hook := test.NewNullLogger()
+	received := make(channelSink, 1)
+	tailer := New(&Config{
+		APIBaseURL:           apiServer.URL,
+		AuthorizeMaxAttempts: 1,
+		ColorMode:            "never",
+		Fields:               []string{"request_id"},
+		Keys:                 []string{"sk_live_456", "sk_test_123"},
+		Log:                  logger,
+		NoSummary:            true,
+		Sinks:                []Sink{received},
+		Stderr:               ioutil.Discard,
+		Stdout:               ioutil.Discard,
+		WebSocketFeature:     "request_logs",
+	})
+
+	done := make(chan error)
+	go func() { done <- tailer.Run() }()
+
+	require.Equal(t, []string{"[TEST] req_0"}, receiveLines(t, received, 1))
+
+	tailer.Stop()
+	requireReturns(t, done)
+
+	var warnings []string
+	for _, entry := range hook.AllEntries() {
+		if strings.Contains(entry.Message, "won't be tailed") {
+			warnings = append(warnings, entry.Message)
+		}
+	}
+	require.Equal(t, []string{"error while authenticating with Stripe with a live mode key: Authorization failed, status=401, body=, its request logs won't be tailed"}, warnings)
+}
+
+func TestRunKeysStrict(t *testing.T) {
+	stripe := newFakeStripe(t, 1)
+	defer stripe.close()
+	apiServer := keyAPI(stripe)
+	defer apiServer.Close()
+
+	tailer := New(&Config{
+		APIBaseURL:           apiServer.URL,
+		AuthorizeMaxAttempts: 1,
+		Keys:                 []string{"sk_test_123", "sk_live_456"},
+		Quiet:                true,
+		Stderr:               ioutil.Discard,
+		Stdout:               ioutil.Discard,
+		Strict:               true,
+		WebSocketFeature:     "request_logs",
+	})
+
+	err := tailer.Run()
+	require.EqualError(t, err, "error while authenticating with Stripe with a live mode key: Authorization failed, status=401, body=")
+}
+
+func TestRunKeysAllFailing(t *testing.T) {
+	stripe := newFakeStripe(t, 1)
+	defer stripe.close()
+	apiServer := keyAPI(stripe)
+	defer apiServer.Close()
+
+	tailer := New(&Config{
+		APIBaseURL:           apiServer.URL,
+		AuthorizeMaxAttempts: 1,
+		Keys:                 []string{"sk_live_123", "sk_live_456"},
+		Quiet:                true,
+		Stderr:               ioutil.Discard,
+		Stdout:               ioutil.Discard,
+		WebSocketFeature:     "request_logs",
+	})
+
+	require.EqualError(t, tailer.Run(), "error while authenticating with Stripe with a live mode key: Authorization failed, status=401, body=")
+}
+
+func TestLabeledTestModeEvent(t *testing.T) {
+	var stdout bytes.Buffer
+	tailer := New(&Config{
+		ColorMode: "never",
+		Keys:      []string{"sk_test_123", "sk_live_123"},
+		NoSummary: true,
+		Stdout:    &stdout,
+		Timezone:  "UTC",
+	})
+
+	tailer.processStreamEvent(tailer.streams[0], websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+		EventPayload: `{"created_at": 1570000000, "livemode": false, "method": "POST", "request_id": "req_1", "status": 200, "url": "/v1/charges"}`,
+		RequestLogID: "resp_123",
+		Type:         "request_log_event",
+	}})
+
+	// The label shows the mode, so the test mode marker isn't repeated
+	require.Equal(t, "[TEST] 2019-10-02 07:06:40 [200 OK] POST /v1/charges req_1\n", stdout.String())
+}
+
+func TestLabeledOutputFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		line   string
+	}{
+		{outputFormatNDJSON, `{"request_id":"req_123","status":200,"request_log_id":"resp_123","label":"acme"}`},
+		{outputFormatEnvelope, `{"received_at":"2019-10-02T07:06:40Z","request_log_id":"resp_123","type":"request_log_event","payload":{"request_id":"req_123","status":200},"label":"acme"}`},
+		{outputFormatLogfmt, `label=acme time="" status=200 method="" url="" request_id=req_123 request_log_id=resp_123`},
+		{outputFormatCSV, `,200,,,req_123,,,,,,,,,,,acme`},
+		{outputFormatJSON, "{\n  \"request_id\": \"req_123\",\n  \"status\": 200,\n  \"label\": \"acme\"\n}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var stdout bytes.Buffer
+			tailer := New(&Config{
+				ColorMode:    "never",
+				Key:          "sk_live_123",
+				KeyLabels:    map[string]string{"sk_live_123": "acme"},
+				NoSummary:    true,
+				OutputFormat: tt.format,
+				Stdout:       &stdout,
+			})
+			tailer.now = func() time.Time { return time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC) }
+
+			tailer.processStreamEvent(tailer.streams[0], websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{
+				EventPayload: `{"request_id":"req_123","status":200}`,
+				RequestLogID: "resp_123",
+				Type:         "request_log_event",
+			}})
+
+			require.Equal(t, tt.line+"\n", stdout.String())
+		})
+	}
+}
+
+func TestLabeledCSVHeader(t *testing.T) {
+	tailer := New(&Config{Keys: []string{"sk_test_123", "sk_live_456"}})
+	require.Equal(t, "label", tailer.csvHeader()[len(csvHeader)])
+
+	tailer = New(&Config{Key: "sk_test_123"})
+	require.Equal(t, csvHeader, tailer.csvHeader())
+}
+
+func TestStreamConnectionNotices(t *testing.T) {
+	var stderr bytes.Buffer
+	tailer := New(&Config{ColorMode: "never", Keys: []string{"sk_test_123", "sk_live_123"}, Stderr: &stderr})
+	st := tailer.streams[0]
+
+	// The connection is only announced lost once the tailer is ready
+	tailer.onStreamConnect(st, 1)
+	tailer.onStreamDisconnect(st, errors.New("unexpected EOF"), true)
+	tailer.onStreamConnect(st, 1)
+	require.Empty(t, stderr.String())
+	atomic.StoreInt32(&tailer.ready, 1)
+
+	// The periodic resets and stopping aren't announced
+	tailer.onStreamDisconnect(st, nil, true)
+	tailer.onStreamConnect(st, 1)
+	tailer.onStreamDisconnect(st, nil, false)
+	require.Empty(t, stderr.String())
+
+	tailer.onStreamDisconnect(st, errors.New("unexpected EOF"), true)
+	require.Equal(t, int32(0), atomic.LoadInt32(&st.connected))
+	tailer.onStreamConnect(st, 3)
+	require.Equal(t, "[TEST] Connection lost, reconnecting…\n[TEST] Reconnected after 3 attempts\n", stderr.String())
+}
@@ -0,0 +1,276 @@
+package logtailing
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// summaryTopPaths is the number of most requested paths in the summary
+const summaryTopPaths = 5
+
+// sessionStats accumulates the statistics of the request logs displayed
+// during a session, for the summary printed on exit. The websocket client
+// handles request logs concurrently, so it's guarded by a mutex.
+type sessionStats struct {
+	mu sync.Mutex
+
+	start   time.Time
+	total   int
+	classes map[int]int
+	paths   map[string]int
+	errors  int
+
+	// malformed is the number of request logs whose payload couldn't be
+	// read, which aren't counted in total
+	malformed int
+
+	// dropped is the number of request logs dropped by the queue of
+	// cfg.QueueSize, which aren't counted in total either
+	dropped int
+
+	// duplicates is the number of request logs redelivered by Stripe that
+	// were dropped, which aren't counted in total either
+	duplicates int
+
+	// filtered is the number of request logs hidden by the local filters,
+	// e.g. the successful requests with cfg.OnlyErrors, which aren't counted
+	// in total either
+	filtered int
+
+	// labels are the statistics of each label when the request logs are
+	// labeled with their key, in the order the labels were first seen
+	labels     map[string]*labelStats
+	labelOrder []string
+}
+
+// labelStats are the statistics of the request logs of a label
+type labelStats struct {
+	total   int
+	classes map[int]int
+	errors  int
+}
+
+func newSessionStats(start time.Time) *sessionStats {
+	return &sessionStats{
+		start:   start,
+		classes: make(map[int]int),
+		paths:   make(map[string]int),
+		labels:  make(map[string]*labelStats),
+	}
+}
+
+// record adds a displayed request log to the statistics, with the label of
+// its key if it's labeled.
+func (s *sessionStats) record(label string, payload *EventPayload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total++
+	s.classes[payload.Status/100]++
+	if payload.Status >= 400 {
+		s.errors++
+	}
+
+	if label != "" {
+		stats, ok := s.labels[label]
+		if !ok {
+			stats = &labelStats{classes: make(map[int]int)}
+			s.labels[label] = stats
+			s.labelOrder = append(s.labelOrder, label)
+		}
+
+		stats.total++
+		stats.classes[payload.Status/100]++
+		if payload.Status >= 400 {
+			stats.errors++
+		}
+	}
+
+	if path := strings.SplitN(payload.URL, "?", 2)[0]; path != "" {
+		s.paths[path]++
+	}
+}
+
+// recordMalformed counts a request log whose payload couldn't be read
+func (s *sessionStats) recordMalformed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.malformed++
+}
+
+// recordDropped counts a request log dropped by the queue
+func (s *sessionStats) recordDropped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropped++
+}
+
+// recordDuplicate counts a redelivered request log that was dropped
+func (s *sessionStats) recordDuplicate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.duplicates++
+}
+
+// recordFiltered counts a request log hidden by the local filters
+func (s *sessionStats) recordFiltered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filtered++
+}
+
+// pathCount is the number of request logs for a path
+type pathCount struct {
+	path  string
+	count int
+}
+
+// topPaths returns the n most requested paths, the most requested first.
+// Paths requested as many times are sorted alphabetically. The caller must
+// hold mu.
+func (s *sessionStats) topPaths(n int) []pathCount {
+	counts := make([]pathCount, 0, len(s.paths))
+	for path, count := range s.paths {
+		counts = append(counts, pathCount{path: path, count: count})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].path < counts[j].path
+	})
+
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+
+	return counts
+}
+
+// write writes the summary of the session ending at end, e.g.
+//
+//	Session summary (5m3s)
+//	  Request logs: 142 (2xx: 130, 4xx: 10, 5xx: 2)
+//	  Error rate: 8.5%
+//	  Malformed payloads: 1
+//	  Top paths:
+//	    120 /v1/charges
+//	     22 /v1/customers
+//
+// The labeled request logs are also broken down by label, after the error
+// rate. The malformed payloads, the request logs dropped by the queue, the
+// redelivered ones and the ones hidden by the filters are only listed if
+// there are any.
+func (s *sessionStats) write(w io.Writer, end time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "Session summary (%s)\n", end.Sub(s.start).Round(time.Second))
+
+	if s.total == 0 && s.malformed == 0 && s.dropped == 0 && s.duplicates == 0 && s.filtered == 0 {
+		fmt.Fprintln(w, "  No request logs were received")
+		return
+	}
+
+	if s.total > 0 {
+		s.writeCounts(w)
+	}
+	s.writeMalformed(w)
+	if s.dropped > 0 {
+		fmt.Fprintf(w, "  Dropped: %d\n", s.dropped)
+	}
+	if s.duplicates > 0 {
+		fmt.Fprintf(w, "  Duplicates suppressed: %d\n", s.duplicates)
+	}
+	if s.filtered > 0 {
+		fmt.Fprintf(w, "  Filtered out: %d\n", s.filtered)
+	}
+
+	top := s.topPaths(summaryTopPaths)
+	if len(top) == 0 {
+		return
+	}
+
+	width := len(fmt.Sprint(top[0].count))
+
+	fmt.Fprintln(w, "  Top paths:")
+	for _, path := range top {
+		fmt.Fprintf(w, "    %*d %s\n", width, path.count, path.path)
+	}
+}
+
+// writeStats writes the statistics of the session so far, while it's still
+// running, with the websocket reconnects and the request logs dropped by the
+// output, e.g.
+//
+//	Session stats (2m10s)
+//	  Request logs: 42 (2xx: 40, 4xx: 2)
+//	  Error rate: 4.8%
+//	  Reconnects: 1
+//	  Dropped: 0
+func (s *sessionStats) writeStats(w io.Writer, now time.Time, reconnects uint64, dropped uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "Session stats (%s)\n", now.Sub(s.start).Round(time.Second))
+
+	if s.total == 0 {
+		fmt.Fprintln(w, "  Request logs: 0")
+	} else {
+		s.writeCounts(w)
+	}
+	s.writeMalformed(w)
+
+	fmt.Fprintf(w, "  Reconnects: %d\n", reconnects)
+	fmt.Fprintf(w, "  Dropped: %d\n", dropped)
+}
+
+// writeCounts writes the number of request logs by status class and the
+// error rate, broken down by label if they're labeled. The caller must hold
+// mu, and there must be request logs.
+func (s *sessionStats) writeCounts(w io.Writer) {
+	fmt.Fprintf(w, "  Request logs: %d (%s)\n", s.total, classCounts(s.classes))
+	fmt.Fprintf(w, "  Error rate: %.1f%%\n", float64(s.errors)*100/float64(s.total))
+
+	if len(s.labelOrder) > 0 {
+		fmt.Fprintln(w, "  By key:")
+		for _, label := range s.labelOrder {
+			stats := s.labels[label]
+			fmt.Fprintf(w, "    %s: %d (%s), error rate %.1f%%\n", label, stats.total, classCounts(stats.classes), float64(stats.errors)*100/float64(stats.total))
+		}
+	}
+}
+
+// writeMalformed writes the number of request logs whose payload couldn't be
+// read, if any. The caller must hold mu.
+func (s *sessionStats) writeMalformed(w io.Writer) {
+	if s.malformed > 0 {
+		fmt.Fprintf(w, "  Malformed payloads: %d\n", s.malformed)
+	}
+}
+
+// classCounts returns the counts of the status classes, e.g.
+// `2xx: 130, 4xx: 10`
+func classCounts(byClass map[int]int) string {
+	classes := make([]int, 0, len(byClass))
+	for class := range byClass {
+		classes = append(classes, class)
+	}
+	sort.Ints(classes)
+
+	counts := make([]string, 0, len(classes))
+	for _, class := range classes {
+		counts = append(counts, fmt.Sprintf("%dxx: %d", class, byClass[class]))
+	}
+
+	return strings.Join(counts, ", ")
+}
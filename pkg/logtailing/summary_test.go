@@ -0,0 +1,164 @@
+package logtailing
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionStats(t *testing.T) {
+	start := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	stats := newSessionStats(start)
+
+	payloads := []*EventPayload{
+		{Status: 200, URL: "/v1/charges"},
+		{Status: 200, URL: "/v1/charges?limit=3"},
+		{Status: 402, URL: "/v1/charges"},
+		{Status: 200, URL: "/v1/customers"},
+		{Status: 500, URL: "/v1/customers/cus_123"},
+		{Status: 404, URL: "/v1/refunds"},
+		{Status: 200, URL: "/v1/balance"},
+		{Status: 200, URL: "/v1/tokens"},
+	}
+	for _, payload := range payloads {
+		stats.record("", payload)
+	}
+
+	var buf bytes.Buffer
+	stats.write(&buf, start.Add(5*time.Minute+3*time.Second+400*time.Millisecond))
+
+	require.Equal(t, `Session summary (5m3s)
+  Request logs: 8 (2xx: 5, 4xx: 2, 5xx: 1)
+  Error rate: 37.5%
+  Top paths:
+    3 /v1/charges
+    1 /v1/balance
+    1 /v1/customers
+    1 /v1/customers/cus_123
+    1 /v1/refunds
+`, buf.String())
+}
+
+func TestSessionStatsLabels(t *testing.T) {
+	start := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	stats := newSessionStats(start)
+
+	stats.record("acme", &EventPayload{Status: 200, URL: "/v1/charges"})
+	stats.record("globex", &EventPayload{Status: 500, URL: "/v1/charges"})
+	stats.record("acme", &EventPayload{Status: 402, URL: "/v1/charges"})
+	stats.record("acme", &EventPayload{Status: 200, URL: "/v1/charges"})
+
+	var buf bytes.Buffer
+	stats.write(&buf, start.Add(time.Minute))
+
+	require.Equal(t, `Session summary (1m0s)
+  Request logs: 4 (2xx: 2, 4xx: 1, 5xx: 1)
+  Error rate: 50.0%
+  By key:
+    acme: 3 (2xx: 2, 4xx: 1), error rate 33.3%
+    globex: 1 (5xx: 1), error rate 100.0%
+  Top paths:
+    4 /v1/charges
+`, buf.String())
+}
+
+func TestSessionStatsEmpty(t *testing.T) {
+	start := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	stats := newSessionStats(start)
+
+	var buf bytes.Buffer
+	stats.write(&buf, start.Add(time.Minute))
+
+	require.Equal(t, "Session summary (1m0s)\n  No request logs were received\n", buf.String())
+}
+
+func TestSessionStatsMalformed(t *testing.T) {
+	start := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	stats := newSessionStats(start)
+	stats.record("", &EventPayload{Status: 200, URL: "/v1/charges"})
+	stats.recordMalformed()
+	stats.recordMalformed()
+
+	var buf bytes.Buffer
+	stats.write(&buf, start.Add(time.Minute))
+
+	require.Equal(t, `Session summary (1m0s)
+  Request logs: 1 (2xx: 1)
+  Error rate: 0.0%
+  Malformed payloads: 2
+  Top paths:
+    1 /v1/charges
+`, buf.String())
+}
+
+func TestSessionStatsDuplicates(t *testing.T) {
+	start := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	stats := newSessionStats(start)
+	stats.record("", &EventPayload{Status: 200, URL: "/v1/charges"})
+	stats.recordDuplicate()
+
+	var buf bytes.Buffer
+	stats.write(&buf, start.Add(time.Minute))
+
+	require.Equal(t, `Session summary (1m0s)
+  Request logs: 1 (2xx: 1)
+  Error rate: 0.0%
+  Duplicates suppressed: 1
+  Top paths:
+    1 /v1/charges
+`, buf.String())
+}
+
+func TestSessionStatsFiltered(t *testing.T) {
+	start := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	stats := newSessionStats(start)
+	stats.recordFiltered()
+	stats.recordFiltered()
+
+	var buf bytes.Buffer
+	stats.write(&buf, start.Add(time.Minute))
+
+	require.Equal(t, `Session summary (1m0s)
+  Filtered out: 2
+`, buf.String())
+}
+
+func TestSessionStatsTopPathsAlignment(t *testing.T) {
+	stats := newSessionStats(time.Time{})
+	for i := 0; i < 12; i++ {
+		stats.record("", &EventPayload{Status: 200, URL: "/v1/charges"})
+	}
+	stats.record("", &EventPayload{Status: 200, URL: "/v1/customers"})
+
+	var buf bytes.Buffer
+	stats.write(&buf, time.Time{})
+
+	require.Contains(t, buf.String(), "    12 /v1/charges\n     1 /v1/customers\n")
+}
+
+func TestSessionStatsWriteStats(t *testing.T) {
+	start := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	stats := newSessionStats(start)
+
+	var buf bytes.Buffer
+	stats.writeStats(&buf, start.Add(time.Minute), 0, 0)
+	require.Equal(t, `Session stats (1m0s)
+  Request logs: 0
+  Reconnects: 0
+  Dropped: 0
+`, buf.String())
+
+	stats.record("", &EventPayload{Status: 200, URL: "/v1/charges"})
+	stats.record("", &EventPayload{Status: 402, URL: "/v1/charges"})
+
+	buf.Reset()
+	stats.writeStats(&buf, start.Add(2*time.Minute+10*time.Second), 1, 3)
+	require.Equal(t, `Session stats (2m10s)
+  Request logs: 2 (2xx: 1, 4xx: 1)
+  Error rate: 50.0%
+  Reconnects: 1
+  Dropped: 3
+`, buf.String())
+}
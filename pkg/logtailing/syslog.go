@@ -0,0 +1,253 @@
+package logtailing
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+)
+
+const (
+	// syslogDefaultNetwork is the network used when cfg.SyslogNetwork isn't
+	// set
+	syslogDefaultNetwork = "udp"
+
+	// syslogQueueSize is the number of request logs buffered while the
+	// syslog server is unreachable. Newer request logs are dropped once the
+	// queue is full.
+	syslogQueueSize = 1000
+
+	// syslogTag is the tag (or app name) of the syslog messages
+	syslogTag = "stripe"
+
+	syslogMinBackoff = 1 * time.Second
+	syslogMaxBackoff = 30 * time.Second
+)
+
+// Syslog severities, cf. RFC 5424. The messages are sent with the user-level
+// facility.
+const (
+	syslogFacilityUser = 1
+
+	syslogSeverityErr     = 3
+	syslogSeverityWarning = 4
+	syslogSeverityInfo    = 6
+)
+
+// syslogNetworks are the acceptable values of cfg.SyslogNetwork
+var syslogNetworks = []string{"tcp", "udp", "unix", "unixgram"}
+
+// syslogMessage is a request log queued to be sent to the syslog server
+type syslogMessage struct {
+	severity  int
+	timestamp time.Time
+	text      string
+}
+
+// syslogWriter mirrors request logs to a syslog server. The messages are sent
+// from a separate goroutine so that an unreachable server never blocks the
+// terminal output, and the connection is retried with an exponential
+// backoff.
+//
+// The connection is dialed directly rather than through log/syslog, which
+// isn't available on Windows.
+type syslogWriter struct {
+	network  string
+	address  string
+	hostname string
+	log      *log.Logger
+
+	dial       func(network, address string) (net.Conn, error)
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	messages chan syslogMessage
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+
+	// conn and failing are only accessed by the sending goroutine
+	conn    net.Conn
+	failing bool
+}
+
+func newSyslogWriter(network string, address string, logger *log.Logger) *syslogWriter {
+	if network == "" {
+		network = syslogDefaultNetwork
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &syslogWriter{
+		network:    network,
+		address:    address,
+		hostname:   hostname,
+		log:        logger,
+		dial:       net.Dial,
+		minBackoff: syslogMinBackoff,
+		maxBackoff: syslogMaxBackoff,
+		messages:   make(chan syslogMessage, syslogQueueSize),
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+}
+
+// syslogSeverity returns the severity of the syslog message of a request log
+// with the status code.
+func syslogSeverity(status int) int {
+	switch {
+	case status >= 500:
+		return syslogSeverityErr
+	case status >= 400:
+		return syslogSeverityWarning
+	default:
+		return syslogSeverityInfo
+	}
+}
+
+// send queues a request log line to be sent at the severity derived from the
+// status code. The line is dropped if the queue is full.
+func (w *syslogWriter) send(status int, line string) error {
+	message := syslogMessage{
+		severity:  syslogSeverity(status),
+		timestamp: time.Now(),
+		text:      ansi.Strip(line),
+	}
+
+	select {
+	case w.messages <- message:
+		return nil
+	default:
+		return errors.New("the syslog queue is full")
+	}
+}
+
+// start starts sending the queued messages.
+func (w *syslogWriter) start() {
+	go w.run()
+}
+
+// close stops sending messages. The messages still queued are sent if the
+// server is reachable, without retrying.
+func (w *syslogWriter) close() {
+	close(w.stopCh)
+	<-w.doneCh
+}
+
+func (w *syslogWriter) run() {
+	defer close(w.doneCh)
+	defer w.closeConn()
+
+	for {
+		select {
+		case message := <-w.messages:
+			w.write(message)
+		case <-w.stopCh:
+			w.drain()
+			return
+		}
+	}
+}
+
+func (w *syslogWriter) drain() {
+	for {
+		select {
+		case message := <-w.messages:
+			if w.conn == nil {
+				return
+			}
+			if _, err := w.conn.Write(w.format(message)); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// write sends the message, reconnecting with an exponential backoff until it
+// succeeds or the writer is closed.
+func (w *syslogWriter) write(message syslogMessage) {
+	backoff := w.minBackoff
+
+	for {
+		err := w.connect()
+		if err == nil {
+			_, err = w.conn.Write(w.format(message))
+			if err != nil {
+				w.closeConn()
+			}
+		}
+
+		if err == nil {
+			if w.failing {
+				w.log.WithFields(log.Fields{
+					"prefix": "logs.syslogWriter.write",
+				}).Debug("Reconnected to the syslog server")
+				w.failing = false
+			}
+			return
+		}
+
+		if !w.failing {
+			w.log.Warnf("Could not send request logs to syslog at %s, retrying: %v", w.address, err)
+			w.failing = true
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-w.stopCh:
+			return
+		}
+
+		backoff *= 2
+		if backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+	}
+}
+
+func (w *syslogWriter) connect() error {
+	if w.conn != nil {
+		return nil
+	}
+
+	conn, err := w.dial(w.network, w.address)
+	if err != nil {
+		return err
+	}
+
+	w.conn = conn
+	return nil
+}
+
+func (w *syslogWriter) closeConn() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+// format returns the message in the same format as log/syslog, e.g.
+// `<14>2019-10-02T07:06:40Z host stripe[1234]: 200 POST /v1/charges`.
+func (w *syslogWriter) format(message syslogMessage) []byte {
+	priority := syslogFacilityUser*8 + message.severity
+
+	// Stream-oriented networks need a trailing newline to delimit the
+	// messages
+	text := strings.TrimSuffix(message.text, "\n")
+
+	return []byte(fmt.Sprintf("<%d>%s %s %s[%d]: %s\n",
+		priority,
+		message.timestamp.Format(time.RFC3339),
+		w.hostname,
+		syslogTag,
+		os.Getpid(),
+		text,
+	))
+}
@@ -0,0 +1,107 @@
+package logtailing
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogSeverity(t *testing.T) {
+	require.Equal(t, syslogSeverityInfo, syslogSeverity(200))
+	require.Equal(t, syslogSeverityInfo, syslogSeverity(302))
+	require.Equal(t, syslogSeverityWarning, syslogSeverity(402))
+	require.Equal(t, syslogSeverityErr, syslogSeverity(503))
+}
+
+func TestSyslogFormat(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	w := newSyslogWriter("", "localhost:514", logger)
+	w.hostname = "host"
+
+	message := syslogMessage{
+		severity:  syslogSeverityWarning,
+		timestamp: time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC),
+		text:      "402 POST /v1/charges",
+	}
+
+	require.Equal(t, "udp", w.network)
+	require.Regexp(t, `^<12>2019-10-02T07:06:40Z host stripe\[\d+\]: 402 POST /v1/charges\n$`, string(w.format(message)))
+}
+
+func TestSyslogWriterSend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	logger, _ := test.NewNullLogger()
+	w := newSyslogWriter("tcp", listener.Addr().String(), logger)
+	w.start()
+	defer w.close()
+
+	w.send(500, "\x1b[1;31m500\x1b[0m POST /v1/charges")
+	w.send(200, "200 GET /v1/charges")
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Regexp(t, `^<11>\S+ \S+ stripe\[\d+\]: 500 POST /v1/charges\n$`, line)
+
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Regexp(t, `^<14>\S+ \S+ stripe\[\d+\]: 200 GET /v1/charges\n$`, line)
+}
+
+func TestSyslogWriterRetries(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	w := newSyslogWriter("tcp", "localhost:514", logger)
+	w.minBackoff = time.Millisecond
+	w.maxBackoff = 2 * time.Millisecond
+
+	client, server := net.Pipe()
+	defer server.Close()
+
+	attempts := 0
+	w.dial = func(network, address string) (net.Conn, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return client, nil
+	}
+
+	w.start()
+	defer w.close()
+
+	w.send(200, "200 GET /v1/charges")
+
+	line, err := bufio.NewReader(server).ReadString('\n')
+	require.NoError(t, err)
+	require.Contains(t, line, "200 GET /v1/charges")
+	require.Equal(t, 3, attempts)
+
+	// The failure is only reported once until the connection is restored
+	require.Len(t, hook.AllEntries(), 1)
+	require.Contains(t, hook.LastEntry().Message, "Could not send request logs to syslog at localhost:514, retrying: connection refused")
+}
+
+func TestSyslogWriterDropsWhenFull(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	w := newSyslogWriter("tcp", "localhost:514", logger)
+
+	// The writer isn't started, so the queue fills up without blocking
+	for i := 0; i < syslogQueueSize+10; i++ {
+		w.send(200, "200 GET /v1/charges")
+	}
+
+	require.Len(t, w.messages, syslogQueueSize)
+}
@@ -1,23 +1,52 @@
 package logtailing
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"text/template"
 	"time"
 
-	"github.com/logrusorgru/aurora"
+	"github.com/briandowns/spinner"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/stripe/stripe-cli/pkg/ansi"
-	"github.com/stripe/stripe-cli/pkg/stripeauth"
+	"github.com/stripe/stripe-cli/pkg/logtailing/filter"
 	"github.com/stripe/stripe-cli/pkg/websocket"
 )
 
-const outputFormatJSON = "JSON"
+const (
+	// outputFormatCSV prints a CSV header row when the tailer starts, then a
+	// CSV row per request log
+	outputFormatCSV = "CSV"
+
+	// outputFormatEnvelope prints a compact JSON object per request log,
+	// wrapping the payload with the request log ID, the event type and the
+	// time the request log was received at
+	outputFormatEnvelope = "ENVELOPE"
+
+	// outputFormatJSON pretty-prints and colorizes the JSON payloads
+	outputFormatJSON = "JSON"
+
+	// outputFormatLogfmt prints the request logs in logfmt, e.g.
+	// `time=2019-10-02T07:06:40Z status=200 method=POST url=/v1/charges`
+	outputFormatLogfmt = "LOGFMT"
+
+	// outputFormatNDJSON prints the JSON payloads compacted to a single line
+	// each, without colors
+	outputFormatNDJSON = "NDJSON"
+)
 
 // LogFilters contains all of the potential user-provided filters for log tailing
 type LogFilters struct {
@@ -33,47 +62,734 @@ type LogFilters struct {
 
 // Config provides the configuration of a log tailer
 type Config struct {
+	// AuthorizeMaxAttempts is the number of attempts made to initiate the
+	// session with Stripe when it fails with a transient error, such as a
+	// network error, with an exponential backoff between them. Defaults to
+	// 5, and 1 disables the retries.
+	AuthorizeMaxAttempts int
+
+	// Aligned pads the timestamp, status and method columns of the default
+	// output format to fixed widths, so that the URLs are aligned
+	Aligned bool
+
 	APIBaseURL string
 
+	// Bell rings the terminal bell when a server error is printed to Stdout,
+	// or a request log matching FailOn if it's set, at most once every 5
+	// seconds. It's only rung when Stdout is interactive.
+	Bell bool
+
+	// ColorMode controls the use of colors and other ANSI sequences: auto
+	// (the default) uses them on terminals unless NO_COLOR is set, always
+	// uses them even when the output is piped, and never disables them.
+	ColorMode string
+
+	// DedupeWindow collapses the request logs received for the same request
+	// ID within the window into a single line. No deduplication is done when
+	// zero.
+	DedupeWindow time.Duration
+
 	// DeviceName is the name of the device sent to Stripe to help identify the device
 	DeviceName string
 
+	// DrainTimeout is how long Run waits on shutdown for the request logs
+	// being handled and queued to be written, and for the outputs to be
+	// flushed. Interrupting Run again stops waiting. Defaults to 3s.
+	DrainTimeout time.Duration
+
+	// EmitEvents sends the request logs matching the filters on the channel
+	// returned by Tailer.Events, for programs building their own output
+	EmitEvents bool
+
+	// EventLimit stops the tailer once that many request logs matching the
+	// filters are received, like an interrupt does. There's no limit when
+	// zero.
+	EventLimit int
+
+	// ExcludeConnectedAccount hides request logs made on behalf of a connected
+	// account. It can't be combined with RequireConnectedAccount.
+	ExcludeConnectedAccount bool
+
+	// ExcludeRequestPaths hides request logs whose path starts with one of the
+	// prefixes, even if they match the other filters.
+	ExcludeRequestPaths []string
+
+	// FailOn makes Run and Replay return a *FailOnError at the end of the
+	// session if request logs matching the filters with these status codes
+	// were received: 5xx, 4xx or any-error for both
+	FailOn string
+
+	// Fields replaces the default output format with the values at the
+	// dotted paths of the payload (e.g. `error.code`), separated by tabs
+	Fields []string
+
 	// Filters for API request logs
 	Filters *LogFilters
 
+	// FiltersFile is the path to a JSON file of local filters, in the same
+	// format as the filter presets. The file is re-read when the tailer
+	// receives SIGHUP.
+	FiltersFile string
+
+	// FilterAPIVersion only displays request logs made with one of the Stripe
+	// API versions
+	FilterAPIVersion []string
+
+	// FilterAccount only displays request logs made on behalf of one of the
+	// connected accounts (`acct_` IDs). The special value `platform` matches
+	// request logs that aren't associated with a connected account.
+	FilterAccount []string
+
+	// FilterErrorCode only displays failed request logs whose error code is
+	// one of the values (e.g. card_declined). Successful requests are hidden.
+	FilterErrorCode []string
+
+	// FilterErrorType only displays failed request logs whose error type is
+	// one of the values (e.g. card_error)
+	FilterErrorType []string
+
+	// FilterExpression only displays request logs matching the expression,
+	// e.g. `status>=500 and method=POST`. See the filter package for the
+	// syntax.
+	FilterExpression string
+
+	// FilterHTTPMethods only displays request logs made with one of the HTTP
+	// methods (case-insensitive).
+	FilterHTTPMethods []string
+
+	// FilterIdempotencyKeys only displays request logs made with one of the
+	// idempotency keys (case-sensitive)
+	FilterIdempotencyKeys []string
+
+	// FilterIPAddress only displays request logs made from one of the IP
+	// addresses or CIDR ranges (e.g. 10.0.0.0/8)
+	FilterIPAddress []string
+
+	// FilterLivemode only displays live mode request logs when true, or test
+	// mode request logs when false. No filtering is done when nil.
+	FilterLivemode *bool
+
+	// FilterMaxLatency only displays request logs that took at most the
+	// given duration to be served by Stripe. No filtering is done when zero.
+	FilterMaxLatency time.Duration
+
+	// FilterMinLatency only displays request logs that took at least the
+	// given duration to be served by Stripe. No filtering is done when zero.
+	FilterMinLatency time.Duration
+
+	// FilterRequestIDs only displays request logs for the given `req_` IDs. A
+	// trailing `*` matches any ID starting with the rest of the value.
+	FilterRequestIDs []string
+
+	// FilterRequestPaths only displays request logs whose path starts with one
+	// of the prefixes. Query strings are ignored when matching.
+	FilterRequestPaths []string
+
+	// FilterResources only displays request logs for one of the resource types
+	// (e.g. payment_intents), as inferred from the URL
+	FilterResources []string
+
+	// FilterSince only displays request logs created at or after the given
+	// time. No filtering is done when zero.
+	FilterSince time.Time
+
+	// FilterSource only displays request logs coming from the given source
+	// (api or dashboard)
+	FilterSource string
+
+	// FilterStatusClasses only displays request logs whose status code falls
+	// in one of the classes (succeeded, client-err, server-err). Combined with
+	// FilterStatusCodes, an event is displayed if it matches either.
+	FilterStatusClasses []string
+
+	// FilterStatusCodes only displays request logs whose status code is in
+	// the list. Unlike Filters, this is applied locally by the tailer.
+	FilterStatusCodes []int
+
+	// FilterText only displays request logs whose raw payload contains all of
+	// the terms (case-insensitive), regardless of the field they're in.
+	FilterText []string
+
+	// FilterUntil only displays request logs created at or before the given
+	// time. No filtering is done when zero.
+	FilterUntil time.Time
+
+	// FilterURLRegex only displays request logs whose URL matches the regular
+	// expression
+	FilterURLRegex string
+
+	// FilterUserAgent only displays request logs whose user agent contains one
+	// of the values (case-insensitive), e.g. the name of an SDK
+	FilterUserAgent []string
+
+	// FlushInterval is how often the request logs are flushed to Stdout when
+	// it isn't a terminal, e.g. when it's redirected to a file. Defaults to
+	// one second, and a negative interval disables the buffering.
+	FlushInterval time.Duration
+
+	// ForwardHeaders are the headers added to the requests made to
+	// ForwardURL, e.g. for authentication
+	ForwardHeaders map[string]string
+
+	// ForwardURL is the URL of an HTTP endpoint the raw JSON payload of each
+	// request log matching the filters is POSTed to
+	ForwardURL string
+
+	// Handler handles the request logs matching the filters in place of the
+	// outputs: Stdout, OutputFile, ForwardURL, Sinks, etc. The tailer still
+	// authenticates, reconnects, filters and records the request logs, and
+	// keeps the stats of the summary. Set KeepOutput to write the request
+	// logs to the outputs as well.
+	Handler Handler
+
+	// IdleAfter prints a notice to Stderr when no request log was received
+	// for this long, with the state of the connection, once per idle period.
+	// Nothing is printed when zero, or in quiet mode.
+	IdleAfter time.Duration
+
+	// JSONCompact prints the payloads of the JSON output format on a single
+	// line each, still colorized. It can't be combined with JSONIndent.
+	JSONCompact bool
+
+	// JSONIndent is the indentation of the payloads in the JSON output
+	// format, made of spaces and tabs. Defaults to two spaces.
+	JSONIndent string
+
+	// KeepOutput still writes the request logs to the outputs when Handler
+	// is set, after calling it
+	KeepOutput bool
+
 	// Key is the API key used to authenticate with Stripe
 	Key string
 
+	// KeyLabels are labels of the keys, e.g. account nicknames, used instead
+	// of their mode to tell the request logs of several keys apart
+	KeyLabels map[string]string
+
+	// Keys tails the request logs of several API keys in a single session,
+	// e.g. a test mode and a live mode key, instead of Key. Each key has its
+	// own websocket connection, and the request logs are labeled with the
+	// mode of their key, e.g. TEST or LIVE, or their label in KeyLabels: the
+	// default output format prefixes them with [TEST], the structured output
+	// formats have a label field, and the summary breaks down by label.
+	Keys []string
+
+	// LiveStats shows a line below the request logs with the throughput, the
+	// error rate and the last request log, redrawn every second. It's only
+	// shown when Stdout is interactive, see ansi.IsInteractive.
+	LiveStats bool
+
 	// Info, error, etc. logger. Unrelated to API request logs.
 	Log *log.Logger
 
+	// MaxReconnectAttempts stops the tail with a *websocket.ReconnectError
+	// when a websocket client couldn't connect to Stripe after that many
+	// consecutive attempts, instead of retrying forever when it's 0
+	MaxReconnectAttempts int
+
+	// MaxURLLength truncates the URLs of the default output format to the
+	// number of characters, dropping the query string first. When negative,
+	// URLs are truncated to fit the rest of the line within the width of the
+	// terminal. No truncation is done when zero.
+	MaxURLLength int
+
+	// MetricsAddr is the address of an HTTP listener exposing counters of
+	// the session on /metrics in the Prometheus text format, e.g.
+	// localhost:9090: the request logs received, by status class, the
+	// malformed payloads, the websocket reconnects and the dropped request
+	// logs. The Status of the tailer is also served on /status as JSON.
+	MetricsAddr string
+
+	// Middlewares are custom steps of the pipeline processing the request
+	// logs, run in order after the built-in filters. They can change the
+	// request logs, or drop them by not calling the next step.
+	Middlewares []Middleware
+
+	// NoStatusText only displays the status code of request logs in the
+	// default output format, e.g. `[402]` instead of `[402 Payment Required]`
+	NoStatusText bool
+
+	// NoStdout doesn't print the request logs to Stdout, e.g. when they're
+	// consumed with EmitEvents or cfg.Sinks. The warnings and the summary are
+	// still printed to Stderr.
+	NoStdout bool
+
+	// NoSummary disables the summary of the session printed to Stderr on
+	// exit: the number of request logs by status class, the error rate and
+	// the most requested paths.
+	NoSummary bool
+
 	// Force use of unencrypted ws:// protocol instead of wss://
 	NoWSS bool
 
+	// NotifyInterval is the minimum time between two desktop notifications
+	// of NotifyOn, the request logs matching in between are only counted in
+	// the next one. Defaults to 30s.
+	NotifyInterval time.Duration
+
+	// NotifyOn shows a native desktop notification with the status, method,
+	// path and request ID of the request logs matching the filters and
+	// either 5xx, 4xx+ or a filter expression, e.g. when tailing in a
+	// background terminal. A warning is logged once if the platform can't
+	// show them.
+	NotifyOn string
+
+	// NotifyReconnects calls OnReady and prints the PrintReady line again
+	// every time a websocket client reconnects
+	NotifyReconnects bool
+
+	// OnReady is called once the tailer is ready and its websocket clients
+	// are all connected, e.g. to start sending test requests. It's called
+	// from a goroutine of the tailer and must not block.
+	OnReady func()
+
+	// OnlyErrors only displays request logs for failed requests (status code
+	// 400 and above). It can't be combined with the status filters.
+	OnlyErrors bool
+
+	// OTLPEndpoint is the OTLP/HTTP endpoint of an OpenTelemetry collector
+	// the request logs are exported to as log records, e.g.
+	// http://localhost:4318. The records are batched, and the queued ones are
+	// exported on shutdown.
+	OTLPEndpoint string
+
+	// Glyphs prefixes the lines of the default output format with a glyph
+	// for the status code: ✓ for successes, ⚠ for client errors and ✗ for
+	// server errors, or OK, ?? and !! without colors or UTF-8 support.
+	Glyphs bool
+
+	// HighlightMode displays all request logs when filters are set, and
+	// emphasizes the ones matching the filters instead of hiding the others.
+	// It only applies to the default output format, on terminals supporting
+	// colors.
+	HighlightMode bool
+
+	// OutputFile is the path to a file the request logs are appended to,
+	// stripped of ANSI sequences, in addition to being printed. SIGHUP
+	// reopens it while Run is running, for external log rotation.
+	OutputFile string
+
+	// OutputFileMaxBackups is the number of rotated output files to keep, the
+	// oldest ones are removed first. All of them are kept when 0.
+	OutputFileMaxBackups int
+
+	// OutputFileMaxSize is the size in bytes above which the output file is
+	// rotated. The file isn't rotated when 0.
+	OutputFileMaxSize int64
+
 	// Output format for request logs
 	OutputFormat string
 
+	// OutputTemplate is a text/template rendering request logs in place of
+	// the default format, e.g. `{{color .Status}} {{.Method}} {{.URL}}`. The
+	// template is executed against the EventPayload of each request log.
+	OutputTemplate string
+
+	// PauseKey pauses the output of the request logs when space is pressed
+	// while Run is running, and resumes it when space is pressed again. The
+	// terminal of Stdin is put into cbreak mode to read the keypresses, and
+	// restored when Run returns. The request logs received while paused are
+	// buffered, dropping the oldest ones once the buffer is full. It's
+	// ignored when Stdin isn't a terminal.
+	PauseKey bool
+
+	// PresetName is the name of a filter preset to load from Presets when the
+	// tailer starts. Filters set on the config take precedence.
+	PresetName string
+
+	// Presets stores the filter presets
+	Presets PresetStore
+
+	// PrintReady prints a {"ready": true} line to Stdout once the tailer is
+	// ready, like OnReady, for scripts parsing the NDJSON or JSON output
+	// formats
+	PrintReady bool
+
+	// QueuePolicy is the request log dropped when cfg.QueueSize is reached:
+	// drop-oldest (the default) drops the oldest queued one, and drop-newest
+	// the one received. The server errors (5xx status codes) are spared
+	// unless all of them are.
+	QueuePolicy string
+
+	// QueueSize bounds the queue of the request logs received by the
+	// websocket clients waiting to be processed, to shed load predictably
+	// when they can't keep up. They're processed in order by cfg.Workers
+	// goroutines, or one. The dropped request logs are counted in the
+	// summary and the metrics.
+	QueueSize int
+
+	// Quiet only prints the request logs and the errors, without the spinner,
+	// the ready message, notices and warnings. It also applies to the
+	// messages logged by the websocket client, e.g. when reconnecting.
+	Quiet bool
+
+	// RecordFile is the path to a file every raw request log matching the
+	// filters is appended to, with the time it was received, regardless of
+	// the output format. The file can be replayed later.
+	RecordFile string
+
+	// RecordFiltered also records the request logs hidden by the filters to
+	// RecordFile
+	RecordFiltered bool
+
+	// RedeliveryWindow is the number of most recently received request log
+	// IDs remembered to drop the request logs Stripe redelivers, e.g. after
+	// a websocket reconnect. The dropped request logs are counted as
+	// duplicates in the summary and the metrics. Defaults to 1000, and
+	// negative disables it to get every request log received.
+	RedeliveryWindow int
+
+	// RelativeTimestamps displays how long ago request logs were created in
+	// the default output format, e.g. `3s ago`, instead of their creation
+	// time. Request logs older than an hour are displayed with their
+	// creation time.
+	RelativeTimestamps bool
+
+	// ReplayNoDelay replays the request logs as fast as possible with
+	// Replay, rather than with the time elapsed between them when they were
+	// recorded
+	ReplayNoDelay bool
+
+	// RequireConnectedAccount only displays request logs made on behalf of a
+	// connected account, whichever the account is
+	RequireConnectedAccount bool
+
+	// RunDuration stops Run that long after it's ready to receive request
+	// logs, like an interrupt does. It doesn't include the time spent
+	// authenticating with Stripe. Run doesn't stop by itself when zero.
+	RunDuration time.Duration
+
+	// SampleRate only displays one in every SampleRate request logs matching
+	// the filters, to keep up with high traffic. Server errors are always
+	// displayed. No sampling is done when 0 or 1.
+	SampleRate uint
+
+	// ShowLogID appends the `resp_` ID of request logs to the default output
+	// format. The NDJSON and logfmt output formats always include it.
+	ShowLogID bool
+
+	// ShowSession prints the websocket ID and endpoint of the session
+	// authorized by Stripe when the tailer is ready, for debugging. The
+	// secret of the session is never printed.
+	ShowSession bool
+
+	// Sinks are additional destinations of the request logs, written to
+	// after the built-in ones (the terminal, cfg.OutputFile, cfg.ForwardURL,
+	// cfg.SyslogAddress, cfg.OTLPEndpoint and cfg.StatsdAddr). A failing
+	// sink doesn't affect the others.
+	Sinks []Sink
+
+	// SortJSONKeys sorts the keys of the objects of the JSON output format
+	// alphabetically at every nesting level, so that payloads are easier to
+	// compare. Arrays keep their order.
+	SortJSONKeys bool
+
+	// StatsdAddr is the address of a statsd server, e.g. localhost:8125.
+	// The request logs passing the filters are counted as
+	// stripe.requestlogs.count, tagged with their status class and method,
+	// and the websocket reconnects are reported as the
+	// stripe.requestlogs.reconnects gauge.
+	StatsdAddr string
+
+	// StatsdTagStyle is how the tags are sent to StatsdAddr: "datadog" for
+	// DogStatsD tags, or "plain" to append their values to the metric names
+	// for servers that don't support tags. Defaults to "datadog".
+	StatsdTagStyle string
+
+	// Stderr is where everything that isn't a request log is printed, such
+	// as the spinner and notices, so that the request logs can be piped to
+	// other programs. Defaults to os.Stderr.
+	Stderr io.Writer
+
+	// Stdin is where the keypresses of PauseKey are read. Defaults to
+	// os.Stdin.
+	Stdin io.Reader
+
+	// Stdout is where the request logs are printed. Defaults to os.Stdout.
+	Stdout io.Writer
+
+	// Strict fails when one of Keys can't be authorized. By default, the
+	// error is reported and the other keys are still tailed.
+	Strict bool
+
+	// SyslogAddress is the address of a syslog server the request logs are
+	// mirrored to, e.g. localhost:514. Request logs are sent at the err
+	// severity for 5xx status codes, warning for 4xx and info otherwise.
+	SyslogAddress string
+
+	// SyslogNetwork is the network used to connect to SyslogAddress (tcp,
+	// udp, unix or unixgram). Defaults to udp.
+	SyslogNetwork string
+
+	// Theme are the colors of the status codes and JSON payloads. Defaults to
+	// ansi.DefaultTheme.
+	Theme *ansi.Theme
+
+	// TimestampFormat is the format of the creation time of request logs in
+	// the default and logfmt output formats: either a Go layout of the
+	// reference time (e.g. 15:04:05.000) or unix/unixms for the number of
+	// seconds/milliseconds since the Unix epoch
+	TimestampFormat string
+
+	// Timezone is the time zone the creation time of request logs is
+	// displayed in: local (the default), utc or an IANA time zone name such
+	// as America/New_York
+	Timezone string
+
 	// WebSocketFeature is the feature specified for the websocket connection
 	WebSocketFeature string
+
+	// Wide appends the API version, source, IP address and error code of
+	// request logs to the default output format, `-` standing for the
+	// missing ones
+	Wide bool
+
+	// Workers is the number of goroutines parsing, filtering and rendering
+	// the request logs received by the websocket clients in parallel. The
+	// request logs are still written to the outputs in the order they're
+	// received. When zero, each request log is processed on its own
+	// goroutine, and they may be written out of order.
+	Workers int
 }
 
 // Tailer is the main interface for running the log tailing session
 type Tailer struct {
 	cfg *Config
 
-	stripeAuthClient *stripeauth.Client
-	webSocketClient  *websocket.Client
+	// streams are the connections tailing the request logs of each key
+	streams []*stream
 
 	interruptCh chan os.Signal
+
+	// stopCh is closed by Stop, once
+	stopCh   chan struct{}
+	stopOnce sync.Once
+
+	// closeOutputsOnce closes the outputs once, when they're drained or
+	// when drain gives up
+	closeOutputsOnce sync.Once
+
+	// now returns the current time, and after waits for a duration. They
+	// are replaced in tests.
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+
+	// terminalWidth returns the width of the terminal cfg.Stdout is
+	// attached to, and supportsUnicode whether the locale uses UTF-8. They
+	// are replaced in tests.
+	terminalWidth   func() (int, bool)
+	supportsUnicode func() bool
+
+	// cbreak puts the terminal of cfg.Stdin into cbreak mode and returns a
+	// function restoring it. It's replaced in tests.
+	cbreak func(stdin io.Reader) (func() error, error)
+
+	// expression is the compiled version of cfg.FilterExpression
+	expression *filter.Expression
+
+	// ipNets are the parsed networks of cfg.FilterIPAddress, by filter value
+	ipNets map[string]*net.IPNet
+
+	// urlRegexp is the compiled version of cfg.FilterURLRegex
+	urlRegexp *regexp.Regexp
+
+	// location is the loaded version of cfg.Timezone, and timestampWarning
+	// makes sure invalid creation times are only reported once
+	location         *time.Location
+	timestampWarning sync.Once
+
+	// outputTemplate is the parsed version of cfg.OutputTemplate, and
+	// templateWarning makes sure template errors are only reported once
+	outputTemplate  *template.Template
+	templateWarning sync.Once
+
+	// filtersMu guards the local filters of cfg and their compiled versions
+	// below, which can be swapped by reloadFilters while request logs are
+	// being processed
+	filtersMu sync.RWMutex
+
+	// baseFilters are the local filters set on the config before
+	// cfg.FiltersFile was loaded
+	baseFilters FilterPreset
+
+	// dedupe tracks the recently seen request IDs when cfg.DedupeWindow is set
+	dedupe *dedupeCache
+
+	// notifications shows the desktop notifications of cfg.NotifyOn, when
+	// it's set
+	notifications *desktopNotifications
+
+	// idleResetCh resets the idle timer when cfg.IdleAfter is set
+	idleResetCh chan struct{}
+
+	// redeliveries remembers the recently received request log IDs, unless
+	// cfg.RedeliveryWindow is negative
+	redeliveries *redeliveryRing
+
+	// warnings rate-limits the warnings logged for each request log
+	warnings *warningLimiter
+
+	// process passes the request logs through the middlewares of the
+	// pipeline, then writes them to the outputs
+	process EventFunc
+
+	// pool processes the request logs in parallel when cfg.Workers or
+	// cfg.QueueSize is set
+	pool *workerPool
+
+	// sessions are the sessions authorized by Stripe, which Session reads
+	// from other goroutines
+	sessionsMu sync.RWMutex
+	sessions   []Session
+
+	// events is the channel returned by Events when cfg.EmitEvents is set
+	events *eventStream
+
+	// eventCount is the number of request logs counted against
+	// cfg.EventLimit. It must be accessed atomically.
+	eventCount int64
+
+	// failOn tracks the request logs matching cfg.FailOn, when it's set
+	failOn *failOnTracker
+
+	// outputMu serializes the output of request logs, and guards dedupe,
+	// lastRequestID and lastBell
+	outputMu sync.Mutex
+
+	// stdout is where the request logs are printed: cfg.Stdout, buffered by
+	// stdoutBuffer while Run is running if cfg.Stdout isn't a terminal. They
+	// are guarded by outputMu.
+	stdout       io.Writer
+	stdoutBuffer *bufio.Writer
+
+	// lastRequestID is the request ID of the last printed line, if it's a
+	// request log
+	lastRequestID string
+
+	// lastBell is when the bell of cfg.Bell was last rung
+	lastBell time.Time
+
+	// outputFile is the opened cfg.OutputFile, and outputFileEmpty is true if
+	// it was empty when it was opened. They're guarded by outputMu.
+	outputFile        io.WriteCloser
+	outputFileEmpty   bool
+	outputFileWarning sync.Once
+
+	// sinks are the destinations of the request logs: the terminal, then the
+	// optional sinks added by Run
+	sinks *multiSink
+
+	// stats accumulates the statistics of the summary, while Run is running
+	stats *sessionStats
+
+	// live tracks the stats of the live stats line, when it's shown
+	live *liveStats
+
+	// pendingConnects is the number of websocket clients that haven't
+	// connected yet, and connectedCh is closed once they all have.
+	// pendingConnects must be accessed atomically.
+	pendingConnects int64
+	connectedCh     chan struct{}
+
+	// ready is 1 once the tailer was ready. It must be accessed atomically.
+	ready int32
+
+	// stopped is 1 once Run returned, and must be accessed atomically.
+	// received tracks the request logs received, for Status.
+	stopped  int32
+	received eventTracker
+
+	// handlers tracks the request logs being handled for the websocket
+	// clients, for shutdown to wait for them
+	handlers handlerGroup
+
+	// writer writes the request logs to the sinks while Run is running
+	writer *eventWriter
+
+	// forwarder POSTs the request logs to cfg.ForwardURL, if set
+	forwarder *forwarder
+
+	// syslog mirrors the request logs to cfg.SyslogAddress, if set
+	syslog *syslogWriter
+
+	// otlp exports the request logs to cfg.OTLPEndpoint, if set
+	otlp *otlpExporter
+
+	// recorder records the raw request logs to cfg.RecordFile, if set
+	recorder *recorder
+
+	// statsd sends metrics to cfg.StatsdAddr, if set
+	statsd *statsdClient
+
+	// metrics are the counters exposed on cfg.MetricsAddr, if set
+	metrics       *tailMetrics
+	metricsServer *metricsServer
+
+	// excludedCount is the number of request logs hidden by the exclusion
+	// filters since the last report. It must be accessed atomically.
+	excludedCount uint64
+
+	// sampledCount is the number of request logs subject to sampling, and
+	// suppressedCount the number of them suppressed since the last report.
+	// They must be accessed atomically.
+	sampledCount    uint64
+	suppressedCount uint64
 }
 
 // EventPayload is the mapping for fields in event payloads from request log tailing
 type EventPayload struct {
-	CreatedAt int    `json:"created_at"`
-	Method    string `json:"method"`
-	RequestID string `json:"request_id"`
-	Status    int    `json:"status"`
-	URL       string `json:"url"`
+	Account        string       `json:"account"`
+	APIVersion     string       `json:"api_version"`
+	CreatedAt      int          `json:"created_at"`
+	Error          ErrorPayload `json:"error"`
+	IdempotencyKey string       `json:"idempotency_key"`
+	IPAddress      string       `json:"ip_address"`
+	Latency        *int         `json:"latency"`
+	Livemode       *bool        `json:"livemode"`
+	Method         string       `json:"method"`
+	RequestID      string       `json:"request_id"`
+	Source         string       `json:"source"`
+	Status         int          `json:"status"`
+	URL            string       `json:"url"`
+	UserAgent      string       `json:"user_agent"`
+}
+
+// ErrorPayload is the mapping for the error of failed requests in event
+// payloads from request log tailing
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param"`
+	Type    string `json:"type"`
+}
+
+// ConnectedAccount returns the connected account the request was made on
+// behalf of, and false if it wasn't made on behalf of a connected account.
+// An empty account is treated the same as a missing one.
+func (payload *EventPayload) ConnectedAccount() (string, bool) {
+	account := strings.TrimSpace(payload.Account)
+	return account, account != ""
+}
+
+// CreatedAtTime returns the creation time of the request log, and false if
+// the payload doesn't carry a usable creation time.
+func (payload *EventPayload) CreatedAtTime() (time.Time, bool) {
+	if payload.CreatedAt <= 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(payload.CreatedAt), 0), true
+}
+
+// LatencyDuration returns how long Stripe took to serve the request, and
+// false if the payload doesn't include it. The latency is sent in
+// milliseconds.
+func (payload *EventPayload) LatencyDuration() (time.Duration, bool) {
+	if payload.Latency == nil {
+		return 0, false
+	}
+
+	return time.Duration(*payload.Latency) * time.Millisecond, true
 }
 
 // New creates a new Tailer
@@ -81,126 +797,546 @@ func New(cfg *Config) *Tailer {
 	if cfg.Log == nil {
 		cfg.Log = &log.Logger{Out: ioutil.Discard}
 	}
-	return &Tailer{
-		cfg: cfg,
-		stripeAuthClient: stripeauth.NewClient(cfg.Key, &stripeauth.Config{
-			Log:        cfg.Log,
-			APIBaseURL: cfg.APIBaseURL,
-		}),
+	if cfg.Quiet {
+		cfg.Log = quietLogger(cfg.Log)
+	}
+	if cfg.Theme == nil {
+		cfg.Theme = ansi.DefaultTheme
+	}
+	if cfg.Stdout == nil {
+		cfg.Stdout = os.Stdout
+	}
+	if cfg.Stderr == nil {
+		cfg.Stderr = os.Stderr
+	}
+	if cfg.Stdin == nil {
+		cfg.Stdin = os.Stdin
+	}
+	tailer := &Tailer{
+		cfg:         cfg,
+		streams:     newStreams(cfg),
 		interruptCh: make(chan os.Signal, 1),
+		stopCh:      make(chan struct{}),
+		stdout:      cfg.Stdout,
+		now:         time.Now,
+		after:       time.After,
+		terminalWidth: func() (int, bool) {
+			return ansi.TerminalWidth(cfg.Stdout)
+		},
+		supportsUnicode: ansi.SupportsUnicode,
+		cbreak:          cbreakTerminal,
+		warnings:        newWarningLimiter(warningInterval),
+	}
+	tailer.process = tailer.pipeline()
+	tailer.sinks = newMultiSink(cfg.Log)
+	if !cfg.NoStdout {
+		tailer.sinks.addDisplay("stdout", stdoutSink{tailer})
+	}
+	if cfg.EmitEvents {
+		tailer.events = newEventStream(eventsBufferSize)
+	}
+	if cfg.FailOn != "" {
+		tailer.failOn = newFailOnTracker(cfg.FailOn)
+	}
+	if cfg.DedupeWindow > 0 {
+		tailer.dedupe = newDedupeCache(cfg.DedupeWindow, dedupeCacheSize)
+	}
+	if cfg.IdleAfter > 0 {
+		tailer.idleResetCh = make(chan struct{}, 1)
+	}
+	switch {
+	case cfg.RedeliveryWindow > 0:
+		tailer.redeliveries = newRedeliveryRing(cfg.RedeliveryWindow)
+	case cfg.RedeliveryWindow == 0:
+		tailer.redeliveries = newRedeliveryRing(redeliveryWindowSize)
+	}
+	return tailer
+}
+
+// prepare loads the preset and the filters file, validates the config and
+// compiles what it needs to render the request logs. The fields used to
+// connect to Stripe are only validated when connecting.
+func (tailer *Tailer) prepare(connecting bool) error {
+	if connecting {
+		if err := tailer.cfg.validateConnection(); err != nil {
+			return err
+		}
+	}
+
+	if tailer.cfg.PresetName != "" {
+		if err := tailer.cfg.loadPreset(); err != nil {
+			return err
+		}
+	}
+
+	if tailer.cfg.FiltersFile != "" {
+		if err := tailer.loadFiltersFile(); err != nil {
+			return err
+		}
+	}
+
+	if err := tailer.cfg.validateOptions(); err != nil {
+		return err
+	}
+
+	if err := tailer.compileFilters(); err != nil {
+		return err
+	}
+
+	if err := tailer.parseOutputTemplate(); err != nil {
+		return err
+	}
+
+	if tailer.cfg.NotifyOn != "" {
+		notifications, err := newDesktopNotifications(tailer.cfg.NotifyOn, tailer.cfg.NotifyInterval, newDesktopNotifier())
+		if err != nil {
+			return err
+		}
+		tailer.notifications = notifications
 	}
+
+	location, err := loadTimezone(tailer.cfg.Timezone)
+	if err != nil {
+		return err
+	}
+	tailer.location = location
+
+	return nil
 }
 
-// Run sets the websocket connection
+// Run sets the websocket connection, and tails the request logs until an
+// interrupt. It's equivalent to RunContext with a background context.
 func (tailer *Tailer) Run() error {
-	s := ansi.StartSpinner("Getting ready...", tailer.cfg.Log.Out)
+	return tailer.RunContext(context.Background())
+}
 
-	// Intercept Ctrl+c so we can do some clean up
-	signal.Notify(tailer.interruptCh, os.Interrupt, syscall.SIGTERM)
+// RunContext sets the websocket connection, and tails the request logs until
+// an interrupt, a call to Stop, the context is cancelled, cfg.EventLimit or
+// cfg.RunDuration is reached. They all stop the websocket clients, write the
+// queued request logs and close the outputs before returning nil, or a
+// *FailOnError if request logs matching cfg.FailOn were received. An error is
+// returned if the config is invalid or the session can't be initiated with
+// Stripe, and a *websocket.ReconnectError if a websocket client couldn't
+// connect after cfg.MaxReconnectAttempts attempts.
+func (tailer *Tailer) RunContext(ctx context.Context) error {
+	defer atomic.StoreInt32(&tailer.stopped, 1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-tailer.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := tailer.prepare(true)
+	if err != nil {
+		return err
+	}
 
 	filters, err := jsonifyFilters(tailer.cfg.Filters)
 	if err != nil {
-		tailer.cfg.Log.Fatalf("Error while converting log filters to JSON encoding: %v", err)
+		return fmt.Errorf("error while converting log filters to JSON encoding: %v", err)
+	}
+
+	if err := tailer.openOutputFile(); err != nil {
+		return err
+	}
+	defer tailer.closeOutputFile()
+
+	// The outputs started below are closed by shutdown, or here if one of
+	// them can't be set up
+	defer tailer.closeOutputs()
+	if tailer.outputFile != nil {
+		tailer.sinks.addDisplay("file", fileSink{tailer})
+	}
+
+	if tailer.cfg.ForwardURL != "" {
+		tailer.forwarder = newForwarder(tailer.cfg.ForwardURL, tailer.cfg.ForwardHeaders, tailer.cfg.Log)
+		tailer.forwarder.start()
+		tailer.sinks.add("forward", forwardSink{tailer.forwarder})
+	}
+
+	if tailer.cfg.SyslogAddress != "" {
+		tailer.syslog = newSyslogWriter(tailer.cfg.SyslogNetwork, tailer.cfg.SyslogAddress, tailer.cfg.Log)
+		tailer.syslog.start()
+		defer tailer.syslog.close()
+		tailer.sinks.add("syslog", syslogSink{writer: tailer.syslog, ndjson: tailer.cfg.OutputFormat == outputFormatJSON})
+	}
+
+	if tailer.cfg.OTLPEndpoint != "" {
+		tailer.otlp = newOTLPExporter(newOTLPHTTPCollector(tailer.cfg.OTLPEndpoint), tailer.cfg.Log)
+		tailer.otlp.start()
+		tailer.sinks.add("otlp", tailer.otlp)
+	}
+
+	if tailer.cfg.RecordFile != "" {
+		tailer.recorder, err = newRecorder(tailer.cfg.RecordFile, tailer.cfg.Log)
+		if err != nil {
+			return fmt.Errorf("the record file %s can't be opened: %v", tailer.cfg.RecordFile, err)
+		}
+		tailer.recorder.start()
+	}
+
+	if tailer.cfg.StatsdAddr != "" {
+		tailer.statsd, err = newStatsdClient(tailer.cfg.StatsdAddr, tailer.cfg.StatsdTagStyle)
+		if err != nil {
+			return err
+		}
+		defer tailer.statsd.close()
+		tailer.sinks.add("statsd", statsdSink{tailer.statsd})
+	}
+
+	for i, sink := range tailer.cfg.Sinks {
+		tailer.sinks.add(configSinkName(i), sink)
+	}
+	defer tailer.logSinkErrors()
+
+	if tailer.cfg.MetricsAddr != "" {
+		tailer.metrics = newTailMetrics(tailer.dropped)
+		tailer.metricsServer, err = listenMetrics(tailer.cfg.MetricsAddr, tailer.metrics, tailer.StatusHandler())
+		if err != nil {
+			return err
+		}
+	}
+
+	var s *spinner.Spinner
+	if !tailer.cfg.Quiet {
+		s = tailer.cfg.colorMode().StartSpinner("Getting ready...", tailer.cfg.Stderr)
+	}
+
+	// Intercept Ctrl+c so we can do some clean up
+	signal.Notify(tailer.interruptCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(tailer.interruptCh)
+
+	// SIGHUP reopens the output file and reloads the filters file. Windows
+	// never sends it.
+	if tailer.cfg.OutputFile != "" || tailer.cfg.FiltersFile != "" {
+		signal.Notify(tailer.interruptCh, syscall.SIGHUP)
+	}
+
+	// SIGUSR1 prints the stats of the session so far, where it's supported
+	if statsSignal != nil {
+		signal.Notify(tailer.interruptCh, statsSignal)
 	}
 
-	session, err := tailer.stripeAuthClient.Authorize(tailer.cfg.DeviceName, tailer.cfg.WebSocketFeature, &filters)
+	stopFlushCh := make(chan struct{})
+	tailer.bufferStdout(stopFlushCh)
+
+	sessions, failures, err := tailer.authorizeStreams(ctx, &filters, s)
 	if err != nil {
-		tailer.cfg.Log.Fatalf("Error while authenticating with Stripe: %v", err)
-	}
-
-	tailer.webSocketClient = websocket.NewClient(
-		session.WebSocketURL,
-		session.WebSocketID,
-		session.WebSocketAuthorizedFeature,
-		&websocket.Config{
-			EventHandler:      websocket.EventHandlerFunc(tailer.processRequestLogEvent),
-			Log:               tailer.cfg.Log,
-			NoWSS:             tailer.cfg.NoWSS,
-			ReconnectInterval: time.Duration(session.ReconnectDelay) * time.Second,
-		},
-	)
-	go tailer.webSocketClient.Run()
+		if s != nil {
+			s.Stop()
+		}
+		tailer.shutdown(stopFlushCh)
 
-	ansi.StopSpinner(s, "Ready! You're now waiting to receive API request logs (^C to quit)", tailer.cfg.Log.Out)
+		if err == errStopped {
+			return nil
+		}
+		return err
+	}
+
+	tailer.setSessions(sessions)
 
-	if session.DisplayConnectFilterWarning {
-		color := ansi.Color(os.Stdout)
-		fmt.Println(fmt.Sprintf("%s you specified the 'account' filter for connect accounts but are not a connect merchant, so the filter will not be applied.", color.Yellow("Warning")))
+	// The websocket clients submit the request logs to the pool in the
+	// order they're received, so it must be started before
+	if tailer.cfg.Workers > 0 || tailer.cfg.QueueSize > 0 {
+		tailer.startPool()
 	}
 
-	// Block until Ctrl+C is received
-	<-tailer.interruptCh
+	displayConnectFilterWarning := false
+	for i, session := range sessions {
+		if session != nil {
+			tailer.streams[i].client = tailer.newWebSocketClient(tailer.streams[i], session)
+			displayConnectFilterWarning = displayConnectFilterWarning || session.DisplayConnectFilterWarning
+		}
+	}
+
+	if tailer.cfg.OutputFormat == outputFormatCSV && tailer.cfg.printsOutput() {
+		tailer.printHeader(csvLine(tailer.csvHeader()))
+	}
+
+	// The stats are also printed on demand with a signal, so they're
+	// accumulated even without the summary
+	tailer.stats = newSessionStats(tailer.now())
+
+	tailer.writer = newEventWriter(tailer.sinks, eventWriterQueueSize)
+	if tailer.cfg.LiveStats && tailer.cfg.printsOutput() && ansi.IsInteractive(tailer.cfg.Stdout) {
+		tailer.live = newLiveStats(tailer.now())
+		tailer.writer.tick(liveStatsInterval, tailer.tickLiveStats)
+	}
+	tailer.writer.start()
+
+	// The terminal is restored even if Run panics, as the keypresses
+	// wouldn't be echoed anymore otherwise
+	watchingKeys := false
+	if tailer.cfg.PauseKey {
+		var restoreTerminal func()
+		restoreTerminal, watchingKeys = tailer.watchKeys()
+		defer restoreTerminal()
+	}
+
+	// The metrics read the dropped request logs from the writer, so they
+	// can't be served before it's created
+	if tailer.metricsServer != nil {
+		tailer.metricsServer.serve()
+	}
+
+	clients := 0
+	for _, st := range tailer.streams {
+		if st.client != nil {
+			clients++
+		}
+	}
+	tailer.expectConnects(clients)
+
+	for _, st := range tailer.streams {
+		if st.client != nil {
+			go st.client.Run()
+		}
+	}
+
+	stopReportCh := make(chan struct{})
+	defer close(stopReportCh)
+	go tailer.reportExcludedEvents(stopReportCh)
+	go tailer.reportSuppressedEvents(stopReportCh)
+	go tailer.reportRepeatedEvents(stopReportCh)
+	go tailer.reportSuppressedWarnings(stopReportCh)
+	go tailer.watchIdle(stopReportCh)
+
+	if !tailer.cfg.Quiet {
+		controls := "^C to quit"
+		if watchingKeys {
+			controls = "space to pause, ^C to quit"
+		}
+		tailer.cfg.colorMode().StopSpinner(s, fmt.Sprintf("Ready! You're now waiting to receive API request logs (%s)", controls), tailer.cfg.Stderr)
+	}
+	tailer.printSessions()
+
+	for _, failure := range failures {
+		tailer.cfg.Log.Warnf("%v, its request logs won't be tailed", failure)
+	}
+
+	go tailer.awaitReady(stopReportCh)
+
+	if displayConnectFilterWarning && !tailer.cfg.Quiet {
+		color := tailer.cfg.colorMode().Color(tailer.cfg.Stderr)
+		fmt.Fprintln(tailer.cfg.Stderr, fmt.Sprintf("%s you specified the 'account' filter for connect accounts but are not a connect merchant, so the filter will not be applied.", color.Yellow("Warning")))
+	}
+
+	// The run duration starts once the tailer is ready
+	var deadline <-chan time.Time
+	if tailer.cfg.RunDuration > 0 {
+		deadline = tailer.after(tailer.cfg.RunDuration)
+	}
+
+	clientErr := tailer.wait(ctx, deadline, tailer.clientErrors(stopReportCh))
 
 	log.WithFields(log.Fields{
 		"prefix": "logs.Tailer.Run",
-	}).Debug("Ctrl+C received, cleaning up...")
+	}).Debug("Stopping, cleaning up...")
 
-	if tailer.webSocketClient != nil {
-		tailer.webSocketClient.Stop()
+	for _, st := range tailer.streams {
+		if st.client != nil {
+			st.client.Stop()
+		}
 	}
 
+	tailer.shutdown(stopFlushCh)
+
 	log.WithFields(log.Fields{
 		"prefix": "logs.Tailer.Run",
 	}).Debug("Bye!")
 
-	return nil
+	if clientErr != nil {
+		return clientErr
+	}
+
+	return tailer.failOnError()
 }
 
-func (tailer *Tailer) processRequestLogEvent(msg websocket.IncomingMessage) {
-	if msg.RequestLogEvent == nil {
-		tailer.cfg.Log.Warn("WebSocket specified for request logs received non-request-logs event")
-		return
-	}
+// Stop stops a running tailer like an interrupt does, and makes Run return.
+// It can be called from any goroutine, several times, and before Run has
+// connected, in which case Run returns without connecting.
+func (tailer *Tailer) Stop() {
+	tailer.stopOnce.Do(func() {
+		close(tailer.stopCh)
+	})
+}
+
+// wait blocks until Ctrl+C is received, the context is cancelled, the
+// deadline, if any, is reached or a websocket client gives up connecting,
+// handling SIGHUP with hangUp and printing the stats of the session on
+// SIGUSR1. It returns the error the websocket client gave up with, if any.
+func (tailer *Tailer) wait(ctx context.Context, deadline <-chan time.Time, clientErrors <-chan error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-deadline:
+			tailer.cfg.Log.WithFields(log.Fields{
+				"prefix":   "logs.Tailer.wait",
+				"duration": tailer.cfg.RunDuration,
+			}).Debug("Reached the run duration")
+			return nil
+		case err := <-clientErrors:
+			return err
+		case sig := <-tailer.interruptCh:
+			if statsSignal != nil && sig == statsSignal {
+				tailer.printStats()
+				continue
+			}
+			if sig != syscall.SIGHUP {
+				return nil
+			}
+		}
 
-	requestLogEvent := msg.RequestLogEvent
+		tailer.hangUp()
+	}
+}
 
-	tailer.cfg.Log.WithFields(log.Fields{
-		"prefix":     "logs.Tailer.processRequestLogEvent",
-		"webhook_id": requestLogEvent.RequestLogID,
-	}).Debugf("Processing request log event")
+// hangUp reopens the output file, e.g. after logrotate renamed it, and
+// reloads the filters file on SIGHUP
+func (tailer *Tailer) hangUp() {
+	color := tailer.cfg.colorMode().Color(tailer.cfg.Stderr)
 
-	var payload EventPayload
-	if err := json.Unmarshal([]byte(requestLogEvent.EventPayload), &payload); err != nil {
-		tailer.cfg.Log.Warn("Received malformed payload: ", err)
+	if tailer.cfg.OutputFile != "" {
+		if err := tailer.reopenOutputFile(); err != nil {
+			fmt.Fprintln(tailer.cfg.Stderr, fmt.Sprintf("%s %v", color.Yellow("Warning"), err))
+		}
 	}
 
-	// Don't show stripecli/sessions logs since they're generated by the CLI
-	if payload.URL == "/v1/stripecli/sessions" {
-		tailer.cfg.Log.Debug("Filtering out /v1/stripecli/sessions from logs")
+	if tailer.cfg.FiltersFile == "" {
 		return
 	}
 
-	if tailer.cfg.OutputFormat == outputFormatJSON {
-		fmt.Println(ansi.ColorizeJSON(requestLogEvent.EventPayload, os.Stdout))
+	if err := tailer.reloadFilters(); err != nil {
+		fmt.Fprintln(tailer.cfg.Stderr, fmt.Sprintf("%s couldn't reload the filters, keeping the current ones: %v", color.Yellow("Warning"), err))
 		return
 	}
 
-	coloredStatus := colorizeStatus(payload.Status)
+	if !tailer.cfg.Quiet {
+		fmt.Fprintln(tailer.cfg.Stderr, fmt.Sprintf("Reloaded the filters from %s", tailer.cfg.FiltersFile))
+	}
+}
 
-	url := fmt.Sprintf("https://dashboard.stripe.com/test/logs/%s", payload.RequestID)
-	requestLink := ansi.Linkify(payload.RequestID, url, os.Stdout)
+// shutdown waits for the request logs to be written, then closes the outputs
+// and prints the summary of the session. The websocket clients must be
+// stopped first, so that no new request log comes in.
+func (tailer *Tailer) shutdown(stopFlushCh chan struct{}) {
+	tailer.drain()
 
-	if payload.URL == "" {
-		payload.URL = "[View path in dashboard]"
+	if tailer.live != nil {
+		tailer.outputMu.Lock()
+		tailer.clearLiveStats()
+		tailer.live = nil
+		tailer.outputMu.Unlock()
 	}
 
-	exampleLayout := "2006-01-02 15:04:05"
-	localTime := time.Unix(int64(payload.CreatedAt), 0).Format(exampleLayout)
+	if tailer.events != nil {
+		if dropped := tailer.events.close(); dropped > 0 {
+			tailer.cfg.Log.Warnf("%d request logs were dropped because the events channel was full", dropped)
+		}
+	}
 
-	outputStr := fmt.Sprintf("%s [%d] %s %s %s", localTime, coloredStatus, payload.Method, payload.URL, requestLink)
-	fmt.Println(outputStr)
+	if tailer.metricsServer != nil {
+		if err := tailer.metricsServer.close(); err != nil {
+			tailer.cfg.Log.WithFields(log.Fields{
+				"prefix": "logs.Tailer.shutdown",
+			}).Debugf("Could not shut down the metrics listener: %v", err)
+		}
+	}
+
+	if tailer.dedupe != nil {
+		// Report the pending repeats without waiting for their window to elapse
+		tailer.flushRepeatedEvents(tailer.now().Add(tailer.cfg.DedupeWindow))
+	}
+
+	// Nothing is printed past this point, so make sure that the request logs
+	// received before stopping aren't lost
+	close(stopFlushCh)
+	tailer.flushStdout()
+
+	if tailer.stats != nil && !tailer.cfg.NoSummary && !tailer.cfg.Quiet {
+		tailer.stats.write(tailer.cfg.Stderr, tailer.now())
+	}
 }
 
-func colorizeStatus(status int) aurora.Value {
-	color := ansi.Color(os.Stdout)
+func (tailer *Tailer) processRequestLogEvent(msg websocket.IncomingMessage) {
+	tailer.processStreamEvent(nil, msg)
+}
 
-	switch {
-	case status >= 500:
-		return color.Red(status).Bold()
-	case status >= 400:
-		return color.Yellow(status).Bold()
-	default:
-		return color.Green(status).Bold()
+// processStreamEvent passes a request log received by the stream through the
+// pipeline. The stream is nil when replaying request logs.
+func (tailer *Tailer) processStreamEvent(st *stream, msg websocket.IncomingMessage) {
+	tailer.runPipeline(st, msg, false)
+}
+
+// runPipeline passes a request log received by the stream through the
+// pipeline, unless it's a redelivery of a request log the stream received
+// recently. When pooled, the rendered request log is returned instead of
+// being written to the outputs, or nil if it was dropped.
+func (tailer *Tailer) runPipeline(st *stream, msg websocket.IncomingMessage, pooled bool) *RenderedEvent {
+	if msg.RequestLogEvent == nil {
+		tailer.warnLimited(warningNotRequestLog, log.Fields{
+			"prefix": "logs.Tailer.processStreamEvent",
+		}, "WebSocket specified for request logs received non-request-logs event")
+		return nil
+	}
+
+	receivedAt := tailer.now()
+	tailer.received.record(receivedAt)
+	tailer.resetIdle()
+
+	var label string
+	if st != nil {
+		if tailer.redelivered(st, msg) {
+			return nil
+		}
+		label = st.label
+	}
+
+	tailer.filtersMu.RLock()
+	defer tailer.filtersMu.RUnlock()
+
+	event := &PipelineEvent{
+		RequestLogEvent: msg.RequestLogEvent,
+		Label:           label,
+		ReceivedAt:      receivedAt,
+		pooled:          pooled,
+	}
+	tailer.process(event)
+
+	return event.rendered
+}
+
+// countEvent counts a request log against cfg.EventLimit. The request logs
+// dimmed in highlight mode aren't counted. It returns false if the limit was
+// already reached, and whether the request log reaches the limit.
+func (tailer *Tailer) countEvent(hidden bool) (bool, bool) {
+	limit := int64(tailer.cfg.EventLimit)
+
+	if hidden {
+		return atomic.LoadInt64(&tailer.eventCount) < limit, false
+	}
+
+	count := atomic.AddInt64(&tailer.eventCount, 1)
+	return count <= limit, count == limit
+}
+
+// logSinkErrors logs the number of request logs each sink failed to write.
+// The sinks report their own errors as they happen, so this is only a debug
+// summary.
+func (tailer *Tailer) logSinkErrors() {
+	for name, count := range tailer.sinks.errors() {
+		if count > 0 {
+			tailer.cfg.Log.WithFields(log.Fields{
+				"prefix": "logs.Tailer.logSinkErrors",
+				"sink":   name,
+			}).Debugf("%d request logs couldn't be written", count)
+		}
 	}
 }
 
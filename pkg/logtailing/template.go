@@ -0,0 +1,75 @@
+package logtailing
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+// templateFuncs returns the functions available to output templates
+func (tailer *Tailer) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		// color colorizes a status code the same way as the default format
+		"color": func(status int) string {
+			return tailer.colorizeStatus(status).String()
+		},
+		// default returns the fallback if the value is empty
+		"default": func(fallback string, value interface{}) string {
+			if value == nil || fmt.Sprint(value) == "" {
+				return fallback
+			}
+			return fmt.Sprint(value)
+		},
+		// time formats a created_at timestamp like the default format
+		"time": func(createdAt int) string {
+			if createdAt <= 0 {
+				return ""
+			}
+			return tailer.timestampFormat().format(time.Unix(int64(createdAt), 0))
+		},
+	}
+}
+
+// parseOutputTemplate parses cfg.OutputTemplate so that it's only parsed once
+// when the tailer starts.
+func (tailer *Tailer) parseOutputTemplate() error {
+	if tailer.cfg.OutputTemplate == "" {
+		return nil
+	}
+
+	tmpl, err := template.New("output").Funcs(tailer.templateFuncs()).Parse(tailer.cfg.OutputTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid output template: %v", err)
+	}
+
+	tailer.outputTemplate = tmpl
+
+	return nil
+}
+
+// renderRequestLogEvent renders a request log with the output template, or in
+// the default format if there's no template. If the template can't be
+// executed, e.g. because it references a field that doesn't exist, the
+// default format is used instead and a warning is logged once.
+func (tailer *Tailer) renderRequestLogEvent(event *websocket.RequestLogEvent, payload *EventPayload, hl highlight) string {
+	if tailer.outputTemplate == nil {
+		return tailer.formatRequestLogEvent(event, payload, hl)
+	}
+
+	var buf bytes.Buffer
+	if err := tailer.outputTemplate.Execute(&buf, payload); err != nil {
+		tailer.templateWarning.Do(func() {
+			tailer.cfg.Log.WithFields(log.Fields{
+				"prefix": "logs.Tailer.renderRequestLogEvent",
+			}).Warnf("Could not render the output template, using the default format instead: %v", err)
+		})
+		return tailer.formatRequestLogEvent(event, payload, hl)
+	}
+
+	return buf.String()
+}
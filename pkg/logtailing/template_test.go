@@ -0,0 +1,57 @@
+package logtailing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutputTemplate(t *testing.T) {
+	tailer := New(&Config{})
+	require.NoError(t, tailer.parseOutputTemplate())
+	require.Nil(t, tailer.outputTemplate)
+
+	tailer = New(&Config{OutputTemplate: "{{.Status}} {{.Method}}"})
+	require.NoError(t, tailer.parseOutputTemplate())
+	require.NotNil(t, tailer.outputTemplate)
+
+	tailer = New(&Config{OutputTemplate: "{{.Status"})
+	err := tailer.parseOutputTemplate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid output template")
+}
+
+func TestRenderRequestLogEvent(t *testing.T) {
+	defer withColors(false)()
+	payload := &EventPayload{CreatedAt: 1570000000, Method: "POST", RequestID: "req_123", Status: 200, URL: "/v1/charges"}
+
+	tailer := New(&Config{OutputTemplate: "{{color .Status}} {{.Method}} {{.URL}} {{.RequestID}} {{default \"-\" .Account}} {{time .CreatedAt}}"})
+	require.NoError(t, tailer.parseOutputTemplate())
+	require.Equal(t, "200 POST /v1/charges req_123 - "+localTime(1570000000), tailer.renderRequestLogEvent(noEvent, payload, highlightNone))
+
+	// Without a template, the default format is used
+	tailer = New(&Config{})
+	require.Equal(t, tailer.formatRequestLogEvent(noEvent, payload, highlightNone), tailer.renderRequestLogEvent(noEvent, payload, highlightNone))
+}
+
+func TestRenderRequestLogEventColor(t *testing.T) {
+	defer withColors(true)()
+
+	tailer := New(&Config{OutputTemplate: "{{color .Status}}"})
+	require.NoError(t, tailer.parseOutputTemplate())
+	require.Equal(t, "\x1b[1;31m500\x1b[0m", tailer.renderRequestLogEvent(noEvent, &EventPayload{Status: 500}, highlightNone))
+}
+
+func TestRenderRequestLogEventFallback(t *testing.T) {
+	defer withColors(false)()
+	payload := &EventPayload{Method: "GET", RequestID: "req_123", Status: 200, URL: "/v1/balance"}
+
+	tailer := New(&Config{OutputTemplate: "{{.DoesNotExist}}"})
+	require.NoError(t, tailer.parseOutputTemplate())
+	require.Equal(t, tailer.formatRequestLogEvent(noEvent, payload, highlightNone), tailer.renderRequestLogEvent(noEvent, payload, highlightNone))
+
+	// Empty fields render as empty strings
+	tailer = New(&Config{OutputTemplate: "[{{.Account}}] [{{.Error.Code}}]"})
+	require.NoError(t, tailer.parseOutputTemplate())
+	require.Equal(t, "[] []", tailer.renderRequestLogEvent(noEvent, payload, highlightNone))
+}
@@ -0,0 +1,156 @@
+package logtailing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultTimestampLayout is the layout of the creation time of request
+	// logs in the default output format
+	defaultTimestampLayout = "2006-01-02 15:04:05"
+
+	// timestampFormatUnix and timestampFormatUnixMs are the keywords of
+	// cfg.TimestampFormat for the number of seconds and milliseconds since
+	// the Unix epoch
+	timestampFormatUnix   = "unix"
+	timestampFormatUnixMs = "unixms"
+
+	// relativeTimestampMaxAge is the age above which request logs are shown
+	// with their absolute creation time when cfg.RelativeTimestamps is set
+	relativeTimestampMaxAge = time.Hour
+)
+
+// timestampFormat renders the creation time of request logs with a Go layout
+// or one of the Unix keywords.
+type timestampFormat struct {
+	layout   string
+	location *time.Location
+}
+
+func (f timestampFormat) format(t time.Time) string {
+	switch strings.ToLower(f.layout) {
+	case timestampFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case timestampFormatUnixMs:
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	default:
+		return t.In(f.location).Format(f.layout)
+	}
+}
+
+// validateTimestampFormat checks that cfg.TimestampFormat is a keyword or a
+// layout that renders at least part of the time.
+func validateTimestampFormat(layout string) error {
+	switch strings.ToLower(layout) {
+	case "", timestampFormatUnix, timestampFormatUnixMs:
+		return nil
+	}
+
+	formatted := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC).Format(layout)
+	if strings.TrimSpace(formatted) == "" || formatted == layout {
+		return fmt.Errorf("%s is not a valid timestamp format, use unix, unixms or a Go layout of the reference time Mon Jan 2 15:04:05 MST 2006 (e.g. 15:04:05.000)", layout)
+	}
+
+	return nil
+}
+
+// loadTimezone returns the location of cfg.Timezone: the local time zone when
+// empty or `local`, UTC when `utc`, or else an IANA time zone name such as
+// America/New_York.
+func loadTimezone(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case "", "local":
+		return time.Local, nil
+	case "utc":
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a known time zone, use local, utc or an IANA time zone name (e.g. America/New_York)", name)
+	}
+
+	return location, nil
+}
+
+// timeLocation returns the time zone request logs are displayed in.
+func (tailer *Tailer) timeLocation() *time.Location {
+	if tailer.location == nil {
+		return time.Local
+	}
+
+	return tailer.location
+}
+
+// logfmtTimestampFormat is the format of the creation time of request logs in
+// the logfmt output format when cfg.TimestampFormat isn't set
+var logfmtTimestampFormat = timestampFormat{layout: time.RFC3339, location: time.UTC}
+
+// timestampFormat returns the format of the creation time of request logs in
+// the default output format: cfg.TimestampFormat in the time zone of
+// cfg.Timezone.
+func (tailer *Tailer) timestampFormat() timestampFormat {
+	layout := tailer.cfg.TimestampFormat
+	if layout == "" {
+		layout = defaultTimestampLayout
+	}
+
+	return timestampFormat{layout: layout, location: tailer.timeLocation()}
+}
+
+// logfmtTimestampFormat returns the format of the creation time of request
+// logs in the logfmt output format, RFC 3339 in UTC unless
+// cfg.TimestampFormat is set.
+func (tailer *Tailer) logfmtTimestampFormat() timestampFormat {
+	if tailer.cfg.TimestampFormat == "" {
+		return logfmtTimestampFormat
+	}
+
+	return tailer.timestampFormat()
+}
+
+// formatTimestamp returns the creation time of a request log in the format of
+// cfg.TimestampFormat and the time zone of cfg.Timezone. Creation times that
+// can't be read are printed as is, and reported once.
+func (tailer *Tailer) formatTimestamp(payload *EventPayload) string {
+	createdAt, ok := payload.CreatedAtTime()
+	if !ok {
+		tailer.timestampWarning.Do(func() {
+			tailer.cfg.Log.WithFields(log.Fields{
+				"prefix":     "logs.Tailer.formatTimestamp",
+				"created_at": payload.CreatedAt,
+			}).Warn("Received a request log with an invalid creation time, printing it as is")
+		})
+		return strconv.Itoa(payload.CreatedAt)
+	}
+
+	if tailer.cfg.RelativeTimestamps {
+		if relative, ok := relativeTimestamp(tailer.now().Sub(createdAt)); ok {
+			return relative
+		}
+	}
+
+	return tailer.timestampFormat().format(createdAt)
+}
+
+// relativeTimestamp returns how long ago a request log was created given the
+// delta between now and its creation time, e.g. `3s ago`, and false if it's
+// been more than relativeTimestampMaxAge. Request logs that seem to be
+// created in the future because of clock skew are treated as just created.
+func relativeTimestamp(delta time.Duration) (string, bool) {
+	switch {
+	case delta > relativeTimestampMaxAge:
+		return "", false
+	case delta < time.Second:
+		return "just now", true
+	case delta < time.Minute:
+		return fmt.Sprintf("%ds ago", int(delta/time.Second)), true
+	default:
+		return fmt.Sprintf("%dm ago", int(delta/time.Minute)), true
+	}
+}
@@ -0,0 +1,123 @@
+package logtailing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTimezone(t *testing.T) {
+	location, err := loadTimezone("")
+	require.NoError(t, err)
+	require.Equal(t, time.Local, location)
+
+	location, err = loadTimezone("UTC")
+	require.NoError(t, err)
+	require.Equal(t, time.UTC, location)
+
+	location, err = loadTimezone("America/New_York")
+	require.NoError(t, err)
+	require.Equal(t, "America/New_York", location.String())
+
+	_, err = loadTimezone("Mars/Olympus_Mons")
+	require.EqualError(t, err, "Mars/Olympus_Mons is not a known time zone, use local, utc or an IANA time zone name (e.g. America/New_York)")
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	tailer := New(&Config{})
+	tailer.location = time.UTC
+
+	payload := &EventPayload{CreatedAt: 1570000000}
+	require.Equal(t, "2019-10-02 07:06:40", tailer.formatTimestamp(payload))
+
+	location, err := loadTimezone("America/New_York")
+	require.NoError(t, err)
+	tailer.location = location
+	require.Equal(t, "2019-10-02 03:06:40", tailer.formatTimestamp(payload))
+}
+
+func TestFormatTimestampInvalid(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	tailer := New(&Config{Log: logger})
+
+	require.Equal(t, "0", tailer.formatTimestamp(&EventPayload{}))
+	require.Equal(t, "-1", tailer.formatTimestamp(&EventPayload{CreatedAt: -1}))
+
+	require.Len(t, hook.AllEntries(), 1)
+	require.Equal(t, "Received a request log with an invalid creation time, printing it as is", hook.LastEntry().Message)
+}
+
+func TestTimestampFormat(t *testing.T) {
+	createdAt := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+
+	require.Equal(t, "1570000000", timestampFormat{layout: "unix"}.format(createdAt))
+	require.Equal(t, "1570000000000", timestampFormat{layout: "unixms"}.format(createdAt))
+	require.Equal(t, "07:06:40.000", timestampFormat{layout: "15:04:05.000", location: time.UTC}.format(createdAt))
+}
+
+func TestFormatTimestampLayout(t *testing.T) {
+	payload := &EventPayload{CreatedAt: 1570000000}
+
+	tailer := New(&Config{TimestampFormat: "unixms"})
+	require.Equal(t, "1570000000000", tailer.formatTimestamp(payload))
+
+	tailer = New(&Config{TimestampFormat: "15:04:05"})
+	tailer.location = time.UTC
+	require.Equal(t, "07:06:40", tailer.formatTimestamp(payload))
+	require.Equal(t, "time=07:06:40 status=0 method=\"\" url=\"\" request_id=\"\"", logfmtLine(noEvent, payload, tailer.logfmtTimestampFormat()))
+
+	// The logfmt output format defaults to RFC 3339 timestamps
+	tailer = New(&Config{})
+	require.Equal(t, logfmtTimestampFormat, tailer.logfmtTimestampFormat())
+}
+
+func TestValidateTimestampFormat(t *testing.T) {
+	require.NoError(t, validateTimestampFormat(""))
+	require.NoError(t, validateTimestampFormat("unix"))
+	require.NoError(t, validateTimestampFormat("UnixMs"))
+	require.NoError(t, validateTimestampFormat(time.Kitchen))
+
+	require.EqualError(t, validateTimestampFormat("hh:mm:ss"), "hh:mm:ss is not a valid timestamp format, use unix, unixms or a Go layout of the reference time Mon Jan 2 15:04:05 MST 2006 (e.g. 15:04:05.000)")
+	require.Error(t, validateTimestampFormat("  "))
+}
+
+func TestRelativeTimestamp(t *testing.T) {
+	tests := []struct {
+		delta    time.Duration
+		expected string
+	}{
+		{-5 * time.Second, "just now"},
+		{0, "just now"},
+		{999 * time.Millisecond, "just now"},
+		{time.Second, "1s ago"},
+		{59*time.Second + 999*time.Millisecond, "59s ago"},
+		{time.Minute, "1m ago"},
+		{59*time.Minute + 59*time.Second, "59m ago"},
+		{time.Hour, "60m ago"},
+	}
+
+	for _, tt := range tests {
+		relative, ok := relativeTimestamp(tt.delta)
+		require.True(t, ok, tt.delta.String())
+		require.Equal(t, tt.expected, relative, tt.delta.String())
+	}
+
+	_, ok := relativeTimestamp(time.Hour + time.Second)
+	require.False(t, ok)
+}
+
+func TestFormatTimestampRelative(t *testing.T) {
+	tailer := New(&Config{RelativeTimestamps: true})
+	tailer.location = time.UTC
+	tailer.now = func() time.Time { return time.Unix(1570000003, 0) }
+
+	require.Equal(t, "3s ago", tailer.formatTimestamp(&EventPayload{CreatedAt: 1570000000}))
+
+	// Older request logs fall back to the absolute time
+	require.Equal(t, "2019-10-02 05:06:40", tailer.formatTimestamp(&EventPayload{CreatedAt: 1570000000 - 2*60*60}))
+
+	// So do the ones whose creation time can't be read
+	require.Equal(t, "0", tailer.formatTimestamp(&EventPayload{}))
+}
@@ -0,0 +1,458 @@
+package logtailing
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stripe/stripe-cli/pkg/ansi"
+	"github.com/stripe/stripe-cli/pkg/logtailing/filter"
+	"github.com/stripe/stripe-cli/pkg/validators"
+)
+
+// Acceptable values of the filters validated against a fixed set, used to
+// suggest a fix for near misses.
+var (
+	errorTypeValues  = []string{"api_error", "card_error", "idempotency_error", "invalid_request_error"}
+	httpMethodValues = []string{"GET", "POST", "DELETE"}
+	sourceValues     = []string{"API", "DASHBOARD"}
+	statusClassNames = []string{"succeeded", "client-err", "server-err"}
+)
+
+// Validate checks the config so that invalid values are reported before a
+// connection to Stripe is established. The errors name the invalid field and
+// its acceptable values.
+func (cfg *Config) Validate() error {
+	if err := cfg.validateConnection(); err != nil {
+		return err
+	}
+
+	return cfg.validateOptions()
+}
+
+// validateConnection checks the fields used to connect to Stripe, which
+// aren't needed to replay request logs.
+func (cfg *Config) validateConnection() error {
+	if len(cfg.Keys) > 0 {
+		if cfg.Key != "" {
+			return errors.New("the Key and Keys fields can't be combined, Key must be empty when Keys is set")
+		}
+
+		seen := make(map[string]bool, len(cfg.Keys))
+		for i, key := range cfg.Keys {
+			if strings.TrimSpace(key) == "" {
+				return fmt.Errorf("the key %d of the Keys field can't be empty, it must be a Stripe secret or restricted API key (sk_... or rk_...)", i+1)
+			}
+			if seen[key] {
+				return fmt.Errorf("the key %d of the Keys field is a duplicate, each key can only be tailed once", i+1)
+			}
+			seen[key] = true
+		}
+	} else if strings.TrimSpace(cfg.Key) == "" {
+		return errors.New("the Key field can't be empty, it must be a Stripe secret or restricted API key (sk_... or rk_...)")
+	}
+
+	for key, label := range cfg.KeyLabels {
+		if key != cfg.Key && !containsString(cfg.Keys, key) {
+			return errors.New("the KeyLabels field has a label for a key that isn't tailed, its keys must be Key or one of Keys")
+		}
+		if strings.TrimSpace(label) == "" {
+			return errors.New("the KeyLabels field has an empty label, each label must be a name such as an account nickname")
+		}
+	}
+
+	if cfg.Strict && len(cfg.Keys) == 0 {
+		return errors.New("the Strict field can only be set with the Keys field")
+	}
+
+	if cfg.APIBaseURL != "" {
+		if err := validateAPIBaseURL(cfg.APIBaseURL); err != nil {
+			return err
+		}
+	}
+
+	if strings.TrimSpace(cfg.WebSocketFeature) == "" {
+		return fmt.Errorf("the WebSocketFeature field can't be empty, it must be %s to tail the request logs", defaultWebSocketFeature)
+	}
+
+	return nil
+}
+
+// validateOptions checks the output and the client-side filters of the
+// config.
+func (cfg *Config) validateOptions() error {
+	if err := validateOutputFormat(cfg.OutputFormat); err != nil {
+		return err
+	}
+
+	if cfg.PrintReady && cfg.OutputFormat != outputFormatNDJSON && cfg.OutputFormat != outputFormatJSON {
+		return errors.New("the PrintReady field can only be used with the NDJSON and JSON output formats, as the ready line is printed with the request logs")
+	}
+
+	if cfg.KeepOutput && cfg.Handler == nil {
+		return errors.New("the KeepOutput field can only be set with the Handler field")
+	}
+
+	if cfg.LiveStats && cfg.NoStdout {
+		return errors.New("the LiveStats and NoStdout fields can't be combined, as the live stats are printed to Stdout")
+	}
+
+	if cfg.OutputFileMaxSize < 0 || cfg.OutputFileMaxBackups < 0 {
+		return errors.New("the output file maximum size and number of backups can't be negative")
+	}
+
+	if _, err := ansi.ParseColorMode(cfg.ColorMode); err != nil {
+		return err
+	}
+
+	if _, err := loadTimezone(cfg.Timezone); err != nil {
+		return err
+	}
+
+	if cfg.AuthorizeMaxAttempts < 0 {
+		return errors.New("the maximum number of authorization attempts can't be negative")
+	}
+
+	if cfg.MaxReconnectAttempts < 0 {
+		return fmt.Errorf("the MaxReconnectAttempts field (%d) can't be negative, it must be a number of attempts or 0 to retry forever", cfg.MaxReconnectAttempts)
+	}
+
+	if cfg.FailOn != "" && !containsString(failOnValues, cfg.FailOn) {
+		err := fmt.Errorf("the FailOn field (%s) is not acceptable, it must be one of %s", cfg.FailOn, strings.Join(failOnValues, ", "))
+		return withSuggestion(err, cfg.FailOn, failOnValues)
+	}
+
+	if cfg.QueueSize < 0 {
+		return fmt.Errorf("the QueueSize field (%d) can't be negative, it must be a number of request logs or 0 for no queue", cfg.QueueSize)
+	}
+
+	if cfg.QueuePolicy != "" && !containsString(queuePolicies, cfg.QueuePolicy) {
+		err := fmt.Errorf("the QueuePolicy field (%s) is not acceptable, it must be one of %s", cfg.QueuePolicy, strings.Join(queuePolicies, ", "))
+		return withSuggestion(err, cfg.QueuePolicy, queuePolicies)
+	}
+
+	if cfg.QueuePolicy != "" && cfg.QueueSize == 0 {
+		return fmt.Errorf("the QueuePolicy field (%s) requires the QueueSize field, as request logs are only dropped when the queue is full", cfg.QueuePolicy)
+	}
+
+	if cfg.Workers < 0 {
+		return fmt.Errorf("the Workers field (%d) can't be negative, it must be a number of workers or 0 to process each request log on its own goroutine", cfg.Workers)
+	}
+
+	if cfg.DrainTimeout < 0 {
+		return fmt.Errorf("the DrainTimeout field (%s) can't be negative, it must be a duration or 0 for the default of %s", cfg.DrainTimeout, defaultDrainTimeout)
+	}
+
+	if cfg.RunDuration < 0 {
+		return fmt.Errorf("the RunDuration field (%s) can't be negative, it must be a duration or 0 to run until interrupted", cfg.RunDuration)
+	}
+
+	if cfg.IdleAfter < 0 {
+		return fmt.Errorf("the IdleAfter field (%s) can't be negative, it must be a duration or 0 for no idle notice", cfg.IdleAfter)
+	}
+
+	if cfg.NotifyInterval < 0 {
+		return fmt.Errorf("the NotifyInterval field (%s) can't be negative, it must be a duration or 0 for the default of %s", cfg.NotifyInterval, defaultNotifyInterval)
+	}
+
+	if cfg.NotifyInterval > 0 && cfg.NotifyOn == "" {
+		return fmt.Errorf("the NotifyInterval field (%s) requires the NotifyOn field, as no desktop notifications are shown without it", cfg.NotifyInterval)
+	}
+
+	if cfg.EventLimit < 0 {
+		return fmt.Errorf("the EventLimit field (%d) can't be negative, it must be a number of request logs or 0 for no limit", cfg.EventLimit)
+	}
+
+	if err := validateTimestampFormat(cfg.TimestampFormat); err != nil {
+		return err
+	}
+
+	if cfg.ForwardURL != "" {
+		forwardURL, err := url.Parse(cfg.ForwardURL)
+		if err != nil || (forwardURL.Scheme != "http" && forwardURL.Scheme != "https") || forwardURL.Host == "" {
+			return fmt.Errorf("%s is not a valid forward URL, it must be an http:// or https:// URL", cfg.ForwardURL)
+		}
+	} else if len(cfg.ForwardHeaders) > 0 {
+		return errors.New("the forward headers can't be set without a forward URL")
+	}
+
+	if cfg.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.MetricsAddr); err != nil {
+			return fmt.Errorf("%s is not a valid metrics address, it must be a host and a port such as localhost:9090", cfg.MetricsAddr)
+		}
+	}
+
+	if cfg.RecordFiltered && cfg.RecordFile == "" {
+		return errors.New("the filtered request logs can't be recorded without a record file")
+	}
+
+	if cfg.StatsdAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.StatsdAddr); err != nil {
+			return fmt.Errorf("%s is not a valid statsd address, it must be a host and a port such as localhost:8125", cfg.StatsdAddr)
+		}
+	}
+
+	switch cfg.StatsdTagStyle {
+	case "", statsdTagStyleDatadog, statsdTagStylePlain:
+	default:
+		return fmt.Errorf("%s is not a valid statsd tag style, it must be %s or %s", cfg.StatsdTagStyle, statsdTagStyleDatadog, statsdTagStylePlain)
+	}
+	if cfg.StatsdTagStyle != "" && cfg.StatsdAddr == "" {
+		return errors.New("the statsd tag style can't be set without a statsd address")
+	}
+
+	if cfg.OTLPEndpoint != "" {
+		endpoint, err := url.Parse(cfg.OTLPEndpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("%s is not a valid OTLP endpoint, it must be an http:// or https:// URL", cfg.OTLPEndpoint)
+		}
+	}
+
+	if cfg.SyslogNetwork != "" {
+		if cfg.SyslogAddress == "" {
+			return errors.New("the syslog network can't be set without a syslog address")
+		}
+		if !containsString(syslogNetworks, cfg.SyslogNetwork) {
+			return fmt.Errorf("%s is not an acceptable syslog network (%s)", cfg.SyslogNetwork, strings.Join(syslogNetworks, ", "))
+		}
+	}
+
+	if cfg.JSONCompact || cfg.JSONIndent != "" {
+		if cfg.OutputFormat != outputFormatJSON {
+			return errors.New("the JSON compact and indent options can only be used with the JSON output format")
+		}
+		if cfg.JSONCompact && cfg.JSONIndent != "" {
+			return errors.New("the JSON compact and indent options can't be combined")
+		}
+		if strings.Trim(cfg.JSONIndent, " \t") != "" {
+			return fmt.Errorf("%q is not a valid JSON indent, it can only contain spaces and tabs", cfg.JSONIndent)
+		}
+	}
+
+	if cfg.SortJSONKeys && cfg.OutputFormat != outputFormatJSON {
+		return errors.New("the sort JSON keys option can only be used with the JSON output format")
+	}
+
+	if cfg.OutputTemplate != "" && cfg.structuredOutput() {
+		return fmt.Errorf("the output template can't be combined with the %s output format", cfg.OutputFormat)
+	}
+
+	if len(cfg.Fields) > 0 {
+		if cfg.structuredOutput() {
+			return fmt.Errorf("the fields can't be combined with the %s output format", cfg.OutputFormat)
+		}
+		if cfg.OutputTemplate != "" {
+			return errors.New("the fields can't be combined with the output template")
+		}
+		for _, field := range cfg.Fields {
+			if field == "" || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") || strings.Contains(field, "..") {
+				return fmt.Errorf("%q is not a valid field, it must be a dotted path such as error.code", field)
+			}
+		}
+	}
+
+	if cfg.OnlyErrors && (len(cfg.FilterStatusCodes) > 0 || len(cfg.FilterStatusClasses) > 0) {
+		return errors.New("the only errors filter can't be combined with the status code or status class filters")
+	}
+
+	if cfg.OnlyErrors && cfg.Filters != nil && (onlySuccessStatuses(cfg.Filters.FilterStatusCode) || onlySuccessStatuses(cfg.Filters.FilterStatusCodeType)) {
+		return errors.New("the only errors filter can't be combined with status code filters that only select successful requests, as no request logs would be shown")
+	}
+
+	if cfg.RequireConnectedAccount && cfg.ExcludeConnectedAccount {
+		return errors.New("the require connected account and exclude connected account filters can't be combined")
+	}
+
+	for _, code := range cfg.FilterStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("%d is not a valid HTTP status code", code)
+		}
+	}
+
+	for _, class := range cfg.FilterStatusClasses {
+		class, _ = parseFilterValue(class)
+		if _, ok := statusClasses[strings.ToLower(class)]; !ok {
+			err := fmt.Errorf("%s is not an acceptable status class (%s)", class, strings.Join(statusClassNames, ", "))
+			return withSuggestion(err, class, statusClassNames)
+		}
+	}
+
+	for _, account := range cfg.FilterAccount {
+		account, _ = parseFilterValue(account)
+		if account != platformAccount && !strings.HasPrefix(account, "acct_") {
+			return fmt.Errorf("%s is not an acceptable account filter (an acct_ ID or %s)", account, platformAccount)
+		}
+	}
+
+	for _, address := range cfg.FilterIPAddress {
+		address, _ = parseFilterValue(address)
+		if _, err := parseIPNet(address); err != nil {
+			return err
+		}
+	}
+
+	for _, method := range cfg.FilterHTTPMethods {
+		method, _ = parseFilterValue(method)
+		if err := validators.CallNonEmpty(validators.HTTPMethod, method); err != nil {
+			return withSuggestion(err, method, httpMethodValues)
+		}
+	}
+
+	for _, errorType := range cfg.FilterErrorType {
+		errorType, _ = parseFilterValue(errorType)
+		if err := validators.CallNonEmpty(validators.ErrorType, errorType); err != nil {
+			return withSuggestion(err, errorType, errorTypeValues)
+		}
+	}
+
+	source, _ := parseFilterValue(cfg.FilterSource)
+	if err := validators.CallNonEmpty(validators.RequestSource, source); err != nil {
+		return withSuggestion(err, source, sourceValues)
+	}
+
+	if cfg.FilterURLRegex != "" {
+		if _, err := regexp.Compile(cfg.FilterURLRegex); err != nil {
+			return fmt.Errorf("invalid URL filter regular expression: %v", err)
+		}
+	}
+
+	if cfg.FilterExpression != "" {
+		if _, err := filter.Compile(cfg.FilterExpression, expressionFields); err != nil {
+			return err
+		}
+	}
+
+	if cfg.FilterMinLatency < 0 || cfg.FilterMaxLatency < 0 {
+		return errors.New("the latency filters can't be negative")
+	}
+
+	if cfg.FilterMinLatency > 0 && cfg.FilterMaxLatency > 0 && cfg.FilterMinLatency > cfg.FilterMaxLatency {
+		return fmt.Errorf("the minimum latency filter (%s) must be lower than the maximum latency filter (%s)", cfg.FilterMinLatency, cfg.FilterMaxLatency)
+	}
+
+	if !cfg.FilterSince.IsZero() && !cfg.FilterUntil.IsZero() && cfg.FilterSince.After(cfg.FilterUntil) {
+		return fmt.Errorf("the since filter (%s) must be before the until filter (%s)", cfg.FilterSince.Format(time.RFC3339), cfg.FilterUntil.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// onlySuccessStatuses returns true if the status codes or status code types of
+// the server-side filters, e.g. 200 or 2XX, are all below 400
+func onlySuccessStatuses(statuses []string) bool {
+	if len(statuses) == 0 {
+		return false
+	}
+
+	for _, status := range statuses {
+		code, err := strconv.Atoi(strings.ReplaceAll(strings.ToUpper(status), "X", "0"))
+		if err != nil || code >= 400 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateAPIBaseURL checks that the base URL of the Stripe API is an http://
+// or https:// URL
+func validateAPIBaseURL(baseURL string) error {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("the APIBaseURL field (%s) is not valid, it must be an http:// or https:// URL such as https://api.stripe.com", baseURL)
+	}
+	return nil
+}
+
+// validateOutputFormat checks that the output format is one of the
+// outputFormats, or empty for the default format. Unknown formats used to
+// silently fall back to the default format.
+func validateOutputFormat(format string) error {
+	if format == "" || containsString(outputFormats, format) {
+		return nil
+	}
+
+	err := fmt.Errorf("the OutputFormat field (%s) is not acceptable, it must be empty for the default format or one of %s", format, strings.Join(outputFormats, ", "))
+	return withSuggestion(err, format, outputFormats)
+}
+
+// withSuggestion appends a suggestion to err if the value is a near miss of
+// one of the candidates, e.g. because of a typo.
+func withSuggestion(err error, value string, candidates []string) error {
+	suggestion, ok := suggest(value, candidates)
+	if !ok {
+		return err
+	}
+
+	return fmt.Errorf("%v, did you mean %s?", err, suggestion)
+}
+
+// suggest returns the candidate closest to the value, and false if none of
+// them is close enough to be a likely typo. The comparison is
+// case-insensitive.
+func suggest(value string, candidates []string) (string, bool) {
+	best := ""
+	bestDistance := -1
+
+	for _, candidate := range candidates {
+		distance := editDistance(strings.ToLower(value), strings.ToLower(candidate))
+
+		maxDistance := len(candidate) / 3
+		if maxDistance < 2 {
+			maxDistance = 2
+		}
+
+		if distance > maxDistance {
+			continue
+		}
+
+		if bestDistance == -1 || distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+
+	return best, bestDistance != -1
+}
+
+// editDistance returns the Levenshtein distance between a and b, i.e. the
+// number of single character insertions, deletions and substitutions needed
+// to turn one into the other.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
@@ -0,0 +1,234 @@
+package logtailing
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestValidate(t *testing.T) {
+	early := time.Date(2019, 10, 1, 11, 0, 0, 0, time.UTC)
+	late := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{"no filters", Config{}, ""},
+		{"require connected account", Config{RequireConnectedAccount: true}, ""},
+		{"exclude connected account", Config{ExcludeConnectedAccount: true}, ""},
+		{"only errors with error status codes", Config{OnlyErrors: true, Filters: &LogFilters{FilterStatusCode: []string{"200", "402"}}}, ""},
+		{"only errors with success status codes", Config{OnlyErrors: true, Filters: &LogFilters{FilterStatusCode: []string{"200", "302"}}}, "the only errors filter can't be combined with status code filters that only select successful requests, as no request logs would be shown"},
+		{"only errors with success status code types", Config{OnlyErrors: true, Filters: &LogFilters{FilterStatusCodeType: []string{"200"}}}, "the only errors filter can't be combined with status code filters that only select successful requests, as no request logs would be shown"},
+		{"only errors with error status code types", Config{OnlyErrors: true, Filters: &LogFilters{FilterStatusCodeType: []string{"2XX", "5XX"}}}, ""},
+		{"require and exclude connected account", Config{RequireConnectedAccount: true, ExcludeConnectedAccount: true}, "the require connected account and exclude connected account filters can't be combined"},
+		{"status code lower bound", Config{FilterStatusCodes: []int{100}}, ""},
+		{"status code upper bound", Config{FilterStatusCodes: []int{599}}, ""},
+		{"status code too low", Config{FilterStatusCodes: []int{99}}, "99 is not a valid HTTP status code"},
+		{"status code too high", Config{FilterStatusCodes: []int{600}}, "600 is not a valid HTTP status code"},
+		{"status class", Config{FilterStatusClasses: []string{"server-err"}}, ""},
+		{"status class typo", Config{FilterStatusClasses: []string{"server-error"}}, "server-error is not an acceptable status class (succeeded, client-err, server-err), did you mean server-err?"},
+		{"status class unknown", Config{FilterStatusClasses: []string{"failed"}}, "failed is not an acceptable status class (succeeded, client-err, server-err)"},
+		{"http method", Config{FilterHTTPMethods: []string{"delete"}}, ""},
+		{"http method typo", Config{FilterHTTPMethods: []string{"DELTE"}}, "DELTE is not an acceptable HTTP method (GET, POST, DELETE), did you mean DELETE?"},
+		{"http method lowercase typo", Config{FilterHTTPMethods: []string{"pots"}}, "pots is not an acceptable HTTP method (GET, POST, DELETE), did you mean POST?"},
+		{"http method unknown", Config{FilterHTTPMethods: []string{"PATCH"}}, "PATCH is not an acceptable HTTP method (GET, POST, DELETE)"},
+		{"error type typo", Config{FilterErrorType: []string{"card_eror"}}, "card_eror is not an acceptable error type (api_error, card_error, idempotency_error, invalid_request_error), did you mean card_error?"},
+		{"source typo", Config{FilterSource: "API_"}, "API_ is not an acceptable source (API, DASHBOARD), did you mean API?"},
+		{"negated http method", Config{FilterHTTPMethods: []string{"!GET"}}, ""},
+		{"negated http method typo", Config{FilterHTTPMethods: []string{"!GTE"}}, "GTE is not an acceptable HTTP method (GET, POST, DELETE), did you mean GET?"},
+		{"negated status class", Config{FilterStatusClasses: []string{"!succeeded"}}, ""},
+		{"negated account", Config{FilterAccount: []string{"!acct_123"}}, ""},
+		{"negated cidr range", Config{FilterIPAddress: []string{"!10.0.0.0/8"}}, ""},
+		{"output file rotation", Config{OutputFileMaxSize: 1 << 20, OutputFileMaxBackups: 3}, ""},
+		{"output file negative max size", Config{OutputFileMaxSize: -1}, "the output file maximum size and number of backups can't be negative"},
+		{"timezone", Config{Timezone: "Europe/Paris"}, ""},
+		{"timezone utc", Config{Timezone: "utc"}, ""},
+		{"timezone unknown", Config{Timezone: "Paris"}, "Paris is not a known time zone, use local, utc or an IANA time zone name (e.g. America/New_York)"},
+		{"color mode", Config{ColorMode: "Never"}, ""},
+		{"color mode unknown", Config{ColorMode: "on"}, "on is not an acceptable color mode (auto, always, never)"},
+		{"forward url", Config{ForwardURL: "https://collector.example.com/logs", ForwardHeaders: map[string]string{"Authorization": "Bearer token"}}, ""},
+		{"negative authorize max attempts", Config{AuthorizeMaxAttempts: -1}, "the maximum number of authorization attempts can't be negative"},
+		{"metrics address", Config{MetricsAddr: "localhost:9090"}, ""},
+		{"metrics address without port", Config{MetricsAddr: "localhost"}, "localhost is not a valid metrics address, it must be a host and a port such as localhost:9090"},
+		{"record filtered", Config{RecordFile: "session.jsonl", RecordFiltered: true}, ""},
+		{"record filtered without file", Config{RecordFiltered: true}, "the filtered request logs can't be recorded without a record file"},
+		{"statsd address", Config{StatsdAddr: "localhost:8125", StatsdTagStyle: "plain"}, ""},
+		{"statsd address without port", Config{StatsdAddr: "localhost"}, "localhost is not a valid statsd address, it must be a host and a port such as localhost:8125"},
+		{"invalid statsd tag style", Config{StatsdAddr: "localhost:8125", StatsdTagStyle: "influx"}, "influx is not a valid statsd tag style, it must be datadog or plain"},
+		{"statsd tag style without address", Config{StatsdTagStyle: "plain"}, "the statsd tag style can't be set without a statsd address"},
+		{"otlp endpoint", Config{OTLPEndpoint: "http://localhost:4318"}, ""},
+		{"otlp endpoint without scheme", Config{OTLPEndpoint: "localhost:4318"}, "localhost:4318 is not a valid OTLP endpoint, it must be an http:// or https:// URL"},
+		{"forward url without scheme", Config{ForwardURL: "collector.example.com/logs"}, "collector.example.com/logs is not a valid forward URL, it must be an http:// or https:// URL"},
+		{"forward headers without url", Config{ForwardHeaders: map[string]string{"Authorization": "Bearer token"}}, "the forward headers can't be set without a forward URL"},
+		{"syslog", Config{SyslogAddress: "localhost:514", SyslogNetwork: "tcp"}, ""},
+		{"syslog default network", Config{SyslogAddress: "localhost:514"}, ""},
+		{"syslog network without address", Config{SyslogNetwork: "tcp"}, "the syslog network can't be set without a syslog address"},
+		{"syslog network unknown", Config{SyslogAddress: "localhost:514", SyslogNetwork: "http"}, "http is not an acceptable syslog network (tcp, udp, unix, unixgram)"},
+		{"output template", Config{OutputTemplate: "{{.Status}}"}, ""},
+		{"output template with structured format", Config{OutputTemplate: "{{.Status}}", OutputFormat: outputFormatJSON}, "the output template can't be combined with the JSON output format"},
+		{"JSON compact", Config{JSONCompact: true, OutputFormat: outputFormatJSON}, ""},
+		{"JSON indent", Config{JSONIndent: "\t", OutputFormat: outputFormatJSON}, ""},
+		{"JSON indent without JSON format", Config{JSONIndent: "    "}, "the JSON compact and indent options can only be used with the JSON output format"},
+		{"JSON compact and indent", Config{JSONCompact: true, JSONIndent: " ", OutputFormat: outputFormatJSON}, "the JSON compact and indent options can't be combined"},
+		{"invalid JSON indent", Config{JSONIndent: "--", OutputFormat: outputFormatJSON}, `"--" is not a valid JSON indent, it can only contain spaces and tabs`},
+		{"sort JSON keys", Config{SortJSONKeys: true, OutputFormat: outputFormatJSON}, ""},
+		{"sort JSON keys without JSON format", Config{SortJSONKeys: true, OutputFormat: outputFormatNDJSON}, "the sort JSON keys option can only be used with the JSON output format"},
+		{"fields", Config{Fields: []string{"status", "error.code"}}, ""},
+		{"fields with structured format", Config{Fields: []string{"status"}, OutputFormat: outputFormatCSV}, "the fields can't be combined with the CSV output format"},
+		{"fields with output template", Config{Fields: []string{"status"}, OutputTemplate: "{{.Status}}"}, "the fields can't be combined with the output template"},
+		{"invalid field", Config{Fields: []string{"error..code"}}, `"error..code" is not a valid field, it must be a dotted path such as error.code`},
+		{"url regex", Config{FilterURLRegex: "^/v1/(charges|refunds)"}, ""},
+		{"url regex invalid", Config{FilterURLRegex: "("}, "invalid URL filter regular expression: error parsing regexp: missing closing ): `(`"},
+		{"ip address", Config{FilterIPAddress: []string{"10.0.0.1"}}, ""},
+		{"cidr range", Config{FilterIPAddress: []string{"10.0.0.0/8"}}, ""},
+		{"cidr range invalid", Config{FilterIPAddress: []string{"10.0.0.0/40"}}, "10.0.0.0/40 is not a valid CIDR range"},
+		{"latency window", Config{FilterMinLatency: time.Second, FilterMaxLatency: 2 * time.Second}, ""},
+		{"latency window reversed", Config{FilterMinLatency: 2 * time.Second, FilterMaxLatency: time.Second}, "the minimum latency filter (2s) must be lower than the maximum latency filter (1s)"},
+		{"latency negative", Config{FilterMinLatency: -time.Second}, "the latency filters can't be negative"},
+		{"time window", Config{FilterSince: early, FilterUntil: late}, ""},
+		{"time window reversed", Config{FilterSince: late, FilterUntil: early}, "the since filter (2019-10-01T12:00:00Z) must be before the until filter (2019-10-01T11:00:00Z)"},
+		{"print ready", Config{OutputFormat: "NDJSON", PrintReady: true}, ""},
+		{"print ready default format", Config{PrintReady: true}, "the PrintReady field can only be used with the NDJSON and JSON output formats, as the ready line is printed with the request logs"},
+		{"keep output", Config{Handler: func(*EventPayload, *websocket.RequestLogEvent) {}, KeepOutput: true}, ""},
+		{"keep output without handler", Config{KeepOutput: true}, "the KeepOutput field can only be set with the Handler field"},
+		{"drain timeout", Config{DrainTimeout: time.Second}, ""},
+		{"drain timeout negative", Config{DrainTimeout: -time.Second}, "the DrainTimeout field (-1s) can't be negative, it must be a duration or 0 for the default of 3s"},
+		{"queue", Config{QueueSize: 100, QueuePolicy: "drop-newest"}, ""},
+		{"queue negative", Config{QueueSize: -1}, "the QueueSize field (-1) can't be negative, it must be a number of request logs or 0 for no queue"},
+		{"queue policy typo", Config{QueueSize: 100, QueuePolicy: "drop-old"}, "the QueuePolicy field (drop-old) is not acceptable, it must be one of drop-oldest, drop-newest, did you mean drop-oldest?"},
+		{"queue policy without size", Config{QueuePolicy: "drop-oldest"}, "the QueuePolicy field (drop-oldest) requires the QueueSize field, as request logs are only dropped when the queue is full"},
+		{"workers", Config{Workers: 4}, ""},
+		{"workers negative", Config{Workers: -1}, "the Workers field (-1) can't be negative, it must be a number of workers or 0 to process each request log on its own goroutine"},
+		{"run duration", Config{RunDuration: time.Minute}, ""},
+		{"run duration negative", Config{RunDuration: -time.Minute}, "the RunDuration field (-1m0s) can't be negative, it must be a duration or 0 to run until interrupted"},
+		{"notify interval", Config{NotifyInterval: time.Minute, NotifyOn: "5xx"}, ""},
+		{"notify interval negative", Config{NotifyInterval: -time.Minute, NotifyOn: "5xx"}, "the NotifyInterval field (-1m0s) can't be negative, it must be a duration or 0 for the default of 30s"},
+		{"notify interval without notify on", Config{NotifyInterval: time.Minute}, "the NotifyInterval field (1m0s) requires the NotifyOn field, as no desktop notifications are shown without it"},
+		{"idle after", Config{IdleAfter: 5 * time.Minute}, ""},
+		{"idle after negative", Config{IdleAfter: -time.Minute}, "the IdleAfter field (-1m0s) can't be negative, it must be a duration or 0 for no idle notice"},
+		{"max reconnect attempts", Config{MaxReconnectAttempts: 3}, ""},
+		{"max reconnect attempts negative", Config{MaxReconnectAttempts: -1}, "the MaxReconnectAttempts field (-1) can't be negative, it must be a number of attempts or 0 to retry forever"},
+		{"fail on", Config{FailOn: "any-error"}, ""},
+		{"fail on typo", Config{FailOn: "5xxx"}, "the FailOn field (5xxx) is not acceptable, it must be one of 5xx, 4xx, any-error, did you mean 5xx?"},
+		{"event limit", Config{EventLimit: 1}, ""},
+		{"event limit negative", Config{EventLimit: -1}, "the EventLimit field (-1) can't be negative, it must be a number of request logs or 0 for no limit"},
+		{"output format", Config{OutputFormat: outputFormatNDJSON}, ""},
+		{"output format unknown", Config{OutputFormat: "YAML"}, "the OutputFormat field (YAML) is not acceptable, it must be empty for the default format or one of CSV, ENVELOPE, JSON, LOGFMT, NDJSON"},
+		{"output format lowercase", Config{OutputFormat: "json"}, "the OutputFormat field (json) is not acceptable, it must be empty for the default format or one of CSV, ENVELOPE, JSON, LOGFMT, NDJSON, did you mean JSON?"},
+		{"live stats without stdout", Config{LiveStats: true, NoStdout: true}, "the LiveStats and NoStdout fields can't be combined, as the live stats are printed to Stdout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validateOptions()
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateConnection(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{"valid", Config{Key: "sk_test_123", WebSocketFeature: "request_logs"}, ""},
+		{"api base url", Config{Key: "sk_test_123", APIBaseURL: "http://localhost:12111", WebSocketFeature: "request_logs"}, ""},
+		{"empty key", Config{Key: " ", WebSocketFeature: "request_logs"}, "the Key field can't be empty, it must be a Stripe secret or restricted API key (sk_... or rk_...)"},
+		{"api base url without scheme", Config{Key: "sk_test_123", APIBaseURL: "localhost:12111", WebSocketFeature: "request_logs"}, "the APIBaseURL field (localhost:12111) is not valid, it must be an http:// or https:// URL such as https://api.stripe.com"},
+		{"api base url malformed", Config{Key: "sk_test_123", APIBaseURL: "http://%zz", WebSocketFeature: "request_logs"}, "the APIBaseURL field (http://%zz) is not valid, it must be an http:// or https:// URL such as https://api.stripe.com"},
+		{"empty websocket feature", Config{Key: "sk_test_123"}, "the WebSocketFeature field can't be empty, it must be request_logs to tail the request logs"},
+		{"keys", Config{Keys: []string{"sk_test_123", "sk_live_456"}, WebSocketFeature: "request_logs"}, ""},
+		{"keys strict", Config{Keys: []string{"sk_test_123", "sk_live_456"}, Strict: true, WebSocketFeature: "request_logs"}, ""},
+		{"key and keys", Config{Key: "sk_test_123", Keys: []string{"sk_live_456"}, WebSocketFeature: "request_logs"}, "the Key and Keys fields can't be combined, Key must be empty when Keys is set"},
+		{"empty key in keys", Config{Keys: []string{"sk_test_123", ""}, WebSocketFeature: "request_logs"}, "the key 2 of the Keys field can't be empty, it must be a Stripe secret or restricted API key (sk_... or rk_...)"},
+		{"duplicate keys", Config{Keys: []string{"sk_test_123", "sk_test_123"}, WebSocketFeature: "request_logs"}, "the key 2 of the Keys field is a duplicate, each key can only be tailed once"},
+		{"key labels", Config{Keys: []string{"sk_test_123", "sk_live_456"}, KeyLabels: map[string]string{"sk_live_456": "acme"}, WebSocketFeature: "request_logs"}, ""},
+		{"key label of an unknown key", Config{Key: "sk_test_123", KeyLabels: map[string]string{"sk_live_456": "acme"}, WebSocketFeature: "request_logs"}, "the KeyLabels field has a label for a key that isn't tailed, its keys must be Key or one of Keys"},
+		{"empty key label", Config{Key: "sk_test_123", KeyLabels: map[string]string{"sk_test_123": " "}, WebSocketFeature: "request_logs"}, "the KeyLabels field has an empty label, each label must be a name such as an account nickname"},
+		{"strict without keys", Config{Key: "sk_test_123", Strict: true, WebSocketFeature: "request_logs"}, "the Strict field can only be set with the Keys field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRunValidatesConfig(t *testing.T) {
+	// The API isn't reachable, so the tailer must fail before connecting
+	tailer := New(&Config{
+		APIBaseURL:       "http://127.0.0.1:0",
+		Key:              "sk_test_123",
+		OutputFormat:     "yaml",
+		Stderr:           ioutil.Discard,
+		Stdout:           ioutil.Discard,
+		WebSocketFeature: "request_logs",
+	})
+
+	require.EqualError(t, tailer.Run(), "the OutputFormat field (yaml) is not acceptable, it must be empty for the default format or one of CSV, ENVELOPE, JSON, LOGFMT, NDJSON")
+}
+
+func TestValidateExpression(t *testing.T) {
+	require.NoError(t, (&Config{FilterExpression: "status>=500"}).validateOptions())
+	require.Error(t, (&Config{FilterExpression: "status>="}).validateOptions())
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		value      string
+		suggestion string
+		ok         bool
+	}{
+		{"DELET", "DELETE", true},
+		{"gte", "GET", true},
+		{"POSTT", "POST", true},
+		{"PATCH", "", false},
+		{"OPTIONS", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			suggestion, ok := suggest(tt.value, httpMethodValues)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.suggestion, suggestion)
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"GET", "", 3},
+		{"", "GET", 3},
+		{"GET", "GET", 0},
+		{"GET", "GTE", 2},
+		{"DELETE", "DELTE", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			require.Equal(t, tt.distance, editDistance(tt.a, tt.b))
+		})
+	}
+}
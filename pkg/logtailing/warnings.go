@@ -0,0 +1,143 @@
+package logtailing
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// warningInterval is the interval during which the repeated warnings of a
+// kind are suppressed after the first one, and then rolled up.
+const warningInterval = 30 * time.Second
+
+const (
+	// warningMalformedPayload is the kind of the warnings about the payloads
+	// that aren't valid JSON
+	warningMalformedPayload = "malformed payload"
+
+	// warningNotRequestLog is the kind of the warnings about the websocket
+	// messages that aren't request logs
+	warningNotRequestLog = "non-request-logs event"
+)
+
+// warningLimiter suppresses the warnings repeated for every request log when
+// something goes systematically wrong, e.g. a schema change. The first
+// warning of a kind is logged, then the next ones are counted until the
+// interval elapses. The websocket client handles request logs concurrently,
+// so it's guarded by a mutex.
+type warningLimiter struct {
+	interval time.Duration
+
+	mu       sync.Mutex
+	warnings map[string]*limitedWarning
+}
+
+// limitedWarning is the window of a kind of warnings
+type limitedWarning struct {
+	start      time.Time
+	suppressed int
+}
+
+// suppressedWarnings is the number of warnings of a kind suppressed during a
+// window that elapsed
+type suppressedWarnings struct {
+	kind  string
+	count int
+}
+
+func newWarningLimiter(interval time.Duration) *warningLimiter {
+	return &warningLimiter{
+		interval: interval,
+		warnings: make(map[string]*limitedWarning),
+	}
+}
+
+// allow returns true if a warning of the kind can be logged at now, in which
+// case it starts a new window. It also returns the number of warnings
+// suppressed during the previous window if it just elapsed, for the rollup.
+func (l *warningLimiter) allow(kind string, now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	warning, ok := l.warnings[kind]
+	if ok && now.Sub(warning.start) < l.interval {
+		warning.suppressed++
+		return false, 0
+	}
+
+	suppressed := 0
+	if ok {
+		suppressed = warning.suppressed
+	}
+
+	l.warnings[kind] = &limitedWarning{start: now}
+	return true, suppressed
+}
+
+// rollups returns the warnings suppressed during the windows elapsed at now,
+// sorted by kind, and forgets those windows so that the next warnings are
+// logged right away.
+func (l *warningLimiter) rollups(now time.Time) []suppressedWarnings {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var rollups []suppressedWarnings
+	for kind, warning := range l.warnings {
+		if now.Sub(warning.start) < l.interval {
+			continue
+		}
+
+		if warning.suppressed > 0 {
+			rollups = append(rollups, suppressedWarnings{kind: kind, count: warning.suppressed})
+		}
+		delete(l.warnings, kind)
+	}
+
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].kind < rollups[j].kind })
+	return rollups
+}
+
+// warnLimited logs the warning, unless warnings of the same kind were already
+// logged during the last warningInterval, in which case it's only counted
+func (tailer *Tailer) warnLimited(kind string, fields log.Fields, args ...interface{}) {
+	allowed, suppressed := tailer.warnings.allow(kind, tailer.now())
+	if !allowed {
+		return
+	}
+
+	tailer.logSuppressedWarnings(kind, suppressed)
+	tailer.cfg.Log.WithFields(fields).Warn(args...)
+}
+
+// logSuppressedWarnings logs the rollup of the warnings of a kind that were
+// suppressed, if any
+func (tailer *Tailer) logSuppressedWarnings(kind string, count int) {
+	if count == 0 {
+		return
+	}
+
+	tailer.cfg.Log.WithFields(log.Fields{
+		"prefix": "logs.Tailer.logSuppressedWarnings",
+		"kind":   kind,
+	}).Warnf("Suppressed %d similar warnings in the last %s", count, tailer.warnings.interval)
+}
+
+// reportSuppressedWarnings periodically logs the rollups of the suppressed
+// warnings until stopCh is closed.
+func (tailer *Tailer) reportSuppressedWarnings(stopCh chan struct{}) {
+	ticker := time.NewTicker(tailer.warnings.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, rollup := range tailer.warnings.rollups(tailer.now()) {
+				tailer.logSuppressedWarnings(rollup.kind, rollup.count)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package logtailing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stripe/stripe-cli/pkg/websocket"
+)
+
+func TestWarningLimiter(t *testing.T) {
+	start := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	l := newWarningLimiter(30 * time.Second)
+
+	allowed, suppressed := l.allow(warningMalformedPayload, start)
+	require.True(t, allowed)
+	require.Equal(t, 0, suppressed)
+
+	for i := 1; i <= 3; i++ {
+		allowed, _ = l.allow(warningMalformedPayload, start.Add(time.Duration(i)*time.Second))
+		require.False(t, allowed)
+	}
+
+	// The kinds are limited separately
+	allowed, _ = l.allow(warningNotRequestLog, start.Add(time.Second))
+	require.True(t, allowed)
+
+	// The next warning after the window starts a new one, with the rollup of
+	// the previous one
+	allowed, suppressed = l.allow(warningMalformedPayload, start.Add(30*time.Second))
+	require.True(t, allowed)
+	require.Equal(t, 3, suppressed)
+}
+
+func TestWarningLimiterRollups(t *testing.T) {
+	start := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+	l := newWarningLimiter(30 * time.Second)
+
+	l.allow(warningNotRequestLog, start)
+	l.allow(warningMalformedPayload, start)
+	l.allow(warningMalformedPayload, start.Add(time.Second))
+	l.allow(warningMalformedPayload, start.Add(2*time.Second))
+
+	require.Empty(t, l.rollups(start.Add(29*time.Second)))
+	require.Equal(t, []suppressedWarnings{{kind: warningMalformedPayload, count: 2}}, l.rollups(start.Add(30*time.Second)))
+
+	// The elapsed windows are forgotten, so the next warning is logged right
+	// away
+	require.Empty(t, l.rollups(start.Add(time.Minute)))
+	allowed, suppressed := l.allow(warningMalformedPayload, start.Add(31*time.Second))
+	require.True(t, allowed)
+	require.Equal(t, 0, suppressed)
+}
+
+func TestProcessStreamEventLimitsWarnings(t *testing.T) {
+	now := time.Date(2019, 10, 2, 7, 6, 40, 0, time.UTC)
+
+	logger, hook := test.NewNullLogger()
+	tailer := New(&Config{Log: logger, NoStdout: true})
+	tailer.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{EventPayload: `{"status": 200`}})
+		tailer.processRequestLogEvent(websocket.IncomingMessage{})
+	}
+
+	require.Len(t, hook.AllEntries(), 2)
+	require.Equal(t, "Received malformed payload: unexpected end of JSON input", hook.AllEntries()[0].Message)
+	require.Equal(t, "WebSocket specified for request logs received non-request-logs event", hook.AllEntries()[1].Message)
+
+	hook.Reset()
+	now = now.Add(warningInterval)
+	tailer.processRequestLogEvent(websocket.IncomingMessage{RequestLogEvent: &websocket.RequestLogEvent{EventPayload: `{"status": 200`}})
+
+	require.Len(t, hook.AllEntries(), 2)
+	require.Equal(t, "Suppressed 4 similar warnings in the last 30s", hook.AllEntries()[0].Message)
+	require.Equal(t, warningMalformedPayload, hook.AllEntries()[0].Data["kind"])
+	require.Equal(t, "Received malformed payload: unexpected end of JSON input", hook.AllEntries()[1].Message)
+}
@@ -0,0 +1,192 @@
+package logtailing
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventWriterQueueSize is the number of request logs buffered while the sinks
+// are busy. The oldest request logs are dropped once the queue is full.
+const eventWriterQueueSize = 1000
+
+// eventWriter writes the request logs to a sink from a dedicated goroutine,
+// so that a slow reader of the output (e.g. a paused pager) never blocks the
+// websocket client. The websocket connection would time out otherwise.
+type eventWriter struct {
+	sink Sink
+
+	events chan RenderedEvent
+	stopCh chan struct{}
+	doneCh chan struct{}
+
+	// abortCh is closed by abort to stop writing the queued request logs
+	// while closing
+	abortCh   chan struct{}
+	abortOnce sync.Once
+
+	// onTick is called every tickInterval if set, from the same goroutine as
+	// the writes
+	tickInterval time.Duration
+	onTick       func(now time.Time)
+
+	// pauseCh pauses and resumes the writes. While paused, the request logs
+	// stay queued. onPause is called if set when the writes are paused or
+	// resumed, with the number of queued request logs, from the same
+	// goroutine as the writes.
+	pauseCh chan bool
+	onPause func(paused bool, queued int)
+
+	// dropped is the number of request logs dropped because the queue was
+	// full. It must be accessed atomically.
+	dropped uint64
+}
+
+func newEventWriter(sink Sink, size int) *eventWriter {
+	return &eventWriter{
+		sink:    sink,
+		events:  make(chan RenderedEvent, size),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+		abortCh: make(chan struct{}),
+		pauseCh: make(chan bool),
+	}
+}
+
+// send queues a request log to be written. If the queue is full, the oldest
+// queued request log is dropped to make room for it. send never blocks.
+func (w *eventWriter) send(event RenderedEvent) {
+	for {
+		select {
+		case w.events <- event:
+			return
+		default:
+		}
+
+		select {
+		case <-w.events:
+			atomic.AddUint64(&w.dropped, 1)
+		default:
+		}
+	}
+}
+
+// tick sets a function called periodically between the writes. It must be
+// called before start.
+func (w *eventWriter) tick(interval time.Duration, onTick func(now time.Time)) {
+	w.tickInterval = interval
+	w.onTick = onTick
+}
+
+// pauseNotify sets a function called when the writes are paused or resumed.
+// It must be called before start.
+func (w *eventWriter) pauseNotify(onPause func(paused bool, queued int)) {
+	w.onPause = onPause
+}
+
+// pause pauses the writes if paused is true, and resumes them otherwise. The
+// request logs received while paused are queued, dropping the oldest ones
+// once the queue is full, and written on resume. It returns once the writer
+// has paused or resumed, or immediately if the writer is stopped.
+func (w *eventWriter) pause(paused bool) {
+	select {
+	case w.pauseCh <- paused:
+	case <-w.doneCh:
+	}
+}
+
+// start starts writing the queued request logs.
+func (w *eventWriter) start() {
+	go w.run()
+}
+
+// close stops the writer once the queued request logs are written, or once
+// abort is called. It returns the number of request logs that were dropped,
+// including the queued ones when aborted.
+func (w *eventWriter) close() uint64 {
+	close(w.stopCh)
+	<-w.doneCh
+
+	return atomic.LoadUint64(&w.dropped) + uint64(len(w.events))
+}
+
+// abort makes close return without writing the queued request logs, once the
+// request log being written, if any, is written. It can be called from any
+// goroutine, several times.
+func (w *eventWriter) abort() {
+	w.abortOnce.Do(func() {
+		close(w.abortCh)
+	})
+}
+
+func (w *eventWriter) run() {
+	defer close(w.doneCh)
+
+	// A nil channel never receives, which disables the ticks
+	var tickCh <-chan time.Time
+	if w.onTick != nil {
+		ticker := time.NewTicker(w.tickInterval)
+		defer ticker.Stop()
+		tickCh = ticker.C
+	}
+
+	// A nil channel never receives either, which holds the queued request
+	// logs while paused
+	events := w.events
+
+	for {
+		// Once aborted, the request log being written is the last one
+		select {
+		case <-w.abortCh:
+			return
+		default:
+		}
+
+		select {
+		case event := <-events:
+			w.sink.Write(event) // #nosec G104
+		case now := <-tickCh:
+			w.onTick(now)
+		case paused := <-w.pauseCh:
+			if paused == (events == nil) {
+				continue
+			}
+			if paused {
+				events = nil
+			} else {
+				events = w.events
+			}
+			w.notifyPause(paused)
+		case <-w.stopCh:
+			// The request logs queued while paused are still written
+			if events == nil {
+				w.notifyPause(false)
+			}
+			w.drain()
+			return
+		}
+	}
+}
+
+func (w *eventWriter) notifyPause(paused bool) {
+	if w.onPause != nil {
+		w.onPause(paused, len(w.events))
+	}
+}
+
+func (w *eventWriter) drain() {
+	for {
+		select {
+		case <-w.abortCh:
+			return
+		default:
+		}
+
+		select {
+		case event := <-w.events:
+			w.sink.Write(event) // #nosec G104
+		default:
+			return
+		}
+	}
+}
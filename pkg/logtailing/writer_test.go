@@ -0,0 +1,148 @@
+package logtailing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// blockingSink blocks the writes until it's unblocked, then records them
+type blockingSink struct {
+	unblock chan struct{}
+	lines   []string
+}
+
+func (s *blockingSink) Write(event RenderedEvent) error {
+	<-s.unblock
+	s.lines = append(s.lines, event.Line)
+	return nil
+}
+
+func TestEventWriterDoesntBlock(t *testing.T) {
+	sink := &blockingSink{unblock: make(chan struct{})}
+	w := newEventWriter(sink, 2)
+	w.start()
+
+	// The first request log is picked up by the writer, which blocks on it
+	w.send(RenderedEvent{Line: "1"})
+	require.Eventually(t, func() bool { return len(w.events) == 0 }, time.Second, time.Millisecond)
+
+	sent := make(chan struct{})
+	go func() {
+		for _, line := range []string{"2", "3", "4", "5"} {
+			w.send(RenderedEvent{Line: line})
+		}
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		require.FailNow(t, "send blocked on a blocked sink")
+	}
+
+	close(sink.unblock)
+
+	// The oldest request logs were dropped, and the queued ones are written
+	// before the writer stops
+	require.Equal(t, uint64(2), w.close())
+	require.Equal(t, []string{"1", "4", "5"}, sink.lines)
+}
+
+func TestEventWriterCloseDrains(t *testing.T) {
+	recording := &recordingSink{}
+	w := newEventWriter(recording, 10)
+
+	w.send(RenderedEvent{Line: "1"})
+	w.send(RenderedEvent{Line: "2"})
+
+	w.start()
+	require.Equal(t, uint64(0), w.close())
+	require.Len(t, recording.events, 2)
+}
+
+func TestEventWriterPause(t *testing.T) {
+	received := make(channelSink, 10)
+	w := newEventWriter(received, 10)
+
+	type pauseChange struct {
+		paused bool
+		queued int
+	}
+	changes := make(chan pauseChange, 2)
+	w.pauseNotify(func(paused bool, queued int) {
+		changes <- pauseChange{paused, queued}
+	})
+	w.start()
+
+	w.pause(true)
+	require.Equal(t, pauseChange{true, 0}, <-changes)
+
+	// Pausing twice doesn't notify again
+	w.pause(true)
+
+	w.send(RenderedEvent{Line: "1"})
+	w.send(RenderedEvent{Line: "2"})
+
+	select {
+	case event := <-received:
+		require.FailNow(t, "request log written while paused", event.Line)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	w.pause(false)
+	require.Equal(t, pauseChange{false, 2}, <-changes)
+	require.Equal(t, "1", (<-received).Line)
+	require.Equal(t, "2", (<-received).Line)
+
+	require.Equal(t, uint64(0), w.close())
+
+	// Pausing a stopped writer doesn't block
+	w.pause(true)
+}
+
+func TestEventWriterPauseBounded(t *testing.T) {
+	recording := &recordingSink{}
+	w := newEventWriter(recording, 2)
+
+	var queued int
+	w.pauseNotify(func(paused bool, n int) {
+		queued = n
+	})
+	w.start()
+
+	w.pause(true)
+	for _, line := range []string{"1", "2", "3"} {
+		w.send(RenderedEvent{Line: line})
+	}
+
+	// Closing a paused writer resumes it, writing the queued request logs
+	require.Equal(t, uint64(1), w.close())
+	require.Equal(t, 2, queued)
+	require.Len(t, recording.events, 2)
+	require.Equal(t, "2", recording.events[0].Line)
+}
+
+func TestEventWriterAbort(t *testing.T) {
+	sink := &blockingSink{unblock: make(chan struct{})}
+	w := newEventWriter(sink, 10)
+	w.start()
+
+	for _, line := range []string{"1", "2", "3"} {
+		w.send(RenderedEvent{Line: line})
+	}
+	require.Eventually(t, func() bool { return len(w.events) == 2 }, time.Second, time.Millisecond)
+
+	closed := make(chan uint64)
+	go func() { closed <- w.close() }()
+
+	// The request log being written is still written, but not the queued
+	// ones
+	w.abort()
+	w.abort()
+	close(sink.unblock)
+
+	require.Equal(t, uint64(2), <-closed)
+	require.Equal(t, []string{"1"}, sink.lines)
+}
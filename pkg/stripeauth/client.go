@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 
@@ -27,6 +28,35 @@ type Config struct {
 	APIBaseURL string
 }
 
+// AuthorizationError is returned by Authorize when Stripe responds with an
+// error status code.
+type AuthorizationError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *AuthorizationError) Error() string {
+	return fmt.Sprintf("Authorization failed, status=%d, body=%s", e.StatusCode, e.Body)
+}
+
+// Temporary returns true if the authorization may succeed when retried, i.e.
+// when Stripe is rate limiting or failed to handle the request. Client errors
+// such as an invalid API key are permanent.
+func (e *AuthorizationError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// IsRetryable returns true if an error returned by Authorize is transient,
+// such as a network error or a temporary AuthorizationError.
+func IsRetryable(err error) bool {
+	if authErr, ok := err.(*AuthorizationError); ok {
+		return authErr.Temporary()
+	}
+
+	_, isNetErr := err.(net.Error)
+	return isNetErr
+}
+
 // Client is the client used to initiate new CLI sessions with Stripe.
 type Client struct {
 	apiKey string
@@ -71,8 +101,7 @@ func (c *Client) Authorize(deviceName string, websocketFeature string, filters *
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		err := fmt.Errorf("Authorization failed, status=%d, body=%s", resp.StatusCode, body)
-		return nil, err
+		return nil, &AuthorizationError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var session *StripeCLISession
@@ -79,3 +79,42 @@ func TestStripeClientUserAgent(t *testing.T) {
 	})
 	client.Authorize("my-device", "webhooks", nil)
 }
+
+func TestAuthorizeError(t *testing.T) {
+	tests := []struct {
+		status    int
+		retryable bool
+	}{
+		{http.StatusUnauthorized, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusServiceUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(`{"error": {}}`))
+		}))
+
+		client := NewClient("sk_test_123", &Config{
+			APIBaseURL: ts.URL,
+		})
+		_, err := client.Authorize("my-device", "webhooks", nil)
+		ts.Close()
+
+		require.Equal(t, &AuthorizationError{StatusCode: tt.status, Body: `{"error": {}}`}, err)
+		require.Equal(t, tt.retryable, IsRetryable(err), tt.status)
+	}
+}
+
+func TestAuthorizeNetworkErrorIsRetryable(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	client := NewClient("sk_test_123", &Config{
+		APIBaseURL: ts.URL,
+	})
+	_, err := client.Authorize("my-device", "webhooks", nil)
+	require.Error(t, err)
+	require.True(t, IsRetryable(err))
+}
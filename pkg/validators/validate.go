@@ -72,6 +72,17 @@ func Account(account string) error {
 	return fmt.Errorf("%s is not an acceptable account filter (CONNECT_IN, CONNECT_OUT, SELF)", account)
 }
 
+// ErrorType validates that a string is one of the error types returned by the
+// Stripe API.
+func ErrorType(errorType string) error {
+	switch strings.ToLower(errorType) {
+	case "api_error", "card_error", "idempotency_error", "invalid_request_error":
+		return nil
+	}
+
+	return fmt.Errorf("%s is not an acceptable error type (api_error, card_error, idempotency_error, invalid_request_error)", errorType)
+}
+
 // HTTPMethod validates that a string is an acceptable HTTP method.
 func HTTPMethod(method string) error {
 	methodUpper := strings.ToUpper(method)
@@ -32,6 +32,16 @@ func TestTestmodeRestrictedAPIKey(t *testing.T) {
 	require.Nil(t, err)
 }
 
+func TestErrorType(t *testing.T) {
+	err := ErrorType("card_error")
+	require.Nil(t, err)
+}
+
+func TestErrorTypeInvalid(t *testing.T) {
+	err := ErrorType("card_declined")
+	require.Equal(t, "card_declined is not an acceptable error type (api_error, card_error, idempotency_error, invalid_request_error)", fmt.Sprintf("%s", err))
+}
+
 func TestHTTPMethod(t *testing.T) {
 	err := HTTPMethod("GET")
 	require.Nil(t, err)
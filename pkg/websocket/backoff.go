@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReconnectBackoff configures the delays between the attempts to reconnect to
+// Stripe: they start at Initial and are multiplied by Multiplier after each
+// attempt, up to Max, then randomized by Jitter so that the clients of an
+// outage don't all retry in lockstep. The zero value waits
+// Config.ConnectAttemptWait between the failed attempts, and reconnects right
+// away when a connection is lost.
+type ReconnectBackoff struct {
+	// Initial is the delay before the first attempt. Defaults to
+	// Config.ConnectAttemptWait.
+	Initial time.Duration
+
+	// Max is the maximum delay between two attempts. Defaults to 60s, or
+	// Initial if it's longer.
+	Max time.Duration
+
+	// Multiplier multiplies the delay after each attempt. Defaults to 1,
+	// which waits Initial before every attempt.
+	Multiplier float64
+
+	// Jitter is the fraction of the delay that's randomized, e.g. 0.2 waits
+	// between 80% and 120% of the delay. No jitter is applied when zero.
+	Jitter float64
+
+	// ResetAfter is how long a connection must last for the delay to be
+	// reset to Initial. The connections lost sooner are reconnected after
+	// the next delay, as the endpoint is likely flapping. When zero, the
+	// connections lost are always reconnected right away.
+	ResetAfter time.Duration
+}
+
+// withDefaults returns the backoff with the defaults of the fields that
+// aren't set
+func (b ReconnectBackoff) withDefaults(connectAttemptWait time.Duration) ReconnectBackoff {
+	if b.Initial == 0 {
+		b.Initial = connectAttemptWait
+	}
+	if b.Max == 0 {
+		b.Max = defaultMaxReconnectDelay
+		if b.Initial > b.Max {
+			b.Max = b.Initial
+		}
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = 1
+	}
+	return b
+}
+
+// delay returns the delay before the attempt, starting at 1, randomized with
+// random
+func (b ReconnectBackoff) delay(attempt int, random *rand.Rand) time.Duration {
+	delay := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt-1))
+	if delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		delay *= 1 - b.Jitter + 2*b.Jitter*random.Float64()
+	}
+
+	return time.Duration(delay)
+}
+
+// backOff waits before the next attempt to connect, logging the attempt and
+// the delay
+func (c *Client) backOff() {
+	c.attempts++
+	delay := c.cfg.ReconnectBackoff.delay(c.attempts, c.random)
+
+	c.cfg.Log.WithFields(log.Fields{
+		"prefix":  "websocket.Client.backOff",
+		"attempt": c.attempts,
+		"delay":   delay,
+	}).Debug("Waiting before reconnecting")
+
+	time.Sleep(delay)
+}
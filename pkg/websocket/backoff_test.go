@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconnectBackoffDelay(t *testing.T) {
+	backoff := ReconnectBackoff{Initial: time.Second, Max: 8 * time.Second, Multiplier: 2}.withDefaults(defaultConnectAttemptWait)
+	random := rand.New(rand.NewSource(1)) // #nosec G404
+
+	var delays []time.Duration
+	for attempt := 1; attempt <= 6; attempt++ {
+		delays = append(delays, backoff.delay(attempt, random))
+	}
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second, 8 * time.Second}, delays)
+}
+
+func TestReconnectBackoffJitter(t *testing.T) {
+	backoff := ReconnectBackoff{Initial: time.Second, Max: 8 * time.Second, Multiplier: 2, Jitter: 0.2}.withDefaults(defaultConnectAttemptWait)
+
+	delays := func(seed int64) []time.Duration {
+		random := rand.New(rand.NewSource(seed)) // #nosec G404
+
+		var delays []time.Duration
+		for attempt := 1; attempt <= 6; attempt++ {
+			delays = append(delays, backoff.delay(attempt, random))
+		}
+		return delays
+	}
+
+	// The delays are within 20% of the backoff
+	seeded := delays(1)
+	for i, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second, 8 * time.Second} {
+		require.True(t, seeded[i] >= max*8/10 && seeded[i] <= max*12/10, "attempt %d: %s", i+1, seeded[i])
+	}
+	require.Equal(t, seeded, delays(1))
+	require.NotEqual(t, seeded, delays(2))
+}
+
+func TestReconnectBackoffDefaults(t *testing.T) {
+	backoff := ReconnectBackoff{}.withDefaults(10 * time.Second)
+	require.Equal(t, ReconnectBackoff{Initial: 10 * time.Second, Max: 60 * time.Second, Multiplier: 1}, backoff)
+
+	// The failed attempts are retried every ConnectAttemptWait, as before
+	random := rand.New(rand.NewSource(1)) // #nosec G404
+	require.Equal(t, 10*time.Second, backoff.delay(1, random))
+	require.Equal(t, 10*time.Second, backoff.delay(5, random))
+
+	backoff = ReconnectBackoff{Initial: 2 * time.Minute}.withDefaults(10 * time.Second)
+	require.Equal(t, 2*time.Minute, backoff.Max)
+}
+
+func TestClientReconnectBackoff(t *testing.T) {
+	upgrader := ws.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.Nil(t, err)
+
+		// Closing the connection right away makes the client back off
+		c.Close()
+	}))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	connects := make(chan time.Time, 10)
+	client := NewClient(
+		url,
+		"websocket-random-id",
+		"request-log-payloads",
+		&Config{
+			EventHandler: EventHandlerFunc(func(msg IncomingMessage) {}),
+			OnConnect: func(attempt int) {
+				select {
+				case connects <- time.Now():
+				default:
+				}
+			},
+			ReconnectBackoff: ReconnectBackoff{
+				Initial:    50 * time.Millisecond,
+				Multiplier: 2,
+				ResetAfter: time.Minute,
+			},
+		},
+	)
+	go client.Run()
+	defer client.Stop()
+
+	var times []time.Time
+	for i := 0; i < 3; i++ {
+		select {
+		case connected := <-connects:
+			times = append(times, connected)
+		case <-time.After(time.Second):
+			require.FailNow(t, "Timed out waiting for the client to connect")
+		}
+	}
+
+	// The client waited 50ms, then 100ms, before reconnecting
+	require.True(t, times[1].Sub(times[0]) >= 50*time.Millisecond, "first delay: %s", times[1].Sub(times[0]))
+	require.True(t, times[2].Sub(times[1]) >= 100*time.Millisecond, "second delay: %s", times[2].Sub(times[1]))
+}
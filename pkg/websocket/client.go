@@ -2,12 +2,15 @@ package websocket
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	ws "github.com/gorilla/websocket"
@@ -32,19 +35,45 @@ type Config struct {
 
 	Log *log.Logger
 
+	// MaxReconnectAttempts is the number of consecutive failed attempts to
+	// connect after which the client gives up: Run returns and a
+	// *ReconnectError is sent on Errors. The client retries forever when
+	// it's 0.
+	MaxReconnectAttempts int
+
 	// Force use of unencrypted ws:// protocol instead of wss://
 	NoWSS bool
 
+	// OnConnect is called every time the client connects, including when it
+	// reconnects, with the number of attempts it took: 1 when the first
+	// attempt succeeded. It's called from the goroutine running Run, without
+	// holding any lock, so it can call back into the client.
+	OnConnect func(attempt int)
+
+	// OnDisconnect is called every time the connection is lost or reset,
+	// with the error the connection was lost with, nil when it was reset or
+	// the client stopped. willRetry is true unless the client stopped. It's
+	// called like OnConnect.
+	OnDisconnect func(err error, willRetry bool)
+
 	PingPeriod time.Duration
 
 	PongWait time.Duration
 
+	// ReconnectBackoff is the backoff between the attempts to reconnect
+	ReconnectBackoff ReconnectBackoff
+
 	// Interval at which the websocket client should reset the connection
 	ReconnectInterval time.Duration
 
 	WriteWait time.Duration
 
 	EventHandler EventHandler
+
+	// SerialEventHandler calls EventHandler from the goroutine reading the
+	// connection, in the order the messages are received, instead of from a
+	// new goroutine for each message. No message is read until it returns.
+	SerialEventHandler bool
 }
 
 // EventHandler handles an event.
@@ -63,6 +92,20 @@ func (f EventHandlerFunc) ProcessEvent(msg IncomingMessage) {
 	f(msg)
 }
 
+// ReconnectError is the error a Client gives up with when it couldn't connect
+// after Config.MaxReconnectAttempts attempts.
+type ReconnectError struct {
+	// Attempts is the number of failed attempts
+	Attempts int
+
+	// Err is the error of the last attempt
+	Err error
+}
+
+func (e *ReconnectError) Error() string {
+	return fmt.Sprintf("could not connect to Stripe after %d attempts: %v", e.Attempts, e.Err)
+}
+
 // Client is the client used to receive webhook requests from Stripe
 // and send back webhook responses from the local endpoint to Stripe.
 type Client struct {
@@ -80,12 +123,29 @@ type Client struct {
 
 	conn          *ws.Conn
 	done          chan struct{}
+	errors        chan error
 	isConnected   bool
+	lastPong      int64
 	notifyClose   chan error
 	send          chan *OutgoingMessage
 	stopReadPump  chan struct{}
 	stopWritePump chan struct{}
-	wg            *sync.WaitGroup
+
+	// attempts is the number of attempts to connect since the delay of
+	// cfg.ReconnectBackoff was last reset, connectedAt is when the client
+	// last connected, and random randomizes the delays. They're only used
+	// by the goroutine running Run.
+	attempts    int
+	connectedAt time.Time
+	random      *rand.Rand
+
+	// state is the State of the client, and stateChanges notifies its
+	// changes. The transitions are guarded by stateMu.
+	state        atomic.Value
+	stateChanges chan State
+	stateMu      sync.Mutex
+
+	wg *sync.WaitGroup
 }
 
 // Run starts listening for incoming webhook requests from Stripe.
@@ -96,41 +156,108 @@ func (c *Client) Run() {
 			"prefix": "websocket.client.Run",
 		}).Debug("Attempting to connect to Stripe")
 
-		for !c.connect() {
+		failures := 0
+		for {
+			err := c.connect()
+			if err == nil {
+				break
+			}
+
+			failures++
+			if c.cfg.MaxReconnectAttempts > 0 && failures >= c.cfg.MaxReconnectAttempts {
+				c.cfg.Log.WithFields(log.Fields{
+					"prefix":   "websocket.client.Run",
+					"attempts": failures,
+				}).Debug("Failed to connect to Stripe. Giving up")
+				c.setState(StateStopped)
+				c.errors <- &ReconnectError{Attempts: failures, Err: err}
+				return
+			}
+
 			c.cfg.Log.WithFields(log.Fields{
 				"prefix": "websocket.client.Run",
 			}).Debug("Failed to connect to Stripe. Retrying...")
-			time.Sleep(c.cfg.ConnectAttemptWait)
+			c.backOff()
 		}
+		c.setState(StateConnected)
+		c.onConnect(failures + 1)
+
 		select {
 		case <-c.done:
 			close(c.send)
 			close(c.stopReadPump)
 			close(c.stopWritePump)
+			c.onDisconnect(nil, false)
 			return
-		case <-c.notifyClose:
+		case err := <-c.notifyClose:
 			c.cfg.Log.WithFields(log.Fields{
 				"prefix": "websocket.client.Run",
 			}).Debug("Disconnected from Stripe")
+			c.setState(StateReconnecting)
 			close(c.stopReadPump)
 			close(c.stopWritePump)
 			c.wg.Wait()
+			c.onDisconnect(err, true)
+
+			// Back off if the connection didn't last, as the endpoint is
+			// likely flapping
+			if resetAfter := c.cfg.ReconnectBackoff.ResetAfter; resetAfter > 0 && time.Since(c.connectedAt) < resetAfter {
+				c.backOff()
+			} else {
+				c.attempts = 0
+			}
 		case <-time.After(c.cfg.ReconnectInterval):
 			c.cfg.Log.WithFields(log.Fields{
 				"prefix": "websocket.Client.Run",
 			}).Debug("Resetting the connection")
+			c.setState(StateReconnecting)
 			close(c.stopReadPump)
 			close(c.stopWritePump)
 			if c.conn != nil {
 				c.conn.Close() // #nosec G104
 			}
 			c.wg.Wait()
+			c.onDisconnect(nil, true)
+			c.attempts = 0
 		}
 	}
 }
 
+// onConnect calls cfg.OnConnect, if set
+func (c *Client) onConnect(attempt int) {
+	if c.cfg.OnConnect != nil {
+		c.cfg.OnConnect(attempt)
+	}
+}
+
+// onDisconnect calls cfg.OnDisconnect, if set
+func (c *Client) onDisconnect(err error, willRetry bool) {
+	if c.cfg.OnDisconnect != nil {
+		c.cfg.OnDisconnect(err, willRetry)
+	}
+}
+
+// LastPong returns when the last pong message was received from Stripe in
+// response to the pings of the client, or the zero time if none was. It's
+// safe to call from any goroutine.
+func (c *Client) LastPong() time.Time {
+	nanos := atomic.LoadInt64(&c.lastPong)
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// Errors returns the channel receiving the error the client gives up with,
+// when it couldn't connect after cfg.MaxReconnectAttempts attempts. Run has
+// returned by the time it's received, and SendMessage must not be called.
+func (c *Client) Errors() <-chan error {
+	return c.errors
+}
+
 // Stop stops listening for incoming webhook events.
 func (c *Client) Stop() {
+	c.setState(StateStopped)
 	close(c.done)
 }
 
@@ -140,8 +267,8 @@ func (c *Client) SendMessage(msg *OutgoingMessage) {
 }
 
 // connect makes a single attempt to connect to the websocket URL. It returns
-// the success of the attempt.
-func (c *Client) connect() bool {
+// the error of the attempt, if it failed.
+func (c *Client) connect() error {
 	header := http.Header{}
 	// Disable compression by requiring "identity"
 	header.Set("Accept-Encoding", "identity")
@@ -167,11 +294,12 @@ func (c *Client) connect() bool {
 			"prefix": "websocket.Client.connect",
 			"error":  err,
 		}).Debug("Websocket connection error")
-		return false
+		return err
 	}
 	defer resp.Body.Close()
 	c.changeConnection(conn)
 	c.isConnected = true
+	c.connectedAt = time.Now()
 
 	c.wg = &sync.WaitGroup{}
 	c.wg.Add(2)
@@ -181,7 +309,8 @@ func (c *Client) connect() bool {
 	c.cfg.Log.WithFields(log.Fields{
 		"prefix": "websocket.client.connect",
 	}).Debug("Connected!")
-	return true
+
+	return nil
 }
 
 // changeConnection takes a new connection and recreates the channels.
@@ -209,6 +338,7 @@ func (c *Client) readPump() {
 		c.cfg.Log.WithFields(log.Fields{
 			"prefix": "websocket.Client.readPump",
 		}).Debug("Received pong message")
+		atomic.StoreInt64(&c.lastPong, time.Now().UnixNano())
 		err := c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
 		if err != nil {
 			c.cfg.Log.Warn("SetReadDeadline error: ", err)
@@ -246,7 +376,11 @@ func (c *Client) readPump() {
 			continue
 		}
 
-		go c.cfg.EventHandler.ProcessEvent(msg)
+		if c.cfg.SerialEventHandler {
+			c.cfg.EventHandler.ProcessEvent(msg)
+		} else {
+			go c.cfg.EventHandler.ProcessEvent(msg)
+		}
 	}
 }
 
@@ -352,15 +486,22 @@ func NewClient(url string, webSocketID string, websocketAuthorizedFeature string
 	if cfg.EventHandler == nil {
 		cfg.EventHandler = nullEventHandler
 	}
+	cfg.ReconnectBackoff = cfg.ReconnectBackoff.withDefaults(cfg.ConnectAttemptWait)
 
-	return &Client{
+	c := &Client{
 		URL:                        url,
 		WebSocketID:                webSocketID,
 		WebSocketAuthorizedFeature: websocketAuthorizedFeature,
 		cfg:                        cfg,
 		done:                       make(chan struct{}),
+		errors:                     make(chan error, 1),
 		send:                       make(chan *OutgoingMessage),
+		random:                     rand.New(rand.NewSource(time.Now().UnixNano())), // #nosec G404
+		stateChanges:               make(chan State, 1),
 	}
+	c.state.Store(StateConnecting)
+
+	return c
 }
 
 //
@@ -370,6 +511,8 @@ func NewClient(url string, webSocketID string, websocketAuthorizedFeature string
 const (
 	defaultConnectAttemptWait = 10 * time.Second
 
+	defaultMaxReconnectDelay = 60 * time.Second
+
 	defaultPongWait = 10 * time.Second
 
 	defaultReconnectInterval = 60 * time.Second
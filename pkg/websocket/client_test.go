@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -143,3 +144,241 @@ func TestClientRequestLogEventHandler(t *testing.T) {
 	require.Equal(t, "request_log_event", rcvMsg.Type)
 	require.Equal(t, "{}", rcvMsg.EventPayload)
 }
+
+func TestClientOnConnect(t *testing.T) {
+	upgrader := ws.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.Nil(t, err)
+
+		// Closing the connection right away makes the client reconnect
+		c.Close()
+	}))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	connects := make(chan int, 10)
+	disconnects := make(chan error, 10)
+	client := NewClient(
+		url,
+		"websocket-random-id",
+		"request-log-payloads",
+		&Config{
+			EventHandler: EventHandlerFunc(func(msg IncomingMessage) {}),
+			OnConnect: func(attempt int) {
+				select {
+				case connects <- attempt:
+				default:
+				}
+			},
+			OnDisconnect: func(err error, willRetry bool) {
+				if !willRetry {
+					return
+				}
+				select {
+				case disconnects <- err:
+				default:
+				}
+			},
+		},
+	)
+	go client.Run()
+	defer client.Stop()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case attempt := <-connects:
+			require.Equal(t, 1, attempt)
+		case <-time.After(500 * time.Millisecond):
+			require.FailNow(t, "Timed out waiting for the client to connect")
+		}
+	}
+
+	// The client lost the connection before reconnecting
+	select {
+	case err := <-disconnects:
+		require.Error(t, err)
+	case <-time.After(500 * time.Millisecond):
+		require.FailNow(t, "Timed out waiting for the client to disconnect")
+	}
+}
+
+func TestClientOnDisconnectStopped(t *testing.T) {
+	upgrader := ws.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.Nil(t, err)
+		defer c.Close()
+
+		// Keep the connection open until the client stops
+		c.ReadMessage() // #nosec G104
+	}))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	connected := make(chan struct{})
+	type disconnect struct {
+		err       error
+		willRetry bool
+	}
+	disconnects := make(chan disconnect, 1)
+	client := NewClient(
+		url,
+		"websocket-random-id",
+		"request-log-payloads",
+		&Config{
+			EventHandler: EventHandlerFunc(func(msg IncomingMessage) {}),
+			OnConnect:    func(attempt int) { close(connected) },
+			OnDisconnect: func(err error, willRetry bool) {
+				disconnects <- disconnect{err, willRetry}
+			},
+		},
+	)
+	go client.Run()
+
+	select {
+	case <-connected:
+	case <-time.After(500 * time.Millisecond):
+		require.FailNow(t, "Timed out waiting for the client to connect")
+	}
+	client.Stop()
+
+	select {
+	case d := <-disconnects:
+		require.NoError(t, d.err)
+		require.False(t, d.willRetry)
+	case <-time.After(500 * time.Millisecond):
+		require.FailNow(t, "Timed out waiting for the client to disconnect")
+	}
+}
+
+func TestClientSerialEventHandler(t *testing.T) {
+	const count = 50
+
+	upgrader := ws.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.Nil(t, err)
+		defer c.Close()
+
+		for i := 0; i < count; i++ {
+			msg, err := json.Marshal(RequestLogEvent{
+				EventPayload: "{}",
+				RequestLogID: fmt.Sprintf("resp_%d", i),
+				Type:         "request_log_event",
+			})
+			require.Nil(t, err)
+			require.Nil(t, c.WriteMessage(ws.TextMessage, msg))
+		}
+
+		// Keep the connection open until the client stops
+		c.ReadMessage() // #nosec G104
+	}))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	received := make(chan string, count)
+	client := NewClient(
+		url,
+		"websocket-random-id",
+		"request-log-payloads",
+		&Config{
+			EventHandler: EventHandlerFunc(func(msg IncomingMessage) {
+				received <- msg.RequestLogEvent.RequestLogID
+			}),
+			SerialEventHandler: true,
+		},
+	)
+	go client.Run()
+	defer client.Stop()
+
+	for i := 0; i < count; i++ {
+		select {
+		case id := <-received:
+			require.Equal(t, fmt.Sprintf("resp_%d", i), id)
+		case <-time.After(500 * time.Millisecond):
+			require.FailNow(t, "Timed out waiting for the messages")
+		}
+	}
+}
+
+func TestClientLastPong(t *testing.T) {
+	upgrader := ws.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.Nil(t, err)
+		defer c.Close()
+
+		// Reading the connection answers the pings with pongs
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	client := NewClient(
+		url,
+		"websocket-random-id",
+		"request-log-payloads",
+		&Config{
+			EventHandler: EventHandlerFunc(func(msg IncomingMessage) {}),
+			PingPeriod:   10 * time.Millisecond,
+		},
+	)
+	require.True(t, client.LastPong().IsZero())
+
+	start := time.Now()
+	go client.Run()
+	defer client.Stop()
+
+	require.Eventually(t, func() bool {
+		return !client.LastPong().Before(start)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestClientMaxReconnectAttempts(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	client := NewClient(
+		url,
+		"websocket-random-id",
+		"request-log-payloads",
+		&Config{
+			ConnectAttemptWait:   time.Millisecond,
+			EventHandler:         EventHandlerFunc(func(msg IncomingMessage) {}),
+			MaxReconnectAttempts: 3,
+		},
+	)
+
+	returned := make(chan struct{})
+	go func() {
+		client.Run()
+		close(returned)
+	}()
+
+	select {
+	case err := <-client.Errors():
+		require.Equal(t, 3, err.(*ReconnectError).Attempts)
+		require.Equal(t, ws.ErrBadHandshake, err.(*ReconnectError).Err)
+		require.Equal(t, "could not connect to Stripe after 3 attempts: websocket: bad handshake", err.Error())
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for the client to give up")
+	}
+
+	<-returned
+	require.Equal(t, 3, attempts)
+}
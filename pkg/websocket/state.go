@@ -0,0 +1,65 @@
+package websocket
+
+// State is the state of the connection of a Client
+type State string
+
+const (
+	// StateConnecting is the state of a client until it connects for the
+	// first time
+	StateConnecting State = "connecting"
+
+	// StateConnected is the state of a client while it's connected
+	StateConnected State = "connected"
+
+	// StateReconnecting is the state of a client once its connection was
+	// lost or reset, until it connects again
+	StateReconnecting State = "reconnecting"
+
+	// StateStopped is the state of a client once it's stopped, or gave up
+	// connecting after Config.MaxReconnectAttempts attempts. It's final.
+	StateStopped State = "stopped"
+)
+
+// State returns the state of the connection of the client. It's safe to call
+// from any goroutine.
+func (c *Client) State() State {
+	state, ok := c.state.Load().(State)
+	if !ok {
+		return StateConnecting
+	}
+	return state
+}
+
+// StateChanges returns a channel receiving the states of the client as they
+// change. It's never blocked on: the changes not received yet are coalesced,
+// so that the latest state is the one received next.
+func (c *Client) StateChanges() <-chan State {
+	return c.stateChanges
+}
+
+// setState transitions the client to the state and notifies the change,
+// unless the client is already stopped. The transitions are serialized so
+// that the changes are notified in order.
+func (c *Client) setState(state State) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if previous := c.State(); previous == state || previous == StateStopped {
+		return
+	}
+	c.state.Store(state)
+
+	// Replace the change not received yet, if any, with this one
+	for {
+		select {
+		case c.stateChanges <- state:
+			return
+		default:
+		}
+
+		select {
+		case <-c.stateChanges:
+		default:
+		}
+	}
+}
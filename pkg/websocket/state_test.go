@@ -0,0 +1,164 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientStateLifecycle(t *testing.T) {
+	drop := make(chan struct{})
+	reconnect := make(chan struct{})
+	var connections int32
+
+	upgrader := ws.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first := atomic.AddInt32(&connections, 1) == 1
+		if !first {
+			// Refuse the attempts to reconnect until allowed to
+			select {
+			case <-reconnect:
+			default:
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.Nil(t, err)
+		defer c.Close()
+
+		if first {
+			<-drop
+			return
+		}
+
+		// Keep the connection open until the client stops
+		c.ReadMessage() // #nosec G104
+	}))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	client := NewClient(
+		url,
+		"websocket-random-id",
+		"request-log-payloads",
+		&Config{
+			ConnectAttemptWait: 5 * time.Millisecond,
+			EventHandler:       EventHandlerFunc(func(msg IncomingMessage) {}),
+		},
+	)
+	require.Equal(t, StateConnecting, client.State())
+
+	go client.Run()
+	requireState(t, client, StateConnected)
+
+	close(drop)
+	requireState(t, client, StateReconnecting)
+
+	// The client keeps reconnecting while the attempts fail
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, StateReconnecting, client.State())
+
+	close(reconnect)
+	requireState(t, client, StateConnected)
+
+	client.Stop()
+	require.Equal(t, StateStopped, client.State())
+
+	// The changes weren't received, so they were coalesced into the last
+	// one instead of blocking the client
+	select {
+	case state := <-client.StateChanges():
+		require.Equal(t, StateStopped, state)
+	default:
+		require.FailNow(t, "No state change to receive")
+	}
+	select {
+	case state := <-client.StateChanges():
+		require.FailNow(t, "Unexpected state change", "%s", state)
+	default:
+	}
+}
+
+func TestClientStateChanges(t *testing.T) {
+	upgrader := ws.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		require.Nil(t, err)
+		defer c.Close()
+
+		// Keep the connection open until the client stops
+		c.ReadMessage() // #nosec G104
+	}))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	client := NewClient(
+		url,
+		"websocket-random-id",
+		"request-log-payloads",
+		&Config{
+			EventHandler: EventHandlerFunc(func(msg IncomingMessage) {}),
+		},
+	)
+	go client.Run()
+
+	require.Equal(t, StateConnected, receiveState(t, client))
+
+	client.Stop()
+	require.Equal(t, StateStopped, receiveState(t, client))
+}
+
+func TestClientStateGaveUp(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	client := NewClient(
+		url,
+		"websocket-random-id",
+		"request-log-payloads",
+		&Config{
+			ConnectAttemptWait:   time.Millisecond,
+			EventHandler:         EventHandlerFunc(func(msg IncomingMessage) {}),
+			MaxReconnectAttempts: 2,
+		},
+	)
+	client.Run()
+
+	require.Equal(t, StateStopped, client.State())
+	require.Equal(t, StateStopped, receiveState(t, client))
+}
+
+// requireState waits for the client to be in the state
+func requireState(t *testing.T, client *Client, state State) {
+	deadline := time.Now().Add(time.Second)
+	for client.State() != state {
+		if time.Now().After(deadline) {
+			require.FailNow(t, "Timed out waiting for the state", "want %s, got %s", state, client.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func receiveState(t *testing.T, client *Client) State {
+	select {
+	case state := <-client.StateChanges():
+		return state
+	case <-time.After(time.Second):
+		require.FailNow(t, "Timed out waiting for a state change")
+		return ""
+	}
+}